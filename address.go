@@ -7,11 +7,14 @@ package bmutil
 
 import (
 	"bytes"
+	"crypto/subtle"
+	"encoding/binary"
 	"errors"
+	"sync/atomic"
 
+	"github.com/DanielKrawisz/bmutil/base58"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcutil/base58"
 )
 
 const (
@@ -19,6 +22,11 @@ const (
 	// create new addresses.
 	DefaultAddressVersion = 4
 
+	// ExperimentalAddressVersion is the proposed next address version. It is
+	// encoded like version 4 but has its own tag. Nothing on the network
+	// understands it yet, so it should only be used for experiments.
+	ExperimentalAddressVersion = 5
+
 	// DefaultStream is the only stream currently in use on the Bitmessage
 	// network, which is 1.
 	DefaultStream = 1
@@ -26,8 +34,9 @@ const (
 
 var (
 	// ErrChecksumMismatch describes an error where decoding failed due
-	// to a bad checksum.
-	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// to a bad checksum. SuggestAddressCorrection gives the details for
+	// an address.
+	ErrChecksumMismatch = base58.ErrChecksum
 
 	// ErrUnknownAddressType describes an error where an address cannot be
 	// decoded as a specific address type due to the string encoding
@@ -44,23 +53,83 @@ var (
 )
 
 // Address represents a Bitmessage address.
+//
+// The addresses returned by this package implement encoding.TextMarshaler
+// and json.Marshaler with their string forms, so an Address in a struct is
+// written by encoding/json as a string. Since Address is an interface,
+// encoding/json can only decode into a field which already holds an address
+// of a compatible version. Otherwise, decode a string and call DecodeAddress.
 type Address interface {
 	Version() uint64
 	Stream() uint64
 	RipeHash() *hash.Ripe
 	String() string
+
+	// Equal returns whether the address has the same version, stream and
+	// ripe hash as another.
+	Equal(Address) bool
+
+	// Compare orders addresses by version, then stream, then ripe hash. It
+	// returns -1, 0 or 1 as the address comes before, is equal to or comes
+	// after the other.
+	Compare(Address) int
+
+	// Fingerprint returns a short digest of the address for people to
+	// compare by eye or read out to each other. See AddressFingerprint.
+	Fingerprint() string
+
+	// Bytes returns the address in binary form. See AddressBytes.
+	Bytes() []byte
+}
+
+// CompareAddresses orders two addresses in the same way as Address.Compare.
+// It can be used by implementations of Address and with sort.
+func CompareAddresses(a, b Address) int {
+	switch {
+	case a.Version() < b.Version():
+		return -1
+	case a.Version() > b.Version():
+		return 1
+	case a.Stream() < b.Stream():
+		return -1
+	case a.Stream() > b.Stream():
+		return 1
+	}
+	return bytes.Compare(a.RipeHash()[:], b.RipeHash()[:])
+}
+
+// ConstantTimeAddressEqual is like Equal, but takes the same time whether
+// or not the addresses are equal and wherever they differ. It should be used
+// to check an address that was derived from secret keys against one that was
+// given.
+func ConstantTimeAddressEqual(a, b Address) bool {
+	return subtle.ConstantTimeCompare(fixedAddressBytes(a), fixedAddressBytes(b)) == 1
+}
+
+// fixedAddressBytes returns the version, stream and ripe hash of addr as a
+// fixed length string of bytes.
+func fixedAddressBytes(addr Address) []byte {
+	b := make([]byte, 16+hash.RipeSize)
+	binary.BigEndian.PutUint64(b, addr.Version())
+	binary.BigEndian.PutUint64(b[8:], addr.Stream())
+	copy(b[16:], addr.RipeHash()[:])
+	return b
 }
 
 // addressV4 represents a version 4  Bitmessage address.
 type addressV4 struct {
 	stream uint64
 	ripe   hash.Ripe
+	tags   tagCache
 }
 
 // NewAddress creates a new address. Currently supported parameters
-// must be provided for the object to be created. That means version 4 only
-// and stream 1.
+// must be provided for the object to be created. That means version 4, or
+// ExperimentalAddressVersion, and stream 1.
 func NewAddress(version, stream uint64, ripe *hash.Ripe) (Address, error) {
+	if version == ExperimentalAddressVersion {
+		return NewAddressV5(stream, ripe)
+	}
 	if version > DefaultAddressVersion {
 		return nil, ErrUnknownAddressType
 	}
@@ -88,25 +157,89 @@ func (addr *addressV4) RipeHash() *hash.Ripe {
 	return &addr.ripe
 }
 
+func (addr *addressV4) Equal(other Address) bool {
+	return CompareAddresses(addr, other) == 0
+}
+
+func (addr *addressV4) Compare(other Address) int {
+	return CompareAddresses(addr, other)
+}
+
 // String outputs the address to a string that begins with BM-.
 // Output: [Varint(addressVersion) Varint(stream) ripe checksum] where the
 // Varints are serialized. Then this byte array is base58 encoded to produce our
 // needed address.
 func (addr *addressV4) String() string {
+	return EncodeAddress(addr.Version(), addr.stream, &addr.ripe)
+}
 
-	ripe := bytes.TrimLeft(addr.ripe[:], "\x00")
+// addressV5 represents a version 5 Bitmessage address.
+type addressV5 struct {
+	stream uint64
+	ripe   hash.Ripe
+	tags   tagCache
+}
 
-	var binaryData bytes.Buffer
-	WriteVarInt(&binaryData, addr.Version())
-	WriteVarInt(&binaryData, addr.stream)
-	binaryData.Write(ripe)
+// NewAddressV5 creates a new address of ExperimentalAddressVersion. As with
+// NewAddress, only stream 1 is allowed.
+func NewAddressV5(stream uint64, ripe *hash.Ripe) (Address, error) {
+	if stream != DefaultStream {
+		return nil, ErrInvalidStream
+	}
+	return &addressV5{
+		stream: stream,
+		ripe:   *ripe,
+	}, nil
+}
+
+func (addr *addressV5) Version() uint64 {
+	return ExperimentalAddressVersion
+}
+
+func (addr *addressV5) Stream() uint64 {
+	return addr.stream
+}
+
+func (addr *addressV5) RipeHash() *hash.Ripe {
+	return &addr.ripe
+}
+
+func (addr *addressV5) Equal(other Address) bool {
+	return CompareAddresses(addr, other) == 0
+}
+
+func (addr *addressV5) Compare(other Address) int {
+	return CompareAddresses(addr, other)
+}
+
+// String outputs the address to a string that begins with BM-, in the same
+// way as for version 4.
+func (addr *addressV5) String() string {
+	return EncodeAddress(addr.Version(), addr.stream, &addr.ripe)
+}
+
+// calcTag is the second half of the SHA-512 hash of the double SHA-512 hash
+// prefixed with v5TagLabel, so that it is not taken from the same hash as
+// the broadcast decryption key.
+func (addr *addressV5) calcTag() *hash.Sha {
+	var a hash.Sha
+	copy(a[:], hash.Sha512(append(v5TagLabel, DoubleSha512(addr)...))[32:])
+	return &a
+}
 
-	// calc checksum from 2 rounds of SHA512
-	checksum := hash.DoubleSha512(binaryData.Bytes())[:4]
+// v5TagLabel is prefixed to the hash from which the tag of a v5 address is
+// taken.
+var v5TagLabel = []byte("bitmessage v5 tag")
 
-	totalBin := append(binaryData.Bytes(), checksum...)
+// encodeAddress returns the string form of an address: ripe is given with
+// any null bytes that are not to be encoded already removed.
+func encodeAddress(version, stream uint64, ripe []byte) string {
+	var binaryData bytes.Buffer
+	WriteVarInt(&binaryData, version)
+	WriteVarInt(&binaryData, stream)
+	binaryData.Write(ripe)
 
-	return "BM-" + string(base58.Encode(totalBin))
+	return "BM-" + base58.EncodeCheck(binaryData.Bytes())
 }
 
 // depricatedAddress represents a version 2 or 3 Bitmessage address.
@@ -114,6 +247,7 @@ type depricatedAddress struct {
 	version uint64
 	stream  uint64
 	ripe    hash.Ripe
+	tags    tagCache
 }
 
 // NewDepricatedAddress creates a new depricated address.
@@ -140,35 +274,30 @@ func (addr *depricatedAddress) RipeHash() *hash.Ripe {
 	return &addr.ripe
 }
 
+func (addr *depricatedAddress) Equal(other Address) bool {
+	return CompareAddresses(addr, other) == 0
+}
+
+func (addr *depricatedAddress) Compare(other Address) int {
+	return CompareAddresses(addr, other)
+}
+
 // String outputs the address to a string that begins with BM-.
 // Output: [Varint(addressVersion) Varint(stream) ripe checksum] where the
 // Varints are serialized. Then this byte array is base58 encoded to produce our
 // needed address.
 func (addr *depricatedAddress) String() string {
-	ripe := addr.ripe[:]
-
-	if ripe[0] == 0x00 {
-		ripe = ripe[1:] // exclude first byte
-		if ripe[0] == 0x00 {
-			ripe = ripe[1:] // exclude second byte as well
-		}
-	}
-
-	var binaryData bytes.Buffer
-	WriteVarInt(&binaryData, addr.version)
-	WriteVarInt(&binaryData, addr.stream)
-	binaryData.Write(ripe)
-
-	// calc checksum from 2 rounds of SHA512
-	checksum := hash.DoubleSha512(binaryData.Bytes())[:4]
-
-	totalBin := append(binaryData.Bytes(), checksum...)
-
-	return "BM-" + string(base58.Encode(totalBin))
+	return EncodeAddress(addr.version, addr.stream, &addr.ripe)
 }
 
 // DecodeAddress decodes the Bitmessage address into an Address object.
 func DecodeAddress(addr string) (Address, error) {
+	return decodeAddress(addr, base58.Verify)
+}
+
+// decodeAddress is DecodeAddress with the function that checks the checksum
+// given, so that a batch can share the hash state.
+func decodeAddress(addr string, verify func([]byte) ([]byte, error)) (Address, error) {
 	if len(addr) >= 3 && addr[:3] == "BM-" { // Clients should accept addresses without BM-
 		addr = addr[3:]
 	}
@@ -178,10 +307,7 @@ func DecodeAddress(addr string) (Address, error) {
 		return nil, ErrUnknownAddressType
 	}
 
-	hashData := data[:len(data)-4]
-	checksum := data[len(data)-4:]
-
-	if !bytes.Equal(checksum, hash.DoubleSha512(hashData)[0:4]) {
+	if _, err := verify(data); err != nil {
 		return nil, ErrChecksumMismatch
 	}
 
@@ -201,6 +327,14 @@ func DecodeAddress(addr string) (Address, error) {
 	ripe := make([]byte, buf.Len()-4) // exclude bytes already read and checksum
 	buf.Read(ripe)                    // this can never cause an error
 
+	return newAddressFromEncodedRipe(version, stream, ripe)
+}
+
+// newAddressFromEncodedRipe returns the address with the given version and
+// stream, and the ripe hash as it is written in the string form of the
+// address, with null bytes removed from its front. It checks that the ripe
+// hash has been encoded properly for the version.
+func newAddressFromEncodedRipe(version, stream uint64, ripe []byte) (Address, error) {
 	lenRipe := len(ripe)
 
 	switch version {
@@ -219,7 +353,7 @@ func DecodeAddress(addr string) (Address, error) {
 		return a, nil
 	case 4:
 		// encoded ripe data MUST have null bytes removed from front
-		if ripe[0] == 0x00 {
+		if lenRipe > 0 && ripe[0] == 0x00 {
 			return nil, errors.New("version 4, ripe data has null bytes in" +
 				" the beginning, not properly encoded")
 		}
@@ -232,7 +366,31 @@ func DecodeAddress(addr string) (Address, error) {
 		// prepend null bytes to make sure that the total ripe length is 20
 		copy(a.ripe[:], append(make([]byte, 20-lenRipe), ripe...))
 		return a, nil
+	case ExperimentalAddressVersion:
+		// same rules as version 4
+		if lenRipe > 0 && ripe[0] == 0x00 {
+			return nil, errors.New("version 5, ripe data has null bytes in" +
+				" the beginning, not properly encoded")
+		}
+		if lenRipe > 19 || lenRipe < 4 { // improper size
+			return nil, errors.New("version 5, the ripe length is invalid")
+		}
+		a := &addressV5{
+			stream: stream,
+		}
+		copy(a.ripe[:], append(make([]byte, 20-lenRipe), ripe...))
+		return a, nil
 	default:
+		if f := addressFormat(version); f != nil {
+			a, err := f.Decode(stream, ripe)
+			if err != nil {
+				return nil, err
+			}
+			if a.Version() != version {
+				return nil, ErrUnknownAddressType
+			}
+			return a, nil
+		}
 		return nil, ErrUnknownAddressType
 	}
 }
@@ -240,12 +398,7 @@ func DecodeAddress(addr string) (Address, error) {
 // Sha512 calculates the sha512 sum of the address, the first half of
 // which is used as private encryption key for v2 and v3 broadcasts.
 func Sha512(addr Address) []byte {
-	var b bytes.Buffer
-	WriteVarInt(&b, addr.Version())
-	WriteVarInt(&b, addr.Stream())
-	b.Write(addr.RipeHash()[:])
-
-	return hash.Sha512(b.Bytes())
+	return hash.Sha512(AddressBytes(addr))
 }
 
 // DoubleSha512 calculates the double sha512 sum of the address, the first
@@ -257,13 +410,65 @@ func DoubleSha512(addr Address) []byte {
 
 // Tag calculates tag corresponding to the Bitmessage address. According to
 // protocol specifications, it is the second half of the double SHA-512 hash
-// of version, stream and ripe concatenated together.
+// of version, stream and ripe concatenated together. Version 5 addresses
+// have their own rule, and versions with a registered AddressFormat may too.
+//
+// The addresses returned by this package remember their tags, so the hash
+// is only calculated the first time.
 func Tag(addr Address) *hash.Sha {
+	var tag *hash.Sha
+	switch a := addr.(type) {
+	case *addressV4:
+		tag = a.tags.get(func() *hash.Sha { return calcTag(a) })
+	case *addressV5:
+		tag = a.tags.get(a.calcTag)
+	case *depricatedAddress:
+		tag = a.tags.get(func() *hash.Sha { return calcTag(a) })
+	default:
+		if f := addressFormat(addr.Version()); f != nil && f.Tag != nil {
+			return f.Tag(addr)
+		}
+		return calcTag(addr)
+	}
+
+	// Copy the tag so that the cache can't be changed through it.
+	t := *tag
+	return &t
+}
+
+// calcTag calculates the tag of an address of any version but 5.
+func calcTag(addr Address) *hash.Sha {
 	var a hash.Sha
 	copy(a[:], DoubleSha512(addr)[32:])
 	return &a
 }
 
+// tagCache holds the tag of an address once it has been calculated. It is
+// safe for concurrent use; two goroutines may both calculate the tag, but
+// they get the same result.
+type tagCache struct {
+	v atomic.Value // *hash.Sha
+}
+
+// get returns the tag, calculating it with calc if it is not known yet.
+func (c *tagCache) get(calc func() *hash.Sha) *hash.Sha {
+	if tag, ok := c.v.Load().(*hash.Sha); ok {
+		return tag
+	}
+	tag := calc()
+	c.v.Store(tag)
+	return tag
+}
+
+// PrecomputeTags calculates the tags of the given addresses ahead of time,
+// so that the first broadcasts to be checked against them, as by a keyring
+// with many subscriptions, aren't slowed down.
+func PrecomputeTags(addrs []Address) {
+	for _, addr := range addrs {
+		Tag(addr)
+	}
+}
+
 // V4BroadcastDecryptionKey generates the decryption private key used to decrypt v4
 // broadcasts originating from the address. They are encrypted with the public
 // key corresponding to this private key as the target key. It is the first half
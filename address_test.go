@@ -6,8 +6,15 @@
 package bmutil
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcd/btcec"
 )
 
 type addressTestPair struct {
@@ -98,6 +105,81 @@ func TestAddressErrors(t *testing.T) {
 	}
 }
 
+func TestDecodeAddresses(t *testing.T) {
+	var strs []string
+	for len(strs) < 2000 {
+		for _, pair := range addressTests {
+			strs = append(strs, pair.addrString)
+		}
+		strs = append(strs, "BM-2DBXxtaBSV37DsHjN978mRiMbX5rdKNvJ2")
+	}
+
+	addrs, errs := DecodeAddresses(strs)
+	if len(addrs) != len(strs) {
+		t.Fatalf("got %d addresses want %d", len(addrs), len(strs))
+	}
+	next := 0
+	for i, s := range strs {
+		want, wantErr := DecodeAddress(s)
+		if wantErr != nil {
+			if next >= len(errs) || errs[next].Index != i || errs[next].Address != s ||
+				errs[next].Err != ErrChecksumMismatch {
+				t.Fatalf("#%d: missing error for %s", i, s)
+			}
+			if addrs[i] != nil {
+				t.Errorf("#%d: got address %s for an error", i, addrs[i])
+			}
+			next++
+			continue
+		}
+		if !reflect.DeepEqual(addrs[i], want) {
+			t.Errorf("#%d: got %v want %v", i, addrs[i], want)
+		}
+	}
+	if next != len(errs) {
+		t.Errorf("got %d errors want %d", len(errs), next)
+	}
+
+	if addrs, errs = DecodeAddresses(nil); len(addrs) != 0 || len(errs) != 0 {
+		t.Errorf("empty batch got %v, %v", addrs, errs)
+	}
+}
+
+func TestIsValidAddressString(t *testing.T) {
+	strs := []string{"", "BM-", "1", "1111111111111111111111111111111111",
+		"BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ\n", "bm-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
+		"BM-" + strings.Repeat("z", 100)}
+	for _, pair := range addressTests {
+		strs = append(strs, pair.addrString, pair.addrString[3:])
+	}
+	strs = append(strs,
+		"BM-4biUVd9M1g46fES4Ggz8ktmnfoJndYA",     // v3, ripe too short
+		"BM-2cShcu4VoVChUUc9GQnFrJtRe9NdmEoBUq",  // v4, null bytes in front
+		"BM-3xSpfkKJqnFf",                        // v4, ripe too short
+		"BM-9tSxgK6q4X6bNdEbyMRgGBcfnFC3MoW3Bp5") // unknown version
+
+	// Every substitution of one character in the address, and every
+	// truncation.
+	const addr = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ"
+	for i := 3; i < len(addr); i++ {
+		for _, c := range "1zV0" {
+			strs = append(strs, addr[:i]+string(c)+addr[i+1:])
+		}
+		strs = append(strs, addr[:i])
+	}
+
+	for _, s := range strs {
+		_, err := DecodeAddress(s)
+		if got := IsValidAddressString(s); got != (err == nil) {
+			t.Errorf("IsValidAddressString(%q) got %v, DecodeAddress got error %v", s, got, err)
+		}
+	}
+
+	if n := testing.AllocsPerRun(100, func() { IsValidAddressString(addr) }); n != 0 {
+		t.Errorf("IsValidAddressString made %v allocations", n)
+	}
+}
+
 // Test Tag, PrivateKey and PrivateKeySingleHash
 func TestCalcHash(t *testing.T) {
 	for _, pair := range addressTests {
@@ -111,3 +193,358 @@ func TestCalcHash(t *testing.T) {
 		Tag(addr)
 	}
 }
+
+func TestTagCache(t *testing.T) {
+	var addrs []Address
+	for _, pair := range addressTests {
+		addr, _ := DecodeAddress(pair.addrString)
+		addrs = append(addrs, addr)
+	}
+	PrecomputeTags(addrs)
+
+	for i, addr := range addrs {
+		var want hash.Sha
+		copy(want[:], DoubleSha512(addr)[32:])
+		tag := Tag(addr)
+		if *tag != want {
+			t.Errorf("#%d got tag %x want %x", i, tag[:], want[:])
+		}
+
+		// Changing a returned tag doesn't change the cached one.
+		tag[0]++
+		if *Tag(addr) != want {
+			t.Errorf("#%d cached tag was changed", i)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, pair := range addressTests {
+		// Not pair.address, which would keep its cached tag and no longer
+		// be DeepEqual to a decoded address.
+		addr, _ := DecodeAddress(pair.addrString)
+		fp := addr.Fingerprint()
+		if want := hex.EncodeToString(Tag(addr)[:FingerprintSize]); strings.Replace(fp, " ", "", -1) != want {
+			t.Errorf("for %s got fingerprint %s want %s", pair.addrString, fp, want)
+		}
+		if len(fp) != 19 {
+			t.Errorf("for %s got fingerprint %q", pair.addrString, fp)
+		}
+		if seen[fp] {
+			t.Errorf("for %s fingerprint %s is not unique", pair.addrString, fp)
+		}
+		seen[fp] = true
+
+		for _, s := range []string{fp, strings.ToUpper(fp),
+			strings.Replace(fp, " ", "-", -1), strings.Replace(fp, " ", "", -1)} {
+			if !MatchFingerprint(addr, s) {
+				t.Errorf("for %s fingerprint %q did not match", pair.addrString, s)
+			}
+		}
+		if MatchFingerprint(addr, fp[:len(fp)-1]) || MatchFingerprint(addr, "") {
+			t.Errorf("for %s short fingerprint matched", pair.addrString)
+		}
+	}
+}
+
+func TestAddressV5(t *testing.T) {
+	v4, _ := DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+
+	addr, err := NewAddress(ExperimentalAddressVersion, DefaultStream, v4.RipeHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Version() != ExperimentalAddressVersion {
+		t.Errorf("got version %d", addr.Version())
+	}
+
+	decoded, err := DecodeAddress(addr.String())
+	if err != nil {
+		t.Fatalf("DecodeAddress(%s) error %v", addr, err)
+	}
+	if !reflect.DeepEqual(decoded, addr) {
+		t.Errorf("DecodeAddress(%s) got %v", addr, decoded)
+	}
+	if addr.String() == v4.String() {
+		t.Error("v4 and v5 addresses encoded the same")
+	}
+
+	if *Tag(addr) == *Tag(v4) {
+		t.Error("v5 tag is the same as the v4 tag")
+	}
+	expected := hash.Sha512(append([]byte("bitmessage v5 tag"), DoubleSha512(addr)...))[32:]
+	if !bytes.Equal(Tag(addr)[:], expected) {
+		t.Errorf("got tag %x, want %x", Tag(addr)[:], expected)
+	}
+
+	if _, err = NewAddressV5(2, v4.RipeHash()); err != ErrInvalidStream {
+		t.Errorf("stream 2: got %v", err)
+	}
+	if _, err = NewAddress(6, DefaultStream, v4.RipeHash()); err != ErrUnknownAddressType {
+		t.Errorf("version 6: got %v", err)
+	}
+}
+
+// testAddressV6 is a made up address version to register.
+type testAddressV6 struct {
+	stream uint64
+	ripe   hash.Ripe
+}
+
+func (a *testAddressV6) Version() uint64           { return 6 }
+func (a *testAddressV6) Stream() uint64            { return a.stream }
+func (a *testAddressV6) RipeHash() *hash.Ripe      { return &a.ripe }
+func (a *testAddressV6) Equal(other Address) bool  { return CompareAddresses(a, other) == 0 }
+func (a *testAddressV6) Compare(other Address) int { return CompareAddresses(a, other) }
+func (a *testAddressV6) Fingerprint() string       { return AddressFingerprint(a) }
+func (a *testAddressV6) Bytes() []byte             { return AddressBytes(a) }
+func (a *testAddressV6) String() string            { return EncodeAddress(6, a.stream, &a.ripe) }
+
+func TestRegisterAddressFormat(t *testing.T) {
+	v4, _ := DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	v6 := &testAddressV6{stream: 1, ripe: *v4.RipeHash()}
+	str := v6.String()
+
+	if _, err := DecodeAddress(str); err != ErrUnknownAddressType {
+		t.Fatalf("unregistered version got %v", err)
+	}
+	if err := RegisterAddressFormat(4, &AddressFormat{}); err != ErrBuiltInAddressVersion {
+		t.Errorf("registering version 4 got %v", err)
+	}
+
+	var tag hash.Sha
+	tag[0] = 6
+	key := V4BroadcastDecryptionKey(v4)
+	err := RegisterAddressFormat(6, &AddressFormat{
+		Decode: func(stream uint64, ripe []byte) (Address, error) {
+			a := &testAddressV6{stream: stream}
+			copy(a.ripe[20-len(ripe):], ripe)
+			return a, nil
+		},
+		Encode:       func(ripe *hash.Ripe) []byte { return ripe[:] },
+		Tag:          func(Address) *hash.Sha { return &tag },
+		BroadcastKey: func(Address) *btcec.PrivateKey { return key },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer RegisterAddressFormat(6, nil)
+
+	addr, err := DecodeAddress(str)
+	if err != nil {
+		t.Fatalf("DecodeAddress(%s) got error %v", str, err)
+	}
+	if !reflect.DeepEqual(addr, v6) {
+		t.Errorf("DecodeAddress(%s) got %v", str, addr)
+	}
+	if *Tag(addr) != tag {
+		t.Errorf("got tag %x", Tag(addr)[:])
+	}
+	if BroadcastDecryptionKey(addr) != key {
+		t.Error("registered broadcast key was not used")
+	}
+
+	// The string is now written with the registered encoding, which keeps
+	// the null bytes of the ripe hash, and it is read back the same.
+	registered := v6.String()
+	if registered == str {
+		t.Errorf("registered encoding was not used for %s", registered)
+	}
+	if addr, err = DecodeAddress(registered); err != nil || !reflect.DeepEqual(addr, v6) {
+		t.Errorf("DecodeAddress(%s) got %v, %v", registered, addr, err)
+	}
+
+	// Built in versions ignore the registry.
+	if *Tag(v4) == tag {
+		t.Error("v4 address got registered tag")
+	}
+	if !bytes.Equal(BroadcastDecryptionKey(v4).Serialize(), V5BroadcastDecryptionKey(v4).Serialize()) {
+		t.Error("v4 address got wrong broadcast key")
+	}
+	v3 := addressTests[1].address
+	if !bytes.Equal(BroadcastDecryptionKey(v3).Serialize(), V4BroadcastDecryptionKey(v3).Serialize()) {
+		t.Error("v3 address got wrong broadcast key")
+	}
+
+	RegisterAddressFormat(6, nil)
+	if _, err := DecodeAddress(str); err != ErrUnknownAddressType {
+		t.Errorf("unregistered version got %v", err)
+	}
+}
+
+func TestAddressBytes(t *testing.T) {
+	for _, pair := range addressTests {
+		b := pair.address.Bytes()
+		if len(b) != 2+hash.RipeSize {
+			t.Errorf("for %s got %d bytes", pair.addrString, len(b))
+		}
+		addr, err := AddressFromBytes(b)
+		if err != nil {
+			t.Errorf("for %s got error %v", pair.addrString, err)
+			continue
+		}
+		if !addr.Equal(pair.address) || addr.String() != pair.addrString {
+			t.Errorf("for %s got %s", pair.addrString, addr)
+		}
+	}
+
+	v4 := addressTests[0].address.Bytes()
+	tests := [][]byte{
+		nil,
+		{4},
+		v4[:len(v4)-1],
+		append(v4, 0),
+		// No null byte at the front of the ripe hash.
+		append([]byte{4, 1}, bytes.Repeat([]byte{1}, hash.RipeSize)...),
+		// All null bytes.
+		append([]byte{4, 1}, make([]byte, hash.RipeSize)...),
+		append([]byte{9}, v4[1:]...),
+	}
+	for i, b := range tests {
+		if addr, err := AddressFromBytes(b); err == nil {
+			t.Errorf("#%d: got %s, expected error", i, addr)
+		}
+	}
+}
+
+func TestAddressJSON(t *testing.T) {
+	type config struct {
+		Address Address
+	}
+
+	for _, pair := range addressTests {
+		b, err := json.Marshal(config{pair.address})
+		if err != nil {
+			t.Errorf("for %s got error %v", pair.addrString, err)
+			continue
+		}
+		if expected := `{"Address":"` + pair.addrString + `"}`; string(b) != expected {
+			t.Errorf("got %s, want %s", b, expected)
+		}
+
+		c := config{Address: reflect.New(reflect.TypeOf(pair.address).Elem()).Interface().(Address)}
+		if err = json.Unmarshal(b, &c); err != nil {
+			t.Errorf("for %s got error %v", pair.addrString, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.Address, pair.address) {
+			t.Errorf("got %v, want %v", c.Address, pair.address)
+		}
+	}
+
+	var v4 addressV4
+	if err := v4.UnmarshalText([]byte(addressTests[1].addrString)); err != ErrUnknownAddressType {
+		t.Errorf("v3 address into v4: got %v", err)
+	}
+	if err := v4.UnmarshalText([]byte("BM-2DBXxtaBSV37DsHjN978mRiMbX5rdKNvJ2")); err != ErrChecksumMismatch {
+		t.Errorf("bad checksum: got %v", err)
+	}
+
+	text, _ := addressTests[0].address.(*addressV4).MarshalText()
+	if err := v4.UnmarshalText(text); err != nil || !reflect.DeepEqual(&v4, addressTests[0].address) {
+		t.Errorf("UnmarshalText got %v, %v", &v4, err)
+	}
+}
+
+func TestAddressCompare(t *testing.T) {
+	for i, a := range addressTests {
+		for j, b := range addressTests {
+			if a.address.Equal(b.address) != (i == j) {
+				t.Errorf("%s.Equal(%s) got %v", a.addrString, b.addrString, !(i == j))
+			}
+			if ConstantTimeAddressEqual(a.address, b.address) != (i == j) {
+				t.Errorf("ConstantTimeAddressEqual(%s, %s) got %v", a.addrString,
+					b.addrString, !(i == j))
+			}
+			if c := a.address.Compare(b.address); c != -b.address.Compare(a.address) ||
+				(c == 0) != (i == j) {
+				t.Errorf("%s.Compare(%s) got %d", a.addrString, b.addrString, c)
+			}
+		}
+
+		decoded, _ := DecodeAddress(a.addrString)
+		if !decoded.Equal(a.address) || decoded.Compare(a.address) != 0 {
+			t.Errorf("decoded %s is not equal to itself", a.addrString)
+		}
+	}
+
+	v3, v4 := addressTests[1].address, addressTests[0].address
+	if v3.Compare(v4) != -1 {
+		t.Errorf("version 3 did not come before version 4")
+	}
+	otherStream := &addressV4{stream: 2, ripe: *v4.RipeHash()}
+	if v4.Compare(otherStream) != -1 || otherStream.Equal(v4) ||
+		ConstantTimeAddressEqual(otherStream, v4) {
+		t.Errorf("stream is not compared")
+	}
+	v5 := &addressV5{stream: 1, ripe: *v4.RipeHash()}
+	if v5.Equal(v4) || v5.Compare(v4) != 1 || ConstantTimeAddressEqual(v5, v4) {
+		t.Errorf("v5 address compared with v4 address of the same ripe")
+	}
+	lower := &addressV4{stream: 1, ripe: *v4.RipeHash()}
+	lower.ripe[19]--
+	if lower.Compare(v4) != -1 {
+		t.Errorf("ripe hash is not compared")
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	const addr = "BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B"
+
+	tests := []struct {
+		addr     string
+		position int
+	}{
+		// One character changed.
+		{"BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9C", 36},
+		{"2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9C", 33},
+		{"BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVD62f9B", 31},
+		// Two characters swapped.
+		{"BM-2cVLR8vzEu6QUjGkYAPHQQTUenPV6C2f9B", 31},
+		// Too many mistakes to say which.
+		{"BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC6f29C", -1},
+	}
+
+	for i, test := range tests {
+		if _, err := DecodeAddress(test.addr); err != ErrChecksumMismatch {
+			t.Errorf("DecodeAddress #%d got error %v want %v", i, err, ErrChecksumMismatch)
+		}
+
+		e := SuggestAddressCorrection(test.addr)
+		if e == nil {
+			t.Errorf("SuggestAddressCorrection #%d got nil", i)
+			continue
+		}
+		if !e.Is(ErrChecksumMismatch) {
+			t.Errorf("SuggestAddressCorrection #%d error is not %v", i, ErrChecksumMismatch)
+		}
+		if bytes.Equal(e.Expected, e.Got) || len(e.Got) != 4 {
+			t.Errorf("SuggestAddressCorrection #%d got checksums %x and %x", i,
+				e.Expected, e.Got)
+		}
+		if e.Position != test.position {
+			t.Errorf("SuggestAddressCorrection #%d got position %d want %d", i,
+				e.Position, test.position)
+		}
+		if test.position < 0 {
+			if e.Suggestion != "" {
+				t.Errorf("SuggestAddressCorrection #%d got suggestion %s", i,
+					e.Suggestion)
+			}
+			continue
+		}
+		if want := test.addr[:len(test.addr)-len(addr)+3] + addr[3:]; e.Suggestion != want {
+			t.Errorf("SuggestAddressCorrection #%d got suggestion %s want %s", i,
+				e.Suggestion, want)
+		}
+	}
+
+	// There is nothing to correct in an address which is valid or which
+	// is not an address at all.
+	for _, s := range []string{addr, "BM-invalid"} {
+		if e := SuggestAddressCorrection(s); e != nil {
+			t.Errorf("SuggestAddressCorrection(%s) got %v", s, e)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/base58"
+)
+
+// minBatchPerWorker is the fewest addresses worth starting a goroutine for.
+const minBatchPerWorker = 256
+
+// AddressError describes why a string in a batch could not be decoded as an
+// address.
+type AddressError struct {
+	// Index is the position of the string in the batch.
+	Index   int
+	Address string
+	Err     error
+}
+
+// Error returns a human-readable description of the error.
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("address %d (%s): %v", e.Index, e.Address, e.Err)
+}
+
+// DecodeAddresses decodes many addresses at once, as when importing a
+// subscription list. Each string is decoded as by DecodeAddress. The returned
+// addresses are in the same order as the strings, with nil where a string
+// failed, and the failures are in order of index. Large batches are split
+// between runtime.NumCPU() goroutines, each of which reuses its hash state
+// for every checksum it checks.
+func DecodeAddresses(strs []string) ([]Address, []*AddressError) {
+	addrs := make([]Address, len(strs))
+	errs := make([]error, len(strs))
+
+	workers := runtime.NumCPU()
+	if max := len(strs) / minBatchPerWorker; workers > max {
+		workers = max
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker decodes a contiguous part of the batch.
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(from, to int) {
+			defer wg.Done()
+			v := base58.NewVerifier()
+			for i := from; i < to; i++ {
+				addrs[i], errs[i] = decodeAddress(strs[i], v.Verify)
+			}
+		}(w*len(strs)/workers, (w+1)*len(strs)/workers)
+	}
+	wg.Wait()
+
+	var failures []*AddressError
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, &AddressError{
+				Index:   i,
+				Address: strs[i],
+				Err:     err,
+			})
+		}
+	}
+
+	return addrs, failures
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+// ErrInvalidAddressBytes is returned by AddressFromBytes when the input is
+// not an address in binary form.
+var ErrInvalidAddressBytes = errors.New("invalid binary address")
+
+// AddressBytes returns the binary form of an address, which is its version
+// and stream as var_ints followed by all 20 bytes of its ripe hash. Unlike
+// the string form, it has no checksum and the ripe hash is not shortened, so
+// addresses of the same version and stream all have the same length, which
+// makes it suitable as a database key. It can be used by implementations of
+// Address.
+func AddressBytes(addr Address) []byte {
+	var b bytes.Buffer
+	WriteVarInt(&b, addr.Version())
+	WriteVarInt(&b, addr.Stream())
+	b.Write(addr.RipeHash()[:])
+	return b.Bytes()
+}
+
+// AddressFromBytes decodes an address in the form returned by AddressBytes.
+// It accepts the same addresses as DecodeAddress.
+func AddressFromBytes(b []byte) (Address, error) {
+	r := bytes.NewReader(b)
+	version, err := ReadVarInt(r)
+	if err != nil {
+		return nil, ErrInvalidAddressBytes
+	}
+	stream, err := ReadVarInt(r)
+	if err != nil {
+		return nil, ErrInvalidAddressBytes
+	}
+	if r.Len() != hash.RipeSize {
+		return nil, ErrInvalidAddressBytes
+	}
+
+	// Shorten the ripe hash as in the string form so that it is checked in
+	// the same way.
+	ripe := b[len(b)-hash.RipeSize:]
+	if version < 4 {
+		for i := 0; i < 2 && ripe[0] == 0x00; i++ {
+			ripe = ripe[1:]
+		}
+	} else {
+		ripe = bytes.TrimLeft(ripe, "\x00")
+	}
+	return newAddressFromEncodedRipe(version, stream, append([]byte(nil), ripe...))
+}
+
+// Bytes returns the address in binary form.
+func (addr *addressV4) Bytes() []byte {
+	return AddressBytes(addr)
+}
+
+// Bytes returns the address in binary form.
+func (addr *addressV5) Bytes() []byte {
+	return AddressBytes(addr)
+}
+
+// Bytes returns the address in binary form.
+func (addr *depricatedAddress) Bytes() []byte {
+	return AddressBytes(addr)
+}
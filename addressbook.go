@@ -0,0 +1,147 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+// AddressBookEntry is an address in an AddressBook along with its label.
+type AddressBookEntry struct {
+	Address Address
+	Label   string
+}
+
+// AddressBook is a set of labeled addresses which can be looked up by their
+// string form, their tag or their ripe hash. Since the same ripe hash can
+// appear in addresses of different versions and streams, a lookup by ripe
+// hash can return more than one entry. An AddressBook is safe for concurrent
+// use.
+type AddressBook struct {
+	mtx     sync.RWMutex
+	entries map[string]*AddressBookEntry
+	tags    map[hash.Sha]*AddressBookEntry
+	ripes   map[hash.Ripe][]*AddressBookEntry
+}
+
+// NewAddressBook returns an empty AddressBook.
+func NewAddressBook() *AddressBook {
+	return &AddressBook{
+		entries: make(map[string]*AddressBookEntry),
+		tags:    make(map[hash.Sha]*AddressBookEntry),
+		ripes:   make(map[hash.Ripe][]*AddressBookEntry),
+	}
+}
+
+// Add adds an address to the book, or changes its label if it is already
+// there.
+func (b *AddressBook) Add(addr Address, label string) {
+	str := addr.String()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if e, ok := b.entries[str]; ok {
+		e.Label = label
+		return
+	}
+
+	e := &AddressBookEntry{Address: addr, Label: label}
+	ripe := *addr.RipeHash()
+	b.entries[str] = e
+	b.tags[*Tag(addr)] = e
+	b.ripes[ripe] = append(b.ripes[ripe], e)
+}
+
+// Remove removes an address from the book and reports whether it was there.
+func (b *AddressBook) Remove(addr Address) bool {
+	str := addr.String()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	e, ok := b.entries[str]
+	if !ok {
+		return false
+	}
+	delete(b.entries, str)
+	delete(b.tags, *Tag(e.Address))
+
+	ripe := *e.Address.RipeHash()
+	list := b.ripes[ripe]
+	for i, f := range list {
+		if f == e {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(b.ripes, ripe)
+	} else {
+		b.ripes[ripe] = list
+	}
+	return true
+}
+
+// Get returns the entry for the address with the given string form, or nil.
+func (b *AddressBook) Get(addr string) *AddressBookEntry {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if e, ok := b.entries[addr]; ok {
+		c := *e
+		return &c
+	}
+	return nil
+}
+
+// ByTag returns the entry for the address with the given tag, or nil.
+func (b *AddressBook) ByTag(tag *hash.Sha) *AddressBookEntry {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if e, ok := b.tags[*tag]; ok {
+		c := *e
+		return &c
+	}
+	return nil
+}
+
+// ByRipe returns the entries for the addresses with the given ripe hash.
+func (b *AddressBook) ByRipe(ripe *hash.Ripe) []AddressBookEntry {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	list := make([]AddressBookEntry, len(b.ripes[*ripe]))
+	for i, e := range b.ripes[*ripe] {
+		list[i] = *e
+	}
+	return list
+}
+
+// Len returns the number of addresses in the book.
+func (b *AddressBook) Len() int {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return len(b.entries)
+}
+
+// List returns every entry in the book, ordered by address.
+func (b *AddressBook) List() []AddressBookEntry {
+	b.mtx.RLock()
+	list := make([]AddressBookEntry, 0, len(b.entries))
+	for _, e := range b.entries {
+		list = append(list, *e)
+	}
+	b.mtx.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address.String() < list[j].Address.String()
+	})
+	return list
+}
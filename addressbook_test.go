@@ -0,0 +1,59 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"testing"
+)
+
+func TestAddressBook(t *testing.T) {
+	b := NewAddressBook()
+	for i, pair := range addressTests {
+		b.Add(pair.address, pair.addrString)
+		if b.Len() != i+1 {
+			t.Fatalf("after %d adds got Len %d", i+1, b.Len())
+		}
+	}
+	first := addressTests[0].address
+	otherStream := &addressV4{stream: 2, ripe: *first.RipeHash()}
+	b.Add(otherStream, "other stream")
+	b.Add(otherStream, "relabeled")
+	if b.Len() != len(addressTests)+1 {
+		t.Errorf("got Len %d after relabeling", b.Len())
+	}
+
+	for _, pair := range addressTests {
+		if e := b.Get(pair.addrString); e == nil || e.Label != pair.addrString {
+			t.Errorf("Get(%s) got %v", pair.addrString, e)
+		}
+		if e := b.ByTag(Tag(pair.address)); e == nil || e.Label != pair.addrString {
+			t.Errorf("ByTag for %s got %v", pair.addrString, e)
+		}
+	}
+
+	if list := b.ByRipe(first.RipeHash()); len(list) != 2 {
+		t.Errorf("ByRipe got %d entries, want 2", len(list))
+	}
+	if e := b.ByTag(Tag(otherStream)); e == nil || e.Label != "relabeled" {
+		t.Errorf("ByTag for other stream got %v", e)
+	}
+
+	list := b.List()
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Address.String() >= list[i].Address.String() {
+			t.Errorf("List is not ordered at %d", i)
+		}
+	}
+
+	if !b.Remove(otherStream) || b.Remove(otherStream) {
+		t.Error("Remove did not report whether the address was present")
+	}
+	if b.Get(otherStream.String()) != nil || b.ByTag(Tag(otherStream)) != nil {
+		t.Error("removed address still found")
+	}
+	if list := b.ByRipe(first.RipeHash()); len(list) != 1 || list[0].Label != addressTests[0].addrString {
+		t.Errorf("ByRipe after Remove got %v", list)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"fmt"
+
+	"github.com/DanielKrawisz/bmutil/base58"
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+// ChecksumError describes an address whose checksum does not match, as
+// returned by SuggestAddressCorrection. If changing a single character of the
+// address, or swapping two neighbouring ones, gives an address with a valid
+// checksum, Position is the index in the string of the first character
+// changed and Suggestion is the corrected address. Otherwise Position is -1
+// and Suggestion is empty. A suggestion only has a valid checksum; it is up
+// to the user whether it is the address they meant.
+type ChecksumError struct {
+	Expected []byte
+	Got      []byte
+
+	Position   int
+	Suggestion string
+}
+
+// Error returns a human-readable description of the error.
+func (e *ChecksumError) Error() string {
+	str := fmt.Sprintf("checksum mismatch: expected %x, got %x", e.Expected, e.Got)
+	if e.Position >= 0 {
+		str += fmt.Sprintf("; character %d may be mistyped, did you mean %s?",
+			e.Position, e.Suggestion)
+	}
+	return str
+}
+
+// Is returns whether target is ErrChecksumMismatch, which is what
+// DecodeAddress returns for the same address.
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// SuggestAddressCorrection looks for a likely typo in an address which
+// DecodeAddress rejected with ErrChecksumMismatch, for a wallet to show as a
+// hint. It returns nil if the checksum of addr matches or addr is too short
+// to be an address. The search decodes the address once for every character
+// it tries, so DecodeAddress does not do it.
+func SuggestAddressCorrection(addr string) *ChecksumError {
+	s := addr
+	if len(s) >= 3 && s[:3] == "BM-" {
+		s = s[3:]
+	}
+
+	data := base58.Decode(s)
+	if len(data) <= 12 {
+		return nil
+	}
+	if _, err := base58.Verify(data); err == nil {
+		return nil
+	}
+	return newChecksumError(data, addr, len(addr)-len(s))
+}
+
+// checksumValid returns whether the base58 string s has a valid checksum.
+func checksumValid(s string) bool {
+	_, err := base58.Verify(base58.Decode(s))
+	return err == nil
+}
+
+// newChecksumError returns the ChecksumError for the decoded address data.
+// addr is the address as given and prefix is the length of the "BM-" prefix
+// which was removed from it, if any.
+func newChecksumError(data []byte, addr string, prefix int) *ChecksumError {
+	body := data[:len(data)-base58.ChecksumSize]
+	e := &ChecksumError{
+		Expected: hash.DoubleSha512(body)[:base58.ChecksumSize],
+		Got:      data[len(data)-base58.ChecksumSize:],
+		Position: -1,
+	}
+
+	// Look for one correction. If there is more than one, none of them
+	// is more likely than another, so none is suggested.
+	found := 0
+	try := func(pos int, s []byte) {
+		if checksumValid(string(s)) {
+			found++
+			e.Position = prefix + pos
+			e.Suggestion = addr[:prefix] + string(s)
+		}
+	}
+
+	s := []byte(addr[prefix:])
+	for i, c := range s {
+		for j := 0; j < len(base58.Alphabet); j++ {
+			if base58.Alphabet[j] == c {
+				continue
+			}
+			s[i] = base58.Alphabet[j]
+			try(i, s)
+		}
+		s[i] = c
+	}
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == s[i+1] {
+			continue
+		}
+		s[i], s[i+1] = s[i+1], s[i]
+		try(i, s)
+		s[i], s[i+1] = s[i+1], s[i]
+	}
+
+	if found != 1 {
+		e.Position = -1
+		e.Suggestion = ""
+	}
+	return e
+}
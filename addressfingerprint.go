@@ -0,0 +1,58 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// FingerprintSize is the number of bytes of the tag in a fingerprint.
+const FingerprintSize = 8
+
+// AddressFingerprint returns the first FingerprintSize bytes of the address's
+// tag in hex, in groups of four digits, such as "3f2a 91c0 0b7e d415". Any
+// change to the version, stream or ripe hash changes it. It is short enough
+// to check over the phone, but it is not a substitute for comparing the whole
+// address when that can be done. It can be used by implementations of
+// Address.
+func AddressFingerprint(addr Address) string {
+	digits := hex.EncodeToString(Tag(addr)[:FingerprintSize])
+
+	groups := make([]string, 0, len(digits)/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// MatchFingerprint says whether fp is the fingerprint of the address. Case
+// and any spaces, dashes or colons separating the digits are ignored.
+func MatchFingerprint(addr Address, fp string) bool {
+	fp = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', ':':
+			return -1
+		}
+		return r
+	}, strings.ToLower(fp))
+
+	return fp == strings.Replace(addr.Fingerprint(), " ", "", -1)
+}
+
+// Fingerprint returns the fingerprint of the address.
+func (addr *addressV4) Fingerprint() string {
+	return AddressFingerprint(addr)
+}
+
+// Fingerprint returns the fingerprint of the address.
+func (addr *addressV5) Fingerprint() string {
+	return AddressFingerprint(addr)
+}
+
+// Fingerprint returns the fingerprint of the address.
+func (addr *depricatedAddress) Fingerprint() string {
+	return AddressFingerprint(addr)
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// ErrBuiltInAddressVersion is returned when trying to register a format for
+// an address version that this package already handles.
+var ErrBuiltInAddressVersion = errors.New("address version is built in")
+
+// AddressFormat describes an address version that this package does not
+// handle itself, so that experimental versions can be tried out without
+// changing it. The address type that Decode returns must implement Address,
+// and its String method should use EncodeAddress so that the string is
+// written with Encode and read back with Decode.
+type AddressFormat struct {
+	// Decode returns the address of the version with the given stream and
+	// ripe hash. The ripe hash is as it was read from the string: it has
+	// fewer than 20 bytes if null bytes were removed from its front.
+	Decode func(stream uint64, ripe []byte) (Address, error)
+
+	// Encode returns the ripe hash of an address of the version as it is
+	// written in the string form, which Decode must accept. If it is nil,
+	// null bytes are removed from the front of the ripe hash as for
+	// version 4.
+	Encode func(ripe *hash.Ripe) []byte
+
+	// Tag returns the tag of an address of the version. If it is nil, the
+	// tag is calculated as for version 4.
+	Tag func(Address) *hash.Sha
+
+	// BroadcastKey returns the private key that broadcasts from an address
+	// of the version are encrypted to. If it is nil, the key is
+	// V5BroadcastDecryptionKey.
+	BroadcastKey func(Address) *btcec.PrivateKey
+}
+
+var (
+	addressFormatMtx sync.RWMutex
+
+	// addressFormats maps address versions to their registered formats.
+	addressFormats = make(map[uint64]*AddressFormat)
+)
+
+// builtInAddressVersion says whether the version of address is handled by
+// this package without a registered format.
+func builtInAddressVersion(version uint64) bool {
+	return version >= 2 && version <= ExperimentalAddressVersion
+}
+
+// RegisterAddressFormat sets the format for addresses of the given version,
+// which DecodeAddress, EncodeAddress, Tag and BroadcastDecryptionKey then
+// use. Registering
+// nil removes the format. The versions that this package handles itself
+// cannot be replaced. It is intended for experimenting with new address
+// versions and should be called during initialization.
+func RegisterAddressFormat(version uint64, f *AddressFormat) error {
+	if builtInAddressVersion(version) {
+		return ErrBuiltInAddressVersion
+	}
+
+	addressFormatMtx.Lock()
+	defer addressFormatMtx.Unlock()
+
+	if f == nil {
+		delete(addressFormats, version)
+		return nil
+	}
+	addressFormats[version] = f
+	return nil
+}
+
+// addressFormat returns the format registered for the given version, or nil.
+func addressFormat(version uint64) *AddressFormat {
+	addressFormatMtx.RLock()
+	defer addressFormatMtx.RUnlock()
+
+	return addressFormats[version]
+}
+
+// EncodeAddress returns the string form of an address with the given
+// version, stream and ripe hash. Null bytes are removed from the front of
+// the ripe hash, up to two of them for versions 2 and 3 and all of them for
+// later versions, unless the registered format says otherwise.
+func EncodeAddress(version, stream uint64, ripe *hash.Ripe) string {
+	return encodeAddress(version, stream, encodeRipe(version, ripe))
+}
+
+// encodeRipe returns the ripe hash as it is written in the string form of
+// an address of the given version.
+func encodeRipe(version uint64, ripe *hash.Ripe) []byte {
+	if version < 4 {
+		r := ripe[:]
+		for i := 0; i < 2 && r[0] == 0x00; i++ {
+			r = r[1:]
+		}
+		return r
+	}
+	if !builtInAddressVersion(version) {
+		if f := addressFormat(version); f != nil && f.Encode != nil {
+			return f.Encode(ripe)
+		}
+	}
+	return bytes.TrimLeft(ripe[:], "\x00")
+}
+
+// BroadcastDecryptionKey returns the private key that broadcasts from the
+// address are encrypted to: V4BroadcastDecryptionKey for versions 2 and 3,
+// V5BroadcastDecryptionKey for later versions, or the key given by the
+// registered format.
+func BroadcastDecryptionKey(addr Address) *btcec.PrivateKey {
+	if addr.Version() < 4 {
+		return V4BroadcastDecryptionKey(addr)
+	}
+	if f := addressFormat(addr.Version()); f != nil && f.BroadcastKey != nil {
+		return f.BroadcastKey(addr)
+	}
+	return V5BroadcastDecryptionKey(addr)
+}
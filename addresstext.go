@@ -0,0 +1,118 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// decodeAddressText decodes text as an address of the same kind as like.
+func decodeAddressText(text []byte, like Address) (Address, error) {
+	addr, err := DecodeAddress(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	if reflect.TypeOf(addr) != reflect.TypeOf(like) {
+		return nil, ErrUnknownAddressType
+	}
+	return addr, nil
+}
+
+func unquoteAddress(data []byte) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// MarshalText returns the address as a string beginning with BM-.
+func (addr *addressV4) MarshalText() ([]byte, error) {
+	return []byte(addr.String()), nil
+}
+
+// UnmarshalText decodes a version 4 address.
+func (addr *addressV4) UnmarshalText(text []byte) error {
+	a, err := decodeAddressText(text, addr)
+	if err != nil {
+		return err
+	}
+	*addr = *a.(*addressV4)
+	return nil
+}
+
+// MarshalJSON returns the address as a JSON string.
+func (addr *addressV4) MarshalJSON() ([]byte, error) {
+	return json.Marshal(addr.String())
+}
+
+// UnmarshalJSON decodes a version 4 address from a JSON string.
+func (addr *addressV4) UnmarshalJSON(data []byte) error {
+	text, err := unquoteAddress(data)
+	if err != nil {
+		return err
+	}
+	return addr.UnmarshalText(text)
+}
+
+// MarshalText returns the address as a string beginning with BM-.
+func (addr *addressV5) MarshalText() ([]byte, error) {
+	return []byte(addr.String()), nil
+}
+
+// UnmarshalText decodes a version 5 address.
+func (addr *addressV5) UnmarshalText(text []byte) error {
+	a, err := decodeAddressText(text, addr)
+	if err != nil {
+		return err
+	}
+	*addr = *a.(*addressV5)
+	return nil
+}
+
+// MarshalJSON returns the address as a JSON string.
+func (addr *addressV5) MarshalJSON() ([]byte, error) {
+	return json.Marshal(addr.String())
+}
+
+// UnmarshalJSON decodes a version 5 address from a JSON string.
+func (addr *addressV5) UnmarshalJSON(data []byte) error {
+	text, err := unquoteAddress(data)
+	if err != nil {
+		return err
+	}
+	return addr.UnmarshalText(text)
+}
+
+// MarshalText returns the address as a string beginning with BM-.
+func (addr *depricatedAddress) MarshalText() ([]byte, error) {
+	return []byte(addr.String()), nil
+}
+
+// UnmarshalText decodes a version 2 or 3 address.
+func (addr *depricatedAddress) UnmarshalText(text []byte) error {
+	a, err := decodeAddressText(text, addr)
+	if err != nil {
+		return err
+	}
+	*addr = *a.(*depricatedAddress)
+	return nil
+}
+
+// MarshalJSON returns the address as a JSON string.
+func (addr *depricatedAddress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(addr.String())
+}
+
+// UnmarshalJSON decodes a version 2 or 3 address from a JSON string.
+func (addr *depricatedAddress) UnmarshalJSON(data []byte) error {
+	text, err := unquoteAddress(data)
+	if err != nil {
+		return err
+	}
+	return addr.UnmarshalText(text)
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"crypto/sha512"
+
+	"github.com/DanielKrawisz/bmutil/base58"
+)
+
+// maxAddressData is the most bytes that an address can hold: two var_ints of
+// nine bytes, a ripe hash and a checksum.
+const maxAddressData = 9 + 9 + 20 + base58.ChecksumSize
+
+// base58Digits maps each byte to its value as a base58 digit, or -1.
+var base58Digits [256]int8
+
+func init() {
+	for i := range base58Digits {
+		base58Digits[i] = -1
+	}
+	for i := 0; i < len(base58.Alphabet); i++ {
+		base58Digits[base58.Alphabet[i]] = int8(i)
+	}
+}
+
+// IsValidAddressString says whether DecodeAddress would accept s, without
+// allocating anything, for checking large amounts of input which is mostly
+// not addresses. The prefix, alphabet, length and checksum are checked, as
+// are the version and the length of the ripe hash for the versions that
+// this package decodes itself. An address of a version with a registered
+// AddressFormat is only checked as far as its version.
+func IsValidAddressString(s string) bool {
+	if len(s) >= 3 && s[:3] == "BM-" {
+		s = s[3:]
+	}
+	if len(s) == 0 || len(s) > 2*maxAddressData {
+		return false
+	}
+
+	// Decode the base58 into the end of buf.
+	// start is the index of the first byte used so far.
+	var buf [2 * maxAddressData]byte
+	zeros, start := 0, len(buf)
+	for i := 0; i < len(s); i++ {
+		d := base58Digits[s[i]]
+		if d < 0 {
+			return false
+		}
+		if d == 0 && zeros == i {
+			zeros++
+		}
+		carry := uint(d)
+		j := len(buf) - 1
+		for ; j >= start || carry != 0; j-- {
+			if j < 0 {
+				return false
+			}
+			carry += 58 * uint(buf[j])
+			buf[j] = byte(carry)
+			carry >>= 8
+		}
+		start = j + 1
+	}
+	start -= zeros
+	if start < 0 {
+		return false
+	}
+	data := buf[start:]
+	if len(data) <= 12 || len(data) > maxAddressData {
+		return false
+	}
+
+	body := data[:len(data)-base58.ChecksumSize]
+	first := sha512.Sum512(body)
+	sum := sha512.Sum512(first[:])
+	for i := 0; i < base58.ChecksumSize; i++ {
+		if sum[i] != data[len(body)+i] {
+			return false
+		}
+	}
+
+	version, n, err := ReadVarIntBuf(body)
+	if err != nil {
+		return false
+	}
+	_, m, err := ReadVarIntBuf(body[n:])
+	if err != nil {
+		return false
+	}
+	ripe := body[n+m:]
+
+	// The same rules as newAddressFromEncodedRipe.
+	switch version {
+	case 2, 3:
+		return len(ripe) >= 18 && len(ripe) <= 19
+	case 4, ExperimentalAddressVersion:
+		return len(ripe) >= 4 && len(ripe) <= 19 && ripe[0] != 0x00
+	default:
+		return addressFormat(version) != nil
+	}
+}
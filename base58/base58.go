@@ -0,0 +1,170 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package base58 is the base58 encoding used by Bitmessage addresses, with
+// the alphabet of Bitcoin. The check encoding puts a four byte checksum after
+// the data, which is the start of the double SHA512 of the data, in the same
+// way as an address. Unlike Bitcoin's check encoding, there is no version
+// byte; in an address the version is the first var_int of the data.
+package base58
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	gohash "hash"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// Alphabet is the characters of the encoding in order of their values.
+const Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ChecksumSize is the length of the checksum added by EncodeCheck.
+const ChecksumSize = 4
+
+var (
+	// ErrChecksum is returned by DecodeCheck when the checksum does not
+	// match the data.
+	ErrChecksum = errors.New("checksum mismatch")
+
+	// ErrInvalidFormat is returned by DecodeCheck when the string is not
+	// base58 or is too short to hold a checksum.
+	ErrInvalidFormat = errors.New("invalid format: checksum bytes missing")
+)
+
+// Encode encodes data as base58.
+func Encode(data []byte) string {
+	return base58.Encode(data)
+}
+
+// Decode decodes a base58 string. An empty slice is returned if the string
+// contains a character which is not in the alphabet.
+func Decode(str string) []byte {
+	return base58.Decode(str)
+}
+
+// checksum returns the checksum of data.
+func checksum(data []byte) []byte {
+	return hash.DoubleSha512(data)[:ChecksumSize]
+}
+
+// EncodeCheck encodes data followed by its checksum as base58.
+func EncodeCheck(data []byte) string {
+	b := make([]byte, 0, len(data)+ChecksumSize)
+	b = append(b, data...)
+	b = append(b, checksum(data)...)
+	return base58.Encode(b)
+}
+
+// Verify checks the checksum at the end of data which has been decoded from
+// base58 and returns the data before it.
+func Verify(decoded []byte) ([]byte, error) {
+	if len(decoded) < ChecksumSize {
+		return nil, ErrInvalidFormat
+	}
+
+	data, sum := decoded[:len(decoded)-ChecksumSize], decoded[len(decoded)-ChecksumSize:]
+	if subtle.ConstantTimeCompare(sum, checksum(data)) != 1 {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+// Verifier checks checksums as Verify does, but reuses its hash state from
+// one call to the next so that checking many strings does not allocate for
+// each. A Verifier must not be used by more than one goroutine at a time.
+type Verifier struct {
+	h   gohash.Hash
+	sum [sha512.Size]byte
+}
+
+// NewVerifier returns a new Verifier.
+func NewVerifier() *Verifier {
+	return &Verifier{h: sha512.New()}
+}
+
+// Verify is like the Verify function.
+func (v *Verifier) Verify(decoded []byte) ([]byte, error) {
+	if len(decoded) < ChecksumSize {
+		return nil, ErrInvalidFormat
+	}
+
+	data, sum := decoded[:len(decoded)-ChecksumSize], decoded[len(decoded)-ChecksumSize:]
+	v.h.Reset()
+	v.h.Write(data)
+	first := v.h.Sum(v.sum[:0])
+	v.h.Reset()
+	v.h.Write(first)
+	if subtle.ConstantTimeCompare(sum, v.h.Sum(v.sum[:0])[:ChecksumSize]) != 1 {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+// DecodeCheck decodes a string encoded by EncodeCheck and returns the data
+// without the checksum.
+func DecodeCheck(str string) ([]byte, error) {
+	return Verify(base58.Decode(str))
+}
+
+// BatchError describes why a string in a batch could not be decoded.
+type BatchError struct {
+	// Index is the position of the string in the batch.
+	Index int
+	Err   error
+}
+
+// Error returns a human-readable description of the error.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("string %d: %v", e.Index, e.Err)
+}
+
+// EncodeBatch encodes each of a list of byte slices, as by Encode.
+func EncodeBatch(data [][]byte) []string {
+	strs := make([]string, len(data))
+	for i, d := range data {
+		strs[i] = base58.Encode(d)
+	}
+	return strs
+}
+
+// EncodeCheckBatch encodes each of a list of byte slices, as by
+// EncodeCheck.
+func EncodeCheckBatch(data [][]byte) []string {
+	strs := make([]string, len(data))
+	for i, d := range data {
+		strs[i] = EncodeCheck(d)
+	}
+	return strs
+}
+
+// DecodeBatch decodes each of a list of strings, as by Decode.
+func DecodeBatch(strs []string) [][]byte {
+	data := make([][]byte, len(strs))
+	for i, s := range strs {
+		data[i] = base58.Decode(s)
+	}
+	return data
+}
+
+// DecodeCheckBatch decodes each of a list of strings, as by DecodeCheck. The
+// returned data is in the same order as the strings, with nil where a string
+// failed, and the failures are in order of index. A failed string does not
+// stop the others from being decoded.
+func DecodeCheckBatch(strs []string) ([][]byte, []*BatchError) {
+	data := make([][]byte, len(strs))
+	var errs []*BatchError
+	for i, s := range strs {
+		d, err := DecodeCheck(s)
+		if err != nil {
+			errs = append(errs, &BatchError{Index: i, Err: err})
+			continue
+		}
+		data[i] = d
+	}
+	return data, errs
+}
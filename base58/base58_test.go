@@ -0,0 +1,94 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package base58_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/base58"
+)
+
+func TestCheck(t *testing.T) {
+	// The payload of an address, which is the version, stream and ripe.
+	addr := "BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B"
+	data, err := base58.DecodeCheck(addr[3:])
+	if err != nil {
+		t.Fatalf("DecodeCheck error %v", err)
+	}
+	if data[0] != 4 || data[1] != 1 {
+		t.Errorf("DecodeCheck got version %d stream %d", data[0], data[1])
+	}
+	if s := "BM-" + base58.EncodeCheck(data); s != addr {
+		t.Errorf("EncodeCheck got %s want %s", s, addr)
+	}
+
+	tests := []struct {
+		str string
+		err error
+	}{
+		{"", base58.ErrInvalidFormat},
+		{"2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B0", base58.ErrInvalidFormat},
+		{"2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9C", base58.ErrChecksum},
+		{"3", base58.ErrInvalidFormat},
+	}
+	for i, test := range tests {
+		if _, err := base58.DecodeCheck(test.str); err != test.err {
+			t.Errorf("DecodeCheck #%d got %v want %v", i, err, test.err)
+		}
+	}
+
+	// A Verifier gives the same results, including when it is reused.
+	v := base58.NewVerifier()
+	for i, test := range append(tests, tests...) {
+		if _, err := v.Verify(base58.Decode(test.str)); err != test.err {
+			t.Errorf("Verifier #%d got %v want %v", i, err, test.err)
+		}
+		if got, err := v.Verify(base58.Decode(addr[3:])); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Verifier #%d got %x, %v", i, got, err)
+		}
+	}
+
+	// Addresses report the same error.
+	if _, err := bmutil.DecodeAddress(tests[2].str); err != bmutil.ErrChecksumMismatch {
+		t.Errorf("DecodeAddress got %v want %v", err, bmutil.ErrChecksumMismatch)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	data := [][]byte{{}, {0, 0, 1}, []byte("bitmessage")}
+
+	strs := base58.EncodeBatch(data)
+	for i, d := range base58.DecodeBatch(strs) {
+		if !bytes.Equal(d, data[i]) {
+			t.Errorf("DecodeBatch #%d got %x want %x", i, d, data[i])
+		}
+	}
+
+	strs = base58.EncodeCheckBatch(data)
+	strs = append(strs, "BM-notbase58")
+	strs[1] = strs[2][1:]
+	decoded, errs := base58.DecodeCheckBatch(strs)
+	if len(decoded) != len(strs) {
+		t.Fatalf("DecodeCheckBatch returned %d results want %d",
+			len(decoded), len(strs))
+	}
+	if len(errs) != 2 || errs[0].Index != 1 || errs[1].Index != 3 {
+		t.Fatalf("DecodeCheckBatch got errors %v", errs)
+	}
+	if errs[1].Err != base58.ErrInvalidFormat {
+		t.Errorf("DecodeCheckBatch got %v want %v", errs[1].Err,
+			base58.ErrInvalidFormat)
+	}
+	for _, i := range []int{0, 2} {
+		if !bytes.Equal(decoded[i], data[i]) {
+			t.Errorf("DecodeCheckBatch #%d got %x want %x", i, decoded[i], data[i])
+		}
+	}
+	if decoded[1] != nil {
+		t.Errorf("DecodeCheckBatch #1 got %x for a failed string", decoded[1])
+	}
+}
@@ -82,6 +82,24 @@ func BenchmarkReadVarInt9(b *testing.B) {
 	}
 }
 
+// BenchmarkReadVarIntBuf9 performs a benchmark on how long it takes to read
+// a nine byte variable length integer from a byte slice.
+func BenchmarkReadVarIntBuf9(b *testing.B) {
+	buf := []byte{0xff, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	for i := 0; i < b.N; i++ {
+		ReadVarIntBuf(buf)
+	}
+}
+
+// BenchmarkAppendVarInt9 performs a benchmark on how long it takes to append
+// a nine byte variable length integer to a byte slice.
+func BenchmarkAppendVarInt9(b *testing.B) {
+	buf := make([]byte, 0, MaxVarIntSize)
+	for i := 0; i < b.N; i++ {
+		AppendVarInt(buf, 18446744073709551615)
+	}
+}
+
 // BenchmarkReadVarStr4 performs a benchmark on how long it takes to read a
 // four byte variable length string.
 func BenchmarkReadVarStr4(b *testing.B) {
@@ -115,3 +133,41 @@ func BenchmarkWriteVarStr10(b *testing.B) {
 		WriteVarString(ioutil.Discard, "test012345")
 	}
 }
+
+// BenchmarkTag performs a benchmark on how long it takes to get the tag of an
+// address whose tag has already been calculated.
+func BenchmarkTag(b *testing.B) {
+	addr, _ := DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	for i := 0; i < b.N; i++ {
+		Tag(addr)
+	}
+}
+
+// BenchmarkDecodeAddress performs a benchmark on how long it takes to decode
+// an address.
+func BenchmarkDecodeAddress(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	}
+}
+
+// BenchmarkDecodeAddresses performs a benchmark on how long it takes to
+// decode a batch of a thousand addresses.
+func BenchmarkDecodeAddresses(b *testing.B) {
+	strs := make([]string, 1000)
+	for i := range strs {
+		strs[i] = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ"
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DecodeAddresses(strs)
+	}
+}
+
+// BenchmarkIsValidAddressString performs a benchmark on how long it takes to
+// check that a string is an address without decoding it.
+func BenchmarkIsValidAddressString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		IsValidAddressString("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	}
+}
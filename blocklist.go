@@ -0,0 +1,106 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+// BlockedAddressError is returned when an address is on a Blocklist.
+type BlockedAddressError struct {
+	Address Address
+
+	// Reason is the explanation given when the address or prefix was
+	// added to the blocklist.
+	Reason string
+}
+
+// Error returns a human-readable description of the error.
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("address %s is blocked: %s", e.Address, e.Reason)
+}
+
+type blockedPrefix struct {
+	prefix []byte
+	reason string
+}
+
+// Blocklist is a list of addresses which should not be used, such as burn
+// addresses or the sentinel addresses of gateways, along with the reason
+// each was listed. Addresses are matched by their ripe hash, so an entry
+// covers every version and stream. An entry can also cover every ripe hash
+// which begins with a given prefix. A Blocklist is safe for concurrent use.
+type Blocklist struct {
+	mtx      sync.RWMutex
+	ripes    map[hash.Ripe]string
+	prefixes []blockedPrefix
+}
+
+// NewBlocklist returns an empty Blocklist.
+func NewBlocklist() *Blocklist {
+	return &Blocklist{
+		ripes: make(map[hash.Ripe]string),
+	}
+}
+
+// Add blocks the given address.
+func (b *Blocklist) Add(addr Address, reason string) {
+	b.mtx.Lock()
+	b.ripes[*addr.RipeHash()] = reason
+	b.mtx.Unlock()
+}
+
+// AddPrefix blocks every address whose ripe hash begins with prefix.
+func (b *Blocklist) AddPrefix(prefix []byte, reason string) {
+	p := make([]byte, len(prefix))
+	copy(p, prefix)
+
+	b.mtx.Lock()
+	b.prefixes = append(b.prefixes, blockedPrefix{p, reason})
+	b.mtx.Unlock()
+}
+
+// Remove unblocks the given address. Prefixes which cover it are not
+// affected.
+func (b *Blocklist) Remove(addr Address) {
+	b.mtx.Lock()
+	delete(b.ripes, *addr.RipeHash())
+	b.mtx.Unlock()
+}
+
+// Check returns a *BlockedAddressError if the address is blocked, and nil
+// otherwise.
+func (b *Blocklist) Check(addr Address) error {
+	ripe := addr.RipeHash()
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if reason, ok := b.ripes[*ripe]; ok {
+		return &BlockedAddressError{addr, reason}
+	}
+	for _, p := range b.prefixes {
+		if bytes.HasPrefix(ripe[:], p.prefix) {
+			return &BlockedAddressError{addr, p.reason}
+		}
+	}
+	return nil
+}
+
+// DecodeAddress decodes the address like DecodeAddress and then checks it
+// against the blocklist. A blocked address is returned along with a
+// *BlockedAddressError, so that callers which only want to warn about it
+// can still use it.
+func (b *Blocklist) DecodeAddress(addr string) (Address, error) {
+	a, err := DecodeAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	return a, b.Check(a)
+}
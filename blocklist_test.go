@@ -0,0 +1,71 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	b := NewBlocklist()
+	for _, pair := range addressTests {
+		if _, err := b.DecodeAddress(pair.addrString); err != nil {
+			t.Errorf("DecodeAddress(%s) with empty blocklist: got error %v",
+				pair.addrString, err)
+		}
+	}
+
+	burn := addressTests[0].address
+	otherStream := &addressV4{stream: 2, ripe: *burn.RipeHash()}
+	b.Add(burn, "burn address")
+	// Prefix covering the v3 address with two leading null bytes.
+	b.AddPrefix([]byte{0, 0}, "reserved")
+
+	tests := []struct {
+		addr   string
+		reason string // Empty if not blocked
+	}{
+		{addressTests[0].addrString, "burn address"},
+		{addressTests[1].addrString, ""},
+		{addressTests[3].addrString, "reserved"},
+		// The same ripe hash in a different stream is also blocked.
+		{otherStream.String(), "burn address"},
+	}
+
+	for i, test := range tests {
+		addr, err := b.DecodeAddress(test.addr)
+		if addr == nil {
+			t.Fatalf("#%d DecodeAddress returned no address, error %v", i, err)
+		}
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("#%d got unexpected error %v", i, err)
+			}
+			continue
+		}
+
+		blocked, ok := err.(*BlockedAddressError)
+		if !ok {
+			t.Errorf("#%d got error %v, want BlockedAddressError", i, err)
+			continue
+		}
+		if blocked.Reason != test.reason {
+			t.Errorf("#%d got reason %q, want %q", i, blocked.Reason, test.reason)
+		}
+		if blocked.Address.String() != addr.String() {
+			t.Errorf("#%d got address %s, want %s", i, blocked.Address, addr)
+		}
+	}
+
+	b.Remove(burn)
+	if err := b.Check(burn); err != nil {
+		t.Errorf("Check after Remove: got error %v", err)
+	}
+
+	if _, err := b.DecodeAddress("BM-invalid"); err != ErrChecksumMismatch &&
+		err != ErrUnknownAddressType {
+		t.Errorf("DecodeAddress of invalid address: got error %v", err)
+	}
+}
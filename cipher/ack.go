@@ -0,0 +1,155 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// ErrInvalidAck is returned by Message.AckObject when the ack embedded in a
+// message is not a msg object.
+var ErrInvalidAck = errors.New("invalid ack")
+
+// NewAck returns the ack for a message, which is a msg object whose payload
+// is the ackdata, with the proof of work already done for the given
+// difficulty. It is embedded in the message so that the recipient can send
+// it as soon as the message arrives, without doing any work. As in
+// PyBitmessage, an ack is in the stream of the recipient, who sends it, and
+// expires with the message.
+func NewAck(expiration time.Time, stream uint64, ackData []byte, data pow.Data) *obj.Message {
+	ack := obj.NewMessage(0, expiration, stream, ackData)
+
+	encoded := wire.Encode(ack)
+	ttl := uint64(0)
+	if d := expiration.Unix() - time.Now().Unix(); d > 0 {
+		ttl = uint64(d)
+	}
+	target := pow.CalculateTarget(uint64(len(encoded)), ttl, data)
+	ack.Header().Nonce = pow.Do(target, hash.Sha512(encoded[8:]))
+	return ack
+}
+
+// SignAndEncryptMessageWithAck creates a message from the private identity
+// to the public one, filling in the Public and Destination of bm. If the
+// recipient asks for acks with identity.BehaviorAck, an ack with random
+// ackdata and proof of work for ackPow is embedded, and the ackdata is
+// returned along with the message so that the ack can be recognized when it
+// comes back, as by an AckTracker. Otherwise the ackdata returned is nil.
+func SignAndEncryptMessageWithAck(expiration time.Time, bm *Bitmessage,
+	privID *identity.PrivateID, to identity.Public,
+	ackPow pow.Data) (*Message, []byte, error) {
+
+	addr := to.Address()
+
+	var ack, ackData []byte
+	if to.Behavior()&identity.BehaviorAck != 0 {
+		var err error
+		if ackData, err = RandomAckData(); err != nil {
+			return nil, nil, err
+		}
+		ack = wire.Encode(NewAck(expiration, addr.Stream(), ackData, ackPow))
+	}
+
+	bm.Public = privID.Public()
+	bm.Destination = addr.RipeHash()
+	msg, err := signAndEncryptMessage(expiration, addr.Stream(), bm, ack,
+		privID.PrivateKey().Signing, to.Key())
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, ackData, nil
+}
+
+// AckObject returns the ack embedded in the message, which the recipient
+// should send if its identity has identity.BehaviorAck set. It returns nil
+// if there is no ack and ErrInvalidAck if the ack is not a msg object.
+func (msg *Message) AckObject() (*obj.Message, error) {
+	if len(msg.ack) == 0 {
+		return nil, nil
+	}
+	o, err := obj.ReadObject(msg.ack)
+	if err != nil {
+		return nil, ErrInvalidAck
+	}
+	ack, ok := o.(*obj.Message)
+	if !ok {
+		return nil, ErrInvalidAck
+	}
+	return ack, nil
+}
+
+// AckTracker keeps the ackdata of sent messages whose acks have not come
+// back yet, and recognizes the acks among incoming objects. It is safe for
+// concurrent use.
+type AckTracker struct {
+	mtx     sync.Mutex
+	pending map[string]time.Time
+}
+
+// NewAckTracker returns an AckTracker which is waiting for nothing.
+func NewAckTracker() *AckTracker {
+	return &AckTracker{pending: make(map[string]time.Time)}
+}
+
+// Expect records that an ack with the given ackdata is expected until the
+// given time, which is usually the expiration of the message.
+func (t *AckTracker) Expect(ackData []byte, until time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.pending[string(ackData)] = until
+}
+
+// Check returns whether o is an ack that is expected, in which case it is
+// no longer expected, and its ackdata.
+func (t *AckTracker) Check(o obj.Object) ([]byte, bool) {
+	msg, ok := o.(*obj.Message)
+	if !ok {
+		return nil, false
+	}
+	ackData := msg.Payload()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	until, ok := t.pending[string(ackData)]
+	if !ok || time.Now().After(until) {
+		return nil, false
+	}
+	delete(t.pending, string(ackData))
+	return ackData, true
+}
+
+// Prune stops expecting acks whose time is past and returns how many there
+// were.
+func (t *AckTracker) Prune(now time.Time) int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	var n int
+	for ackData, until := range t.pending {
+		if now.After(until) {
+			delete(t.pending, ackData)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of acks expected.
+func (t *AckTracker) Len() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return len(t.pending)
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestSignAndEncryptMessageWithAck(t *testing.T) {
+	easy := pow.Data{NonceTrialsPerByte: 1, ExtraBytes: 1}
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	bm := &Bitmessage{Content: &format.Encoding2{Subject: "Hi", Body: "Hello."}}
+
+	msg, ackData, err := SignAndEncryptMessageWithAck(expires, bm, PrivID1(),
+		PrivID2().Public(), easy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ackData) != AckDataLength {
+		t.Fatalf("got ackdata %x", ackData)
+	}
+	tracker := NewAckTracker()
+	tracker.Expect(ackData, expires)
+
+	got, err := TryDecryptAndVerifyMessage(msg.Object(), PrivID2())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := got.AckObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ack.Payload(), ackData) || !ack.Header().Expiration().Equal(expires) {
+		t.Errorf("got ack %s", ack)
+	}
+	if !ack.MsgObject().CheckPow(easy, time.Now()) {
+		t.Error("ack has insufficient proof of work")
+	}
+
+	// The message itself is not an ack, and an ack is only recognized once.
+	if _, ok := tracker.Check(msg.Object()); ok {
+		t.Error("message taken for its ack")
+	}
+	if a, ok := tracker.Check(ack); !ok || !bytes.Equal(a, ackData) {
+		t.Errorf("Check got %x, %v", a, ok)
+	}
+	if _, ok := tracker.Check(ack); ok || tracker.Len() != 0 {
+		t.Error("ack recognized twice")
+	}
+
+	tracker.Expect(ackData, expires)
+	if n := tracker.Prune(expires.Add(time.Second)); n != 1 || tracker.Len() != 0 {
+		t.Errorf("Prune got %d, left %d", n, tracker.Len())
+	}
+
+	// No ack is made for a recipient that does not want one.
+	noAck := identity.NewPrivateID(&PrivID2().PrivateAddress, 0, nil)
+	msg, ackData, err = SignAndEncryptMessageWithAck(expires, bm, PrivID1(),
+		noAck.Public(), easy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ackData != nil || len(msg.Ack()) != 0 {
+		t.Errorf("got ackdata %x and ack %x", ackData, msg.Ack())
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// AckDataLength is the length of the ackdata which identifies a message in
+// its ack.
+const AckDataLength = 32
+
+// ackKeyLabel separates the key used for ackdata from any other use of the
+// signing key.
+var ackKeyLabel = []byte("bitmessage ackdata v1")
+
+// RandomAckData returns new random ackdata, which is the usual kind. The
+// sender has to remember it to recognize the ack when it comes.
+func RandomAckData() ([]byte, error) {
+	ackData := make([]byte, AckDataLength)
+	if _, err := io.ReadFull(rand.Reader, ackData); err != nil {
+		return nil, err
+	}
+	return ackData, nil
+}
+
+// DeriveAckData returns ackdata for a message which is determined by the
+// sender's private key, the recipient and the content, so that a client which
+// has lost track of the messages it is waiting for can recognize the ack by
+// deriving the ackdata again from its sent messages.
+//
+// The ackdata is an HMAC under a key derived from the sender's signing key,
+// so without that key it cannot be linked to the sender, the recipient or
+// the content. Sending the same content to the same recipient twice gives
+// the same ackdata.
+func DeriveAckData(sender *identity.PrivateKey, recipient bmutil.Address,
+	content format.Encoding) ([]byte, error) {
+
+	var b bytes.Buffer
+	if err := format.Encode(&b, content); err != nil {
+		return nil, err
+	}
+	digest := sha512.Sum512(b.Bytes())
+
+	keyMac := hmac.New(sha512.New, ackKeyLabel)
+	keyMac.Write(sender.Signing.Serialize())
+	key := keyMac.Sum(nil)
+
+	mac := hmac.New(sha256.New, key)
+	bmutil.WriteVarInt(mac, recipient.Version())
+	bmutil.WriteVarInt(mac, recipient.Stream())
+	mac.Write(recipient.RipeHash()[:])
+	mac.Write(digest[:])
+	return mac.Sum(nil), nil
+}
+
+// CheckAckData returns whether ackData is what DeriveAckData gives for the
+// sender, recipient and content.
+func CheckAckData(ackData []byte, sender *identity.PrivateKey,
+	recipient bmutil.Address, content format.Encoding) bool {
+
+	expected, err := DeriveAckData(sender, recipient, content)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(ackData, expected)
+}
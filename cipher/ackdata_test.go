@@ -0,0 +1,52 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestDeriveAckData(t *testing.T) {
+	sender := PrivID1().PrivateKey()
+	recipient := PrivID2().Address()
+	content := &format.Encoding2{Subject: "hi", Body: "Hey there!"}
+
+	ack, err := DeriveAckData(sender, recipient, content)
+	if err != nil {
+		t.Fatalf("DeriveAckData error %v", err)
+	}
+	if len(ack) != AckDataLength {
+		t.Errorf("got %d bytes, want %d", len(ack), AckDataLength)
+	}
+
+	again, _ := DeriveAckData(sender, recipient, content)
+	if !bytes.Equal(ack, again) {
+		t.Error("ackdata is not deterministic")
+	}
+	if !CheckAckData(ack, sender, recipient, content) {
+		t.Error("CheckAckData rejected derived ackdata")
+	}
+
+	// Changing any input changes the ackdata.
+	other := &format.Encoding2{Subject: "hi", Body: "Hey there"}
+	if CheckAckData(ack, sender, recipient, other) {
+		t.Error("ackdata matched different content")
+	}
+	if CheckAckData(ack, sender, PrivID1().Address(), content) {
+		t.Error("ackdata matched different recipient")
+	}
+	if CheckAckData(ack, PrivID2().PrivateKey(), recipient, content) {
+		t.Error("ackdata matched different sender")
+	}
+
+	random, err := RandomAckData()
+	if err != nil || len(random) != AckDataLength {
+		t.Errorf("RandomAckData got %x, %v", random, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// TryDecryptBroadcastFromBook tries to decrypt and verify a broadcast from
+// one of the addresses in an address book. A tagged broadcast is only
+// decrypted if its tag belongs to an address in the book, so broadcasts
+// from anyone else cost a map lookup rather than a decryption. A tagless
+// broadcast is tried with each address in the book which is old enough to
+// send one. It returns the broadcast along with the book's entry for the
+// address it came from, or ErrInvalidIdentity if it is not from any of them.
+func TryDecryptBroadcastFromBook(msg obj.Broadcast, book *bmutil.AddressBook) (*Broadcast, *bmutil.AddressBookEntry, error) {
+	switch b := msg.(type) {
+	case *obj.TaggedBroadcast:
+		entry := book.ByTag(b.Tag)
+		if entry == nil {
+			return nil, nil, ErrInvalidIdentity
+		}
+		broadcast, err := TryDecryptAndVerifyBroadcast(msg, entry.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+		return broadcast, entry, nil
+	case *obj.TaglessBroadcast:
+		for _, entry := range book.List() {
+			// Addresses from version 4 on send tagged broadcasts.
+			if entry.Address.Version() >= 4 {
+				continue
+			}
+			broadcast, err := TryDecryptAndVerifyBroadcast(msg, entry.Address)
+			if err == ErrInvalidIdentity {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			return broadcast, &entry, nil
+		}
+		return nil, nil, ErrInvalidIdentity
+	default:
+		return nil, nil, obj.ErrInvalidVersion
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+)
+
+func TestTryDecryptBroadcastFromBook(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	broadcast, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, Tag(PrivID1().Address()), 4, 1, 1,
+			SignKey1, EncKey1, 1000, 1000, 1, []byte("Hey there!"), PrivID1()))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	book := NewAddressBook()
+	book.Add(PrivID2().Address(), "Bob")
+	if _, _, err = TryDecryptBroadcastFromBook(broadcast.Object(), book); err != ErrInvalidIdentity {
+		t.Errorf("got error %v want %v", err, ErrInvalidIdentity)
+	}
+
+	book.Add(PrivID1().Address(), "Alice")
+	b, entry, err := TryDecryptBroadcastFromBook(broadcast.Object(), book)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if entry.Label != "Alice" || entry.Address.String() != PrivID1().Address().String() {
+		t.Errorf("got entry %v", entry)
+	}
+	if b.Bitmessage().Public.Address().String() != PrivID1().Address().String() {
+		t.Errorf("got broadcast from %s", b.Bitmessage().Public.Address())
+	}
+}
@@ -88,6 +88,13 @@ func (b *Bitmessage) String() string {
 	return fmt.Sprintf("Bitmessage{destination:%s, %s, %s}", b.Destination.String(), b.Public.String(), string(b.Content.Message()))
 }
 
+// verifySignature checks that sig is a signature of data by the given public
+// identity and returns the hash it was made over, which may be SHA-1 for
+// backwards compatibility. See SetStrictSignatures.
+func verifySignature(data, sig []byte, public identity.Public) (SignatureHash, error) {
+	return verifyHash(data, sig, public.Key().Verification.Btcec())
+}
+
 type Data struct {
 	Key      identity.PublicKey
 	Version  uint64
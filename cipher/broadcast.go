@@ -6,7 +6,6 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/subtle"
 	"errors"
@@ -46,7 +45,7 @@ func (i *incompleteTaglessBroadcast) Encode(w io.Writer) error {
 }
 
 func (i *incompleteTaglessBroadcast) Encrypt(address bmutil.Address, data []byte) (obj.Broadcast, error) {
-	encrypted, err := btcec.Encrypt(bmutil.V4BroadcastDecryptionKey(address).PubKey(), data)
+	encrypted, err := btcec.Encrypt(bmutil.BroadcastDecryptionKey(address).PubKey(), data)
 
 	if err != nil {
 		return nil, err
@@ -80,7 +79,7 @@ func (i *incompleteTaggedBroadcast) Encode(w io.Writer) error {
 }
 
 func (i *incompleteTaggedBroadcast) Encrypt(address bmutil.Address, data []byte) (obj.Broadcast, error) {
-	encrypted, err := btcec.Encrypt(bmutil.V5BroadcastDecryptionKey(address).PubKey(), data)
+	encrypted, err := btcec.Encrypt(bmutil.BroadcastDecryptionKey(address).PubKey(), data)
 
 	if err != nil {
 		return nil, err
@@ -109,6 +108,9 @@ type Broadcast struct {
 	msg obj.Broadcast
 	bm  *Bitmessage
 	sig []byte
+
+	// sigHash is the hash the signature was found to be made over.
+	sigHash SignatureHash
 }
 
 // Object returns the object form of the message.
@@ -121,6 +123,11 @@ func (broadcast *Broadcast) Bitmessage() *Bitmessage {
 	return broadcast.bm
 }
 
+// Signature returns the sender's signature of the broadcast.
+func (broadcast *Broadcast) Signature() []byte {
+	return broadcast.sig
+}
+
 func (broadcast *Broadcast) String() string {
 	return fmt.Sprintf("Broadcast{%s, %s, %v}", broadcast.msg.String(), broadcast.bm.String(), broadcast.sig)
 }
@@ -167,25 +174,15 @@ func (broadcast *Broadcast) decodeFromDecrypted(r io.Reader) error {
 		return err
 	}
 
-	var sigLength uint64
-	if sigLength, err = bmutil.ReadVarInt(r); err != nil {
-		return err
-	}
-	if sigLength > obj.SignatureMaxLength {
-		str := fmt.Sprintf("signature length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			sigLength, obj.SignatureMaxLength)
-		return wire.NewMessageError("DecodeFromDecrypted", str)
-	}
-	broadcast.sig = make([]byte, sigLength)
-	_, err = io.ReadFull(r, broadcast.sig)
+	broadcast.sig, err = wire.ReadVarBytes(r, obj.SignatureMaxLength, "signature",
+		"DecodeFromDecrypted")
 	return err
 }
 
 func (broadcast *Broadcast) signAndEncrypt(
 	i incompleteBroadcast,
 	address bmutil.Address,
-	private *identity.PrivateKey) error {
+	signer identity.Signer) error {
 
 	// Start signing
 	var b bytes.Buffer
@@ -199,7 +196,7 @@ func (broadcast *Broadcast) signAndEncrypt(
 	b.Reset()
 
 	// Sign
-	sig, err := private.Signing.Sign(hash[:])
+	sig, err := signer.Sign(hash[:])
 	if err != nil {
 		return fmt.Errorf("signing failed: %v", err)
 	}
@@ -221,48 +218,44 @@ func (broadcast *Broadcast) signAndEncrypt(
 	return nil
 }
 
-func (broadcast Broadcast) verify(address bmutil.Address) error {
+func (broadcast *Broadcast) verify(address bmutil.Address) error {
 
 	if broadcast.msg == nil {
 		panic("msg is nil")
 	}
 
-	id := broadcast.bm.Public
-
-	addr := id.Address()
-
-	genAddr := addr.String()
-	dencAddr := address.String()
-	if dencAddr != genAddr {
+	addr := broadcast.bm.Public.Address()
+	if !address.Equal(addr) {
 		return fmt.Errorf("Address used for decryption (%s) doesn't match "+
 			"that generated from public key (%s). Possible surreptitious "+
-			"forwarding attack.", dencAddr, genAddr)
+			"forwarding attack.", address, addr)
+	}
+
+	return broadcast.checkSignature()
+}
+
+// checkSignature checks the broadcast's signature against the public
+// identity embedded in it.
+func (broadcast *Broadcast) checkSignature() error {
+	if broadcast.msg == nil {
+		panic("msg is nil")
 	}
 
-	// Start signature verification
 	var b bytes.Buffer
 	err := broadcast.encodeForSigning(&b)
 	if err != nil {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes()) // backwards compatibility
-
-	// Verify
-	sig, err := btcec.ParseSignature(broadcast.sig, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
+	broadcast.sigHash, err = verifySignature(b.Bytes(), broadcast.sig,
+		broadcast.bm.Public)
+	return err
+}
 
-	pk := id.Key().Verification
-	if !sig.Verify(hash[:], pk.Btcec()) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], pk.Btcec()) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-	return nil
+// SignatureHash returns the hash over which the broadcast's signature was
+// made. A broadcast that was not received is signed over SHA-256.
+func (broadcast *Broadcast) SignatureHash() SignatureHash {
+	return broadcast.sigHash
 }
 
 // CreateTaglessBroadcast creates a Broadcast that we send over the network,
@@ -282,7 +275,7 @@ func CreateTaglessBroadcast(expiration time.Time, bm *Bitmessage,
 
 	err := broadcast.signAndEncrypt(
 		&incompleteTaglessBroadcast{expiration, address.Stream()},
-		address, private.PrivateKey())
+		address, private.PrivateKey().Signing)
 	if err != nil {
 		return nil, err
 	}
@@ -307,7 +300,7 @@ func CreateTaggedBroadcast(expires time.Time, bm *Bitmessage, tag *hash.Sha,
 
 	err := broadcast.signAndEncrypt(
 		&incompleteTaggedBroadcast{expires, address.Stream(), tag},
-		address, private.PrivateKey())
+		address, private.PrivateKey().Signing)
 	if err != nil {
 		return nil, err
 	}
@@ -315,7 +308,8 @@ func CreateTaggedBroadcast(expires time.Time, bm *Bitmessage, tag *hash.Sha,
 	return &broadcast, nil
 }
 
-func newBroadcast(msg obj.Broadcast, key *btcec.PrivateKey, address bmutil.Address) (*Broadcast, error) {
+func newBroadcast(msg obj.Broadcast, key *btcec.PrivateKey, address bmutil.Address,
+	opts *bmutil.DecodeOptions) (*Broadcast, error) {
 	encrypted := msg.Encrypted()
 	dec, err := btcec.Decrypt(key, encrypted)
 	if err != nil {
@@ -324,6 +318,14 @@ func newBroadcast(msg obj.Broadcast, key *btcec.PrivateKey, address bmutil.Addre
 		}
 		return nil, err
 	}
+	err = checkPlaintext(dec, msg.MaxPayloadLength(), "newBroadcast")
+	if err != nil {
+		return nil, err
+	}
+	if err = opts.CheckSize(len(dec), "newBroadcast"); err != nil {
+		return nil, err
+	}
+
 	broadcast := Broadcast{}
 
 	var b bytes.Buffer
@@ -333,6 +335,16 @@ func newBroadcast(msg obj.Broadcast, key *btcec.PrivateKey, address bmutil.Addre
 		return nil, err
 	}
 
+	if opts != nil {
+		b.Reset()
+		if err = broadcast.encodeForEncryption(&b); err != nil {
+			return nil, err
+		}
+		if err = opts.CheckEncoding(dec, b.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
 	broadcast.msg = msg
 
 	err = broadcast.verify(address)
@@ -346,25 +358,25 @@ func newBroadcast(msg obj.Broadcast, key *btcec.PrivateKey, address bmutil.Addre
 // NewTaglessBroadcast takes a broadcast we have received over the network
 // and attempts to decrypt it.
 func NewTaglessBroadcast(msg *obj.TaglessBroadcast, address bmutil.Address) (*Broadcast, error) {
-	broadcast, err := newBroadcast(msg, bmutil.V4BroadcastDecryptionKey(address), address)
-	if err != nil {
-		return nil, err
-	}
-
-	return broadcast, nil
+	return newTaglessBroadcast(msg, address, nil)
 }
 
 // NewTaggedBroadcast takes a broadcast we have received over the network
 // and attempts to decrypt it.
 func NewTaggedBroadcast(msg *obj.TaggedBroadcast, address bmutil.Address) (*Broadcast, error) {
+	return newTaggedBroadcast(msg, address, nil)
+}
+
+func newTaglessBroadcast(msg *obj.TaglessBroadcast, address bmutil.Address,
+	opts *bmutil.DecodeOptions) (*Broadcast, error) {
+	return newBroadcast(msg, bmutil.BroadcastDecryptionKey(address), address, opts)
+}
+
+func newTaggedBroadcast(msg *obj.TaggedBroadcast, address bmutil.Address,
+	opts *bmutil.DecodeOptions) (*Broadcast, error) {
 	if subtle.ConstantTimeCompare(msg.Tag[:], bmutil.Tag(address)[:]) != 1 {
 		return nil, ErrInvalidIdentity
 	}
 
-	broadcast, err := newBroadcast(msg, bmutil.V5BroadcastDecryptionKey(address), address)
-	if err != nil {
-		return nil, err
-	}
-
-	return broadcast, nil
+	return newBroadcast(msg, bmutil.BroadcastDecryptionKey(address), address, opts)
 }
@@ -0,0 +1,118 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/internal/lengthcheck"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// CacheVersion is the version of the format written by EncodeCache.
+const CacheVersion = 1
+
+var (
+	// ErrCacheVersion is returned by DecodeCache for a cached Bitmessage
+	// written in a version of the format it does not know.
+	ErrCacheVersion = errors.New("unknown cache format version")
+
+	// ErrCacheIntegrity is returned by DecodeCache when the HMAC of a cached
+	// Bitmessage does not match, because either the data or the key is
+	// wrong.
+	ErrCacheIntegrity = errors.New("cached bitmessage failed integrity check")
+)
+
+// EncodeCache writes a Bitmessage which has already been decrypted and
+// verified so that it can be stored and read back with DecodeCache instead
+// of decrypting the object again. The format is a version byte, the length
+// of the Bitmessage, the Bitmessage itself and an HMAC-SHA256 of all that
+// under key. key should be kept locally and not be derived from anything
+// sent over the network.
+func EncodeCache(w io.Writer, b *Bitmessage, key []byte) error {
+	body := &bytes.Buffer{}
+	var err error
+	if b.Destination == nil {
+		body.WriteByte(0)
+		err = b.encodeBroadcast(body)
+	} else {
+		body.WriteByte(1)
+		err = b.encodeMessage(body)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(CacheVersion)
+	WriteVarInt(buf, uint64(body.Len()))
+	buf.Write(body.Bytes())
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buf.Bytes())
+	buf.Write(mac.Sum(nil))
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// DecodeCache reads a Bitmessage written by EncodeCache. The HMAC is checked
+// before anything else is decoded, and the signature is not checked again.
+func DecodeCache(r io.Reader, key []byte) (*Bitmessage, error) {
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return nil, err
+	}
+	if version[0] != CacheVersion {
+		return nil, ErrCacheVersion
+	}
+
+	length, err := ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 || length > wire.MaxPayloadOfMsgObject {
+		return nil, ErrCacheIntegrity
+	}
+	if lengthcheck.ExceedsInput(r, length) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	sum := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(r, sum); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(version[:])
+	WriteVarInt(mac, length)
+	mac.Write(body)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrCacheIntegrity
+	}
+
+	b := &Bitmessage{}
+	rb := bytes.NewReader(body[1:])
+	switch body[0] {
+	case 0:
+		err = b.decodeBroadcast(rb)
+	case 1:
+		err = b.decodeMessage(rb)
+	default:
+		return nil, ErrCacheIntegrity
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
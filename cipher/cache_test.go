@@ -0,0 +1,71 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestCache(t *testing.T) {
+	key := []byte("local cache key")
+	content := &format.Encoding2{Subject: "hi", Body: "Hey there!"}
+	tests := []*Bitmessage{
+		{
+			Public:      PrivID1().Public(),
+			Destination: PrivID2().Address().RipeHash(),
+			Content:     content,
+		},
+		{
+			Public:  PrivID2().Public(),
+			Content: content,
+		},
+	}
+
+	for i, bm := range tests {
+		var buf bytes.Buffer
+		if err := EncodeCache(&buf, bm, key); err != nil {
+			t.Fatalf("test #%d: EncodeCache error %v", i, err)
+		}
+		encoded := buf.Bytes()
+		if encoded[0] != CacheVersion {
+			t.Errorf("test #%d: got version %d", i, encoded[0])
+		}
+
+		decoded, err := DecodeCache(bytes.NewReader(encoded), key)
+		if err != nil {
+			t.Fatalf("test #%d: DecodeCache error %v", i, err)
+		}
+		if decoded.String() != bm.String() {
+			t.Errorf("test #%d: got %s, want %s", i, decoded, bm)
+		}
+
+		if _, err = DecodeCache(bytes.NewReader(encoded), []byte("wrong")); err != ErrCacheIntegrity {
+			t.Errorf("test #%d: wrong key gave error %v", i, err)
+		}
+
+		for _, n := range []int{3, len(encoded) / 2, len(encoded) - 1} {
+			corrupt := append([]byte(nil), encoded...)
+			corrupt[n] ^= 1
+			if _, err = DecodeCache(bytes.NewReader(corrupt), key); err != ErrCacheIntegrity {
+				t.Errorf("test #%d: corrupt byte %d gave error %v", i, n, err)
+			}
+		}
+
+		corrupt := append([]byte(nil), encoded...)
+		corrupt[0] = CacheVersion + 1
+		if _, err = DecodeCache(bytes.NewReader(corrupt), key); err != ErrCacheVersion {
+			t.Errorf("test #%d: got error %v for unknown version", i, err)
+		}
+
+		if _, err = DecodeCache(bytes.NewReader(encoded[:len(encoded)-1]), key); err != io.ErrUnexpectedEOF {
+			t.Errorf("test #%d: got error %v for truncated input", i, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"errors"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// ErrNotChan is returned by SignAndEncryptChanMessage for an identity which
+// is not a chan.
+var ErrNotChan = errors.New("identity is not a chan")
+
+// SignAndEncryptChanMessage creates a message posted to a chan. As in
+// PyBitmessage, a chan message is sent from the chan to itself, so it is
+// signed and encrypted with the chan's own keys and anyone who has joined
+// the chan can decrypt it with TryDecryptMessage. The message still needs
+// proof of work.
+func SignAndEncryptChanMessage(expiration time.Time, content format.Encoding,
+	ack []byte, ch *identity.PrivateID) (*Message, error) {
+	if !ch.IsChan() {
+		return nil, ErrNotChan
+	}
+
+	bm := &Bitmessage{
+		Public:      ch.Public(),
+		Destination: ch.Address().RipeHash(),
+		Content:     content,
+	}
+	return SignAndEncryptMessage(expiration, ch.Address().Stream(), bm, ack,
+		ch.PrivateKey(), ch.PublicKey())
+}
+
+// IsChanMessage returns whether a decrypted message was posted to a chan,
+// that is, sent from the identity it was sent to. The identity of whoever
+// actually wrote it cannot be known.
+func (msg *Message) IsChanMessage() bool {
+	return *msg.bm.Public.Address().RipeHash() == *msg.bm.Destination
+}
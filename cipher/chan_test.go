@@ -0,0 +1,53 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestChanMessage(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	ch, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := &format.Encoding2{Subject: "Hi", Body: "Hello, chan."}
+	msg, err := SignAndEncryptChanMessage(expires, content, nil, ch)
+	if err != nil {
+		t.Fatalf("SignAndEncryptChanMessage got error %v", err)
+	}
+
+	// Anyone else who has joined the chan can read it.
+	joined, err := identity.JoinChan("general", ch.Address().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := identity.NewKeyring()
+	keyring.AddPrivate(joined)
+	got, id, err := TryDecryptMessage(msg.Object(), keyring)
+	if err != nil {
+		t.Fatalf("TryDecryptMessage got error %v", err)
+	}
+	if !id.IsChan() || !got.IsChanMessage() {
+		t.Errorf("message is not from a chan")
+	}
+	if !bytes.Equal(got.Bitmessage().Content.Message(), content.Message()) {
+		t.Errorf("got content %q want %q", got.Bitmessage().Content.Message(),
+			content.Message())
+	}
+
+	if _, err = SignAndEncryptChanMessage(expires, content, nil, PrivID1()); err != ErrNotChan {
+		t.Errorf("SignAndEncryptChanMessage with a non-chan got %v want %v",
+			err, ErrNotChan)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// exampleIdentity imports one of the identities used by the examples.
+func exampleIdentity(addr, signing, decryption string) *identity.PrivateID {
+	id, err := identity.ImportWIF(addr, signing, decryption)
+	if err != nil {
+		panic(err)
+	}
+	return identity.NewPrivateID(id, identity.BehaviorAck, &pow.Default)
+}
+
+// This example sends a message from one identity to another, and decrypts it
+// on the other side with a keyring which holds the recipient.
+func Example_message() {
+	alice := exampleIdentity("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	bob := exampleIdentity("BM-2cTLMh1CufXWQ9co4CWzD9muDZP4a7N4MA",
+		"5Jw6Gtjy8RCZ5BmTtyx3VykzdXvX4WyWsGu2wLrhfTv8zgKfo7C",
+		"5JY8Lsf5cmNTrXXj1e7FkvCZVYgsK7tAiiocTDtVKLBvQm1EsFw")
+
+	// Alice writes to Bob. Proof of work would be done on msg.Object()
+	// before sending it to the network.
+	bm := &cipher.Bitmessage{
+		Public:      alice.Public(),
+		Destination: bob.Address().RipeHash(),
+		Content:     &format.Encoding2{Subject: "Hello", Body: "How are you?"},
+	}
+	ack, err := cipher.RandomAckData()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	msg, err := cipher.SignAndEncryptMessage(time.Now().Add(time.Hour),
+		bob.Address().Stream(), bm, ack, alice.PrivateKey(), bob.PublicKey())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Bob tries the message with each of his identities.
+	keyring := identity.NewKeyring()
+	keyring.AddPrivate(bob)
+	received, to, err := cipher.TryDecryptMessage(msg.Object(), keyring)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	content := received.Bitmessage().Content.(*format.Encoding2)
+	fmt.Println("to:", to.Address())
+	fmt.Println("from:", received.Bitmessage().Public.Address())
+	fmt.Println(content.Subject)
+	fmt.Println(content.Body)
+
+	// Output:
+	// to: BM-2cTLMh1CufXWQ9co4CWzD9muDZP4a7N4MA
+	// from: BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET
+	// Hello
+	// How are you?
+}
+
+// This example sends a broadcast and receives it as a subscriber, who only
+// needs to know the address it came from.
+func Example_broadcast() {
+	alice := exampleIdentity("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+
+	bm := &cipher.Bitmessage{
+		Public:  alice.Public(),
+		Content: &format.Encoding2{Subject: "News", Body: "Something happened."},
+	}
+	broadcast, err := cipher.SignAndEncryptBroadcast(time.Now().Add(time.Hour),
+		bm, bmutil.Tag(alice.Address()), alice)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	keyring := identity.NewKeyring()
+	keyring.AddSubscription(alice.Address(), "Alice")
+	received, from, err := cipher.TryDecryptBroadcast(broadcast.Object(), keyring)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("from:", from)
+	fmt.Println(received.Bitmessage().Content.(*format.Encoding2).Body)
+
+	// Output:
+	// from: BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET
+	// Something happened.
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// CreateGetPubKey returns a request for the pubkey of address. For a v4
+// address the request carries the address's tag, so that anyone watching
+// the network learns nothing about which address was asked for; for v2 and
+// v3 addresses it carries the ripe hash. The object still needs its proof of
+// work done. ErrUnsupportedOp is returned for any other version.
+func CreateGetPubKey(address Address, expiration time.Time) (*obj.GetPubKey, error) {
+	getpubkey := obj.NewGetPubKey(0, expiration, address)
+	switch address.Version() {
+	case obj.TagGetPubKeyVersion:
+		getpubkey.Ripe = nil
+	case obj.SimplePubKeyVersion, obj.ExtendedPubKeyVersion:
+		getpubkey.Tag = nil
+	default:
+		return nil, ErrUnsupportedOp
+	}
+	return getpubkey, nil
+}
+
+// MatchesIdentity returns whether the request is for the pubkey of id, in
+// which case it should be answered with the object made by GeneratePubKey.
+// Like PyBitmessage, it only matches a request with the version and stream
+// of the identity's address.
+func MatchesIdentity(getpubkey *obj.GetPubKey, id *identity.PrivateID) bool {
+	header := getpubkey.Header()
+	addr := id.Address()
+	if header.Version != addr.Version() || header.StreamNumber != addr.Stream() {
+		return false
+	}
+
+	if header.Version >= obj.TagGetPubKeyVersion {
+		return getpubkey.Tag != nil && Tag(addr).IsEqual(getpubkey.Tag)
+	}
+	return getpubkey.Ripe != nil && addr.RipeHash().IsEqual(getpubkey.Ripe)
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestCreateGetPubKey(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	id := cipher.PrivID1()
+	addr := id.Address()
+
+	// A v4 address is asked for by its tag.
+	getpubkey, err := cipher.CreateGetPubKey(addr, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getpubkey.Ripe != nil || getpubkey.Tag == nil ||
+		getpubkey.Header().Version != obj.TagGetPubKeyVersion {
+		t.Errorf("CreateGetPubKey got %s", getpubkey)
+	}
+	if !cipher.MatchesIdentity(getpubkey, id) {
+		t.Error("request does not match the identity it asks for")
+	}
+	if cipher.MatchesIdentity(getpubkey, cipher.PrivID2()) {
+		t.Error("request matches another identity")
+	}
+
+	// It survives a round trip over the wire.
+	var buf bytes.Buffer
+	if err = getpubkey.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &obj.GetPubKey{}
+	if err = decoded.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !cipher.MatchesIdentity(decoded, id) {
+		t.Error("decoded request does not match the identity it asks for")
+	}
+
+	// A v3 address is asked for by its ripe. A v3 request for the ripe of a
+	// v4 address is not for that address.
+	v3 := cipher.NewTstAddress(obj.ExtendedPubKeyVersion, 1, addr.RipeHash())
+	getpubkey, err = cipher.CreateGetPubKey(v3, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getpubkey.Tag != nil || !getpubkey.Ripe.IsEqual(addr.RipeHash()) ||
+		getpubkey.Header().Version != obj.ExtendedPubKeyVersion {
+		t.Errorf("CreateGetPubKey got %s", getpubkey)
+	}
+	if cipher.MatchesIdentity(getpubkey, id) {
+		t.Error("v3 request matches a v4 identity")
+	}
+
+	// Nor is a request in another stream.
+	other := cipher.NewTstAddress(obj.TagGetPubKeyVersion, 2, addr.RipeHash())
+	getpubkey, err = cipher.CreateGetPubKey(other, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cipher.MatchesIdentity(getpubkey, id) {
+		t.Error("request in another stream matches the identity")
+	}
+
+	for _, version := range []uint64{1, 5} {
+		_, err = cipher.CreateGetPubKey(cipher.NewTstAddress(version, 1,
+			addr.RipeHash()), expires)
+		if err != cipher.ErrUnsupportedOp {
+			t.Errorf("CreateGetPubKey of a v%d address got %v want %v",
+				version, err, cipher.ErrUnsupportedOp)
+		}
+	}
+}
@@ -248,6 +248,14 @@ type TstAddress struct {
 	ripe    *hash.Ripe
 }
 
+func NewTstAddress(version, stream uint64, ripe *hash.Ripe) *TstAddress {
+	return &TstAddress{
+		version: version,
+		stream:  stream,
+		ripe:    ripe,
+	}
+}
+
 func (a *TstAddress) Version() uint64 {
 	return a.version
 }
@@ -260,6 +268,22 @@ func (a *TstAddress) RipeHash() *hash.Ripe {
 	return a.ripe
 }
 
+func (a *TstAddress) Equal(other Address) bool {
+	return CompareAddresses(a, other) == 0
+}
+
+func (a *TstAddress) Compare(other Address) int {
+	return CompareAddresses(a, other)
+}
+
+func (a *TstAddress) Fingerprint() string {
+	return AddressFingerprint(a)
+}
+
+func (a *TstAddress) Bytes() []byte {
+	return AddressBytes(a)
+}
+
 func (a *TstAddress) String() string {
 	var ripe []byte
 
@@ -349,6 +373,14 @@ func (tp *TstPublic) Pow() *pow.Data {
 	return tp.data.Pow
 }
 
+func (tp *TstPublic) Fingerprint() string {
+	return identity.IdentityFingerprint(tp)
+}
+
+func (tp *TstPublic) Equal(other identity.Public) bool {
+	return identity.EqualPublic(tp, other)
+}
+
 func (tp *TstPublic) String() string {
 	return fmt.Sprintf("tstpublic{version:%d, stream:%d, %s}", tp.version, tp.stream, tp.data.String())
 }
@@ -497,6 +529,10 @@ func (b *Message) SetMessage(n *Message) {
 	b.msg = n.msg
 }
 
+func (b *Message) SetSignature(sig []byte) {
+	b.sig = sig
+}
+
 func tstNewExtendedPubKey(nonce pow.Nonce, expires time.Time, streamNumber uint64,
 	behavior uint32, signingKey, encKey *wire.PubKey, nonceTrials,
 	extraBytes uint64, signature []byte) *obj.ExtendedPubKey {
@@ -539,7 +575,7 @@ func TstNewExtendedPubKey(nonce pow.Nonce, expires time.Time, streamNumber uint6
 		behavior, signingKey, encKey, nonceTrials, extraBytes, nil)
 
 	if private != nil {
-		signExtendedPubKey(ep, private)
+		signExtendedPubKey(ep, private.Signing)
 	}
 
 	return ep
@@ -553,7 +589,7 @@ func TstNewDecryptedPubKey(nonce pow.Nonce, expires time.Time, streamNumber uint
 		behavior, signingKey, encKey, nonceTrials, extraBytes, signature, tag, encrypted)
 
 	if encrypted == nil && private != nil {
-		dk.signAndEncrypt(private)
+		dk.signAndEncrypt(private.Address(), private.PrivateKey().Signing)
 	}
 
 	return dk
@@ -961,3 +997,7 @@ func TstGenerateMessageErrorData(t *testing.T, validPubkey *wire.PubKey) (invDes
 
 	return
 }
+
+func TstVerifyHash(data, sig []byte, key *btcec.PublicKey) (SignatureHash, error) {
+	return verifyHash(data, sig, key)
+}
@@ -6,12 +6,11 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/identity"
@@ -32,6 +31,9 @@ type Message struct {
 	bm  *Bitmessage
 	ack []byte
 	sig []byte
+
+	// sigHash is the hash the signature was found to be made over.
+	sigHash SignatureHash
 }
 
 // Object returns the object form of the message that can be sent over
@@ -50,6 +52,11 @@ func (msg *Message) Ack() []byte {
 	return msg.ack
 }
 
+// Signature returns the sender's signature of the message.
+func (msg *Message) Signature() []byte {
+	return msg.sig
+}
+
 // encodeForSigning encodes MessageData so that it can be hashed and signed.
 func (msg *Message) encodeForSigning(w io.Writer) error {
 	err := msg.msg.Header().EncodeForSigning(w)
@@ -104,38 +111,18 @@ func (msg *Message) decodeFromDecrypted(r io.Reader) error {
 		return err
 	}
 
-	var ackLength uint64
-	if ackLength, err = bmutil.ReadVarInt(r); err != nil {
-		return err
-	}
-	if ackLength > wire.MaxPayloadOfMsgObject {
-		str := fmt.Sprintf("ack length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			ackLength, wire.MaxPayloadOfMsgObject)
-		return wire.NewMessageError("decodeFromDecrypted", str)
-	}
-	msg.ack = make([]byte, ackLength)
-	_, err = io.ReadFull(r, msg.ack)
+	msg.ack, err = wire.ReadVarBytes(r, wire.MaxPayloadOfMsgObject, "ack",
+		"decodeFromDecrypted")
 	if err != nil {
 		return err
 	}
 
-	var sigLength uint64
-	if sigLength, err = bmutil.ReadVarInt(r); err != nil {
-		return err
-	}
-	if sigLength > obj.SignatureMaxLength {
-		str := fmt.Sprintf("signature length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			sigLength, obj.SignatureMaxLength)
-		return wire.NewMessageError("decodeFromDecrypted", str)
-	}
-	msg.sig = make([]byte, sigLength)
-	_, err = io.ReadFull(r, msg.sig)
+	msg.sig, err = wire.ReadVarBytes(r, obj.SignatureMaxLength, "signature",
+		"decodeFromDecrypted")
 	return err
 }
 
-func (msg Message) verify(private *identity.PrivateID) error {
+func (msg *Message) verify(private *identity.PrivateID) error {
 	// Check if embedded destination ripe corresponds to private identity.
 	if subtle.ConstantTimeCompare(private.Address().RipeHash()[:],
 		msg.bm.Destination.Bytes()) != 1 {
@@ -144,37 +131,35 @@ func (msg Message) verify(private *identity.PrivateID) error {
 			hex.EncodeToString(private.Address().RipeHash()[:]))
 	}
 
-	// Start signature verification
+	return msg.checkSignature()
+}
+
+// checkSignature checks the message's signature against the public identity
+// embedded in it.
+func (msg *Message) checkSignature() error {
 	var b bytes.Buffer
 	err := msg.encodeForSigning(&b)
 	if err != nil {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes())
-
-	// Verify
-	pvk := msg.bm.Public.Key().Verification.Btcec()
-
-	sig, err := btcec.ParseSignature(msg.sig, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	if !sig.Verify(hash[:], pvk) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], pvk) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
+	msg.sigHash, err = verifySignature(b.Bytes(), msg.sig, msg.bm.Public)
+	return err
+}
 
-	return nil
+// SignatureHash returns the hash over which the message's signature was
+// made. A message that was not received is signed over SHA-256.
+func (msg *Message) SignatureHash() SignatureHash {
+	return msg.sigHash
 }
 
 // NewMessage attempts to decrypt the data in a message object and turn it
 // into a Message.
 func NewMessage(msg *obj.Message, private *identity.PrivateID) (*Message, error) {
+	return newMessage(msg, private, nil)
+}
+
+func newMessage(msg *obj.Message, private *identity.PrivateID, opts *bmutil.DecodeOptions) (*Message, error) {
 	dec, err := btcec.Decrypt(private.PrivateKey().Decryption, msg.Encrypted)
 
 	if err == btcec.ErrInvalidMAC { // decryption failed due to invalid key
@@ -183,6 +168,21 @@ func NewMessage(msg *obj.Message, private *identity.PrivateID) (*Message, error)
 		return nil, err
 	}
 
+	return newDecryptedMessage(msg, dec, private, opts)
+}
+
+// newDecryptedMessage is like newMessage, but for a message which has
+// already been decrypted with the private identity's key.
+func newDecryptedMessage(msg *obj.Message, dec []byte, private *identity.PrivateID,
+	opts *bmutil.DecodeOptions) (*Message, error) {
+	err := checkPlaintext(dec, msg.MaxPayloadLength(), "NewMessage")
+	if err != nil {
+		return nil, err
+	}
+	if err = opts.CheckSize(len(dec), "NewMessage"); err != nil {
+		return nil, err
+	}
+
 	message := Message{
 		msg: msg,
 	}
@@ -191,6 +191,16 @@ func NewMessage(msg *obj.Message, private *identity.PrivateID) (*Message, error)
 		return nil, err
 	}
 
+	if opts != nil {
+		var b bytes.Buffer
+		if err = message.encodeForEncryption(&b); err != nil {
+			return nil, err
+		}
+		if err = opts.CheckEncoding(dec, b.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
 	err = message.verify(private)
 	if err != nil {
 		return nil, err
@@ -198,3 +208,37 @@ func NewMessage(msg *obj.Message, private *identity.PrivateID) (*Message, error)
 
 	return &message, nil
 }
+
+// Rewrap takes a message that has already been decrypted and produces a
+// new message with the same sender, content and ack, signed again by the
+// sender and encrypted for a different recipient. It is intended for
+// resending a message to a correspondent who has moved to new keys. The
+// new message gets a fresh expiration and object; proof of work still
+// needs to be done on it.
+//
+// The private key must be the sender's, since the signature has to verify
+// against the public identity embedded in the message.
+func Rewrap(msg *Message, expiration time.Time, privID *identity.PrivateKey,
+	recipient identity.Public) (*Message, error) {
+
+	if msg.bm == nil {
+		return nil, ErrUnsupportedOp
+	}
+
+	sender := msg.bm.Public.Key()
+	public := privID.Public()
+	if !public.Verification.IsEqual(sender.Verification) ||
+		!public.Encryption.IsEqual(sender.Encryption) {
+		return nil, ErrInvalidIdentity
+	}
+
+	addr := recipient.Address()
+	bm := &Bitmessage{
+		Public:      msg.bm.Public,
+		Destination: addr.RipeHash(),
+		Content:     msg.bm.Content,
+	}
+
+	return SignAndEncryptMessage(expiration, addr.Stream(), bm, msg.ack,
+		privID, recipient.Key())
+}
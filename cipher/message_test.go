@@ -7,6 +7,7 @@ package cipher
 
 import (
 	"bytes"
+	"io"
 	"reflect"
 	"testing"
 	"time"
@@ -179,6 +180,20 @@ func TestMessageEncryptError(t *testing.T) {
 	filledMsgEncodedForEncryption[195] = 0
 	filledMsgEncodedForEncryption[196] = 0
 
+	// Try to decode an ack which is within the max length but longer than
+	// the rest of the payload.
+	filledMsgEncodedForEncryption[194] = 0xfd
+	filledMsgEncodedForEncryption[195] = 0x10
+	filledMsgEncodedForEncryption[196] = 0x00
+	buf = bytes.NewBuffer(filledMsgEncodedForEncryption)
+	err = msg.decodeFromDecrypted(buf)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("decodeFromDecrypted got %v for an ack longer than the payload", err)
+	}
+	filledMsgEncodedForEncryption[194] = 8
+	filledMsgEncodedForEncryption[195] = 0
+	filledMsgEncodedForEncryption[196] = 0
+
 	// Try to decode a message with too long of a signature.
 	filledMsgEncodedForEncryption[203] = 0xff
 	filledMsgEncodedForEncryption[204] = 200
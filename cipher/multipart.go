@@ -0,0 +1,242 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// partMagic begins the body of a message which is one part of a multipart
+// message, so that it can be told apart from ordinary content.
+var partMagic = []byte("bmpart\x00")
+
+// MaxParts is the largest number of parts a multipart message can have.
+const MaxParts = 1024
+
+var (
+	// ErrNotPart is returned by ReadPart for a Bitmessage whose content is
+	// not a part of a multipart message.
+	ErrNotPart = errors.New("content is not a message part")
+
+	// ErrPartMismatch is returned by Reassembly.Add for a part which does
+	// not belong with the parts already received, either because its
+	// sender is different or because it disagrees about the number of
+	// parts or the content of a part.
+	ErrPartMismatch = errors.New("part does not match the rest of the message")
+
+	// ErrIncomplete is returned by Reassembly.Content while some parts have
+	// not been received.
+	ErrIncomplete = errors.New("not every part has been received")
+
+	// ErrContentHash is returned by Reassembly.Content when the parts put
+	// together do not have the hash they were sent with.
+	ErrContentHash = errors.New("reassembled content does not match its hash")
+)
+
+// Part is one of the parts of content which was too large for one message
+// and was sent as several. Each part is sent in its own message with an
+// Encoding1 body, and so is signed by the sender like any other. ID is the
+// hash of the whole content, which is checked once every part is in.
+type Part struct {
+	ID    hash.Sha
+	Index uint32
+	Total uint32
+	Data  []byte
+}
+
+// Content returns the part as the content of a Bitmessage.
+func (p *Part) Content() format.Encoding {
+	var b bytes.Buffer
+	b.Write(partMagic)
+	b.Write(p.ID[:])
+	WriteVarInt(&b, uint64(p.Index))
+	WriteVarInt(&b, uint64(p.Total))
+	b.Write(p.Data)
+	return &format.Encoding1{Body: b.String()}
+}
+
+// SplitContent splits data into parts of at most size bytes each.
+func SplitContent(data []byte, size int) ([]*Part, error) {
+	if size <= 0 {
+		return nil, errors.New("part size must be positive")
+	}
+	total := (len(data) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+	if total > MaxParts {
+		return nil, fmt.Errorf("content needs %d parts, but at most %d are allowed",
+			total, MaxParts)
+	}
+
+	var id hash.Sha
+	copy(id[:], hash.Sha512(data))
+	parts := make([]*Part, total)
+	for i := range parts {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		parts[i] = &Part{
+			ID:    id,
+			Index: uint32(i),
+			Total: uint32(total),
+			Data:  data[i*size : end],
+		}
+	}
+	return parts, nil
+}
+
+// ReadPart returns the part carried by a Bitmessage, or ErrNotPart if it
+// does not carry one.
+func ReadPart(bm *Bitmessage) (*Part, error) {
+	e, ok := bm.Content.(*format.Encoding1)
+	if !ok || !bytes.HasPrefix([]byte(e.Body), partMagic) {
+		return nil, ErrNotPart
+	}
+
+	r := bytes.NewReader([]byte(e.Body[len(partMagic):]))
+	p := &Part{}
+	if _, err := io.ReadFull(r, p.ID[:]); err != nil {
+		return nil, err
+	}
+	index, err := ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	total, err := ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 || total > MaxParts || index >= total {
+		str := fmt.Sprintf("part %d of %d is out of range", index, total)
+		return nil, wire.NewMessageError("ReadPart", str)
+	}
+	p.Index, p.Total = uint32(index), uint32(total)
+	p.Data = make([]byte, r.Len())
+	r.Read(p.Data)
+	return p, nil
+}
+
+// Reassembly keeps the parts of a multipart message as they arrive, so that
+// what has been received so far can be shown before it is complete. Every
+// part must come from the same sender, whose signature on each message has
+// been checked when it was decrypted; the content as a whole is checked
+// against its hash when it is complete. A Reassembly is not safe for
+// concurrent use.
+type Reassembly struct {
+	ID     hash.Sha
+	Sender identity.Public
+
+	parts    [][]byte
+	received int
+}
+
+// NewReassembly starts the reassembly of a multipart message with the first
+// of its parts to arrive, which may be any of them. bm is the verified
+// Bitmessage the part came in.
+func NewReassembly(bm *Bitmessage, p *Part) *Reassembly {
+	r := &Reassembly{
+		ID:     p.ID,
+		Sender: bm.Public,
+		parts:  make([][]byte, p.Total),
+	}
+	r.parts[p.Index] = append([]byte{}, p.Data...)
+	r.received = 1
+	return r
+}
+
+// Add adds a part which came in the verified Bitmessage bm. It returns
+// whether the part was new; a part received again is ignored.
+func (r *Reassembly) Add(bm *Bitmessage, p *Part) (bool, error) {
+	if p.ID != r.ID || int(p.Total) != len(r.parts) ||
+		!bm.Public.Address().Equal(r.Sender.Address()) {
+		return false, ErrPartMismatch
+	}
+
+	if have := r.parts[p.Index]; have != nil {
+		if !bytes.Equal(have, p.Data) {
+			return false, ErrPartMismatch
+		}
+		return false, nil
+	}
+	r.parts[p.Index] = append([]byte{}, p.Data...)
+	r.received++
+	return true, nil
+}
+
+// Total returns the number of parts in the message.
+func (r *Reassembly) Total() int {
+	return len(r.parts)
+}
+
+// Received returns the number of parts received so far.
+func (r *Reassembly) Received() int {
+	return r.received
+}
+
+// Present returns which parts have been received.
+func (r *Reassembly) Present() []bool {
+	present := make([]bool, len(r.parts))
+	for i, p := range r.parts {
+		present[i] = p != nil
+	}
+	return present
+}
+
+// Missing returns the indexes of the parts which have not been received.
+func (r *Reassembly) Missing() []uint32 {
+	var missing []uint32
+	for i, p := range r.parts {
+		if p == nil {
+			missing = append(missing, uint32(i))
+		}
+	}
+	return missing
+}
+
+// Complete returns whether every part has been received.
+func (r *Reassembly) Complete() bool {
+	return r.received == len(r.parts)
+}
+
+// Prefix returns the content of the parts received so far up to the first
+// one missing. Each part has been signed by the sender, but the content
+// has not been checked against its hash.
+func (r *Reassembly) Prefix() []byte {
+	var b bytes.Buffer
+	for _, p := range r.parts {
+		if p == nil {
+			break
+		}
+		b.Write(p)
+	}
+	return b.Bytes()
+}
+
+// Content returns the whole content once every part is in and the content
+// has been checked against its hash.
+func (r *Reassembly) Content() ([]byte, error) {
+	if !r.Complete() {
+		return nil, ErrIncomplete
+	}
+
+	content := r.Prefix()
+	var id hash.Sha
+	copy(id[:], hash.Sha512(content))
+	if id != r.ID {
+		return nil, ErrContentHash
+	}
+	return content, nil
+}
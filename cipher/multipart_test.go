@@ -0,0 +1,100 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestMultipart(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 25)
+	parts, err := SplitContent(content, 100)
+	if err != nil {
+		t.Fatalf("SplitContent error %v", err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+
+	dest := PrivID2().Address().RipeHash()
+	message := func(from int, p *Part) *Bitmessage {
+		public := PrivID1().Public()
+		if from == 2 {
+			public = PrivID2().Public()
+		}
+		return &Bitmessage{Public: public, Destination: dest, Content: p.Content()}
+	}
+
+	// Parts survive being carried in a Bitmessage.
+	read := make([]*Part, len(parts))
+	for i, p := range parts {
+		if read[i], err = ReadPart(message(1, p)); err != nil {
+			t.Fatalf("ReadPart #%d error %v", i, err)
+		}
+		if !reflect.DeepEqual(read[i], p) {
+			t.Errorf("ReadPart #%d got %v, want %v", i, read[i], p)
+		}
+	}
+	if _, err = ReadPart(&Bitmessage{Content: &format.Encoding2{Body: "hi"}}); err != ErrNotPart {
+		t.Errorf("ReadPart of ordinary message got error %v", err)
+	}
+
+	// The last part arrives first.
+	r := NewReassembly(message(1, read[2]), read[2])
+	if r.Total() != 3 || r.Received() != 1 || r.Complete() || len(r.Prefix()) != 0 {
+		t.Errorf("after last part got %d of %d, prefix %q", r.Received(), r.Total(), r.Prefix())
+	}
+	if _, err = r.Content(); err != ErrIncomplete {
+		t.Errorf("Content got error %v, want %v", err, ErrIncomplete)
+	}
+
+	if added, err := r.Add(message(1, read[0]), read[0]); !added || err != nil {
+		t.Errorf("Add got %v, %v", added, err)
+	}
+	if !bytes.Equal(r.Prefix(), content[:100]) {
+		t.Errorf("got prefix %q", r.Prefix())
+	}
+	if !reflect.DeepEqual(r.Present(), []bool{true, false, true}) ||
+		!reflect.DeepEqual(r.Missing(), []uint32{1}) {
+		t.Errorf("got present %v, missing %v", r.Present(), r.Missing())
+	}
+
+	// Duplicates are ignored, and parts which don't fit are rejected.
+	if added, err := r.Add(message(1, read[0]), read[0]); added || err != nil {
+		t.Errorf("Add of duplicate got %v, %v", added, err)
+	}
+	if _, err := r.Add(message(2, read[1]), read[1]); err != ErrPartMismatch {
+		t.Errorf("Add from other sender got error %v", err)
+	}
+	other := *read[0]
+	other.Data = []byte("something else")
+	if _, err := r.Add(message(1, &other), &other); err != ErrPartMismatch {
+		t.Errorf("Add of different data got error %v", err)
+	}
+
+	r.Add(message(1, read[1]), read[1])
+	if !r.Complete() {
+		t.Fatal("reassembly is not complete")
+	}
+	got, err := r.Content()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Content got %q, %v", got, err)
+	}
+
+	// The content is checked against its hash.
+	forged := *read[1]
+	forged.Data = bytes.Repeat([]byte{'x'}, len(forged.Data))
+	r = NewReassembly(message(1, read[0]), read[0])
+	r.Add(message(1, &forged), &forged)
+	r.Add(message(1, read[2]), read[2])
+	if _, err = r.Content(); err != ErrContentHash {
+		t.Errorf("Content of forged parts got error %v", err)
+	}
+}
@@ -30,25 +30,53 @@ var (
 	// ErrInvalidObjectType is returned when the given object is not of
 	// the expected type.
 	ErrInvalidObjectType = errors.New("invalid object type")
+
+	// ErrSignerMismatch is returned when a Signer does not have the
+	// signing key of the identity that it is asked to sign for.
+	ErrSignerMismatch = errors.New("signer does not match identity")
+
+	// ErrPubKeyNotFound is returned by SignAndEncryptMessageTo when the
+	// recipient's public identity is not in the store, in which case it
+	// should be asked for with a getpubkey.
+	ErrPubKeyNotFound = errors.New("public key of recipient not found")
 )
 
 // GeneratePubKey generates a PubKey from the specified private
 // identity. It also signs and encrypts it (if necessary) yielding an object
 // that only needs proof-of-work to be done on it.
 func GeneratePubKey(privID *identity.PrivateID, expiry time.Duration) (PubKeyObject, error) {
+	return GeneratePubKeyWithSigner(privID.Public(), privID.PrivateKey().Signing, expiry)
+}
 
-	switch privID.Address().Version() {
+// GeneratePubKeyWithSigner is like GeneratePubKey, but the pubkey is signed
+// by signer, which must hold the signing key of the public identity.
+func GeneratePubKeyWithSigner(pub identity.Public, signer identity.Signer,
+	expiry time.Duration) (PubKeyObject, error) {
+	if err := checkSigner(signer, pub); err != nil {
+		return nil, err
+	}
+
+	switch pub.Address().Version() {
 	case obj.SimplePubKeyVersion:
-		return createSimplePubKey(time.Now().Add(expiry), privID), nil
+		return createSimplePubKey(time.Now().Add(expiry), pub), nil
 	case obj.ExtendedPubKeyVersion:
-		return createExtendedPubKey(time.Now().Add(expiry), privID)
+		return createExtendedPubKey(time.Now().Add(expiry), pub, signer)
 	case obj.EncryptedPubKeyVersion:
-		return createDecryptedPubKey(time.Now().Add(expiry), privID)
+		return createDecryptedPubKey(time.Now().Add(expiry), pub, signer)
 	default:
 		return nil, ErrUnsupportedOp
 	}
 }
 
+// checkSigner returns ErrSignerMismatch if signer does not have the signing
+// key of the public identity.
+func checkSigner(signer identity.Signer, pub identity.Public) error {
+	if !signer.PubKey().IsEqual(pub.Key().Verification.Btcec()) {
+		return ErrSignerMismatch
+	}
+	return nil
+}
+
 // TryDecryptAndVerifyPubKey tries to decrypt a wire.PubKeyObject of the address.
 // If it fails, it returns ErrInvalidIdentity. If decryption succeeds, it
 // verifies the embedded signature. If signature verification fails, it returns
@@ -124,6 +152,41 @@ func SignAndEncryptBroadcast(expiration time.Time,
 	return CreateTaggedBroadcast(expiration, msg, tag, privID)
 }
 
+// SignAndEncryptBroadcastWithSigner is like SignAndEncryptBroadcast, but the
+// broadcast is signed by signer, which must hold the signing key of the
+// sender in msg.Public.
+func SignAndEncryptBroadcastWithSigner(expiration time.Time,
+	msg *Bitmessage, tag *hash.Sha, signer identity.Signer) (*Broadcast, error) {
+	if err := checkSigner(signer, msg.Public); err != nil {
+		return nil, err
+	}
+	if msg.Destination != nil {
+		return nil, errors.New("Broadcasts do not have a destination.")
+	}
+
+	address := msg.Public.Address()
+	var i incompleteBroadcast
+	if tag == nil {
+		if address.Version() != 2 && address.Version() != 3 {
+			return nil, ErrUnsupportedOp
+		}
+		i = &incompleteTaglessBroadcast{expiration, address.Stream()}
+	} else {
+		if address.Version() != 4 {
+			return nil, ErrUnsupportedOp
+		}
+		i = &incompleteTaggedBroadcast{expiration, address.Stream(), tag}
+	}
+
+	broadcast := Broadcast{
+		bm: msg,
+	}
+	if err := broadcast.signAndEncrypt(i, address, signer); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
 // TryDecryptAndVerifyBroadcast tries to decrypt a wire.BroadcastObject of the
 // public identity. If it fails, it returns ErrInvalidIdentity. If decryption
 // succeeds, it verifies the embedded signature. If signature verification
@@ -131,14 +194,20 @@ func SignAndEncryptBroadcast(expiration time.Time,
 //
 // All necessary fields of the provided wire.BroadcastObject are populated.
 func TryDecryptAndVerifyBroadcast(msg obj.Broadcast, address bmutil.Address) (*Broadcast, error) {
-	var b bytes.Buffer
-	msg.Encode(&b)
+	return TryDecryptAndVerifyBroadcastWithOptions(msg, address, nil)
+}
+
+// TryDecryptAndVerifyBroadcastWithOptions is like TryDecryptAndVerifyBroadcast
+// except that the decrypted contents are checked against the given decode
+// options.
+func TryDecryptAndVerifyBroadcastWithOptions(msg obj.Broadcast, address bmutil.Address,
+	opts *bmutil.DecodeOptions) (*Broadcast, error) {
 
 	switch b := msg.(type) {
 	case *obj.TaglessBroadcast:
-		return NewTaglessBroadcast(b, address)
+		return newTaglessBroadcast(b, address, opts)
 	case *obj.TaggedBroadcast:
-		return NewTaggedBroadcast(b, address)
+		return newTaggedBroadcast(b, address, opts)
 	default:
 		return nil, obj.ErrInvalidVersion
 	}
@@ -153,6 +222,49 @@ func TryDecryptAndVerifyBroadcast(msg obj.Broadcast, address bmutil.Address) (*B
 func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 	bm *Bitmessage, ack []byte, privID *identity.PrivateKey,
 	pubID *identity.PublicKey) (*Message, error) {
+	return signAndEncryptMessage(expiration, streamNumber, bm, ack,
+		privID.Signing, pubID)
+}
+
+// SignAndEncryptMessageWithSigner is like SignAndEncryptMessage, but the
+// message is signed by signer, which must hold the signing key of the
+// sender in bm.Public.
+func SignAndEncryptMessageWithSigner(expiration time.Time, streamNumber uint64,
+	bm *Bitmessage, ack []byte, signer identity.Signer,
+	pubID *identity.PublicKey) (*Message, error) {
+	if err := checkSigner(signer, bm.Public); err != nil {
+		return nil, err
+	}
+	return signAndEncryptMessage(expiration, streamNumber, bm, ack, signer, pubID)
+}
+
+// SignAndEncryptMessageTo creates a message from the private identity to
+// the given address, whose public identity is looked up in the store. If
+// keyring is not nil, the address is first resolved with it, so that a
+// correspondent who has announced a key rotation is sent to at its new
+// address. The Public and Destination of bm are filled in. It returns
+// ErrPubKeyNotFound if the store does not have an identity for the address
+// which is still good.
+func SignAndEncryptMessageTo(expiration time.Time, bm *Bitmessage, ack []byte,
+	privID *identity.PrivateID, to bmutil.Address, keyring *identity.Keyring,
+	store identity.PubKeyStore) (*Message, error) {
+	if keyring != nil {
+		to = keyring.Resolve(to)
+	}
+	pub, _, ok := store.Get(to)
+	if !ok {
+		return nil, ErrPubKeyNotFound
+	}
+
+	bm.Public = privID.Public()
+	bm.Destination = to.RipeHash()
+	return signAndEncryptMessage(expiration, to.Stream(), bm, ack,
+		privID.PrivateKey().Signing, pub.Key())
+}
+
+func signAndEncryptMessage(expiration time.Time, streamNumber uint64,
+	bm *Bitmessage, ack []byte, signer identity.Signer,
+	pubID *identity.PublicKey) (*Message, error) {
 
 	if bm.Destination == nil {
 		return nil, errors.New("No destination given.")
@@ -177,7 +289,7 @@ func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 	b.Reset()
 
 	// Sign
-	sig, err := privID.Signing.Sign(hash[:])
+	sig, err := signer.Sign(hash[:])
 	if err != nil {
 		return nil, fmt.Errorf("signing failed: %v", err)
 	}
@@ -207,19 +319,34 @@ func SignAndEncryptMessage(expiration time.Time, streamNumber uint64,
 //
 // All necessary fields of the provided obj.Message are populated.
 func TryDecryptAndVerifyMessage(msg *obj.Message, privID *identity.PrivateID) (*Message, error) {
+	return TryDecryptAndVerifyMessageWithOptions(msg, privID, nil)
+}
+
+// TryDecryptAndVerifyMessageWithOptions is like TryDecryptAndVerifyMessage
+// except that the decrypted contents are checked against the given decode
+// options.
+func TryDecryptAndVerifyMessageWithOptions(msg *obj.Message, privID *identity.PrivateID,
+	opts *bmutil.DecodeOptions) (*Message, error) {
 	if msg.Header().Version != obj.MessageVersion {
-		println("Wrong message version: ", msg.Header().Version)
 		return nil, ErrUnsupportedOp
 	}
 
+	message, err := copyMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessage(message, privID, opts)
+}
+
+// copyMessage returns a copy of msg which shares nothing with it.
+func copyMessage(msg *obj.Message) (*obj.Message, error) {
 	var b bytes.Buffer
 	msg.Encode(&b)
 
 	var message obj.Message
-	err := message.Decode(&b)
-	if err != nil {
+	if err := message.Decode(&b); err != nil {
 		return nil, err
 	}
-
-	return NewMessage(&message, privID)
+	return &message, nil
 }
@@ -324,6 +324,10 @@ func TestBroadcasts(t *testing.T) {
 	if err != nil {
 		t.Errorf("failed to decrypt broadcast, got error %v", err)
 	}
+	_, err = TryDecryptAndVerifyBroadcastWithOptions(taggedBroadcast, addr, &StrictDecodeOptions)
+	if err != nil {
+		t.Errorf("failed to decrypt broadcast strictly, got error %v", err)
+	}
 
 	// Test errors for TryDecryptAndVerifyBroadcast
 
@@ -418,6 +422,16 @@ func TestMessages(t *testing.T) {
 		t.Errorf("failed to decrypt msg, got error %v", err)
 	}
 
+	// A message produced by PyBitmessage is canonical.
+	_, err = TryDecryptAndVerifyMessageWithOptions(msg, PrivID2(), &StrictDecodeOptions)
+	if err != nil {
+		t.Errorf("failed to decrypt msg strictly, got error %v", err)
+	}
+	_, err = TryDecryptAndVerifyMessageWithOptions(msg, PrivID2(), &DecodeOptions{MaxSize: 100})
+	if err == nil {
+		t.Errorf("decrypting msg with size limit got no error")
+	}
+
 	// Test errors for TryDecryptAndVerifyMessage
 
 	randId, _ := btcec.NewPrivateKey(btcec.S256())
@@ -479,3 +493,54 @@ func TestMessages(t *testing.T) {
 		}
 	}
 }
+
+func TestRewrap(t *testing.T) {
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	message, err := TstSignAndEncryptMessage(t, 0, expires, 1, nil, 4, 1, 1,
+		SignKey1, EncKey1, nil, destRipe, 2, []byte("Subject:Re: hi\nBody:Hey there!"),
+		[]byte{1, 2, 3}, nil, PrivID1().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("for SignAndEncryptMsg got error %v", err)
+	}
+
+	// Send the same message to a new recipient.
+	newExpires := expires.Add(time.Hour)
+	rewrapped, err := Rewrap(message, newExpires, PrivID1().PrivateKey(),
+		PrivID1().Public())
+	if err != nil {
+		t.Fatalf("Rewrap got error %v", err)
+	}
+
+	if !rewrapped.Object().Header().Expiration().Equal(newExpires) {
+		t.Errorf("Rewrap expiration got %v want %v",
+			rewrapped.Object().Header().Expiration(), newExpires)
+	}
+
+	// The old recipient can no longer read it.
+	if _, err = TryDecryptAndVerifyMessage(rewrapped.Object(), PrivID2()); err != ErrInvalidIdentity {
+		t.Errorf("old recipient: got error %v want %v", err, ErrInvalidIdentity)
+	}
+
+	decrypted, err := TryDecryptAndVerifyMessage(rewrapped.Object(), PrivID1())
+	if err != nil {
+		t.Fatalf("TryDecryptAndVerifyMessage got error %v", err)
+	}
+	if !reflect.DeepEqual(decrypted.Bitmessage().Content, message.Bitmessage().Content) {
+		t.Errorf("Rewrap content got %v want %v",
+			decrypted.Bitmessage().Content, message.Bitmessage().Content)
+	}
+	if !bytes.Equal(decrypted.Ack(), message.Ack()) {
+		t.Errorf("Rewrap ack got %v want %v", decrypted.Ack(), message.Ack())
+	}
+	if !decrypted.Bitmessage().Destination.IsEqual(PrivID1().Address().RipeHash()) {
+		t.Errorf("Rewrap destination got %v", decrypted.Bitmessage().Destination)
+	}
+
+	// Only the original sender can rewrap a message.
+	_, err = Rewrap(message, newExpires, PrivID2().PrivateKey(), PrivID1().Public())
+	if err != ErrInvalidIdentity {
+		t.Errorf("Rewrap with wrong key got error %v want %v", err,
+			ErrInvalidIdentity)
+	}
+}
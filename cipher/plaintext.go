@@ -0,0 +1,26 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"fmt"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// checkPlaintext returns an error if a decrypted payload is larger than
+// could have been carried by an object whose maximum payload length is max.
+// Objects read from the wire are already limited to that size, so this only
+// catches objects put together in memory. What keeps a corrupt length field
+// inside the payload from causing a large allocation is the check of each
+// length against the input left with lengthcheck.ExceedsInput.
+func checkPlaintext(dec []byte, max int, fn string) error {
+	if len(dec) > max {
+		str := fmt.Sprintf("decrypted payload too large - "+
+			"%d bytes, but max length is %d", len(dec), max)
+		return wire.NewMessageError(fn, str)
+	}
+	return nil
+}
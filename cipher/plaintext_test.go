@@ -0,0 +1,35 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestCheckPlaintext(t *testing.T) {
+	tests := []struct {
+		size int
+		max  int
+		fail bool
+	}{
+		{0, 0, false},
+		{10, 10, false},
+		{11, 10, true},
+		{wire.MaxPayloadOfMsgObject + 1, wire.MaxPayloadOfMsgObject, true},
+	}
+
+	for i, test := range tests {
+		err := checkPlaintext(make([]byte, test.size), test.max, "test")
+		if test.fail {
+			if _, ok := err.(*wire.MessageError); !ok {
+				t.Errorf("checkPlaintext #%d got %v, want MessageError", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("checkPlaintext #%d error %v", i, err)
+		}
+	}
+}
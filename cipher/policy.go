@@ -0,0 +1,100 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"errors"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+// ErrDropped is returned when a message or broadcast was decrypted and
+// verified but its sender was refused by a SenderPolicy.
+var ErrDropped = errors.New("dropped by sender policy")
+
+// Verdict is what a SenderPolicy decides to do with a message.
+type Verdict int
+
+const (
+	// Accept means the message should be delivered as usual.
+	Accept Verdict = iota
+
+	// Quarantine means the message should be delivered, but kept apart
+	// from the user's ordinary mail, for example in a spam folder.
+	Quarantine
+
+	// Drop means the message should not be delivered at all.
+	Drop
+)
+
+var verdictStrings = map[Verdict]string{
+	Accept:     "Accept",
+	Quarantine: "Quarantine",
+	Drop:       "Drop",
+}
+
+func (v Verdict) String() string {
+	if s, ok := verdictStrings[v]; ok {
+		return s
+	}
+	return "Unknown"
+}
+
+// SenderPolicy decides what to do with a message or broadcast once it has
+// been decrypted and its signature verified, so that the sender is known,
+// but before it is handed to the caller. For a broadcast, sender is the
+// address of the subscription. A nil SenderPolicy accepts everything.
+type SenderPolicy func(sender bmutil.Address, bm *Bitmessage) Verdict
+
+func (p SenderPolicy) judge(sender bmutil.Address, bm *Bitmessage) Verdict {
+	if p == nil {
+		return Accept
+	}
+	return p(sender, bm)
+}
+
+// DenyPolicy returns a SenderPolicy which gives the verdict to senders on the
+// blocklist and accepts everyone else.
+func DenyPolicy(deny *bmutil.Blocklist, verdict Verdict) SenderPolicy {
+	return func(sender bmutil.Address, bm *Bitmessage) Verdict {
+		if deny.Check(sender) != nil {
+			return verdict
+		}
+		return Accept
+	}
+}
+
+// AllowPolicy returns a SenderPolicy which accepts the given senders and gives
+// everyone else the verdict otherwise. Senders are matched by ripe hash, so
+// an address covers every version and stream.
+func AllowPolicy(allow []bmutil.Address, otherwise Verdict) SenderPolicy {
+	ripes := make(map[hash.Ripe]struct{}, len(allow))
+	for _, addr := range allow {
+		ripes[*addr.RipeHash()] = struct{}{}
+	}
+
+	return func(sender bmutil.Address, bm *Bitmessage) Verdict {
+		if _, ok := ripes[*sender.RipeHash()]; ok {
+			return Accept
+		}
+		return otherwise
+	}
+}
+
+// CombinePolicies returns a SenderPolicy which asks each of the given
+// policies and takes the strictest verdict, so that Drop wins over
+// Quarantine and Quarantine over Accept.
+func CombinePolicies(policies ...SenderPolicy) SenderPolicy {
+	return func(sender bmutil.Address, bm *Bitmessage) Verdict {
+		verdict := Accept
+		for _, p := range policies {
+			if v := p.judge(sender, bm); v > verdict {
+				verdict = v
+			}
+		}
+		return verdict
+	}
+}
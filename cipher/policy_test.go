@@ -0,0 +1,117 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestSenderPolicy(t *testing.T) {
+	sender := PrivID1().Address()
+	other := PrivID2().Address()
+
+	blocked := NewBlocklist()
+	blocked.Add(sender, "spam")
+
+	tests := []struct {
+		policy        SenderPolicy
+		sender, other Verdict
+	}{
+		{DenyPolicy(blocked, Drop), Drop, Accept},
+		{DenyPolicy(blocked, Quarantine), Quarantine, Accept},
+		{AllowPolicy([]Address{other}, Quarantine), Quarantine, Accept},
+		{CombinePolicies(), Accept, Accept},
+		{CombinePolicies(nil, AllowPolicy([]Address{sender}, Quarantine),
+			DenyPolicy(blocked, Drop)), Drop, Quarantine},
+	}
+
+	for i, test := range tests {
+		if v := test.policy(sender, nil); v != test.sender {
+			t.Errorf("case %d: sender got %s, want %s", i, v, test.sender)
+		}
+		if v := test.policy(other, nil); v != test.other {
+			t.Errorf("case %d: other got %s, want %s", i, v, test.other)
+		}
+	}
+}
+
+func TestKeyringDecryptWithPolicy(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+	message, err := TstSignAndEncryptMessage(t, 0, expires, 1, nil, 4, 1, 1,
+		SignKey1, EncKey1, nil, destRipe, 1, []byte("Hey there!"), []byte{},
+		nil, PrivID1().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("for SignAndEncryptMsg got error %v", err)
+	}
+
+	broadcast, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, Tag(PrivID1().Address()), 4, 1, 1,
+			SignKey1, EncKey1, 1000, 1000, 1, []byte("Hey there!"), PrivID1()))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	keyring := identity.NewKeyring()
+	keyring.EnableStats()
+	keyring.AddPrivate(PrivID2())
+	keyring.AddSubscription(PrivID1().Address(), "")
+
+	var asked []string
+	verdict := Drop
+	policy := func(sender Address, bm *Bitmessage) Verdict {
+		asked = append(asked, sender.String())
+		return verdict
+	}
+
+	if _, _, _, err = TryDecryptMessageWithPolicy(message.Object(), keyring,
+		policy); err != ErrDropped {
+		t.Errorf("TryDecryptMessageWithPolicy got error %v want %v", err, ErrDropped)
+	}
+	if _, _, _, err = TryDecryptBroadcastWithPolicy(broadcast.Object(), keyring,
+		policy); err != ErrDropped {
+		t.Errorf("TryDecryptBroadcastWithPolicy got error %v want %v", err, ErrDropped)
+	}
+
+	verdict = Quarantine
+	msg, _, v, err := TryDecryptMessageWithPolicy(message.Object(), keyring, policy)
+	if err != nil {
+		t.Fatalf("TryDecryptMessageWithPolicy got error %v", err)
+	}
+	if msg == nil || v != Quarantine {
+		t.Errorf("TryDecryptMessageWithPolicy got message %v, verdict %s", msg, v)
+	}
+	b, _, v, err := TryDecryptBroadcastWithPolicy(broadcast.Object(), keyring, policy)
+	if err != nil {
+		t.Fatalf("TryDecryptBroadcastWithPolicy got error %v", err)
+	}
+	if b == nil || v != Quarantine {
+		t.Errorf("TryDecryptBroadcastWithPolicy got broadcast %v, verdict %s", b, v)
+	}
+
+	for i, s := range asked {
+		if s != PrivID1().Address().String() {
+			t.Errorf("policy call %d got sender %s, want %s", i, s, PrivID1().Address())
+		}
+	}
+	if len(asked) != 4 {
+		t.Errorf("policy was asked %d times, want 4", len(asked))
+	}
+
+	// Dropped objects are not counted.
+	stats := keyring.StatsSnapshot()
+	if s := stats[PrivID2().Address().String()]; s.MessagesReceived != 1 {
+		t.Errorf("stats for recipient got %+v", s)
+	}
+	if s := stats[PrivID1().Address().String()]; s.BroadcastsDecrypted != 1 {
+		t.Errorf("stats for subscription got %+v", s)
+	}
+}
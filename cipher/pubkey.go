@@ -7,7 +7,6 @@ package cipher
 
 import (
 	"bytes"
-	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
@@ -39,40 +38,34 @@ type PubKeyObject interface {
 
 // ToIdentity transforms a PubKeyObject to an identity.Public
 func ToIdentity(pubkey PubKeyObject) (identity.Public, error) {
-	data := pubkey.Data()
-	k, err := identity.NewPublicKey(data.Verification, data.Encryption)
-	if err != nil {
-		return nil, err
-	}
-
 	header := pubkey.Object().Header()
+	data := pubkey.Data()
 
-	id, err := identity.NewPublic(k, header.Version, header.StreamNumber,
-		pubkey.Behavior(), pubkey.Pow())
-	if err != nil {
-		return nil, err
-	}
-
-	return id, nil
+	return identity.NewPublicFromWire(&obj.PubKeyData{
+		Behavior:     pubkey.Behavior(),
+		Verification: data.Verification,
+		Encryption:   data.Encryption,
+		Pow:          pubkey.Pow(),
+	}, header.Version, header.StreamNumber)
 }
 
-func createSimplePubKey(expires time.Time, privID *identity.PrivateID) *obj.SimplePubKey {
+func createSimplePubKey(expires time.Time, pub identity.Public) *obj.SimplePubKey {
 
-	data := privID.Data()
+	data := pub.Data()
 
-	return obj.NewSimplePubKey(0, expires, privID.Address().Stream(), data.Behavior,
+	return obj.NewSimplePubKey(0, expires, pub.Address().Stream(), data.Behavior,
 		data.Verification, data.Encryption)
 }
 
 // sign signs an extendedPubKey, populating the
 // signature fields using the provided private identity.
-func signExtendedPubKey(ep *obj.ExtendedPubKey, private *identity.PrivateKey) error {
+func signExtendedPubKey(ep *obj.ExtendedPubKey, signer identity.Signer) error {
 	if ep == nil {
 		return errors.New("ExtendedPubKey is nil.")
 	}
 
-	if private == nil {
-		return errors.New("PrivateKey is nil.")
+	if signer == nil {
+		return errors.New("Signer is nil.")
 	}
 
 	// Start signing
@@ -87,7 +80,7 @@ func signExtendedPubKey(ep *obj.ExtendedPubKey, private *identity.PrivateKey) er
 	b.Reset()
 
 	// Sign
-	sig, err := private.Signing.Sign(hash[:])
+	sig, err := signer.Sign(hash[:])
 	if err != nil {
 		return fmt.Errorf("signing failed: %v", err)
 	}
@@ -112,30 +105,16 @@ func verifyExtendedPubKey(ep *obj.ExtendedPubKey) error {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes()) // backwards compatibility
-
-	// Verify
-	sig, err := btcec.ParseSignature(ep.Signature, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	if !sig.Verify(hash[:], signKey) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], signKey) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-
-	return nil
+	_, err = verifyHash(b.Bytes(), ep.Signature, signKey)
+	return err
 }
 
-func createExtendedPubKey(expires time.Time, privID *identity.PrivateID) (*obj.ExtendedPubKey, error) {
+func createExtendedPubKey(expires time.Time, pub identity.Public,
+	signer identity.Signer) (*obj.ExtendedPubKey, error) {
 
-	pk := obj.NewExtendedPubKey(0, expires, privID.Address().Stream(), privID.Data(), nil)
+	pk := obj.NewExtendedPubKey(0, expires, pub.Address().Stream(), pub.Data(), nil)
 
-	err := signExtendedPubKey(pk, privID.PrivateKey())
+	err := signExtendedPubKey(pk, signer)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +204,7 @@ func (dp *decryptedPubKey) EncodeForSigning(w io.Writer) error {
 	return dp.data.Encode(w)
 }
 
-func (dp *decryptedPubKey) signAndEncrypt(private *identity.PrivateID) error {
+func (dp *decryptedPubKey) signAndEncrypt(address Address, signer identity.Signer) error {
 	// Start signing
 	var b bytes.Buffer
 	err := dp.EncodeForSigning(&b)
@@ -238,7 +217,7 @@ func (dp *decryptedPubKey) signAndEncrypt(private *identity.PrivateID) error {
 	b.Reset()
 
 	// Sign
-	sig, err := private.PrivateKey().Signing.Sign(hash[:])
+	sig, err := signer.Sign(hash[:])
 	if err != nil {
 		return fmt.Errorf("signing failed: %v", err)
 	}
@@ -251,7 +230,7 @@ func (dp *decryptedPubKey) signAndEncrypt(private *identity.PrivateID) error {
 
 	// Encrypt
 	dp.object.Encrypted, err = btcec.Encrypt(
-		V5BroadcastDecryptionKey(private.Address()).PubKey(), b.Bytes())
+		BroadcastDecryptionKey(address).PubKey(), b.Bytes())
 	if err != nil {
 		return fmt.Errorf("encryption failed: %v", err)
 	}
@@ -266,39 +245,37 @@ func (dp *decryptedPubKey) decryptAndVerify(address Address) error {
 		return ErrInvalidIdentity
 	}
 
-	dec, err := btcec.Decrypt(V5BroadcastDecryptionKey(address), dp.object.Encrypted)
+	dec, err := btcec.Decrypt(BroadcastDecryptionKey(address), dp.object.Encrypted)
 	if err == btcec.ErrInvalidMAC { // decryption failed due to invalid key
 		return ErrInvalidIdentity
 	} else if err != nil { // other reasons
 		return err
 	}
 
-	err = dp.decodeFromDecrypted(bytes.NewReader(dec))
+	err = checkPlaintext(dec, dp.object.MaxPayloadLength(), "decryptAndVerify")
 	if err != nil {
 		return err
 	}
 
-	// Verify validity of secp256k1 public keys.
-	public, err := identity.NewPublicKey(dp.data.Verification, dp.data.Encryption)
+	err = dp.decodeFromDecrypted(bytes.NewReader(dec))
 	if err != nil {
 		return err
 	}
 
 	header := dp.object.Header()
 
-	// Check if embedded keys correspond to the address used for decryption.
-	id, err := identity.NewPublic(public, header.Version,
-		header.StreamNumber, dp.data.Behavior, dp.data.Pow)
+	// Verify validity of secp256k1 public keys and check if embedded keys
+	// correspond to the address used for decryption.
+	id, err := identity.NewPublicFromWire(dp.data, header.Version,
+		header.StreamNumber)
 	if err != nil {
 		return err
 	}
 
-	genAddr := id.Address().String()
-	dencAddr := address.String()
-	if dencAddr != genAddr {
+	if !address.Equal(id.Address()) {
 		return fmt.Errorf("Address used for decryption (%s) doesn't match "+
 			"that generated from public key (%s). Possible surreptitious "+
-			"forwarding attack.", dencAddr, genAddr)
+			"forwarding attack.", address, id.Address())
 	}
 
 	// Start signature verification
@@ -308,38 +285,23 @@ func (dp *decryptedPubKey) decryptAndVerify(address Address) error {
 		return err
 	}
 
-	// Hash
-	hash := sha256.Sum256(b.Bytes())
-	sha1hash := sha1.Sum(b.Bytes()) // backwards compatibility
-
-	// Verify
-	sig, err := btcec.ParseSignature(dp.signature, btcec.S256())
-	if err != nil {
-		return ErrInvalidSignature
-	}
-
-	k := public.Verification.Btcec()
-	if !sig.Verify(hash[:], k) { // Try SHA256 first
-		if !sig.Verify(sha1hash[:], k) { // then SHA1
-			return ErrInvalidSignature
-		}
-	}
-
-	return nil
+	_, err = verifyHash(b.Bytes(), dp.signature, id.Key().Verification.Btcec())
+	return err
 }
 
-func createDecryptedPubKey(expires time.Time, privID *identity.PrivateID) (*decryptedPubKey, error) {
-	addr := privID.Address()
+func createDecryptedPubKey(expires time.Time, pub identity.Public,
+	signer identity.Signer) (*decryptedPubKey, error) {
+	addr := pub.Address()
 
 	var tag hash.Sha
 	copy(tag[:], Tag(addr)[:])
 
 	dp := &decryptedPubKey{
-		object: obj.NewEncryptedPubKey(0, expires, privID.Address().Stream(), &tag, nil),
-		data:   privID.Data(),
+		object: obj.NewEncryptedPubKey(0, expires, addr.Stream(), &tag, nil),
+		data:   pub.Data(),
 	}
 
-	err := dp.signAndEncrypt(privID)
+	err := dp.signAndEncrypt(addr, signer)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,39 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestSignAndEncryptMessageTo(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	to := PrivID2().Address()
+	store := identity.NewMemoryPubKeyStore()
+
+	bm := &Bitmessage{Content: &format.Encoding2{Subject: "Hi", Body: "Hello."}}
+	if _, err := SignAndEncryptMessageTo(expires, bm, nil, PrivID1(), to,
+		nil, store); err != ErrPubKeyNotFound {
+		t.Errorf("got %v want %v", err, ErrPubKeyNotFound)
+	}
+
+	store.Put(PrivID2().Public(), time.Now(), time.Hour)
+	msg, err := SignAndEncryptMessageTo(expires, bm, nil, PrivID1(), to, nil, store)
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessageTo got error %v", err)
+	}
+	got, err := TryDecryptAndVerifyMessage(msg.Object(), PrivID2())
+	if err != nil {
+		t.Fatalf("TryDecryptAndVerifyMessage got error %v", err)
+	}
+	if !got.Bitmessage().Public.Address().Equal(PrivID1().Address()) {
+		t.Errorf("message is from %s", got.Bitmessage().Public.Address())
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// DefaultSeenLimit is the number of inventory hashes a BroadcastReader
+// remembers for spotting duplicates if no other limit is given.
+const DefaultSeenLimit = 10000
+
+// ReceivedBroadcast is a broadcast which has been decrypted and verified,
+// along with the subscription it came from and the verdict of the reader's
+// policy.
+type ReceivedBroadcast struct {
+	*Broadcast
+	From    bmutil.Address
+	Verdict Verdict
+}
+
+// BroadcastReader picks out the broadcasts from a set of subscriptions in a
+// stream of objects. Other objects, duplicates, broadcasts that fail to
+// decrypt or verify and broadcasts dropped by the Policy are passed over.
+//
+// The enabled subscriptions are read when the BroadcastReader is created;
+// later changes to the set are not seen. A BroadcastReader is not safe for
+// concurrent use.
+type BroadcastReader struct {
+	// Rejected counts the broadcasts from a subscription which could not
+	// be decrypted or verified. While Read is running it must not be
+	// looked at until the returned channel has been closed.
+	Rejected int
+
+	// Dropped counts the broadcasts which the Policy dropped. It may be
+	// looked at under the same conditions as Rejected.
+	Dropped int
+
+	// Policy, if not nil, is asked about each broadcast before it is
+	// returned. It must be set before Read is called.
+	Policy SenderPolicy
+
+	tags    map[hash.Sha]bmutil.Address
+	tagless []bmutil.Address
+	seen    map[hash.Sha]struct{}
+	order   []hash.Sha
+	maxSeen int
+	oldest  int
+}
+
+// NewBroadcastReader returns a BroadcastReader for the enabled
+// subscriptions in subs, which remembers up to seenLimit objects for
+// spotting duplicates. If seenLimit is not positive, DefaultSeenLimit is
+// used.
+func NewBroadcastReader(subs *identity.Subscriptions, seenLimit int) *BroadcastReader {
+	if seenLimit <= 0 {
+		seenLimit = DefaultSeenLimit
+	}
+
+	r := &BroadcastReader{
+		tags:    make(map[hash.Sha]bmutil.Address),
+		seen:    make(map[hash.Sha]struct{}),
+		maxSeen: seenLimit,
+	}
+	for _, sub := range subs.List() {
+		if !sub.Enabled {
+			continue
+		}
+		r.tags[*bmutil.Tag(sub.Address)] = sub.Address
+		r.tagless = append(r.tagless, sub.Address)
+	}
+	return r
+}
+
+// Process returns the decrypted broadcast if o is a broadcast from one of
+// the subscriptions which has not been seen before, and nil otherwise.
+func (r *BroadcastReader) Process(o obj.Object) *ReceivedBroadcast {
+	var candidates []bmutil.Address
+	switch b := o.(type) {
+	case *obj.TaggedBroadcast:
+		addr, ok := r.tags[*b.Tag]
+		if !ok {
+			return nil
+		}
+		candidates = []bmutil.Address{addr}
+	case *obj.TaglessBroadcast:
+		candidates = r.tagless
+	default:
+		return nil
+	}
+
+	invHash := *obj.InventoryHash(o)
+	if _, ok := r.seen[invHash]; ok {
+		return nil
+	}
+	r.remember(invHash)
+
+	for _, addr := range candidates {
+		broadcast, err := TryDecryptAndVerifyBroadcast(o.(obj.Broadcast), addr)
+		if err == nil {
+			verdict := r.Policy.judge(addr, broadcast.Bitmessage())
+			if verdict == Drop {
+				r.Dropped++
+				return nil
+			}
+			return &ReceivedBroadcast{broadcast, addr, verdict}
+		}
+		if err != ErrInvalidIdentity {
+			r.Rejected++
+			return nil
+		}
+	}
+	return nil
+}
+
+// remember adds an inventory hash to the seen set, forgetting the oldest
+// one if the set is full.
+func (r *BroadcastReader) remember(invHash hash.Sha) {
+	if len(r.order) < r.maxSeen {
+		r.order = append(r.order, invHash)
+	} else {
+		delete(r.seen, r.order[r.oldest])
+		r.order[r.oldest] = invHash
+		r.oldest = (r.oldest + 1) % r.maxSeen
+	}
+	r.seen[invHash] = struct{}{}
+}
+
+// Read processes the objects received from the given channel in a new
+// goroutine and sends the broadcasts that are found on the returned channel.
+// The channel is unbuffered and no more objects are taken until each
+// broadcast has been received, so a slow consumer holds up the producer
+// rather than broadcasts piling up in memory. The returned channel is closed
+// once objects is closed or done is closed.
+func (r *BroadcastReader) Read(done <-chan struct{}, objects <-chan obj.Object) <-chan *ReceivedBroadcast {
+	out := make(chan *ReceivedBroadcast)
+	go func() {
+		defer close(out)
+		for {
+			var o obj.Object
+			var ok bool
+			select {
+			case o, ok = <-objects:
+				if !ok {
+					return
+				}
+			case <-done:
+				return
+			}
+
+			b := r.Process(o)
+			if b == nil {
+				continue
+			}
+			select {
+			case out <- b:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestBroadcastReader(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+
+	tagged, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, Tag(PrivID1().Address()), 4, 1, 1,
+			SignKey1, EncKey1, 1000, 1000, 1, []byte("tagged"), PrivID1()))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	v3 := identity.NewPrivateID(identity.NewPrivateAddress(PrivKey2(), 3, 1), 1,
+		&pow.Data{NonceTrialsPerByte: 1000, ExtraBytes: 1000})
+	tagless, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, nil, 3, 1, 1,
+			SignKey2, EncKey2, 1000, 1000, 1, []byte("tagless"), v3))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	// A broadcast from an address nobody subscribed to.
+	other, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, Tag(PrivID2().Address()), 4, 1, 1,
+			SignKey2, EncKey2, 1000, 1000, 1, []byte("other"), PrivID2()))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	subs := identity.NewSubscriptions()
+	subs.Add(PrivID1().Address(), "", true)
+	subs.Add(v3.Address(), "", true)
+
+	objects := []obj.Object{
+		tagged.Object(),
+		other.Object(),
+		tagged.Object(), // duplicate
+		tagless.Object(),
+	}
+
+	r := NewBroadcastReader(subs, 0)
+	in := make(chan obj.Object)
+	go func() {
+		for _, o := range objects {
+			in <- o
+		}
+		close(in)
+	}()
+
+	var got []*ReceivedBroadcast
+	for b := range r.Read(nil, in) {
+		got = append(got, b)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d broadcasts, want 2", len(got))
+	}
+	if got[0].From.String() != PrivID1().Address().String() ||
+		string(got[0].Bitmessage().Content.Message()) != "tagged" {
+		t.Errorf("wrong first broadcast %s", got[0])
+	}
+	if got[1].From.String() != v3.Address().String() ||
+		string(got[1].Bitmessage().Content.Message()) != "tagless" {
+		t.Errorf("wrong second broadcast %s", got[1])
+	}
+	if r.Rejected != 0 {
+		t.Errorf("got %d rejected", r.Rejected)
+	}
+
+	// The seen set is limited, so an old duplicate comes through again.
+	r = NewBroadcastReader(subs, 1)
+	if r.Process(tagged.Object()) == nil {
+		t.Errorf("first broadcast was not found")
+	}
+	if r.Process(tagged.Object()) != nil {
+		t.Errorf("duplicate broadcast was not dropped")
+	}
+	r.Process(tagless.Object())
+	if r.Process(tagged.Object()) == nil {
+		t.Errorf("forgotten broadcast was not found")
+	}
+
+	// Broadcasts dropped by the policy are counted but not returned.
+	blocked := NewBlocklist()
+	blocked.Add(v3.Address(), "spam")
+	r = NewBroadcastReader(subs, 0)
+	r.Policy = DenyPolicy(blocked, Drop)
+	if b := r.Process(tagged.Object()); b == nil || b.Verdict != Accept {
+		t.Errorf("allowed broadcast got %v", b)
+	}
+	if r.Process(tagless.Object()) != nil || r.Dropped != 1 {
+		t.Errorf("blocked broadcast was not dropped")
+	}
+
+	// Closing done stops the reader.
+	done := make(chan struct{})
+	close(done)
+	if _, ok := <-r.Read(done, make(chan obj.Object)); ok {
+		t.Errorf("Read did not stop")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// ErrSenderMismatch is returned when a stored message claims to be from
+// the sender being verified, but carries different keys.
+var ErrSenderMismatch = errors.New("message keys do not match sender")
+
+// Signed is a decrypted message or broadcast which carries a signature by
+// its sender. Message and Broadcast implement it.
+type Signed interface {
+	Bitmessage() *Bitmessage
+	checkSignature() error
+}
+
+// VerificationStatus is what is known about whether a stored message was
+// really signed by its sender.
+type VerificationStatus int
+
+const (
+	// Unverified means the message has not been checked against a public
+	// identity that the application trusts.
+	Unverified VerificationStatus = iota
+
+	// Verified means the message's signature checked out against the
+	// sender's public identity.
+	Verified
+
+	// VerificationFailed means the message could not be verified. The
+	// reason is kept in StoredMessage.Err.
+	VerificationFailed
+)
+
+// StoredMessage is a message or broadcast that was decrypted and kept
+// before it could be verified against its sender.
+type StoredMessage struct {
+	Message Signed
+	Status  VerificationStatus
+	Err     error
+}
+
+// Reverify re-runs verification on every stored message from the given
+// sender, which is typically a public identity that has only just become
+// available, and updates each message's Status and Err. Messages from other
+// senders are left as they are. The work is split over the given number of
+// workers, or one per CPU if workers is not positive. The number of messages
+// which were verified is returned.
+func Reverify(stored []*StoredMessage, sender identity.Public, workers int) int {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(stored) {
+		workers = len(stored)
+	}
+
+	addr := sender.Address().String()
+	key := sender.Key()
+
+	jobs := make(chan *StoredMessage)
+	var verified int
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for s := range jobs {
+				s.Err = reverify(s.Message, key)
+				if s.Err != nil {
+					s.Status = VerificationFailed
+					continue
+				}
+
+				s.Status = Verified
+				mtx.Lock()
+				verified++
+				mtx.Unlock()
+			}
+		}()
+	}
+
+	for _, s := range stored {
+		if s.Message.Bitmessage().Public.Address().String() == addr {
+			jobs <- s
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return verified
+}
+
+// reverify checks that msg carries the given keys and that its signature was
+// made with them.
+func reverify(msg Signed, key *identity.PublicKey) error {
+	embedded := msg.Bitmessage().Public.Key()
+	if !embedded.Verification.IsEqual(key.Verification) ||
+		!embedded.Encryption.IsEqual(key.Encryption) {
+		return ErrSenderMismatch
+	}
+
+	return msg.checkSignature()
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+func TestReverify(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+
+	newMessage := func(text string) *Message {
+		message, err := TstSignAndEncryptMessage(t, 0, expires, 1, nil, 4, 1, 1,
+			SignKey1, EncKey1, nil, destRipe, 1, []byte(text), []byte{},
+			nil, PrivID1().PrivateKey(), PrivID2().PublicKey())
+		if err != nil {
+			t.Fatalf("for SignAndEncryptMsg got error %v", err)
+		}
+		return message
+	}
+
+	broadcast, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, Tag(PrivID1().Address()), 4, 1, 1,
+			SignKey1, EncKey1, 1000, 1000, 1, []byte("Hey there!"), PrivID1()))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	forged := newMessage("Forged")
+	forged.SetSignature([]byte{0x30, 0x00})
+
+	// A message from a different sender.
+	other, err := TstSignAndEncryptMessage(t, 0, expires, 1, nil, 4, 1, 1,
+		SignKey2, EncKey2, nil, destRipe, 1, []byte("Hi"), []byte{},
+		nil, PrivID2().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("for SignAndEncryptMsg got error %v", err)
+	}
+
+	stored := []*StoredMessage{
+		{Message: newMessage("one")},
+		{Message: broadcast},
+		{Message: forged},
+		{Message: other},
+		{Message: newMessage("two")},
+	}
+
+	for _, workers := range []int{0, 1, 2, 10} {
+		for _, s := range stored {
+			s.Status, s.Err = Unverified, nil
+		}
+
+		if n := Reverify(stored, PrivID1().Public(), workers); n != 3 {
+			t.Errorf("Reverify with %d workers: got %d verified, want 3",
+				workers, n)
+		}
+
+		want := []VerificationStatus{Verified, Verified, VerificationFailed,
+			Unverified, Verified}
+		for i, s := range stored {
+			if s.Status != want[i] {
+				t.Errorf("Reverify with %d workers: message %d got status "+
+					"%d, want %d (error %v)", workers, i, s.Status, want[i], s.Err)
+			}
+		}
+		if stored[2].Err != ErrInvalidSignature {
+			t.Errorf("Reverify with %d workers: got error %v, want %v",
+				workers, stored[2].Err, ErrInvalidSignature)
+		}
+	}
+
+	if n := Reverify(nil, PrivID1().Public(), 0); n != 0 {
+		t.Errorf("Reverify with no messages: got %d", n)
+	}
+}
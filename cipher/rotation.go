@@ -0,0 +1,67 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+// rotationPrefix begins the body of a message which announces a key
+// rotation, so that it can be told apart from ordinary content. The rest of
+// the body is the rotation in the text form of identity.Rotation.
+const rotationPrefix = "bmrotate:"
+
+var (
+	// ErrNotRotation is returned by ReadRotation for a Bitmessage whose
+	// content is not a key rotation.
+	ErrNotRotation = errors.New("content is not a key rotation")
+
+	// ErrRotationSender is returned by ReadRotation for a key rotation
+	// which was not sent by the identity it moves away from.
+	ErrRotationSender = errors.New("key rotation not sent by the old identity")
+)
+
+// RotationContent returns a rotation as the content of a Bitmessage, which
+// should be sent from the old identity of the rotation to its
+// correspondents. When they receive it with TryDecryptMessage or
+// TryDecryptBroadcast, their keyring records it, and SignAndEncryptMessageTo
+// sends to the new address from then on.
+func RotationContent(r *identity.Rotation) format.Encoding {
+	return &format.Encoding1{Body: rotationPrefix + r.String()}
+}
+
+// ReadRotation returns the rotation carried by a verified Bitmessage, or
+// ErrNotRotation if it does not carry one. The rotation must be from the
+// sender of the Bitmessage and signed by it.
+func ReadRotation(bm *Bitmessage) (*identity.Rotation, error) {
+	e, ok := bm.Content.(*format.Encoding1)
+	if !ok || !strings.HasPrefix(e.Body, rotationPrefix) {
+		return nil, ErrNotRotation
+	}
+
+	r, err := identity.ParseRotation(e.Body[len(rotationPrefix):])
+	if err != nil {
+		return nil, err
+	}
+	if !r.Old.Address().Equal(bm.Public.Address()) {
+		return nil, ErrRotationSender
+	}
+	if err = r.Verify(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// recordRotation records a key rotation carried by bm in the keyring, so
+// that later messages to the sender go to its new address.
+func recordRotation(keyring *identity.Keyring, bm *Bitmessage) {
+	if r, err := ReadRotation(bm); err == nil {
+		keyring.AddRotation(r)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestRotationContent(t *testing.T) {
+	newID, err := identity.NewChan("rotation", 1)
+	if err != nil {
+		t.Fatalf("NewChan error %v", err)
+	}
+
+	when := time.Now()
+	r, err := identity.NewRotation(PrivID1(), newID.Address(), when)
+	if err != nil {
+		t.Fatalf("NewRotation error %v", err)
+	}
+
+	dest := PrivID2().Address().RipeHash()
+	bm := &Bitmessage{Public: PrivID1().Public(), Destination: dest, Content: RotationContent(r)}
+	read, err := ReadRotation(bm)
+	if err != nil {
+		t.Fatalf("ReadRotation error %v", err)
+	}
+	if read.String() != r.String() {
+		t.Errorf("ReadRotation got %s want %s", read, r)
+	}
+
+	// A rotation must come from the identity it moves away from.
+	bm.Public = PrivID2().Public()
+	if _, err = ReadRotation(bm); err != ErrRotationSender {
+		t.Errorf("ReadRotation from other sender got %v want %v", err, ErrRotationSender)
+	}
+	if _, err = ReadRotation(&Bitmessage{Content: &format.Encoding1{Body: "hi"}}); err != ErrNotRotation {
+		t.Errorf("ReadRotation of ordinary message got %v want %v", err, ErrNotRotation)
+	}
+
+	// A message carrying the rotation re-targets later sends.
+	bm.Public = PrivID1().Public()
+	message, err := SignAndEncryptMessage(when.Add(time.Hour), 1, bm, []byte{},
+		PrivID1().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessage error %v", err)
+	}
+	keyring := identity.NewKeyring()
+	keyring.AddPrivate(PrivID2())
+	if _, _, err = TryDecryptMessage(message.Object(), keyring); err != nil {
+		t.Fatalf("TryDecryptMessage error %v", err)
+	}
+	if to := keyring.Resolve(PrivID1().Address()); !to.Equal(newID.Address()) {
+		t.Errorf("Resolve got %s want %s", to, newID.Address())
+	}
+	if to := keyring.Resolve(PrivID2().Address()); !to.Equal(PrivID2().Address()) {
+		t.Errorf("Resolve of other identity got %s", to)
+	}
+
+	store := identity.NewMemoryPubKeyStore()
+	store.Put(newID.Public(), when, time.Hour)
+	reply := &Bitmessage{Content: &format.Encoding2{Subject: "Re", Body: "Noted."}}
+	sent, err := SignAndEncryptMessageTo(when.Add(time.Hour), reply, nil, PrivID2(),
+		PrivID1().Address(), keyring, store)
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessageTo error %v", err)
+	}
+	if _, err = TryDecryptAndVerifyMessage(sent.Object(), newID); err != nil {
+		t.Errorf("message was not sent to the new identity: %v", err)
+	}
+
+	// Older rotations, rotations back to where they started and rotations
+	// which do not verify are ignored.
+	rotation := func(old *identity.PrivateID, new *identity.PrivateID,
+		created time.Time) *identity.Rotation {
+		r, err := identity.NewRotation(old, new.Address(), created)
+		if err != nil {
+			t.Fatalf("NewRotation error %v", err)
+		}
+		return r
+	}
+	if keyring.AddRotation(rotation(PrivID1(), PrivID2(), when.Add(-time.Hour))) {
+		t.Error("AddRotation recorded an older rotation")
+	}
+	if keyring.AddRotation(rotation(newID, PrivID1(), when.Add(time.Hour))) {
+		t.Error("AddRotation recorded a cycle")
+	}
+	forged := rotation(newID, PrivID2(), when.Add(time.Hour))
+	forged.Created = when.Add(2 * time.Hour)
+	if keyring.AddRotation(forged) {
+		t.Error("AddRotation recorded a rotation which does not verify")
+	}
+	if !keyring.AddRotation(rotation(newID, PrivID2(), when.Add(time.Hour))) {
+		t.Error("AddRotation did not record a second rotation")
+	}
+	if to := keyring.Resolve(PrivID1().Address()); !to.Equal(PrivID2().Address()) {
+		t.Errorf("Resolve after two rotations got %s", to)
+	}
+}
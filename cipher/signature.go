@@ -0,0 +1,115 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// Signatures on messages, broadcasts and pubkeys are made over the SHA-256
+// hash of the signed data, but old versions of PyBitmessage signed the SHA-1
+// hash, and for compatibility those are still accepted unless strict
+// signatures have been turned on with SetStrictSignatures. The counts kept
+// by SignatureStats show how often the legacy path is still needed before
+// turning it off.
+
+// ErrLegacySignature is returned in strict mode for a signature which is
+// only valid over the SHA-1 hash of the signed data.
+var ErrLegacySignature = errors.New("signature over SHA-1 hash rejected")
+
+// SignatureHash is the hash function over which a signature was made.
+type SignatureHash int
+
+const (
+	// SignatureSHA256 is what signatures are made over.
+	SignatureSHA256 SignatureHash = iota
+
+	// SignatureSHA1 is what signatures were made over by old clients.
+	SignatureSHA1
+)
+
+func (h SignatureHash) String() string {
+	switch h {
+	case SignatureSHA256:
+		return "SHA-256"
+	case SignatureSHA1:
+		return "SHA-1"
+	}
+	return "unknown"
+}
+
+// strictSignatures is 1 when SHA-1 signatures are rejected.
+var strictSignatures int32
+
+// SetStrictSignatures sets whether signatures over SHA-1 hashes are
+// rejected with ErrLegacySignature. It applies to every verification
+// which starts after it returns.
+func SetStrictSignatures(strict bool) {
+	var v int32
+	if strict {
+		v = 1
+	}
+	atomic.StoreInt32(&strictSignatures, v)
+}
+
+// StrictSignatures returns whether signatures over SHA-1 hashes are
+// rejected.
+func StrictSignatures() bool {
+	return atomic.LoadInt32(&strictSignatures) == 1
+}
+
+// SignatureCounts are the numbers of valid signatures that have been
+// checked, by the hash they were made over.
+type SignatureCounts struct {
+	SHA256 uint64
+	SHA1   uint64
+
+	// Rejected is the number of SHA-1 signatures rejected in strict mode.
+	// They are not counted in SHA1.
+	Rejected uint64
+}
+
+var signatureCounts SignatureCounts
+
+// SignatureStats returns the numbers of valid signatures that have been
+// checked since the program started.
+func SignatureStats() SignatureCounts {
+	return SignatureCounts{
+		SHA256:   atomic.LoadUint64(&signatureCounts.SHA256),
+		SHA1:     atomic.LoadUint64(&signatureCounts.SHA1),
+		Rejected: atomic.LoadUint64(&signatureCounts.Rejected),
+	}
+}
+
+// verifyHash checks that sig is a signature of data by key and returns the
+// hash it was made over.
+func verifyHash(data, sig []byte, key *btcec.PublicKey) (SignatureHash, error) {
+	s, err := btcec.ParseSignature(sig, btcec.S256())
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+
+	hash := sha256.Sum256(data)
+	if s.Verify(hash[:], key) {
+		atomic.AddUint64(&signatureCounts.SHA256, 1)
+		return SignatureSHA256, nil
+	}
+
+	sha1hash := sha1.Sum(data)
+	if !s.Verify(sha1hash[:], key) {
+		return 0, ErrInvalidSignature
+	}
+	if StrictSignatures() {
+		atomic.AddUint64(&signatureCounts.Rejected, 1)
+		return 0, ErrLegacySignature
+	}
+	atomic.AddUint64(&signatureCounts.SHA1, 1)
+	return SignatureSHA1, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestStrictSignatures(t *testing.T) {
+	defer SetStrictSignatures(false)
+
+	data := []byte("some signed data")
+	key := PrivKey1().Signing
+	sum256 := sha256.Sum256(data)
+	sum1 := sha1.Sum(data)
+	sig256, _ := key.Sign(sum256[:])
+	sig1, _ := key.Sign(sum1[:])
+	other, _ := PrivKey2().Signing.Sign(sum256[:])
+
+	tests := []struct {
+		sig    []byte
+		strict bool
+		hash   SignatureHash
+		err    error
+	}{
+		{sig256.Serialize(), false, SignatureSHA256, nil},
+		{sig256.Serialize(), true, SignatureSHA256, nil},
+		{sig1.Serialize(), false, SignatureSHA1, nil},
+		{sig1.Serialize(), true, 0, ErrLegacySignature},
+		{other.Serialize(), false, 0, ErrInvalidSignature},
+		{other.Serialize(), true, 0, ErrInvalidSignature},
+		{[]byte{1, 2, 3}, false, 0, ErrInvalidSignature},
+	}
+
+	for i, test := range tests {
+		SetStrictSignatures(test.strict)
+		if StrictSignatures() != test.strict {
+			t.Fatalf("#%d StrictSignatures got %v", i, !test.strict)
+		}
+
+		before := SignatureStats()
+		hash, err := TstVerifyHash(data, test.sig, key.PubKey())
+		if err != test.err {
+			t.Errorf("#%d got error %v want %v", i, err, test.err)
+			continue
+		}
+		if err == nil && hash != test.hash {
+			t.Errorf("#%d got hash %s want %s", i, hash, test.hash)
+		}
+
+		// The counts are of the hashes which validated.
+		after := SignatureStats()
+		want := before
+		switch {
+		case test.err == ErrLegacySignature:
+			want.Rejected++
+		case test.err != nil:
+		case hash == SignatureSHA1:
+			want.SHA1++
+		default:
+			want.SHA256++
+		}
+		if after != want {
+			t.Errorf("#%d got counts %+v want %+v", i, after, want)
+		}
+	}
+}
+
+func TestMessageSignatureHash(t *testing.T) {
+	SetStrictSignatures(true)
+	defer SetStrictSignatures(false)
+
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	bm := &Bitmessage{
+		Public:      PrivID1().Public(),
+		Destination: PrivID2().Address().RipeHash(),
+		Content:     &format.Encoding2{Subject: "Hi", Body: "Hey there!"},
+	}
+	message, err := SignAndEncryptMessage(expires, 1, bm, []byte{}, PrivKey1(),
+		PrivID2().Public().Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signatures made by this package pass in strict mode.
+	got, err := TryDecryptAndVerifyMessage(message.Object(), PrivID2())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SignatureHash() != SignatureSHA256 {
+		t.Errorf("SignatureHash got %s", got.SignatureHash())
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// remoteSigner stands in for a key held somewhere else.
+type remoteSigner struct {
+	key   *btcec.PrivateKey
+	calls int
+}
+
+func (s *remoteSigner) Sign(hash []byte) (*btcec.Signature, error) {
+	s.calls++
+	return s.key.Sign(hash)
+}
+
+func (s *remoteSigner) PubKey() *btcec.PublicKey {
+	return s.key.PubKey()
+}
+
+func TestSigner(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	signer := &remoteSigner{key: PrivID1().PrivateKey().Signing}
+	content := &format.Encoding2{Subject: "Hi", Body: "Signed elsewhere."}
+
+	bm := &Bitmessage{
+		Public:      PrivID1().Public(),
+		Destination: PrivID2().Address().RipeHash(),
+		Content:     content,
+	}
+	msg, err := SignAndEncryptMessageWithSigner(expires, 1, bm, nil, signer,
+		PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("SignAndEncryptMessageWithSigner got error %v", err)
+	}
+	if _, err = TryDecryptAndVerifyMessage(msg.Object(), PrivID2()); err != nil {
+		t.Errorf("TryDecryptAndVerifyMessage got error %v", err)
+	}
+
+	bm = &Bitmessage{
+		Public:  PrivID1().Public(),
+		Content: content,
+	}
+	broadcast, err := SignAndEncryptBroadcastWithSigner(expires, bm,
+		Tag(PrivID1().Address()), signer)
+	if err != nil {
+		t.Fatalf("SignAndEncryptBroadcastWithSigner got error %v", err)
+	}
+	if _, err = TryDecryptAndVerifyBroadcast(broadcast.Object(), PrivID1().Address()); err != nil {
+		t.Errorf("TryDecryptAndVerifyBroadcast got error %v", err)
+	}
+
+	pubkey, err := GeneratePubKeyWithSigner(PrivID1().Public(), signer, time.Minute*5)
+	if err != nil {
+		t.Fatalf("GeneratePubKeyWithSigner got error %v", err)
+	}
+	if _, err = TryDecryptAndVerifyPubKey(pubkey.Object(), PrivID1().Address()); err != nil {
+		t.Errorf("TryDecryptAndVerifyPubKey got error %v", err)
+	}
+
+	if signer.calls != 3 {
+		t.Errorf("signer was called %d times, want 3", signer.calls)
+	}
+
+	// A signer for someone else is refused before anything is signed.
+	other := &remoteSigner{key: PrivID2().PrivateKey().Signing}
+	if _, err = SignAndEncryptBroadcastWithSigner(expires, bm, Tag(PrivID1().Address()),
+		other); err != ErrSignerMismatch {
+		t.Errorf("SignAndEncryptBroadcastWithSigner got %v want %v", err,
+			ErrSignerMismatch)
+	}
+	if _, err = GeneratePubKeyWithSigner(PrivID1().Public(), other, time.Minute); err != ErrSignerMismatch {
+		t.Errorf("GeneratePubKeyWithSigner got %v want %v", err, ErrSignerMismatch)
+	}
+	if other.calls != 0 {
+		t.Errorf("mismatched signer was called")
+	}
+}
+
+// TestDeterministicSignatures checks that signing the same thing twice gives
+// the same signature, even though the objects are encrypted differently.
+func TestDeterministicSignatures(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	content := &format.Encoding2{Subject: "Hi", Body: "Same every time."}
+
+	bm := &Bitmessage{
+		Public:      PrivID1().Public(),
+		Destination: PrivID2().Address().RipeHash(),
+		Content:     content,
+	}
+	var sigs [2][]byte
+	for i := range sigs {
+		msg, err := SignAndEncryptMessage(expires, 1, bm, []byte{}, PrivKey1(),
+			PrivID2().Public().Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs[i] = msg.Signature()
+	}
+	if !bytes.Equal(sigs[0], sigs[1]) {
+		t.Errorf("message signatures differ: %x and %x", sigs[0], sigs[1])
+	}
+
+	bm = &Bitmessage{
+		Public:  PrivID1().Public(),
+		Content: content,
+	}
+	for i := range sigs {
+		broadcast, err := SignAndEncryptBroadcast(expires, bm,
+			Tag(PrivID1().Address()), PrivID1())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs[i] = broadcast.Signature()
+	}
+	if !bytes.Equal(sigs[0], sigs[1]) {
+		t.Errorf("broadcast signatures differ: %x and %x", sigs[0], sigs[1])
+	}
+
+	for i := range sigs {
+		r, err := identity.NewRotation(PrivID1(), PrivID2().Address(), expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs[i] = []byte(r.String())
+	}
+	if !bytes.Equal(sigs[0], sigs[1]) {
+		t.Errorf("rotation signatures differ: %x and %x", sigs[0], sigs[1])
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package testharness
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// Delivery is a message or broadcast which a client has decrypted and
+// verified.
+type Delivery struct {
+	// Recipient is the identity a message was sent to, and nil for a
+	// broadcast.
+	Recipient *identity.PrivateID
+
+	// Sender is the address the message or broadcast came from.
+	Sender bmutil.Address
+
+	Bitmessage *cipher.Bitmessage
+}
+
+// Outgoing is a message sent with Client.Send.
+type Outgoing struct {
+	From    *identity.PrivateID
+	To      bmutil.Address
+	Content format.Encoding
+
+	// AckData is the ackdata the recipient will send back, derived with
+	// cipher.DeriveAckData.
+	AckData []byte
+
+	acked chan struct{}
+}
+
+// Acked returns a channel which is closed when the ack for the message
+// arrives.
+func (o *Outgoing) Acked() <-chan struct{} {
+	return o.acked
+}
+
+// Client is a Bitmessage client on a Network. It answers getpubkey requests
+// for its identities, receives messages for them and broadcasts from its
+// subscriptions, and sends acks. Objects are handled one at a time by a
+// goroutine of the client's own.
+type Client struct {
+	Name    string
+	Keyring *identity.Keyring
+
+	// Inbox receives everything the client decrypts. It is buffered, but
+	// it should be read from or the client may stop.
+	Inbox <-chan *Delivery
+
+	net   *Network
+	in    chan obj.Object
+	inbox chan *Delivery
+	done  chan struct{}
+
+	mtx       sync.Mutex
+	inventory map[hash.Sha]obj.Object
+	pubkeys   map[string]identity.Public
+	waiting   map[string][]*Outgoing
+	acks      map[string]*Outgoing
+}
+
+// NewClient adds a client to the network with an empty keyring.
+func (n *Network) NewClient(name string) *Client {
+	inbox := make(chan *Delivery, 16)
+	c := &Client{
+		Name:      name,
+		Keyring:   identity.NewKeyring(),
+		Inbox:     inbox,
+		net:       n,
+		in:        make(chan obj.Object, 16),
+		inbox:     inbox,
+		done:      make(chan struct{}),
+		inventory: make(map[hash.Sha]obj.Object),
+		pubkeys:   make(map[string]identity.Public),
+		waiting:   make(map[string][]*Outgoing),
+		acks:      make(map[string]*Outgoing),
+	}
+
+	n.mtx.Lock()
+	n.clients = append(n.clients, c)
+	n.mtx.Unlock()
+
+	go c.run()
+	return c
+}
+
+// AddPublic makes the client know a public identity, so that messages to it
+// can be sent without asking for its pubkey.
+func (c *Client) AddPublic(pub identity.Public) {
+	c.mtx.Lock()
+	c.pubkeys[pub.Address().String()] = pub
+	c.mtx.Unlock()
+}
+
+// Public returns the public identity the client knows for an address, or
+// nil.
+func (c *Client) Public(addr bmutil.Address) identity.Public {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.pubkeys[addr.String()]
+}
+
+// Has returns whether the client has the object with the given inventory
+// hash.
+func (c *Client) Has(invHash *hash.Sha) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	_, ok := c.inventory[*invHash]
+	return ok
+}
+
+// InventoryLen returns the number of objects the client has.
+func (c *Client) InventoryLen() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.inventory)
+}
+
+// Send sends a message from one of the client's identities. If the
+// recipient's pubkey is not known, a getpubkey is relayed and the message is
+// sent when the pubkey arrives.
+func (c *Client) Send(from *identity.PrivateID, to bmutil.Address,
+	content format.Encoding) (*Outgoing, error) {
+
+	ackData, err := cipher.DeriveAckData(from.PrivateKey(), to, content)
+	if err != nil {
+		return nil, err
+	}
+	out := &Outgoing{
+		From:    from,
+		To:      to,
+		Content: content,
+		AckData: ackData,
+		acked:   make(chan struct{}),
+	}
+
+	c.mtx.Lock()
+	c.acks[string(ackData)] = out
+	pub, ok := c.pubkeys[to.String()]
+	if !ok {
+		c.waiting[to.String()] = append(c.waiting[to.String()], out)
+	}
+	c.mtx.Unlock()
+
+	if ok {
+		return out, c.compose(out, pub)
+	}
+
+	getpubkey, err := cipher.CreateGetPubKey(to, c.expiration())
+	if err != nil {
+		return out, err
+	}
+	c.net.doPow(getpubkey)
+	return out, c.net.Relay(c, getpubkey)
+}
+
+// Broadcast sends a broadcast from one of the client's identities to its
+// subscribers.
+func (c *Client) Broadcast(from *identity.PrivateID, content format.Encoding) error {
+	bm := &cipher.Bitmessage{
+		Public:  from.Public(),
+		Content: content,
+	}
+	broadcast, err := cipher.SignAndEncryptBroadcast(c.expiration(), bm,
+		bmutil.Tag(from.Address()), from)
+	if err != nil {
+		return err
+	}
+
+	o := broadcast.Object()
+	c.net.doPow(o)
+	return c.net.Relay(c, o)
+}
+
+func (c *Client) expiration() time.Time {
+	return time.Now().Add(c.net.TTL).Truncate(time.Second)
+}
+
+// compose signs and encrypts a message to a recipient whose pubkey is known,
+// along with its ack, and relays it.
+func (c *Client) compose(out *Outgoing, to identity.Public) error {
+	expiration := c.expiration()
+	stream := to.Address().Stream()
+
+	// The proof of work for the ack is done by the sender, so that the
+	// recipient can send it at once.
+	ack := cipher.NewAck(expiration, stream, out.AckData, c.net.Pow)
+
+	bm := &cipher.Bitmessage{
+		Public:      out.From.Public(),
+		Destination: to.Address().RipeHash(),
+		Content:     out.Content,
+	}
+	msg, err := cipher.SignAndEncryptMessage(expiration, stream, bm,
+		wire.Encode(ack), out.From.PrivateKey(), to.Key())
+	if err != nil {
+		return err
+	}
+
+	o := msg.Object()
+	c.net.doPow(o)
+	return c.net.Relay(c, o)
+}
+
+// store adds an object to the inventory and reports whether it is new.
+func (c *Client) store(o obj.Object) bool {
+	invHash := obj.InventoryHash(o)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.inventory[*invHash]; ok {
+		return false
+	}
+	c.inventory[*invHash] = o
+	return true
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+	for {
+		select {
+		case o := <-c.in:
+			if !c.store(o) {
+				continue
+			}
+			if err := c.handle(o); err != nil {
+				c.net.fail(c, err)
+			}
+		case <-c.net.quit:
+			return
+		}
+	}
+}
+
+func (c *Client) handle(o obj.Object) error {
+	switch o := o.(type) {
+	case *obj.GetPubKey:
+		return c.handleGetPubKey(o)
+	case *obj.Message:
+		return c.handleMessage(o)
+	case obj.Broadcast:
+		return c.handleBroadcast(o)
+	}
+	if o.Header().ObjectType == wire.ObjectTypePubKey {
+		return c.handlePubKey(o)
+	}
+	return nil
+}
+
+// handleGetPubKey answers a request for the pubkey of one of the client's
+// identities.
+func (c *Client) handleGetPubKey(o *obj.GetPubKey) error {
+	for _, id := range c.Keyring.Privates() {
+		if !cipher.MatchesIdentity(o, id) {
+			continue
+		}
+
+		pubkey, err := cipher.GeneratePubKey(id, c.net.TTL)
+		if err != nil {
+			return err
+		}
+		p := pubkey.Object()
+		c.net.doPow(p)
+		return c.net.Relay(c, p)
+	}
+	return nil
+}
+
+// handlePubKey takes the pubkey of a recipient the client is waiting on and
+// sends the messages waiting for it.
+func (c *Client) handlePubKey(o obj.Object) error {
+	c.mtx.Lock()
+	var addrs []bmutil.Address
+	for _, waiting := range c.waiting {
+		addrs = append(addrs, waiting[0].To)
+	}
+	c.mtx.Unlock()
+
+	for _, addr := range addrs {
+		pubkey, err := cipher.TryDecryptAndVerifyPubKey(o, addr)
+		if err != nil {
+			continue
+		}
+		pub, err := cipher.ToIdentity(pubkey)
+		if err != nil {
+			return err
+		}
+
+		c.mtx.Lock()
+		c.pubkeys[addr.String()] = pub
+		waiting := c.waiting[addr.String()]
+		delete(c.waiting, addr.String())
+		c.mtx.Unlock()
+
+		for _, out := range waiting {
+			if err = c.compose(out, pub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// handleMessage recognizes acks for the client's messages and decrypts
+// messages to its identities, relaying their acks.
+func (c *Client) handleMessage(o *obj.Message) error {
+	c.mtx.Lock()
+	out, ok := c.acks[string(o.Payload())]
+	if ok {
+		delete(c.acks, string(o.Payload()))
+	}
+	c.mtx.Unlock()
+	if ok {
+		close(out.acked)
+		return nil
+	}
+
+	msg, id, err := cipher.TryDecryptMessage(o, c.Keyring)
+	if err == cipher.ErrInvalidIdentity {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	bm := msg.Bitmessage()
+	c.deliver(&Delivery{
+		Recipient:  id,
+		Sender:     bm.Public.Address(),
+		Bitmessage: bm,
+	})
+
+	if id.Behavior()&identity.BehaviorAck == 0 || len(msg.Ack()) == 0 {
+		return nil
+	}
+	ack, err := msg.AckObject()
+	if err != nil {
+		return err
+	}
+	return c.net.Relay(c, ack)
+}
+
+func (c *Client) handleBroadcast(o obj.Broadcast) error {
+	broadcast, addr, err := cipher.TryDecryptBroadcast(o, c.Keyring)
+	if err == cipher.ErrInvalidIdentity {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.deliver(&Delivery{
+		Sender:     addr,
+		Bitmessage: broadcast.Bitmessage(),
+	})
+	return nil
+}
+
+func (c *Client) deliver(d *Delivery) {
+	select {
+	case c.inbox <- d:
+	case <-c.net.quit:
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package testharness_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/cipher/testharness"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func receive(t *testing.T, net *testharness.Network, c *testharness.Client) *testharness.Delivery {
+	select {
+	case d := <-c.Inbox:
+		return d
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s received nothing; errors %v", c.Name, net.Errors())
+		return nil
+	}
+}
+
+func TestMessageAndAck(t *testing.T) {
+	net := testharness.NewNetwork()
+	defer net.Close()
+
+	alice, bob := net.NewClient("alice"), net.NewClient("bob")
+	aliceID, err := net.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobID, err := net.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.Keyring.AddPrivate(aliceID)
+	bob.Keyring.AddPrivate(bobID)
+
+	content := &format.Encoding2{Subject: "Hello", Body: "How are you?"}
+	out, err := alice.Send(aliceID, bobID.Address(), content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := receive(t, net, bob)
+	if d.Recipient.Address().String() != bobID.Address().String() {
+		t.Errorf("got recipient %s", d.Recipient.Address())
+	}
+	if d.Sender.String() != aliceID.Address().String() {
+		t.Errorf("got sender %s", d.Sender)
+	}
+	if got, ok := d.Bitmessage.Content.(*format.Encoding2); !ok ||
+		got.Subject != content.Subject || got.Body != content.Body {
+		t.Errorf("got content %v", d.Bitmessage.Content)
+	}
+
+	select {
+	case <-out.Acked():
+	case <-time.After(time.Minute):
+		t.Fatal("no ack")
+	}
+
+	// getpubkey, pubkey, msg and ack.
+	relayed := net.Relayed()
+	expected := []wire.ObjectType{wire.ObjectTypeGetPubKey,
+		wire.ObjectTypePubKey, wire.ObjectTypeMsg, wire.ObjectTypeMsg}
+	if len(relayed) != len(expected) {
+		t.Fatalf("got %d objects relayed, want %d", len(relayed), len(expected))
+	}
+	for i, o := range relayed {
+		if o.Header().ObjectType != expected[i] {
+			t.Errorf("object %d: got %s, want %s", i, o.Header().ObjectType, expected[i])
+		}
+		if !alice.Has(obj.InventoryHash(o)) {
+			t.Errorf("object %d not in alice's inventory", i)
+		}
+	}
+
+	// Now that the pubkey is known, a second message goes straight out.
+	if alice.Public(bobID.Address()) == nil {
+		t.Fatal("alice did not keep bob's pubkey")
+	}
+	out, err = alice.Send(aliceID, bobID.Address(), &format.Encoding1{Body: "again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, net, bob)
+	<-out.Acked()
+	if n := len(net.Relayed()); n != 6 {
+		t.Errorf("got %d objects relayed, want 6", n)
+	}
+
+	if errs := net.Errors(); len(errs) != 0 {
+		t.Errorf("got errors %v", errs)
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	net := testharness.NewNetwork()
+	defer net.Close()
+
+	alice, bob, carol := net.NewClient("alice"), net.NewClient("bob"),
+		net.NewClient("carol")
+	aliceID, err := net.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.Keyring.AddPrivate(aliceID)
+	bob.Keyring.AddSubscription(aliceID.Address(), "alice")
+
+	if err = alice.Broadcast(aliceID, &format.Encoding2{Subject: "News", Body: "..."}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := receive(t, net, bob)
+	if d.Recipient != nil || d.Sender.String() != aliceID.Address().String() {
+		t.Errorf("got delivery %v", d)
+	}
+
+	select {
+	case d := <-carol.Inbox:
+		t.Errorf("carol is not subscribed but got %v", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if carol.InventoryLen() != 1 {
+		t.Errorf("carol has %d objects, want 1", carol.InventoryLen())
+	}
+}
+
+func TestRelayInsufficientPow(t *testing.T) {
+	net := testharness.NewNetwork()
+	defer net.Close()
+
+	id, err := net.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := obj.NewGetPubKey(0, time.Now().Add(time.Hour), id.Address())
+	if err = net.Relay(nil, o); err != testharness.ErrInsufficientPow {
+		t.Errorf("got %v", err)
+	}
+}
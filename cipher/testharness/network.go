@@ -0,0 +1,180 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package testharness runs Bitmessage clients in memory for integration
+// tests. Clients on a Network send each other messages and broadcasts
+// through the whole protocol: the sender asks for the recipient's pubkey,
+// composes and signs the message, does the proof of work and relays it, and
+// the recipient decrypts and verifies it and relays the ack. Objects travel
+// between clients over channels, so no sockets or databases are needed.
+//
+// The proof of work is real but, by default, much easier than on the real
+// network, so that tests run quickly.
+package testharness
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+var (
+	// ErrInsufficientPow is returned by Network.Relay for an object
+	// without enough proof of work.
+	ErrInsufficientPow = errors.New("insufficient proof of work")
+
+	// ErrClosed is returned when a closed Network is used.
+	ErrClosed = errors.New("network closed")
+)
+
+// DefaultPow is the proof of work used by a new Network. It is about a
+// thousand times easier than pow.Default.
+var DefaultPow = pow.Data{
+	NonceTrialsPerByte: 1,
+	ExtraBytes:         1,
+}
+
+// Network connects clients, standing in for the peer-to-peer network. Every
+// object relayed by one client is delivered to all the others.
+type Network struct {
+	// Pow is the proof of work that objects on the network must have.
+	Pow pow.Data
+
+	// TTL is how long objects created by clients live for.
+	TTL time.Duration
+
+	mtx     sync.Mutex
+	clients []*Client
+	relayed []obj.Object
+	errs    []error
+	wg      sync.WaitGroup
+	quit    chan struct{}
+}
+
+// NewNetwork returns a Network with no clients on it, DefaultPow and a TTL
+// of an hour.
+func NewNetwork() *Network {
+	return &Network{
+		Pow:  DefaultPow,
+		TTL:  time.Hour,
+		quit: make(chan struct{}),
+	}
+}
+
+// NewIdentity creates a random identity which asks for acks.
+func (n *Network) NewIdentity() (*identity.PrivateID, error) {
+	key, err := identity.NewRandom(1)
+	if err != nil {
+		return nil, err
+	}
+	addr := identity.NewPrivateAddress(key, bmutil.DefaultAddressVersion,
+		bmutil.DefaultStream)
+	return identity.NewPrivateID(addr, identity.BehaviorAck, nil), nil
+}
+
+// Relay checks the proof of work of an object and delivers it to every
+// client other than from, which may be nil. The object is also added to
+// from's inventory. Each client gets its own copy, decoded from the encoded
+// object as it would be from the network.
+func (n *Network) Relay(from *Client, o obj.Object) error {
+	encoded := wire.Encode(o)
+	msg, err := wire.DecodeMsgObject(encoded)
+	if err != nil {
+		return err
+	}
+	if !msg.CheckPow(n.Pow, time.Now()) {
+		return ErrInsufficientPow
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	select {
+	case <-n.quit:
+		return ErrClosed
+	default:
+	}
+
+	n.relayed = append(n.relayed, o)
+	if from != nil {
+		from.store(o)
+	}
+	for _, c := range n.clients {
+		if c == from {
+			continue
+		}
+		received, err := obj.ReadObject(encoded)
+		if err != nil {
+			return err
+		}
+		n.wg.Add(1)
+		go func(c *Client) {
+			defer n.wg.Done()
+			select {
+			case c.in <- received:
+			case <-n.quit:
+			}
+		}(c)
+	}
+	return nil
+}
+
+// Relayed returns every object relayed so far, in order.
+func (n *Network) Relayed() []obj.Object {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]obj.Object(nil), n.relayed...)
+}
+
+// Errors returns the errors clients have run into while handling objects.
+// There should be none.
+func (n *Network) Errors() []error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]error(nil), n.errs...)
+}
+
+func (n *Network) fail(c *Client, err error) {
+	n.mtx.Lock()
+	n.errs = append(n.errs, fmt.Errorf("%s: %v", c.Name, err))
+	n.mtx.Unlock()
+}
+
+// Close stops all clients and waits for them.
+func (n *Network) Close() {
+	n.mtx.Lock()
+	select {
+	case <-n.quit:
+		n.mtx.Unlock()
+		return
+	default:
+	}
+	close(n.quit)
+	clients := n.clients
+	n.mtx.Unlock()
+
+	n.wg.Wait()
+	for _, c := range clients {
+		<-c.done
+	}
+}
+
+// doPow does the proof of work the network requires for an object. This is
+// done even for messages to identities which ask for more, since they cannot
+// ask for less than pow.Default.
+func (n *Network) doPow(o obj.Object) {
+	header := o.Header()
+	encoded := wire.Encode(o)
+	ttl := uint64(header.Expiration().Unix() - time.Now().Unix())
+	target := pow.CalculateTarget(uint64(len(encoded)), ttl, n.Pow)
+	header.Nonce = pow.Do(target, hash.Sha512(encoded[8:]))
+}
@@ -0,0 +1,72 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// TryDecryptBroadcast tries to decrypt and verify a broadcast with each of
+// the enabled subscriptions in the keyring. It returns the broadcast along
+// with the address it came from, or ErrInvalidIdentity if it is not from any
+// of them. A tagged broadcast is only tried with the subscription that has
+// its tag, which the keyring finds in its index. A tagless broadcast carries
+// nothing to look up, so it is tried with each subscription to an address
+// old enough to send one, as TryDecryptBroadcastFromBook does. If the
+// keyring keeps stats, the subscription's counters are updated. Key
+// rotations are recorded as by TryDecryptMessage.
+func TryDecryptBroadcast(msg obj.Broadcast, keyring *identity.Keyring) (*Broadcast, bmutil.Address, error) {
+	broadcast, addr, _, err := TryDecryptBroadcastWithPolicy(msg, keyring, nil)
+	return broadcast, addr, err
+}
+
+// TryDecryptBroadcastWithPolicy is like TryDecryptBroadcast, except that once
+// the broadcast has been decrypted it is passed to the policy along with the
+// subscription it came from, in the same way as for
+// TryDecryptMessageWithPolicy.
+func TryDecryptBroadcastWithPolicy(msg obj.Broadcast, keyring *identity.Keyring,
+	policy SenderPolicy) (*Broadcast, bmutil.Address, Verdict, error) {
+
+	var subs []*identity.Subscription
+	if tagged, ok := msg.(*obj.TaggedBroadcast); ok {
+		if sub := keyring.SubscriptionByTag(tagged.Tag); sub != nil {
+			subs = append(subs, sub)
+		}
+	} else {
+		for _, sub := range keyring.Subscriptions().List() {
+			// Addresses from version 4 on send tagged broadcasts.
+			if sub.Address.Version() < 4 {
+				subs = append(subs, sub)
+			}
+		}
+	}
+
+	for _, sub := range subs {
+		if !sub.Enabled {
+			continue
+		}
+
+		broadcast, err := TryDecryptAndVerifyBroadcast(msg, sub.Address)
+		if err == ErrInvalidIdentity {
+			continue
+		}
+		if err != nil {
+			return nil, nil, Drop, err
+		}
+
+		verdict := policy.judge(sub.Address, broadcast.Bitmessage())
+		if verdict == Drop {
+			return nil, nil, Drop, ErrDropped
+		}
+
+		keyring.RecordBroadcast(sub.Address.String())
+		recordRotation(keyring, broadcast.Bitmessage())
+		return broadcast, sub.Address, verdict, nil
+	}
+
+	return nil, nil, Drop, ErrInvalidIdentity
+}
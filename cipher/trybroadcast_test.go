@@ -0,0 +1,75 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestTryDecryptBroadcast(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	broadcast, err := SignAndEncryptBroadcast(
+		TstBroadcastEncryptParams(t, expires, 1, Tag(PrivID1().Address()), 4, 1, 1,
+			SignKey1, EncKey1, 1000, 1000, 1, []byte("Hey there!"), PrivID1()))
+	if err != nil {
+		t.Fatalf("for SignAndEncryptBroadcast got error %v", err)
+	}
+
+	keyring := identity.NewKeyring()
+	keyring.AddPrivate(PrivID1())
+
+	// The broadcast cannot be decrypted without a subscription.
+	if _, _, err = TryDecryptBroadcast(broadcast.Object(), keyring); err != ErrInvalidIdentity {
+		t.Errorf("TryDecryptBroadcast got error %v want %v", err, ErrInvalidIdentity)
+	}
+
+	keyring.EnableStats()
+	keyring.AddSubscription(PrivID1().Address(), "")
+
+	_, addr, err := TryDecryptBroadcast(broadcast.Object(), keyring)
+	if err != nil {
+		t.Fatalf("TryDecryptBroadcast got error %v", err)
+	}
+	if addr.String() != PrivID1().Address().String() {
+		t.Errorf("TryDecryptBroadcast got address %s want %s", addr,
+			PrivID1().Address())
+	}
+
+	if s := keyring.StatsSnapshot()[PrivID1().Address().String()]; s.MessagesReceived != 0 ||
+		s.BroadcastsDecrypted != 1 || s.LastActivity.IsZero() {
+		t.Errorf("stats for subscription got %+v", s)
+	}
+}
+
+func TestKeyringDecryptTagless(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	bm := &Bitmessage{
+		Public:  PrivID1().Public(),
+		Content: &format.Encoding2{Subject: "Hi", Body: "Hey there!"},
+	}
+	broadcast, err := CreateTaglessBroadcast(expires, bm, PrivID1())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The broadcast can be decrypted with the address it is from.
+	if _, err = TryDecryptAndVerifyBroadcast(broadcast.Object(), PrivID1().Address()); err != nil {
+		t.Fatalf("TryDecryptAndVerifyBroadcast got error %v", err)
+	}
+
+	// But a v4 address sends tagged broadcasts, so the keyring does not try
+	// it for a tagless one.
+	keyring := identity.NewKeyring()
+	keyring.AddSubscription(PrivID1().Address(), "")
+	if _, _, err = TryDecryptBroadcast(broadcast.Object(), keyring); err != ErrInvalidIdentity {
+		t.Errorf("TryDecryptBroadcast got error %v want %v", err, ErrInvalidIdentity)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// TryDecryptMessage tries to decrypt and verify a msg object with each of
+// the private identities in the keyring. It returns the message along with
+// the identity it was addressed to, or ErrInvalidIdentity if none of them
+// could decrypt it. If the keyring keeps stats, the identity's counters are
+// updated. If the message announces a key rotation by its sender, the
+// rotation is recorded in the keyring.
+func TryDecryptMessage(msg *obj.Message, keyring *identity.Keyring) (*Message, *identity.PrivateID, error) {
+	message, id, _, err := TryDecryptMessageWithPolicy(msg, keyring, nil)
+	return message, id, err
+}
+
+// TryDecryptMessageWithPolicy is like TryDecryptMessage, except that once the
+// message has been decrypted it is passed to the policy along with its
+// sender. If the verdict is Drop, ErrDropped is returned and the message is
+// not counted in the keyring's stats. Otherwise the message is returned
+// along with the verdict.
+func TryDecryptMessageWithPolicy(msg *obj.Message, keyring *identity.Keyring,
+	policy SenderPolicy) (*Message, *identity.PrivateID, Verdict, error) {
+	return tryDecryptMessage(msg, keyring, policy, 1)
+}
+
+// TrialOptions are the options for TryDecryptMessageParallel.
+type TrialOptions struct {
+	// Workers is the number of goroutines trying identities. If not
+	// positive, runtime.NumCPU() is used.
+	Workers int
+
+	// Policy judges the message once it has been decrypted, as for
+	// TryDecryptMessageWithPolicy. If nil, every message is accepted.
+	Policy SenderPolicy
+}
+
+// TryDecryptMessageParallel is like TryDecryptMessage, but shares the
+// identities in the keyring among several workers, which is worth it for a
+// node with many identities since a msg object gives no hint of which one
+// it is for. The workers stop as soon as one of them finds the recipient.
+func TryDecryptMessageParallel(msg *obj.Message, keyring *identity.Keyring,
+	opts *TrialOptions) (*Message, *identity.PrivateID, error) {
+
+	workers := runtime.NumCPU()
+	var policy SenderPolicy
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		policy = opts.Policy
+	}
+
+	message, id, _, err := tryDecryptMessage(msg, keyring, policy, workers)
+	return message, id, err
+}
+
+func tryDecryptMessage(msg *obj.Message, keyring *identity.Keyring,
+	policy SenderPolicy, workers int) (*Message, *identity.PrivateID, Verdict, error) {
+	if msg.Header().Version != obj.MessageVersion {
+		return nil, nil, Drop, ErrUnsupportedOp
+	}
+
+	id, dec, err := findRecipient(msg, keyring.Privates(), workers)
+	if err != nil {
+		return nil, nil, Drop, err
+	}
+
+	copied, err := copyMessage(msg)
+	if err != nil {
+		return nil, nil, Drop, err
+	}
+	message, err := newDecryptedMessage(copied, dec, id, nil)
+	if err != nil {
+		return nil, nil, Drop, err
+	}
+
+	bm := message.Bitmessage()
+	verdict := policy.judge(bm.Public.Address(), bm)
+	if verdict == Drop {
+		return nil, nil, Drop, ErrDropped
+	}
+
+	keyring.RecordMessage(id.Address().String())
+	recordRotation(keyring, bm)
+	return message, id, verdict, nil
+}
+
+// findRecipient returns the identity which can decrypt msg along with the
+// plaintext, or ErrInvalidIdentity if none of them can. Only the MAC is
+// checked for each identity, so those the message is not for cost no more
+// than a key exchange. Nothing else is done with the message until the
+// recipient has been found.
+func findRecipient(msg *obj.Message, ids []*identity.PrivateID,
+	workers int) (*identity.PrivateID, []byte, error) {
+
+	try := func(id *identity.PrivateID) ([]byte, error) {
+		dec, err := btcec.Decrypt(id.PrivateKey().Decryption, msg.Encrypted)
+		if err == btcec.ErrInvalidMAC {
+			return nil, ErrInvalidIdentity
+		}
+		return dec, err
+	}
+
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+	if workers <= 1 {
+		for _, id := range ids {
+			dec, err := try(id)
+			if err == ErrInvalidIdentity {
+				continue
+			}
+			return id, dec, err
+		}
+		return nil, nil, ErrInvalidIdentity
+	}
+
+	type result struct {
+		id  *identity.PrivateID
+		dec []byte
+		err error
+	}
+
+	// The first result other than a MAC mismatch is kept. Errors other
+	// than that have to do with the message rather than the identity, so
+	// any worker would have got the same one.
+	var once sync.Once
+	var found result
+	var done int32
+	next := int32(-1)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&done) == 0 {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(ids) {
+					return
+				}
+				dec, err := try(ids[i])
+				if err == ErrInvalidIdentity {
+					continue
+				}
+				once.Do(func() {
+					found = result{ids[i], dec, err}
+					atomic.StoreInt32(&done, 1)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if found.id == nil {
+		return nil, nil, ErrInvalidIdentity
+	}
+	if found.err != nil {
+		return nil, nil, found.err
+	}
+	return found.id, found.dec, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cipher_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/cipher"
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+func TestTryDecryptMessage(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	destRipe, _ := hash.NewRipe(PrivID2().Address().RipeHash()[:])
+	message, err := TstSignAndEncryptMessage(t, 0, expires, 1, nil, 4, 1, 1,
+		SignKey1, EncKey1, nil, destRipe, 1, []byte("Hey there!"), []byte{},
+		nil, PrivID1().PrivateKey(), PrivID2().PublicKey())
+	if err != nil {
+		t.Fatalf("for SignAndEncryptMsg got error %v", err)
+	}
+
+	keyring := identity.NewKeyring()
+	keyring.AddPrivate(PrivID1())
+
+	// The message cannot be decrypted yet.
+	if _, _, err = TryDecryptMessage(message.Object(), keyring); err != ErrInvalidIdentity {
+		t.Errorf("TryDecryptMessage got error %v want %v", err, ErrInvalidIdentity)
+	}
+
+	keyring.EnableStats()
+	keyring.AddPrivate(PrivID2())
+
+	_, id, err := TryDecryptMessage(message.Object(), keyring)
+	if err != nil {
+		t.Fatalf("TryDecryptMessage got error %v", err)
+	}
+	if id.Address().String() != PrivID2().Address().String() {
+		t.Errorf("TryDecryptMessage got identity %s want %s", id.Address(),
+			PrivID2().Address())
+	}
+
+	if s := keyring.StatsSnapshot()[PrivID2().Address().String()]; s.MessagesReceived != 1 ||
+		s.BroadcastsDecrypted != 0 || s.LastActivity.IsZero() {
+		t.Errorf("stats for recipient got %+v", s)
+	}
+}
+
+func TestTryDecryptMessageParallel(t *testing.T) {
+	expires := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	bm := &Bitmessage{
+		Public:      PrivID1().Public(),
+		Destination: PrivID2().Address().RipeHash(),
+		Content:     &format.Encoding2{Subject: "Hi", Body: "Hey there!"},
+	}
+	message, err := SignAndEncryptMessage(expires, 1, bm, []byte{}, PrivKey1(),
+		PrivID2().Public().Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the keyring with identities the message is not for, with its
+	// recipient among the last.
+	keyring := identity.NewKeyring()
+	keyring.EnableStats()
+	for i := 0; i < 12; i++ {
+		signing, _ := btcec.NewPrivateKey(btcec.S256())
+		decryption, _ := btcec.NewPrivateKey(btcec.S256())
+		addr := identity.NewPrivateAddress(&identity.PrivateKey{
+			Signing:    signing,
+			Decryption: decryption,
+		}, DefaultAddressVersion, DefaultStream)
+		keyring.AddPrivate(identity.NewPrivateID(addr, identity.BehaviorAck, &pow.Default))
+	}
+
+	// Without the recipient, none of the workers finds it.
+	for _, workers := range []int{1, 3, 100} {
+		_, _, err = TryDecryptMessageParallel(message.Object(), keyring,
+			&TrialOptions{Workers: workers})
+		if err != ErrInvalidIdentity {
+			t.Errorf("TryDecryptMessageParallel with %d workers got error %v want %v",
+				workers, err, ErrInvalidIdentity)
+		}
+	}
+
+	keyring.AddPrivate(PrivID2())
+	keyring.AddPrivate(PrivID1())
+	for _, opts := range []*TrialOptions{nil, {Workers: 1}, {Workers: 5}} {
+		got, id, err := TryDecryptMessageParallel(message.Object(), keyring, opts)
+		if err != nil {
+			t.Errorf("TryDecryptMessageParallel with %v got error %v", opts, err)
+			continue
+		}
+		if !id.Address().Equal(PrivID2().Address()) {
+			t.Errorf("TryDecryptMessageParallel got identity %s want %s",
+				id.Address(), PrivID2().Address())
+		}
+		if got.Bitmessage().Content.Message() == nil {
+			t.Error("TryDecryptMessageParallel got no content")
+		}
+	}
+	if s := keyring.StatsSnapshot()[PrivID2().Address().String()]; s.MessagesReceived != 3 {
+		t.Errorf("stats for recipient got %+v", s)
+	}
+
+	// The policy sees the message once it has been decrypted.
+	drop := func(Address, *Bitmessage) Verdict { return Drop }
+	_, _, err = TryDecryptMessageParallel(message.Object(), keyring,
+		&TrialOptions{Workers: 4, Policy: drop})
+	if err != ErrDropped {
+		t.Errorf("TryDecryptMessageParallel got error %v want %v", err, ErrDropped)
+	}
+}
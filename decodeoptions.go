@@ -0,0 +1,89 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNonCanonicalEncoding is returned in strict mode when decoded data
+	// would not encode back to the same bytes it was decoded from.
+	ErrNonCanonicalEncoding = errors.New("non-canonical encoding")
+
+	// ErrTrailingData is returned in strict mode when there are bytes left
+	// over after a value has been decoded.
+	ErrTrailingData = errors.New("unexpected data after end of value")
+)
+
+// DecodeOptions controls how strictly the decode entry points in wire, obj
+// and cipher treat their input. The zero value, which is also what a nil
+// *DecodeOptions means, accepts everything that the protocol allows and is
+// what the functions without options use.
+type DecodeOptions struct {
+	// StrictVarInts rejects input that is not in canonical form, meaning
+	// that it would not encode back to exactly the same bytes. Integers are
+	// not checked as they are read. Instead, the decoded value is encoded
+	// again and compared with the input, so a variable length integer that
+	// is not written in its shortest form, which is the usual cause, is
+	// reported as ErrNonCanonicalEncoding along with any other difference.
+	StrictVarInts bool
+
+	// StrictTrailingData rejects input that has bytes left over after the
+	// value has been decoded.
+	StrictTrailingData bool
+
+	// MaxSize, if non-zero, is the largest input in bytes that will be
+	// accepted. It can only lower the limits imposed by the protocol.
+	MaxSize int
+
+	// SkipOversized, if non-zero, is the largest payload which is read
+	// and discarded when a message in a stream is larger than the limits
+	// allow, so that the stream is left at the start of the next message
+	// and the connection can carry on. Larger payloads are left unread.
+	SkipOversized int
+}
+
+// StrictDecodeOptions rejects anything that is not exactly what this
+// package would have encoded.
+var StrictDecodeOptions = DecodeOptions{
+	StrictVarInts:      true,
+	StrictTrailingData: true,
+}
+
+// CheckSize returns an error if size exceeds the limit set by the options.
+// fn names the function doing the check for the error message.
+func (o *DecodeOptions) CheckSize(size int, fn string) error {
+	if o == nil || o.MaxSize == 0 || size <= o.MaxSize {
+		return nil
+	}
+	return fmt.Errorf("%s: input of %d bytes exceeds limit of %d bytes",
+		fn, size, o.MaxSize)
+}
+
+// CheckEncoding compares the input that a value was decoded from with the
+// result of encoding it again and returns an error if the options say the
+// difference is not allowed. If the input begins with the encoding and has
+// more after it, the extra bytes are trailing data. Any other difference
+// means the input was not canonical.
+func (o *DecodeOptions) CheckEncoding(input, encoded []byte) error {
+	if o == nil || (!o.StrictVarInts && !o.StrictTrailingData) {
+		return nil
+	}
+
+	if len(input) > len(encoded) && bytes.Equal(input[:len(encoded)], encoded) {
+		if o.StrictTrailingData {
+			return ErrTrailingData
+		}
+		return nil
+	}
+
+	if o.StrictVarInts && !bytes.Equal(input, encoded) {
+		return ErrNonCanonicalEncoding
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	strict := &bmutil.StrictDecodeOptions
+	trailingOnly := &bmutil.DecodeOptions{StrictTrailingData: true}
+	tests := []struct {
+		opts    *bmutil.DecodeOptions
+		input   []byte
+		encoded []byte
+		err     error
+	}{
+		{nil, []byte{1, 2, 3}, []byte{1}, nil},
+		{&bmutil.DecodeOptions{}, []byte{1, 2, 3}, []byte{1}, nil},
+		{strict, []byte{1, 2}, []byte{1, 2}, nil},
+		{strict, []byte{1, 2, 3}, []byte{1, 2}, bmutil.ErrTrailingData},
+		{strict, []byte{0xfd, 0x00, 0x01}, []byte{0x01}, bmutil.ErrNonCanonicalEncoding},
+		{trailingOnly, []byte{0xfd, 0x00, 0x01}, []byte{0x01}, nil},
+		{trailingOnly, []byte{1, 2, 3}, []byte{1, 2}, bmutil.ErrTrailingData},
+	}
+
+	for i, test := range tests {
+		if err := test.opts.CheckEncoding(test.input, test.encoded); err != test.err {
+			t.Errorf("CheckEncoding #%d got error %v want %v", i, err, test.err)
+		}
+	}
+
+	limited := &bmutil.DecodeOptions{MaxSize: 10}
+	if err := limited.CheckSize(10, "test"); err != nil {
+		t.Errorf("CheckSize at limit got error %v", err)
+	}
+	if err := limited.CheckSize(11, "test"); err == nil {
+		t.Errorf("CheckSize over limit got no error")
+	}
+	if err := strict.CheckSize(1<<30, "test"); err != nil {
+		t.Errorf("CheckSize without limit got error %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil_test
+
+import (
+	"fmt"
+
+	"github.com/DanielKrawisz/bmutil"
+)
+
+// This example decodes an address and shows what is in it.
+func ExampleDecodeAddress() {
+	addr, err := bmutil.DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("version:", addr.Version())
+	fmt.Println("stream:", addr.Stream())
+	fmt.Println(addr)
+
+	// Output:
+	// version: 4
+	// stream: 1
+	// BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ
+}
+
+// This example shows the hint given for an address with a typo in it.
+func ExampleSuggestAddressCorrection() {
+	const addr = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BK"
+	_, err := bmutil.DecodeAddress(addr)
+	if err == bmutil.ErrChecksumMismatch {
+		if e := bmutil.SuggestAddressCorrection(addr); e != nil && e.Suggestion != "" {
+			fmt.Println("did you mean", e.Suggestion)
+		}
+	}
+
+	// Output:
+	// did you mean BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ
+}
@@ -0,0 +1,251 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxAttachmentSize is the most data that the attachments of a message
+	// can hold altogether. It is the size of the largest object that the
+	// network carries, so a message which reaches it cannot be sent anyway,
+	// but it keeps a malformed message or a careless caller from holding
+	// much more than that in memory.
+	MaxAttachmentSize = 1 << 18
+
+	// MaxFilenameLength is the longest filename an attachment can have, in
+	// bytes.
+	MaxFilenameLength = 255
+
+	// DefaultContentType is the content type of an attachment whose type
+	// was not given and cannot be told from its filename.
+	DefaultContentType = "application/octet-stream"
+)
+
+var (
+	// ErrAttachmentTooLarge is returned when attachments would hold more
+	// than MaxAttachmentSize bytes.
+	ErrAttachmentTooLarge = errors.New("attachments too large")
+
+	// ErrInvalidFilename is returned for an attachment filename which is
+	// empty, too long, names a directory or contains a path separator or a
+	// control character. Filenames come from whoever sent the message, so
+	// one like "../.profile" must never be used as a path.
+	ErrInvalidFilename = errors.New("invalid attachment filename")
+)
+
+// Attachment is a file carried by an extended encoding message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// NewAttachment returns an attachment holding data. If contentType is
+// empty, it is guessed from the extension of the filename.
+func NewAttachment(filename, contentType string, data []byte) (*Attachment, error) {
+	if !validFilename(filename) {
+		return nil, ErrInvalidFilename
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+		if contentType == "" {
+			contentType = DefaultContentType
+		}
+	}
+
+	return &Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// ReadAttachment reads an attachment with the given name and content type
+// from r. It stops with ErrAttachmentTooLarge rather than read more than
+// MaxAttachmentSize bytes.
+func ReadAttachment(filename, contentType string, r io.Reader) (*Attachment, error) {
+	if !validFilename(filename) {
+		return nil, ErrInvalidFilename
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return NewAttachment(filename, contentType, data)
+}
+
+// OpenAttachment reads the file at path as an attachment named by the last
+// element of the path.
+func OpenAttachment(path string) (*Attachment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadAttachment(filepath.Base(path), "", f)
+}
+
+// Save writes the attachment to a new file with its filename in dir and
+// returns the path of the file. It will not overwrite a file which is
+// already there.
+func (a *Attachment) Save(dir string) (string, error) {
+	if !validFilename(a.Filename) {
+		return "", ErrInvalidFilename
+	}
+
+	path := filepath.Join(dir, a.Filename)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err = f.Write(a.Data); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// Attach adds attachments to the message.
+func (l *Encoding3) Attach(attachments ...*Attachment) error {
+	size := l.attachmentSize()
+	for _, a := range attachments {
+		if !validFilename(a.Filename) {
+			return ErrInvalidFilename
+		}
+		size += len(a.Data)
+	}
+	if size > MaxAttachmentSize {
+		return ErrAttachmentTooLarge
+	}
+
+	l.Attachments = append(l.Attachments, attachments...)
+	return nil
+}
+
+// Attachment returns the attachment with the given filename, or nil if the
+// message has none.
+func (l *Encoding3) Attachment(filename string) *Attachment {
+	for _, a := range l.Attachments {
+		if a.Filename == filename {
+			return a
+		}
+	}
+	return nil
+}
+
+// attachmentSize returns the amount of data in the attachments of the
+// message.
+func (l *Encoding3) attachmentSize() int {
+	size := 0
+	for _, a := range l.Attachments {
+		size += len(a.Data)
+	}
+	return size
+}
+
+// validFilename returns whether name can be used as the name of a file in a
+// directory without reaching outside it.
+func validFilename(name string) bool {
+	if name == "" || name == "." || name == ".." ||
+		len(name) > MaxFilenameLength || strings.ContainsAny(name, `/\:`) {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return false
+		}
+	}
+	return true
+}
+
+// Keys of the map for each attachment.
+const (
+	attachmentFilenameKey = "filename"
+	attachmentTypeKey     = "type"
+	attachmentDataKey     = "data"
+)
+
+// writeAttachments writes attachments as a msgpack array of maps.
+func writeAttachments(buf *bytes.Buffer, attachments []*Attachment) {
+	writeMsgpackArrayLen(buf, len(attachments))
+	for _, a := range attachments {
+		writeMsgpackMapLen(buf, 3)
+		writeMsgpackString(buf, attachmentFilenameKey)
+		writeMsgpackString(buf, a.Filename)
+		writeMsgpackString(buf, attachmentTypeKey)
+		writeMsgpackString(buf, a.ContentType)
+		writeMsgpackString(buf, attachmentDataKey)
+		writeMsgpackBin(buf, a.Data)
+	}
+}
+
+// readAttachments reads attachments written by writeAttachments, checking
+// them as Attach does. Entries of an attachment's map other than its
+// filename, content type and data are skipped.
+func readAttachments(r *msgpackReader) ([]*Attachment, error) {
+	n, err := r.readArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []*Attachment
+	size := 0
+	for i := 0; i < n; i++ {
+		fields, err := r.readMapLen()
+		if err != nil {
+			return nil, err
+		}
+
+		a := &Attachment{}
+		for j := 0; j < fields; j++ {
+			key, err := r.readString()
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case attachmentFilenameKey:
+				a.Filename, err = r.readString()
+			case attachmentTypeKey:
+				a.ContentType, err = r.readString()
+			case attachmentDataKey:
+				var data []byte
+				if data, err = r.readBytes(); err == nil {
+					a.Data = append([]byte(nil), data...)
+				}
+			default:
+				err = r.skip(0)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !validFilename(a.Filename) {
+			return nil, ErrInvalidFilename
+		}
+		size += len(a.Data)
+		if size > MaxAttachmentSize {
+			return nil, ErrAttachmentTooLarge
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
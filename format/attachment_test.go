@@ -0,0 +1,182 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func TestAttachments(t *testing.T) {
+	text, err := format.NewAttachment("notes.txt", "", []byte("some notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text.ContentType, "text/plain") {
+		t.Errorf("NewAttachment guessed content type %s", text.ContentType)
+	}
+	blob, err := format.NewAttachment("blob", "", []byte{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob.ContentType != format.DefaultContentType {
+		t.Errorf("NewAttachment guessed content type %s", blob.ContentType)
+	}
+
+	msg := &format.Encoding3{Subject: "files", Body: "see attached"}
+	if err = msg.Attach(text, blob); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = format.Encode(&buf, msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := format.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Errorf("Decode got %v want %v", got, msg)
+	}
+
+	if a := got.(*format.Encoding3).Attachment("blob"); !reflect.DeepEqual(a, blob) {
+		t.Errorf("Attachment got %v want %v", a, blob)
+	}
+	if a := msg.Attachment("missing"); a != nil {
+		t.Errorf("Attachment of a missing file got %v", a)
+	}
+}
+
+func TestAttachmentLimits(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, "c:x",
+		"a\nb", "a\x00b", strings.Repeat("x", format.MaxFilenameLength+1)} {
+		if _, err := format.NewAttachment(name, "", nil); err != format.ErrInvalidFilename {
+			t.Errorf("NewAttachment(%q) got %v want %v", name, err,
+				format.ErrInvalidFilename)
+		}
+	}
+
+	big := make([]byte, format.MaxAttachmentSize)
+	if _, err := format.NewAttachment("big", "", append(big, 0)); err != format.ErrAttachmentTooLarge {
+		t.Errorf("NewAttachment got %v want %v", err, format.ErrAttachmentTooLarge)
+	}
+	_, err := format.ReadAttachment("big", "", bytes.NewReader(append(big, 0)))
+	if err != format.ErrAttachmentTooLarge {
+		t.Errorf("ReadAttachment got %v want %v", err, format.ErrAttachmentTooLarge)
+	}
+
+	// The limit is on all the attachments of a message together.
+	a, err := format.NewAttachment("big", "", big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, _ := format.NewAttachment("small", "", []byte{1})
+	msg := &format.Encoding3{}
+	if err = msg.Attach(a); err != nil {
+		t.Fatal(err)
+	}
+	if err = msg.Attach(small); err != format.ErrAttachmentTooLarge {
+		t.Errorf("Attach got %v want %v", err, format.ErrAttachmentTooLarge)
+	}
+	if len(msg.Attachments) != 1 {
+		t.Errorf("Attach kept %d attachments after an error", len(msg.Attachments))
+	}
+
+	// Received messages are checked in the same way.
+	head := "\x82\xa0\xa7message\xabattachments"
+	bigData := "\xc6\x00\x04\x00\x00" + string(big)
+	tests := []struct {
+		in  string // Uncompressed msgpack.
+		err error
+	}{
+		{head + "\x91\x81\xa8filename\xae../../.profile", format.ErrInvalidFilename},
+		{head + "\x91\x80", format.ErrInvalidFilename},
+		{head + "\x92\x82\xa8filename\xa1a\xa4data" + bigData +
+			"\x82\xa8filename\xa1b\xa4data\xc4\x01\x01", format.ErrAttachmentTooLarge},
+	}
+	for i, test := range tests {
+		if _, err = format.Read(3, compress([]byte(test.in))); err != test.err {
+			t.Errorf("Read #%d got %v want %v", i, err, test.err)
+		}
+	}
+}
+
+// TestAttachmentEncoding checks the layout of attachments in the msgpack
+// map: an array under "attachments" of maps with the filename, content type
+// and data of each. Strings are accepted for the data, and entries that are
+// not known are skipped.
+func TestAttachmentEncoding(t *testing.T) {
+	in := "\x83\xa0\xa7message\xa4body\xa3see\xabattachments\x92" +
+		"\x83\xa8filename\xa5a.txt\xa4type\xaatext/plain\xa4data\xc4\x02hi" +
+		"\x83\xa8filename\xa1b\xa4data\xa2yo\xa4more\x91\x01"
+	got, err := format.Read(3, compress([]byte(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &format.Encoding3{
+		Body: "see",
+		Attachments: []*format.Attachment{
+			{Filename: "a.txt", ContentType: "text/plain", Data: []byte("hi")},
+			{Filename: "b", Data: []byte("yo")},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read got %v want %v", got, want)
+	}
+}
+
+func TestAttachmentFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attachment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.txt")
+	if err = ioutil.WriteFile(path, []byte("all is well"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := format.OpenAttachment(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Filename != "report.txt" || string(a.Data) != "all is well" {
+		t.Errorf("OpenAttachment got %v", a)
+	}
+
+	out := filepath.Join(dir, "out")
+	if err = os.Mkdir(out, 0700); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := a.Save(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != filepath.Join(out, "report.txt") {
+		t.Errorf("Save wrote to %s", saved)
+	}
+	if b, _ := ioutil.ReadFile(saved); string(b) != "all is well" {
+		t.Errorf("Save wrote %q", b)
+	}
+
+	// Save never overwrites a file.
+	if _, err = a.Save(out); err == nil {
+		t.Error("Save overwrote a file")
+	}
+
+	// Nor does it write outside the directory.
+	a.Filename = "../report.txt"
+	if _, err = a.Save(out); err != format.ErrInvalidFilename {
+		t.Errorf("Save got %v want %v", err, format.ErrInvalidFilename)
+	}
+}
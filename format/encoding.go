@@ -18,12 +18,18 @@ import (
 
 var encoding2Regex = regexp.MustCompile(`^Subject:(.*)\nBody:((?s).*)`)
 
-// Encoding represents a msg or broadcast object payload.
+// Encoding represents a msg or broadcast object payload. Besides those of
+// this package, applications can implement encodings of their own and
+// register them with Register.
 type Encoding interface {
+	// Encoding returns the number of the encoding.
 	Encoding() uint64
-	encoding() serialize.Format
+
+	// Message returns the raw form of the object payload.
 	Message() []byte
-	readMessage([]byte) error
+
+	// ToProtobuf returns the message in protobuf form. Encodings which
+	// serialize.Format has no value for may use Format_UNUSED.
 	ToProtobuf() *serialize.Encoding
 }
 
@@ -124,22 +130,14 @@ func (l *Encoding2) ToProtobuf() *serialize.Encoding {
 }
 
 // Read takes an encoding format code and an object payload and
-// returns it as an Encoding object.
+// returns it as an Encoding object, using the Decoder registered for the
+// encoding. It returns ErrUnsupportedEncoding if there is none.
 func Read(encoding uint64, msg []byte) (Encoding, error) {
-	var q Encoding
-	switch encoding {
-	case 1:
-		q = &Encoding1{}
-	case 2:
-		q = &Encoding2{}
-	default:
-		return nil, errors.New("Unsupported encoding")
-	}
-	err := q.readMessage(msg)
-	if err != nil {
-		return nil, err
+	d, ok := decoder(encoding)
+	if !ok {
+		return nil, ErrUnsupportedEncoding
 	}
-	return q, nil
+	return d(msg)
 }
 
 // Decode reads an Encoding type from a stream.
@@ -149,18 +147,8 @@ func Decode(r io.Reader) (Encoding, error) {
 	if encoding, err = bmutil.ReadVarInt(r); err != nil {
 		return nil, err
 	}
-	var msgLength uint64
-	if msgLength, err = bmutil.ReadVarInt(r); err != nil {
-		return nil, err
-	}
-	if msgLength > wire.MaxPayloadOfMsgObject {
-		str := fmt.Sprintf("message length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			msgLength, wire.MaxPayloadOfMsgObject)
-		return nil, wire.NewMessageError("DecodeFromDecrypted", str)
-	}
-	message := make([]byte, msgLength)
-	_, err = io.ReadFull(r, message)
+	message, err := wire.ReadVarBytes(r, wire.MaxPayloadOfMsgObject, "message",
+		"DecodeFromDecrypted")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,203 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/DanielKrawisz/bmutil/format/serialize"
+)
+
+// MaxExtendedSize is the largest decompressed extended encoding message that
+// will be accepted. It is the same limit PyBitmessage uses.
+const MaxExtendedSize = 1 << 20
+
+var (
+	// ErrExtendedTooLarge is returned when an extended encoding message
+	// decompresses to more than MaxExtendedSize bytes.
+	ErrExtendedTooLarge = errors.New("extended encoding message too large")
+
+	// ErrUnsupportedExtendedType is returned when an extended encoding
+	// message is of a type other than an ordinary message.
+	ErrUnsupportedExtendedType = errors.New("unsupported extended encoding message type")
+)
+
+// Keys of the extended encoding map. The empty key holds the message type.
+const (
+	extendedTypeKey        = ""
+	extendedMessageType    = "message"
+	extendedSubjectKey     = "subject"
+	extendedBodyKey        = "body"
+	extendedPriorityKey    = "priority"
+	extendedCategoryKey    = "category"
+	extendedAttachmentsKey = "attachments"
+)
+
+// Encoding3 implements the Encoding interface and represents a MsgMsg or
+// MsgBroadcast with encoding type 3, the extended encoding. The payload is a
+// zlib compressed msgpack map, as written by PyBitmessage. Besides the
+// subject and body, it can carry a priority and a category so that
+// applications such as mailing lists and alerting services can classify
+// messages, and files as attachments. Clients that do not know about these
+// fields ignore them. Any other entries in the map are kept as they are and
+// written back out by Message.
+type Encoding3 struct {
+	Subject string
+	Body    string
+
+	// Priority is the importance of the message. Zero means none was given.
+	Priority uint32
+
+	// Category is an application defined class for the message. The empty
+	// string means none was given.
+	Category string
+
+	// Attachments are the files attached to the message. Use Attach to add
+	// them, which checks their size.
+	Attachments []*Attachment
+
+	// extra holds the encoded entries that this package does not know
+	// about, and count the number of them.
+	extra []byte
+	count int
+}
+
+// Encoding returns the encoding format of the bitmessage.
+func (l *Encoding3) Encoding() uint64 {
+	return 3
+}
+
+// Encoding returns the encoding format of the bitmessage.
+func (l *Encoding3) encoding() serialize.Format {
+	return serialize.Format_ENCODING3
+}
+
+// Message returns the raw form of the object payload.
+func (l *Encoding3) Message() []byte {
+	n := 3 + l.count
+	if l.Priority != 0 {
+		n++
+	}
+	if l.Category != "" {
+		n++
+	}
+	if len(l.Attachments) != 0 {
+		n++
+	}
+
+	var m bytes.Buffer
+	writeMsgpackMapLen(&m, n)
+	writeMsgpackString(&m, extendedTypeKey)
+	writeMsgpackString(&m, extendedMessageType)
+	writeMsgpackString(&m, extendedSubjectKey)
+	writeMsgpackString(&m, l.Subject)
+	writeMsgpackString(&m, extendedBodyKey)
+	writeMsgpackString(&m, l.Body)
+	if l.Priority != 0 {
+		writeMsgpackString(&m, extendedPriorityKey)
+		writeMsgpackUint(&m, uint64(l.Priority))
+	}
+	if l.Category != "" {
+		writeMsgpackString(&m, extendedCategoryKey)
+		writeMsgpackString(&m, l.Category)
+	}
+	if len(l.Attachments) != 0 {
+		writeMsgpackString(&m, extendedAttachmentsKey)
+		writeAttachments(&m, l.Attachments)
+	}
+	m.Write(l.extra)
+
+	var b bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&b, zlib.BestCompression)
+	w.Write(m.Bytes())
+	w.Close()
+	return b.Bytes()
+}
+
+// ReadMessage reads the object payload and incorporates it.
+func (l *Encoding3) readMessage(msg []byte) error {
+	zr, err := zlib.NewReader(bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(zr, MaxExtendedSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxExtendedSize {
+		return ErrExtendedTooLarge
+	}
+
+	r := &msgpackReader{b: data}
+	n, err := r.readMapLen()
+	if err != nil {
+		return err
+	}
+
+	*l = Encoding3{}
+	var extra bytes.Buffer
+	var msgType string
+	for i := 0; i < n; i++ {
+		start := r.pos
+		key, err := r.readString()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case extendedTypeKey:
+			msgType, err = r.readString()
+		case extendedSubjectKey:
+			l.Subject, err = r.readString()
+		case extendedBodyKey:
+			l.Body, err = r.readString()
+		case extendedCategoryKey:
+			l.Category, err = r.readString()
+		case extendedPriorityKey:
+			var p uint64
+			p, err = r.readUnsigned()
+			if err == nil && p > uint64(^uint32(0)) {
+				err = errMsgpackType
+			}
+			l.Priority = uint32(p)
+		case extendedAttachmentsKey:
+			l.Attachments, err = readAttachments(r)
+		default:
+			if err = r.skip(0); err == nil {
+				extra.Write(data[start:r.pos])
+				l.count++
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if msgType != extendedMessageType {
+		return ErrUnsupportedExtendedType
+	}
+	if l.count > 0 {
+		l.extra = extra.Bytes()
+	}
+
+	return nil
+}
+
+// ToProtobuf encodes the message in a protobuf format.
+func (l *Encoding3) ToProtobuf() *serialize.Encoding {
+	return &serialize.Encoding{
+		Format:   l.encoding(),
+		Subject:  []byte(l.Subject),
+		Body:     []byte(l.Body),
+		Priority: l.Priority,
+		Category: l.Category,
+	}
+}
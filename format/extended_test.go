@@ -0,0 +1,174 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+)
+
+func compress(b []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestEncoding3(t *testing.T) {
+	tests := []*format.Encoding3{
+		{Subject: "hi", Body: "Hey there!"},
+		{Subject: "alert", Body: "disk full", Priority: 5, Category: "ops"},
+		{Body: string(make([]byte, 70000)), Priority: 1 << 20},
+		{Subject: "x", Category: "a category which is longer than thirty-one bytes"},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := format.Encode(&buf, test); err != nil {
+			t.Errorf("Encode #%d error %v", i, err)
+			continue
+		}
+
+		got, err := format.Decode(&buf)
+		if err != nil {
+			t.Errorf("Decode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test) {
+			t.Errorf("Decode #%d got %v want %v", i, got, test)
+		}
+
+		pb := test.ToProtobuf()
+		if pb.Priority != test.Priority || pb.Category != test.Category {
+			t.Errorf("ToProtobuf #%d got %v", i, pb)
+		}
+	}
+}
+
+func TestEncoding3Decode(t *testing.T) {
+	tests := []struct {
+		in   []byte // Uncompressed msgpack.
+		want *format.Encoding3
+	}{
+		// As written by PyBitmessage, with strings as raw bytes.
+		{
+			[]byte("\x83\xa0\xa7message\xa7subject\xa2hi\xa4body\xa3yo!"),
+			&format.Encoding3{Subject: "hi", Body: "yo!"},
+		},
+		{
+			[]byte("\x83\xc4\x00\xc4\x07message\xc4\x07subject\xc4\x02hi\xc4\x04body\xc4\x03yo!"),
+			&format.Encoding3{Subject: "hi", Body: "yo!"},
+		},
+		// Priority written as a wider or signed integer.
+		{
+			[]byte("\x84\xa0\xa7message\xa7subject\xa0\xa4body\xa0\xa8priority\xd1\x01\x00"),
+			&format.Encoding3{Priority: 256},
+		},
+		// Unknown type.
+		{[]byte("\x81\xa0\xa4vote"), nil},
+		// Missing type.
+		{[]byte("\x81\xa4body\xa0"), nil},
+		// Negative priority.
+		{[]byte("\x82\xa0\xa7message\xa8priority\xff"), nil},
+		// Truncated.
+		{[]byte("\x83\xa0\xa7message\xa7subject\xa2h"), nil},
+		// Not a map.
+		{[]byte("\x93\x01\x02\x03"), nil},
+	}
+
+	for i, test := range tests {
+		got, err := format.Read(3, compress(test.in))
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("Read #%d expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Read #%d error %v", i, err)
+			continue
+		}
+		e := got.(*format.Encoding3)
+		if e.Subject != test.want.Subject || e.Body != test.want.Body ||
+			e.Priority != test.want.Priority || e.Category != test.want.Category {
+			t.Errorf("Read #%d got %v want %v", i, e, test.want)
+		}
+	}
+
+	if _, err := format.Read(3, []byte("not zlib")); err == nil {
+		t.Errorf("Read of uncompressed data expected error")
+	}
+	big := append([]byte("\x81\xa0\xdb\x00\x10\x00\x01"), make([]byte, 1<<20+1)...)
+	if _, err := format.Read(3, compress(big)); err != format.ErrExtendedTooLarge {
+		t.Errorf("Read of large message got %v want %v", err,
+			format.ErrExtendedTooLarge)
+	}
+}
+
+// TestEncoding3Unknown ensures that entries this package does not know about
+// survive a round trip.
+func TestEncoding3Unknown(t *testing.T) {
+	in := []byte("\x85\xa0\xa7message\xa7subject\xa2hi\xa4body\xa0" +
+		"\xa5extra\x92\x01\xa1x\xa4list\x81\xa1k\xc3")
+
+	got, err := format.Read(3, compress(in))
+	if err != nil {
+		t.Fatalf("Read error %v", err)
+	}
+	again, err := format.Read(3, got.Message())
+	if err != nil {
+		t.Fatalf("Read of re-encoded message error %v", err)
+	}
+	if !reflect.DeepEqual(got, again) {
+		t.Errorf("round trip got %v want %v", again, got)
+	}
+
+	r, _ := zlib.NewReader(bytes.NewReader(again.Message()))
+	var out bytes.Buffer
+	out.ReadFrom(r)
+	if !bytes.Equal(out.Bytes(), in) {
+		t.Errorf("re-encoded message got %x want %x", out.Bytes(), in)
+	}
+}
+
+// pyBitmessageMessage is a message as encoded by PyBitmessage, with
+// zlib.compress(msgpack.dumps(data), 9) where data is
+// {"": "message", "subject": "Grüße", "body": "Hello from PyBitmessage.\nBye!"}.
+var pyBitmessageMessage = []byte{
+	0x78, 0xda, 0x6b, 0x5e, 0xb0, 0x3c, 0x37, 0xb5, 0xb8, 0x38, 0x31, 0x3d,
+	0x75, 0x79, 0x71, 0x69, 0x52, 0x56, 0x6a, 0x72, 0xc9, 0x72, 0xf7, 0xa2,
+	0xc3, 0x7b, 0x0e, 0xcf, 0x4f, 0x5d, 0x92, 0x94, 0x9f, 0x52, 0xb9, 0xd7,
+	0x23, 0x35, 0x27, 0x27, 0x5f, 0x21, 0xad, 0x28, 0x3f, 0x57, 0x21, 0xa0,
+	0xd2, 0x29, 0xb3, 0x04, 0xaa, 0x58, 0x8f, 0xcb, 0xa9, 0x32, 0x55, 0x11,
+	0x00, 0x6f, 0x08, 0x1a, 0x2a,
+}
+
+func TestEncoding3PyBitmessage(t *testing.T) {
+	got, err := format.Read(3, pyBitmessageMessage)
+	if err != nil {
+		t.Fatalf("Read error %v", err)
+	}
+	want := &format.Encoding3{Subject: "Grüße", Body: "Hello from PyBitmessage.\nBye!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read got %v want %v", got, want)
+	}
+
+	// The message is written out with the same entries in the same order,
+	// so PyBitmessage reads back what it sent.
+	r, _ := zlib.NewReader(bytes.NewReader(pyBitmessageMessage))
+	var in bytes.Buffer
+	in.ReadFrom(r)
+	r, _ = zlib.NewReader(bytes.NewReader(want.Message()))
+	var out bytes.Buffer
+	out.ReadFrom(r)
+	if !bytes.Equal(out.Bytes(), in.Bytes()) {
+		t.Errorf("Message got %x want %x", out.Bytes(), in.Bytes())
+	}
+}
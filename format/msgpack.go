@@ -0,0 +1,326 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// This file contains the small subset of msgpack needed for the extended
+// encoding: maps, arrays, strings, binary data and unsigned integers. Other
+// types can be skipped over but not decoded.
+
+// maxMsgpackDepth limits how deeply nested values can be when skipping
+// over them, so that a malicious message cannot exhaust the stack.
+const maxMsgpackDepth = 32
+
+var errMsgpackType = errors.New("unexpected msgpack type")
+
+func writeMsgpackMapLen(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xde)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdf)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackArrayLen(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xdc)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdd)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackBin(buf *bytes.Buffer, b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.Write(b)
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgpackUint(buf *bytes.Buffer, v uint64) {
+	switch {
+	case v < 0x80:
+		buf.WriteByte(byte(v))
+	case v <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(v))
+	case v <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(v))
+	case v <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(v))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+// msgpackReader reads msgpack values from a byte slice.
+type msgpackReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *msgpackReader) read(n int) ([]byte, error) {
+	if n < 0 || n > len(r.b)-r.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *msgpackReader) readByte() (byte, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readUint reads a big endian unsigned integer of n bytes.
+func (r *msgpackReader) readUint(n int) (uint64, error) {
+	b, err := r.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (r *msgpackReader) readMapLen() (int, error) {
+	c, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case c&0xf0 == 0x80:
+		return int(c & 0x0f), nil
+	case c == 0xde:
+		n, err = r.readUint(2)
+	case c == 0xdf:
+		n, err = r.readUint(4)
+	default:
+		return 0, errMsgpackType
+	}
+	return int(n), err
+}
+
+func (r *msgpackReader) readArrayLen() (int, error) {
+	c, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case c&0xf0 == 0x90:
+		return int(c & 0x0f), nil
+	case c == 0xdc:
+		n, err = r.readUint(2)
+	case c == 0xdd:
+		n, err = r.readUint(4)
+	default:
+		return 0, errMsgpackType
+	}
+	return int(n), err
+}
+
+// readString reads a string. Binary data is accepted too since older
+// msgpack implementations write strings that way.
+func (r *msgpackReader) readString() (string, error) {
+	b, err := r.readBytes()
+	return string(b), err
+}
+
+// readBytes reads binary data or a string. The result refers to the
+// reader's buffer.
+func (r *msgpackReader) readBytes() ([]byte, error) {
+	c, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	var n uint64
+	switch {
+	case c&0xe0 == 0xa0:
+		n = uint64(c & 0x1f)
+	case c == 0xd9 || c == 0xc4:
+		n, err = r.readUint(1)
+	case c == 0xda || c == 0xc5:
+		n, err = r.readUint(2)
+	case c == 0xdb || c == 0xc6:
+		n, err = r.readUint(4)
+	default:
+		return nil, errMsgpackType
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.read(int(n))
+}
+
+// readUnsigned reads a non-negative integer of any width.
+func (r *msgpackReader) readUnsigned() (uint64, error) {
+	c, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case 0xcc, 0xd0:
+		return r.readSized(1, c >= 0xd0)
+	case 0xcd, 0xd1:
+		return r.readSized(2, c >= 0xd0)
+	case 0xce, 0xd2:
+		return r.readSized(4, c >= 0xd0)
+	case 0xcf, 0xd3:
+		return r.readSized(8, c >= 0xd0)
+	}
+	if c < 0x80 {
+		return uint64(c), nil
+	}
+	return 0, errMsgpackType
+}
+
+func (r *msgpackReader) readSized(n int, signed bool) (uint64, error) {
+	v, err := r.readUint(n)
+	if err != nil {
+		return 0, err
+	}
+	if signed && v>>(uint(n)*8-1) != 0 {
+		return 0, errMsgpackType
+	}
+	return v, nil
+}
+
+// skip moves past the next value, whatever its type.
+func (r *msgpackReader) skip(depth int) error {
+	if depth > maxMsgpackDepth {
+		return errMsgpackType
+	}
+	c, err := r.readByte()
+	if err != nil {
+		return err
+	}
+
+	var n uint64
+	var elements uint64
+	switch {
+	case c < 0x80 || c >= 0xe0 || c == 0xc0 || c == 0xc2 || c == 0xc3:
+		return nil
+	case c&0xf0 == 0x80:
+		elements = 2 * uint64(c&0x0f)
+	case c&0xf0 == 0x90:
+		elements = uint64(c & 0x0f)
+	case c&0xe0 == 0xa0:
+		_, err = r.read(int(c & 0x1f))
+		return err
+	case c == 0xc4 || c == 0xd9:
+		n, err = r.readUint(1)
+	case c == 0xc5 || c == 0xda:
+		n, err = r.readUint(2)
+	case c == 0xc6 || c == 0xdb:
+		n, err = r.readUint(4)
+	case c == 0xcc || c == 0xd0:
+		n = 1
+	case c == 0xcd || c == 0xd1:
+		n = 2
+	case c == 0xca || c == 0xce || c == 0xd2:
+		n = 4
+	case c == 0xcb || c == 0xcf || c == 0xd3:
+		n = 8
+	case c == 0xd4:
+		n = 2
+	case c == 0xd5:
+		n = 3
+	case c == 0xd6:
+		n = 5
+	case c == 0xd7:
+		n = 9
+	case c == 0xd8:
+		n = 17
+	case c == 0xc7:
+		n, err = r.readUint(1)
+		n++
+	case c == 0xc8:
+		n, err = r.readUint(2)
+		n++
+	case c == 0xc9:
+		n, err = r.readUint(4)
+		n++
+	case c == 0xdc:
+		elements, err = r.readUint(2)
+	case c == 0xdd:
+		elements, err = r.readUint(4)
+	case c == 0xde:
+		elements, err = r.readUint(2)
+		elements *= 2
+	case c == 0xdf:
+		elements, err = r.readUint(4)
+		elements *= 2
+	default:
+		return errMsgpackType
+	}
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		_, err = r.read(int(n))
+		return err
+	}
+	for i := uint64(0); i < elements; i++ {
+		if err = r.skip(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrUnsupportedEncoding is returned when reading a message with an
+	// encoding for which no Decoder is registered.
+	ErrUnsupportedEncoding = errors.New("Unsupported encoding")
+
+	// ErrEncodingRegistered is returned by Register for an encoding which
+	// already has a Decoder.
+	ErrEncodingRegistered = errors.New("encoding already registered")
+)
+
+// Decoder reads the message of an object payload with a particular
+// encoding, as returned by Encoding.Message, into an Encoding.
+type Decoder func(msg []byte) (Encoding, error)
+
+var registry = struct {
+	sync.RWMutex
+	decoders map[uint64]Decoder
+}{
+	decoders: map[uint64]Decoder{
+		1: func(msg []byte) (Encoding, error) { return readBuiltin(&Encoding1{}, msg) },
+		2: func(msg []byte) (Encoding, error) { return readBuiltin(&Encoding2{}, msg) },
+		3: func(msg []byte) (Encoding, error) { return readBuiltin(&Encoding3{}, msg) },
+	},
+}
+
+// builtin is implemented by the encodings of this package.
+type builtin interface {
+	Encoding
+	readMessage([]byte) error
+}
+
+func readBuiltin(l builtin, msg []byte) (Encoding, error) {
+	if err := l.readMessage(msg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Register makes Read and Decode use decoder for messages with the given
+// encoding, so that applications can define encodings of their own. The
+// trivial, simple and extended encodings, 1 to 3, are registered already.
+// It returns ErrEncodingRegistered if the encoding has a Decoder. Encodings
+// are usually registered in an init function.
+func Register(encoding uint64, decoder Decoder) error {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.decoders[encoding]; ok {
+		return ErrEncodingRegistered
+	}
+	registry.decoders[encoding] = decoder
+	return nil
+}
+
+// Registered returns whether a Decoder is registered for the encoding.
+func Registered(encoding uint64) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	_, ok := registry.decoders[encoding]
+	return ok
+}
+
+func decoder(encoding uint64) (Decoder, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	d, ok := registry.decoders[encoding]
+	return d, ok
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/format"
+	"github.com/DanielKrawisz/bmutil/format/serialize"
+)
+
+// reversed is an encoding whose message is the body backwards.
+type reversed struct {
+	Body string
+}
+
+const reversedEncoding = 0x7265
+
+func (l *reversed) Encoding() uint64 {
+	return reversedEncoding
+}
+
+func (l *reversed) Message() []byte {
+	b := []byte(l.Body)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+func (l *reversed) ToProtobuf() *serialize.Encoding {
+	return &serialize.Encoding{Body: []byte(l.Body)}
+}
+
+func init() {
+	format.Register(reversedEncoding, func(msg []byte) (format.Encoding, error) {
+		l := &reversed{Body: string(msg)}
+		l.Body = string(l.Message())
+		return l, nil
+	})
+}
+
+func TestRegister(t *testing.T) {
+	if !format.Registered(reversedEncoding) || !format.Registered(2) {
+		t.Fatal("encoding not registered")
+	}
+
+	var buf bytes.Buffer
+	if err := format.Encode(&buf, &reversed{Body: "stressed"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := format.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := got.(*reversed); !ok || r.Body != "stressed" {
+		t.Errorf("got %#v", got)
+	}
+
+	if err = format.Register(2, nil); err != format.ErrEncodingRegistered {
+		t.Errorf("Register got %v want %v", err, format.ErrEncodingRegistered)
+	}
+	if _, err = format.Read(0x7266, nil); err != format.ErrUnsupportedEncoding {
+		t.Errorf("Read got %v want %v", err, format.ErrUnsupportedEncoding)
+	}
+}
@@ -1,8 +1,8 @@
 #!/bin/bash
 # The script does automatic checking on a Go package and its sub-packages, including:
 # 1. gofmt         (http://golang.org/cmd/gofmt/)
-# 2. goimports     (https://github.com/bradfitz/goimports)
-# 3. golint        (https://github.com/golang/lint)
+# 2. goimports     (https://golang.org/x/tools/cmd/goimports)
+# 3. golint        (https://golang.org/x/lint/golint)
 # 4. go vet        (http://golang.org/cmd/vet)
 # 5. race detector (http://blog.golang.org/race-detector)
 # 6. test coverage (http://blog.golang.org/cover)
@@ -13,7 +13,7 @@ set -e
 test -z "$(gofmt -l -w .     | tee /dev/stderr)"
 test -z "$(goimports -l -w . | tee /dev/stderr)"
 test -z "$(golint ./..       | tee /dev/stderr)"
-go tool vet -composites=false ./..
+go vet -composites=false ./...
 env GORACE="halt_on_error=1" go test -v -race ./...
 
 # Run test coverage on each subdirectories and merge the coverage profile.
@@ -8,6 +8,7 @@ package hash
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 )
 
@@ -53,6 +54,35 @@ func (hash *Ripe) SetBytes(newHash []byte) error {
 	return nil
 }
 
+// MarshalText returns the hash as a hexadecimal string, as by String.
+func (hash Ripe) MarshalText() ([]byte, error) {
+	return []byte(hash.String()), nil
+}
+
+// UnmarshalText sets the hash from a hexadecimal string.
+func (hash *Ripe) UnmarshalText(text []byte) error {
+	h, err := NewRipeFromStr(string(text))
+	if err != nil {
+		return err
+	}
+	*hash = *h
+	return nil
+}
+
+// MarshalJSON returns the hash as a hexadecimal JSON string.
+func (hash Ripe) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hash.String())
+}
+
+// UnmarshalJSON sets the hash from a hexadecimal JSON string.
+func (hash *Ripe) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return hash.UnmarshalText([]byte(s))
+}
+
 // IsEqual returns true if target is the same as hash.
 func (hash *Ripe) IsEqual(target *Ripe) bool {
 	return bytes.Equal(hash[:], target[:])
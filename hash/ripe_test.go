@@ -8,6 +8,7 @@ package hash_test
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"testing"
 
 	"github.com/DanielKrawisz/bmutil/hash"
@@ -158,3 +159,31 @@ func TestNewRipeHashFromStr(t *testing.T) {
 		}
 	}
 }
+
+// TestRipeJSON tests that a Ripe round-trips through encoding/json as a
+// hexadecimal string, including as a map key.
+func TestRipeJSON(t *testing.T) {
+	const str = "385e17e3f2047ca81f71ac604c6da1c2a311f384"
+	h, _ := hash.NewRipeFromStr(str)
+
+	b, err := json.Marshal(map[hash.Ripe]hash.Ripe{*h: *h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"` + str + `":"` + str + `"}`; string(b) != expected {
+		t.Errorf("got %s, want %s", b, expected)
+	}
+
+	var m map[hash.Ripe]hash.Ripe
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m[*h]; !ok || v != *h {
+		t.Errorf("got %v", m)
+	}
+
+	var bad hash.Ripe
+	if err = json.Unmarshal([]byte(`"abc"`), &bad); err == nil {
+		t.Error("expected error for short string")
+	}
+}
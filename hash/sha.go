@@ -11,6 +11,7 @@ package hash
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 )
 
@@ -55,6 +56,35 @@ func (hash *Sha) SetBytes(newHash []byte) error {
 	return nil
 }
 
+// MarshalText returns the hash as a hexadecimal string, as by String.
+func (hash Sha) MarshalText() ([]byte, error) {
+	return []byte(hash.String()), nil
+}
+
+// UnmarshalText sets the hash from a hexadecimal string.
+func (hash *Sha) UnmarshalText(text []byte) error {
+	h, err := NewShaFromStr(string(text))
+	if err != nil {
+		return err
+	}
+	*hash = *h
+	return nil
+}
+
+// MarshalJSON returns the hash as a hexadecimal JSON string.
+func (hash Sha) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hash.String())
+}
+
+// UnmarshalJSON sets the hash from a hexadecimal JSON string.
+func (hash *Sha) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return hash.UnmarshalText([]byte(s))
+}
+
 // IsEqual returns true if target is the same as hash.
 func (hash *Sha) IsEqual(target *Sha) bool {
 	if target == nil {
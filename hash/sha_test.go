@@ -11,6 +11,7 @@ package hash_test
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"testing"
 
 	"github.com/DanielKrawisz/bmutil/hash"
@@ -169,3 +170,31 @@ func TestNewShaHashFromStr(t *testing.T) {
 		}
 	}
 }
+
+// TestShaJSON tests that a Sha round-trips through encoding/json as a
+// hexadecimal string, including as a map key.
+func TestShaJSON(t *testing.T) {
+	const str = "c47ec24a50002d3191e9d87d34ce4f02c55bf83326540cee3d6c33405720f7d2"
+	h, _ := hash.NewShaFromStr(str)
+
+	b, err := json.Marshal(map[hash.Sha]hash.Sha{*h: *h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"` + str + `":"` + str + `"}`; string(b) != expected {
+		t.Errorf("got %s, want %s", b, expected)
+	}
+
+	var m map[hash.Sha]hash.Sha
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m[*h]; !ok || v != *h {
+		t.Errorf("got %v", m)
+	}
+
+	var bad hash.Sha
+	if err = json.Unmarshal([]byte(`"abc"`), &bad); err == nil {
+		t.Error("expected error for short string")
+	}
+}
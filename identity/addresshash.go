@@ -0,0 +1,71 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"crypto/sha512"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// AddressHashFunc computes the ripe hash that goes into an address from the
+// uncompressed serializations of the verification and encryption keys.
+type AddressHashFunc func(verification, encryption []byte) *hash.Ripe
+
+// Sha512Ripemd160 is the address hash used by every address version defined
+// so far: ripemd160(sha512(verification || encryption)).
+func Sha512Ripemd160(verification, encryption []byte) *hash.Ripe {
+	sha := sha512.New()
+	ripemd := ripemd160.New()
+
+	sha.Write(verification)
+	sha.Write(encryption)
+
+	ripemd.Write(sha.Sum(nil)) // take ripemd160 of required elements
+
+	// Get the hash
+	r, _ := hash.NewRipe(ripemd.Sum(nil))
+	return r
+}
+
+var (
+	addressHashMtx sync.RWMutex
+
+	// addressHashes maps address versions to the hash used to derive them.
+	addressHashes = map[uint64]AddressHashFunc{
+		2: Sha512Ripemd160,
+		3: Sha512Ripemd160,
+		4: Sha512Ripemd160,
+		5: Sha512Ripemd160, // bmutil.ExperimentalAddressVersion
+	}
+)
+
+// RegisterAddressHash sets the hash function used to derive addresses of the
+// given version. It is intended for experimenting with new address versions
+// and should be called during initialization.
+func RegisterAddressHash(version uint64, f AddressHashFunc) {
+	addressHashMtx.Lock()
+	defer addressHashMtx.Unlock()
+
+	if f == nil {
+		delete(addressHashes, version)
+		return
+	}
+	addressHashes[version] = f
+}
+
+// AddressHash returns the hash function used to derive addresses of the
+// given version. Versions with no registered function use Sha512Ripemd160.
+func AddressHash(version uint64) AddressHashFunc {
+	addressHashMtx.RLock()
+	defer addressHashMtx.RUnlock()
+
+	if f, ok := addressHashes[version]; ok {
+		return f
+	}
+	return Sha512Ripemd160
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestAddressHash(t *testing.T) {
+	privAddr, err := identity.ImportWIF("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	if err != nil {
+		t.Fatal("Could not create ID: ", err)
+	}
+	key := privAddr.PublicKey()
+
+	// Every known version uses the same hash.
+	for _, version := range []uint64{2, 3, 4, 100} {
+		if !key.HashForVersion(version).IsEqual(key.Hash()) {
+			t.Errorf("HashForVersion(%d) differs from Hash()", version)
+		}
+	}
+
+	custom := &hash.Ripe{0xab, 0xcd}
+	identity.RegisterAddressHash(4, func(vk, ek []byte) *hash.Ripe {
+		return custom
+	})
+	defer identity.RegisterAddressHash(4, identity.Sha512Ripemd160)
+
+	if !key.HashForVersion(4).IsEqual(custom) {
+		t.Errorf("HashForVersion did not use the registered function")
+	}
+	if !key.Hash().IsEqual(custom) {
+		t.Errorf("Hash did not use the function for DefaultAddressVersion")
+	}
+	if key.HashForVersion(3).IsEqual(custom) {
+		t.Errorf("RegisterAddressHash affected another version")
+	}
+
+	addr := identity.NewPrivateAddress(privAddr.PrivateKey(), 4, 1).Address()
+	if !bytes.Equal(addr.RipeHash()[:], custom[:]) {
+		t.Errorf("Address was not derived with the registered function")
+	}
+
+	// Removing a function restores the default.
+	identity.RegisterAddressHash(4, nil)
+	if !key.HashForVersion(4).IsEqual(key.Hash()) {
+		t.Errorf("HashForVersion did not fall back to the default")
+	}
+}
+
+func TestNewRandomForVersion(t *testing.T) {
+	// A hash whose first byte is zero when the default hash's is not.
+	identity.RegisterAddressHash(4, func(vk, ek []byte) *hash.Ripe {
+		r := *identity.Sha512Ripemd160(vk, ek)
+		r[0] ^= 0xff
+		return &r
+	})
+	defer identity.RegisterAddressHash(4, identity.Sha512Ripemd160)
+
+	key, err := identity.NewRandomForVersion(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.HashForVersion(4)[0] != 0 || !key.Hash().IsEqual(key.HashForVersion(4)) {
+		t.Errorf("got hash %s", key.HashForVersion(4))
+	}
+	addr := identity.NewPrivateAddress(key, 4, 1).Address()
+	if addr.RipeHash()[0] != 0 {
+		t.Errorf("got address ripe %x", addr.RipeHash()[:])
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// chanLabelPrefix is put in front of the passphrase by PyBitmessage to make
+// the label of a chan.
+const chanLabelPrefix = "[chan] "
+
+// ErrEmptyPassphrase is returned when asked to derive a chan from an empty
+// passphrase.
+var ErrEmptyPassphrase = errors.New("chan passphrase is empty")
+
+// NewChan derives the identity of the chan with the given passphrase in the
+// given stream the way PyBitmessage does: the first deterministic key for
+// the passphrase with one initial zero, as a version 4 address, with the
+// behavior and proof of work that PyBitmessage gives its own identities. It
+// returns an error for a stream in which an address cannot be made.
+// Everyone who knows the passphrase has the private keys, which is what
+// makes it a chan. Messages in a chan are sent from the chan to itself, and
+// are created with cipher.SignAndEncryptChanMessage and decrypted like any
+// other message to an identity in a keyring.
+func NewChan(passphrase string, stream uint64) (*PrivateID, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	keys, err := NewDeterministicForVersion(4, passphrase, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = NewAddress(4, stream, keys[0].HashForVersion(4)); err != nil {
+		return nil, err
+	}
+	return NewChanID(NewPrivateAddress(keys[0], 4, stream), BehaviorAck,
+		&pow.Default), nil
+}
+
+// JoinChan derives the chan with the given passphrase and checks that it has
+// the given address, as PyBitmessage does when joining a chan, so that a
+// mistyped passphrase is not mistaken for a new chan.
+func JoinChan(passphrase, address string) (*PrivateID, error) {
+	addr, err := DecodeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := NewChan(passphrase, addr.Stream())
+	if err != nil {
+		return nil, err
+	}
+	if !ConstantTimeAddressEqual(id.Address(), addr) {
+		return nil, ErrAddressMismatch
+	}
+	return id, nil
+}
+
+// ChanLabel returns the label that PyBitmessage gives the chan with the given
+// passphrase.
+func ChanLabel(passphrase string) string {
+	return chanLabelPrefix + passphrase
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestChan(t *testing.T) {
+	// The well-known "general" chan in PyBitmessage.
+	const passphrase = "general"
+	const address = "BM-2cW67GEKkHGonXKZLCzouLLxnLym3azS8r"
+
+	id, err := identity.NewChan(passphrase, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Address().String() != address {
+		t.Errorf("got address %s, want %s", id.Address(), address)
+	}
+	if !id.IsChan() || id.Behavior() != identity.BehaviorAck {
+		t.Errorf("got chan %v behavior %d", id.IsChan(), id.Behavior())
+	}
+
+	if _, err = identity.NewChan(passphrase, 2); err != bmutil.ErrInvalidStream {
+		t.Errorf("NewChan in stream 2 got error %v want %v", err,
+			bmutil.ErrInvalidStream)
+	}
+
+	joined, err := identity.JoinChan(passphrase, address)
+	if err != nil {
+		t.Errorf("JoinChan got error %v", err)
+	} else if !joined.IsChan() {
+		t.Errorf("JoinChan did not return a chan")
+	}
+	if _, err = identity.JoinChan("General", address); err != identity.ErrAddressMismatch {
+		t.Errorf("JoinChan with wrong passphrase got error %v", err)
+	}
+	if _, err = identity.NewChan("", 1); err != identity.ErrEmptyPassphrase {
+		t.Errorf("empty passphrase got error %v", err)
+	}
+
+	if label := identity.ChanLabel(passphrase); label != "[chan] general" {
+		t.Errorf("got label %q", label)
+	}
+}
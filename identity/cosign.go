@@ -0,0 +1,440 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// Co-signing lets the signing key of an identity be split between two
+// devices so that neither can sign alone. The key d is split into additive
+// shares d1 + d2 = d mod n. Signing takes one round trip:
+//
+//	device 1: session, request := signer1.Begin()      // send request
+//	device 2: partial := signer2.PartialSign(hash, request) // send partial
+//	device 1: sig := session.Combine(hash, partial)
+//
+// The nonce, unlike the key, is split multiplicatively: each device picks a
+// share of it, k1 and k2, and the signature uses k = k1 * k2. Device 2 computes its
+// part of the signature under a Paillier key belonging to device 1, which
+// holds an encryption of d1 made when the key was split, so that neither
+// device learns the other's share. This is the semi-honest form of Lindell's
+// two party ECDSA; the two devices are assumed to follow the protocol.
+
+// CoSignPaillierBits is the size of the Paillier modulus used by device 1.
+const CoSignPaillierBits = 2048
+
+// maxCoSignFieldSize limits the size of numbers read by the decoders.
+const maxCoSignFieldSize = 2 * CoSignPaillierBits / 8
+
+var (
+	// ErrSessionUsed is returned when a co-signing session is used to
+	// sign more than once, which would reveal the key.
+	ErrSessionUsed = errors.New("co-signing session already used")
+
+	// ErrInvalidPartialSignature is returned when a partial signature
+	// does not produce a valid signature for the key.
+	ErrInvalidPartialSignature = errors.New("invalid partial signature")
+
+	// ErrInvalidCoSignShare is returned when a decoded co-signing share
+	// is not in [1, N-1], or has a Paillier key with no inverse or a
+	// public key off the curve.
+	ErrInvalidCoSignShare = errors.New("invalid co-signing share")
+)
+
+var s256 = btcec.S256()
+
+// paillierKey is a Paillier private key whose generator is N+1.
+type paillierKey struct {
+	n   *big.Int // modulus
+	phi *big.Int // (p-1)(q-1)
+	mu  *big.Int // phi^-1 mod n
+}
+
+func newPaillierKey(bits int) (*paillierKey, error) {
+	for {
+		p, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		phi := new(big.Int).Mul(p.Sub(p, bigOne), q.Sub(q, bigOne))
+		mu := new(big.Int).ModInverse(phi, n)
+		if mu == nil {
+			continue
+		}
+		return &paillierKey{n: n, phi: phi, mu: mu}, nil
+	}
+}
+
+var bigOne = big.NewInt(1)
+
+// paillierEncrypt encrypts m under the public modulus n.
+func paillierEncrypt(n, m *big.Int) (*big.Int, error) {
+	n2 := new(big.Int).Mul(n, n)
+	var r *big.Int
+	for {
+		var err error
+		r, err = rand.Int(rand.Reader, n)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() > 0 && new(big.Int).GCD(nil, nil, r, n).Cmp(bigOne) == 0 {
+			break
+		}
+	}
+
+	// (1 + n)^m = 1 + mn mod n^2
+	c := new(big.Int).Mul(m, n)
+	c.Add(c, bigOne)
+	c.Mod(c, n2)
+	return c.Mul(c, r.Exp(r, n, n2)).Mod(c, n2), nil
+}
+
+func (k *paillierKey) decrypt(c *big.Int) *big.Int {
+	n2 := new(big.Int).Mul(k.n, k.n)
+	m := new(big.Int).Exp(c, k.phi, n2)
+	m.Sub(m, bigOne).Div(m, k.n)
+	return m.Mul(m, k.mu).Mod(m, k.n)
+}
+
+// CoSigner1 is the share of a co-signing key held by the device which
+// starts signing sessions and produces the final signature.
+type CoSigner1 struct {
+	share    *big.Int
+	public   *btcec.PublicKey
+	paillier *paillierKey
+}
+
+// CoSigner2 is the share of a co-signing key held by the device which
+// produces partial signatures.
+type CoSigner2 struct {
+	share     *big.Int
+	public    *btcec.PublicKey
+	paillierN *big.Int
+	encShare  *big.Int // Paillier encryption of the other share.
+}
+
+// SplitSigningKey splits a signing key into two shares for co-signing. The
+// original key should be destroyed once the shares have been moved to their
+// devices.
+func SplitSigningKey(key *btcec.PrivateKey) (*CoSigner1, *CoSigner2, error) {
+	n := s256.N
+	d1, err := randScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	d2 := new(big.Int).Sub(key.D, d1)
+	d2.Mod(d2, n)
+	if d2.Sign() == 0 {
+		return SplitSigningKey(key)
+	}
+
+	pk, err := newPaillierKey(CoSignPaillierBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := paillierEncrypt(pk.n, d1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	public := key.PubKey()
+	return &CoSigner1{share: d1, public: public, paillier: pk},
+		&CoSigner2{share: d2, public: public, paillierN: pk.n, encShare: enc},
+		nil
+}
+
+// PublicKey returns the verification key which the shares sign for.
+func (c *CoSigner1) PublicKey() *PubKey {
+	return (*PubKey)(c.public)
+}
+
+// PublicKey returns the verification key which the shares sign for.
+func (c *CoSigner2) PublicKey() *PubKey {
+	return (*PubKey)(c.public)
+}
+
+// CoSignRequest is sent from device 1 to device 2 to start a signature.
+type CoSignRequest struct {
+	R1 *btcec.PublicKey
+}
+
+// PartialSignature is device 2's reply to a CoSignRequest.
+type PartialSignature struct {
+	R2 *btcec.PublicKey
+	C  *big.Int
+}
+
+// CoSignSession holds device 1's secret nonce for a single signature.
+type CoSignSession struct {
+	signer *CoSigner1
+	k1     *big.Int
+}
+
+// Begin starts a signing session. The request is to be sent to device 2.
+// A session can only be used for one signature.
+func (c *CoSigner1) Begin() (*CoSignSession, *CoSignRequest, error) {
+	k1, err := randScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	x, y := s256.ScalarBaseMult(k1.Bytes())
+	return &CoSignSession{signer: c, k1: k1},
+		&CoSignRequest{R1: &btcec.PublicKey{Curve: s256, X: x, Y: y}}, nil
+}
+
+// PartialSign produces device 2's contribution to a signature over hash.
+func (c *CoSigner2) PartialSign(hash []byte, req *CoSignRequest) (*PartialSignature, error) {
+	if req.R1 == nil || !s256.IsOnCurve(req.R1.X, req.R1.Y) {
+		return nil, ErrInvalidPartialSignature
+	}
+
+	n := s256.N
+	k2, err := randScalar()
+	if err != nil {
+		return nil, err
+	}
+	x2, y2 := s256.ScalarBaseMult(k2.Bytes())
+	rx, _ := s256.ScalarMult(req.R1.X, req.R1.Y, k2.Bytes())
+	r := new(big.Int).Mod(rx, n)
+	if r.Sign() == 0 {
+		return c.PartialSign(hash, req)
+	}
+
+	// u = k2^-1 (z + r d2), v = k2^-1 r, both mod n.
+	k2inv := new(big.Int).ModInverse(k2, n)
+	z := hashToInt(hash)
+	u := new(big.Int).Mul(r, c.share)
+	u.Add(u, z).Mul(u, k2inv).Mod(u, n)
+	v := new(big.Int).Mul(k2inv, r)
+	v.Mod(v, n)
+
+	// Mask u with a random multiple of n so that device 1 learns nothing
+	// more than the value mod n.
+	rho, err := rand.Int(rand.Reader, new(big.Int).Mul(n, n))
+	if err != nil {
+		return nil, err
+	}
+	u.Add(u, rho.Mul(rho, n))
+
+	// c = Enc(u) * Enc(d1)^v = Enc(u + v d1)
+	n2 := new(big.Int).Mul(c.paillierN, c.paillierN)
+	enc, err := paillierEncrypt(c.paillierN, u)
+	if err != nil {
+		return nil, err
+	}
+	enc.Mul(enc, new(big.Int).Exp(c.encShare, v, n2)).Mod(enc, n2)
+
+	return &PartialSignature{
+		R2: &btcec.PublicKey{Curve: s256, X: x2, Y: y2},
+		C:  enc,
+	}, nil
+}
+
+// Combine finishes the signature over hash using device 2's partial
+// signature. The result is checked against the public key before it is
+// returned.
+func (s *CoSignSession) Combine(hash []byte, partial *PartialSignature) (*btcec.Signature, error) {
+	if s.k1 == nil {
+		return nil, ErrSessionUsed
+	}
+	k1 := s.k1
+	s.k1 = nil
+
+	if partial.R2 == nil || partial.C == nil ||
+		!s256.IsOnCurve(partial.R2.X, partial.R2.Y) {
+		return nil, ErrInvalidPartialSignature
+	}
+
+	n := s256.N
+	rx, _ := s256.ScalarMult(partial.R2.X, partial.R2.Y, k1.Bytes())
+	r := new(big.Int).Mod(rx, n)
+
+	sig := s.signer.paillier.decrypt(partial.C)
+	sig.Mod(sig, n).Mul(sig, new(big.Int).ModInverse(k1, n)).Mod(sig, n)
+
+	// Use the low form of s, as btcec does.
+	if sig.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sig.Sub(n, sig)
+	}
+
+	signature := &btcec.Signature{R: r, S: sig}
+	if r.Sign() == 0 || sig.Sign() == 0 || !signature.Verify(hash, s.signer.public) {
+		return nil, ErrInvalidPartialSignature
+	}
+	return signature, nil
+}
+
+// randScalar returns a random number in [1, n).
+func randScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, s256.N)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() > 0 {
+			return k, nil
+		}
+	}
+}
+
+// hashToInt converts a hash to an integer as ECDSA does. Bitmessage signs
+// SHA-256 and SHA-1 hashes, which are never longer than the s256 order.
+func hashToInt(hash []byte) *big.Int {
+	if len(hash) > 32 {
+		hash = hash[:32]
+	}
+	return new(big.Int).SetBytes(hash)
+}
+
+// Encode writes device 1's share to w so that it can be stored.
+func (c *CoSigner1) Encode(w io.Writer) error {
+	return writeBigInts(w, c.share, c.public.X, c.public.Y,
+		c.paillier.n, c.paillier.phi)
+}
+
+// DecodeCoSigner1 reads a share written by CoSigner1.Encode.
+func DecodeCoSigner1(r io.Reader) (*CoSigner1, error) {
+	v, err := readBigInts(r, 5)
+	if err != nil {
+		return nil, err
+	}
+	if !validScalar(v[0]) {
+		return nil, ErrInvalidCoSignShare
+	}
+	public, err := coSignPublic(v[1], v[2])
+	if err != nil {
+		return nil, err
+	}
+	mu := new(big.Int).ModInverse(v[4], v[3])
+	if mu == nil {
+		return nil, ErrInvalidCoSignShare
+	}
+	return &CoSigner1{
+		share:    v[0],
+		public:   public,
+		paillier: &paillierKey{n: v[3], phi: v[4], mu: mu},
+	}, nil
+}
+
+// Encode writes device 2's share to w so that it can be stored or sent to
+// the device.
+func (c *CoSigner2) Encode(w io.Writer) error {
+	return writeBigInts(w, c.share, c.public.X, c.public.Y,
+		c.paillierN, c.encShare)
+}
+
+// DecodeCoSigner2 reads a share written by CoSigner2.Encode.
+func DecodeCoSigner2(r io.Reader) (*CoSigner2, error) {
+	v, err := readBigInts(r, 5)
+	if err != nil {
+		return nil, err
+	}
+	if !validScalar(v[0]) {
+		return nil, ErrInvalidCoSignShare
+	}
+	public, err := coSignPublic(v[1], v[2])
+	if err != nil {
+		return nil, err
+	}
+	return &CoSigner2{
+		share:     v[0],
+		public:    public,
+		paillierN: v[3],
+		encShare:  v[4],
+	}, nil
+}
+
+// validScalar reports whether d is in [1, N-1], as a key share must be.
+func validScalar(d *big.Int) bool {
+	return d.Sign() > 0 && d.Cmp(s256.N) < 0
+}
+
+func coSignPublic(x, y *big.Int) (*btcec.PublicKey, error) {
+	if !s256.IsOnCurve(x, y) {
+		return nil, ErrInvalidCoSignShare
+	}
+	return &btcec.PublicKey{Curve: s256, X: x, Y: y}, nil
+}
+
+func writeBigInts(w io.Writer, v ...*big.Int) error {
+	for _, i := range v {
+		if err := WriteVarBytes(w, i.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBigInts(r io.Reader, count int) ([]*big.Int, error) {
+	v := make([]*big.Int, count)
+	for i := range v {
+		b, err := ReadVarBytes(r, maxCoSignFieldSize, "co-signing key")
+		if err != nil {
+			return nil, err
+		}
+		v[i] = new(big.Int).SetBytes(b)
+	}
+	return v, nil
+}
+
+// Encode writes the request to w.
+func (req *CoSignRequest) Encode(w io.Writer) error {
+	return WriteVarBytes(w, req.R1.SerializeCompressed())
+}
+
+// DecodeCoSignRequest reads a request written by CoSignRequest.Encode.
+func DecodeCoSignRequest(r io.Reader) (*CoSignRequest, error) {
+	b, err := ReadVarBytes(r, btcec.PubKeyBytesLenCompressed, "R1")
+	if err != nil {
+		return nil, err
+	}
+	R1, err := btcec.ParsePubKey(b, s256)
+	if err != nil {
+		return nil, err
+	}
+	return &CoSignRequest{R1: R1}, nil
+}
+
+// Encode writes the partial signature to w.
+func (p *PartialSignature) Encode(w io.Writer) error {
+	if err := WriteVarBytes(w, p.R2.SerializeCompressed()); err != nil {
+		return err
+	}
+	return WriteVarBytes(w, p.C.Bytes())
+}
+
+// DecodePartialSignature reads a partial signature written by
+// PartialSignature.Encode.
+func DecodePartialSignature(r io.Reader) (*PartialSignature, error) {
+	b, err := ReadVarBytes(r, btcec.PubKeyBytesLenCompressed, "R2")
+	if err != nil {
+		return nil, err
+	}
+	R2, err := btcec.ParsePubKey(b, s256)
+	if err != nil {
+		return nil, err
+	}
+	b, err = ReadVarBytes(r, maxCoSignFieldSize, "partial signature")
+	if err != nil {
+		return nil, err
+	}
+	return &PartialSignature{R2: R2, C: new(big.Int).SetBytes(b)}, nil
+}
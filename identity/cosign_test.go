@@ -0,0 +1,112 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+func TestCoSign(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer1, signer2, err := identity.SplitSigningKey(key)
+	if err != nil {
+		t.Fatalf("SplitSigningKey error %v", err)
+	}
+	if !(*btcec.PublicKey)(signer1.PublicKey()).IsEqual(key.PubKey()) ||
+		!(*btcec.PublicKey)(signer2.PublicKey()).IsEqual(key.PubKey()) {
+		t.Fatalf("shares have the wrong public key")
+	}
+
+	// Move the shares to their devices.
+	var b bytes.Buffer
+	if err = signer1.Encode(&b); err != nil {
+		t.Fatalf("CoSigner1.Encode error %v", err)
+	}
+	if signer1, err = identity.DecodeCoSigner1(&b); err != nil {
+		t.Fatalf("DecodeCoSigner1 error %v", err)
+	}
+	if err = signer2.Encode(&b); err != nil {
+		t.Fatalf("CoSigner2.Encode error %v", err)
+	}
+	if signer2, err = identity.DecodeCoSigner2(&b); err != nil {
+		t.Fatalf("DecodeCoSigner2 error %v", err)
+	}
+
+	// Shares outside [1, N-1] are rejected. The share is the first field.
+	for i, share := range [][]byte{{}, btcec.S256().N.Bytes()} {
+		bad := append([]byte{byte(len(share))}, share...)
+		signer1.Encode(&b)
+		rest := b.Bytes()[1+b.Bytes()[0]:]
+		if _, err = identity.DecodeCoSigner1(bytes.NewReader(append(bad, rest...))); err != identity.ErrInvalidCoSignShare {
+			t.Errorf("DecodeCoSigner1 #%d got error %v want %v", i, err,
+				identity.ErrInvalidCoSignShare)
+		}
+		b.Reset()
+		signer2.Encode(&b)
+		rest = b.Bytes()[1+b.Bytes()[0]:]
+		if _, err = identity.DecodeCoSigner2(bytes.NewReader(append(bad, rest...))); err != identity.ErrInvalidCoSignShare {
+			t.Errorf("DecodeCoSigner2 #%d got error %v want %v", i, err,
+				identity.ErrInvalidCoSignShare)
+		}
+		b.Reset()
+	}
+
+	for i, msg := range []string{"hello", "official announcement", ""} {
+		hash := sha256.Sum256([]byte(msg))
+
+		session, req, err := signer1.Begin()
+		if err != nil {
+			t.Fatalf("Begin #%d error %v", i, err)
+		}
+		b.Reset()
+		req.Encode(&b)
+		if req, err = identity.DecodeCoSignRequest(&b); err != nil {
+			t.Fatalf("DecodeCoSignRequest #%d error %v", i, err)
+		}
+
+		partial, err := signer2.PartialSign(hash[:], req)
+		if err != nil {
+			t.Fatalf("PartialSign #%d error %v", i, err)
+		}
+		b.Reset()
+		partial.Encode(&b)
+		if partial, err = identity.DecodePartialSignature(&b); err != nil {
+			t.Fatalf("DecodePartialSignature #%d error %v", i, err)
+		}
+
+		sig, err := session.Combine(hash[:], partial)
+		if err != nil {
+			t.Fatalf("Combine #%d error %v", i, err)
+		}
+		parsed, err := btcec.ParseSignature(sig.Serialize(), btcec.S256())
+		if err != nil || !parsed.Verify(hash[:], key.PubKey()) {
+			t.Errorf("signature #%d does not verify", i)
+		}
+
+		if _, err = session.Combine(hash[:], partial); err != identity.ErrSessionUsed {
+			t.Errorf("Combine twice #%d got error %v want %v", i, err,
+				identity.ErrSessionUsed)
+		}
+	}
+
+	// A partial signature for a different hash does not combine.
+	hash := sha256.Sum256([]byte("one"))
+	other := sha256.Sum256([]byte("two"))
+	session, req, _ := signer1.Begin()
+	partial, _ := signer2.PartialSign(other[:], req)
+	if _, err = session.Combine(hash[:], partial); err != identity.ErrInvalidPartialSignature {
+		t.Errorf("Combine with wrong hash got error %v want %v", err,
+			identity.ErrInvalidPartialSignature)
+	}
+}
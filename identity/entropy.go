@@ -0,0 +1,68 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// ErrLowEntropy is returned when a source of randomness given for key
+// generation produces output which cannot be random, such as a block of
+// zeros or the same block twice in a row.
+var ErrLowEntropy = errors.New("entropy source is not random")
+
+// NewRandomFromReader is like NewRandom, but reads the private keys from r
+// rather than from crypto/rand, so that keys can come from a hardware RNG or
+// be reproduced in tests. r must be a cryptographically secure source in any
+// other use. Obvious failures, such as a reader stuck on one value, are
+// caught and ErrLowEntropy returned, but this is no test that r is random.
+func NewRandomFromReader(r io.Reader, initialZeros int) (*PrivateKey, error) {
+	return newRandom(DefaultAddressVersion, initialZeros, newKeySource(r), nil)
+}
+
+// keySource returns new private keys.
+type keySource func() (*btcec.PrivateKey, error)
+
+// newKeySource returns a keySource which reads keys from r, or from
+// crypto/rand if r is nil.
+func newKeySource(r io.Reader) keySource {
+	if r == nil {
+		return func() (*btcec.PrivateKey, error) {
+			return btcec.NewPrivateKey(btcec.S256())
+		}
+	}
+
+	// Only a hash of the last block is kept, so that the source does not
+	// hold on to a copy of the last key.
+	var last [sha256.Size]byte
+	return func() (*btcec.PrivateKey, error) {
+		var b [32]byte
+		for {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(b[:])
+			if sum == last || bytes.Count(b[:], b[:1]) == len(b) {
+				return nil, ErrLowEntropy
+			}
+			last = sum
+
+			// Read again if the number is not a valid key, which is one
+			// time in 2^128.
+			if validScalar(new(big.Int).SetBytes(b[:])) {
+				key, _ := btcec.PrivKeyFromBytes(btcec.S256(), b[:])
+				zero(b[:])
+				return key, nil
+			}
+		}
+	}
+}
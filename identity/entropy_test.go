@@ -0,0 +1,44 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestNewRandomFromReader(t *testing.T) {
+	a, err := identity.NewRandomFromReader(rand.New(rand.NewSource(1)), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := identity.NewRandomFromReader(rand.New(rand.NewSource(1)), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *a.Hash() != *b.Hash() {
+		t.Error("keys from the same source differ")
+	}
+	if a.Hash()[0] != 0 {
+		t.Errorf("got hash %x", a.Hash())
+	}
+
+	// A source of zeros and one that repeats itself are both rejected.
+	if _, err = identity.NewRandomFromReader(bytes.NewReader(make([]byte, 64)), 1); err != identity.ErrLowEntropy {
+		t.Errorf("zeros: got %v want %v", err, identity.ErrLowEntropy)
+	}
+	block := bytes.Repeat([]byte{1, 2, 3, 4}, 8)
+	stuck := bytes.NewReader(append(append([]byte{}, block...), block...))
+	if _, err = identity.NewRandomFromReader(stuck, 1); err != identity.ErrLowEntropy {
+		t.Errorf("repeated block: got %v want %v", err, identity.ErrLowEntropy)
+	}
+
+	if _, err = identity.NewRandomFromReader(bytes.NewReader(block), 1); err == nil {
+		t.Error("short source: expected error got none")
+	}
+}
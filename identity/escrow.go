@@ -0,0 +1,326 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/base58"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// MaxEscrowShares is the largest number of shares an identity can be split
+// into.
+const MaxEscrowShares = 255
+
+// escrowShareVersion is the first byte of an encoded EscrowShare.
+const escrowShareVersion = 1
+
+// escrowSecretSize is the size of the shared secret, which is the signing
+// key followed by the decryption key.
+const escrowSecretSize = 64
+
+var (
+	// ErrInvalidThreshold is returned when asked to split an identity into
+	// shares with a threshold that is out of range.
+	ErrInvalidThreshold = errors.New("invalid escrow threshold")
+
+	// ErrNotEnoughShares is returned when fewer shares than the threshold
+	// are given to RecoverEscrow.
+	ErrNotEnoughShares = errors.New("not enough escrow shares")
+
+	// ErrInconsistentShares is returned when escrow shares do not belong
+	// to the same split or one of them was changed.
+	ErrInconsistentShares = errors.New("escrow shares do not match")
+
+	// ErrMalformedShare is returned when an escrow share cannot be decoded.
+	ErrMalformedShare = errors.New("malformed escrow share")
+)
+
+// EscrowShare is one share of an identity's private keys, made by
+// EscrowShares.
+//
+// Besides its part of the keys, a share carries the address version and
+// stream, which are not secret, an identifier chosen at random for the split
+// it belongs to and a check over the rest of the share. These are there so
+// that shares from different splits are not silently combined into garbage
+// and a share which was changed is caught. None of them depends on the keys.
+type EscrowShare struct {
+	// Index is the share's x coordinate, from 1 to MaxEscrowShares.
+	Index byte
+
+	// Threshold is the number of shares needed to recover the identity.
+	Threshold byte
+
+	Version uint64
+	Stream  uint64
+
+	split [8]byte
+	check [4]byte
+	data  [escrowSecretSize]byte
+}
+
+// EscrowShares splits the identity's private keys into n shares using
+// Shamir's secret sharing, so that any k of them can be put back together to
+// recover the identity but fewer than k reveal nothing about the keys. The
+// shares can be handed to friends as recovery material without trusting any
+// one of them with the identity.
+func (id *PrivateAddress) EscrowShares(k, n int) ([]*EscrowShare, error) {
+	if k < 1 || n < k || n > MaxEscrowShares {
+		return nil, ErrInvalidThreshold
+	}
+
+	var secret [escrowSecretSize]byte
+	copy(secret[:32], paddedKey(id.private.Signing))
+	copy(secret[32:], paddedKey(id.private.Decryption))
+	defer zero(secret[:])
+
+	var split [8]byte
+	if _, err := io.ReadFull(rand.Reader, split[:]); err != nil {
+		return nil, err
+	}
+
+	shares := make([]*EscrowShare, n)
+	for i := range shares {
+		shares[i] = &EscrowShare{
+			Index:     byte(i + 1),
+			Threshold: byte(k),
+			Version:   id.version,
+			Stream:    id.stream,
+			split:     split,
+		}
+	}
+
+	// Every byte of the secret is the constant term of its own random
+	// polynomial of degree k-1.
+	coeffs := make([]byte, k)
+	defer zero(coeffs)
+	for b := range secret {
+		coeffs[0] = secret[b]
+		if _, err := io.ReadFull(rand.Reader, coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for _, s := range shares {
+			s.data[b] = gfEval(coeffs, s.Index)
+		}
+	}
+	for _, s := range shares {
+		s.check = s.escrowCheck()
+	}
+
+	return shares, nil
+}
+
+// RecoverEscrow puts an identity back together from its escrow shares. Any
+// threshold number of distinct shares from the same split will do; extra
+// shares are checked for consistency but otherwise ignored.
+func RecoverEscrow(shares []*EscrowShare) (*PrivateAddress, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	first := shares[0]
+	seen := make(map[byte]bool)
+	for _, s := range shares {
+		if s.Index == 0 || seen[s.Index] || s.Threshold != first.Threshold ||
+			s.Version != first.Version || s.Stream != first.Stream ||
+			s.split != first.split {
+			return nil, ErrInconsistentShares
+		}
+		check := s.escrowCheck()
+		if subtle.ConstantTimeCompare(check[:], s.check[:]) != 1 {
+			return nil, ErrInconsistentShares
+		}
+		seen[s.Index] = true
+	}
+	k := int(first.Threshold)
+	if k == 0 || len(shares) < k {
+		return nil, ErrNotEnoughShares
+	}
+	shares = shares[:k]
+
+	// Lagrange interpolation at zero.
+	var secret [escrowSecretSize]byte
+	defer zero(secret[:])
+	for i, si := range shares {
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj.Index, sj.Index^si.Index))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(si.data[b], basis)
+		}
+	}
+
+	signing, _ := btcec.PrivKeyFromBytes(btcec.S256(), secret[:32])
+	decryption, _ := btcec.PrivKeyFromBytes(btcec.S256(), secret[32:])
+	return NewPrivateAddress(&PrivateKey{
+		Signing:    signing,
+		Decryption: decryption,
+	}, first.Version, first.Stream), nil
+}
+
+// Encode writes the share to w.
+func (s *EscrowShare) Encode(w io.Writer) error {
+	var err error
+	if _, err = w.Write([]byte{escrowShareVersion, s.Index, s.Threshold}); err != nil {
+		return err
+	}
+	if err = WriteVarInt(w, s.Version); err != nil {
+		return err
+	}
+	if err = WriteVarInt(w, s.Stream); err != nil {
+		return err
+	}
+	if _, err = w.Write(s.split[:]); err != nil {
+		return err
+	}
+	if _, err = w.Write(s.check[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(s.data[:])
+	return err
+}
+
+// DecodeEscrowShare reads a share written by Encode.
+func DecodeEscrowShare(r io.Reader) (*EscrowShare, error) {
+	var head [3]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	if head[0] != escrowShareVersion || head[1] == 0 || head[2] == 0 {
+		return nil, ErrMalformedShare
+	}
+
+	s := &EscrowShare{
+		Index:     head[1],
+		Threshold: head[2],
+	}
+
+	var err error
+	if s.Version, err = ReadVarInt(r); err != nil {
+		return nil, err
+	}
+	if s.Stream, err = ReadVarInt(r); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r, s.split[:]); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r, s.check[:]); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r, s.data[:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// String returns the share as base58 text with a checksum, which is easier
+// to pass around or write down than the binary encoding.
+func (s *EscrowShare) String() string {
+	var b bytes.Buffer
+	s.Encode(&b)
+	return base58.EncodeCheck(b.Bytes())
+}
+
+// ParseEscrowShare reads a share in the form returned by String.
+func ParseEscrowShare(str string) (*EscrowShare, error) {
+	body, err := base58.DecodeCheck(str)
+	if err == base58.ErrInvalidFormat {
+		return nil, ErrMalformedShare
+	}
+	if err != nil {
+		return nil, ErrChecksumMismatch
+	}
+
+	r := bytes.NewReader(body)
+	s, err := DecodeEscrowShare(r)
+	if err != nil {
+		return nil, ErrMalformedShare
+	}
+	if r.Len() != 0 {
+		return nil, ErrMalformedShare
+	}
+	return s, nil
+}
+
+// escrowCheck returns the check stored with the share, which is a hash of
+// the rest of the share keyed with the identifier of its split. It is over
+// the share alone, which reveals nothing about the keys by itself, so the
+// check does not either.
+func (s *EscrowShare) escrowCheck() (check [4]byte) {
+	mac := hmac.New(sha512.New, s.split[:])
+	mac.Write([]byte{s.Index, s.Threshold})
+	WriteVarInt(mac, s.Version)
+	WriteVarInt(mac, s.Stream)
+	mac.Write(s.data[:])
+	copy(check[:], mac.Sum(nil))
+	return
+}
+
+// paddedKey returns the private key as exactly 32 bytes.
+func paddedKey(key *btcec.PrivateKey) []byte {
+	b := key.D.Bytes()
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Arithmetic in GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1.
+var gfExp, gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		// Multiply by the generator x + 1.
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+// gfDiv returns a/b. b must not be zero.
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+// gfEval evaluates the polynomial with the given coefficients at x.
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestEscrow(t *testing.T) {
+	key, err := NewRandom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := NewPrivateAddress(key, 4, 1)
+
+	if _, err := id.EscrowShares(0, 3); err != ErrInvalidThreshold {
+		t.Errorf("EscrowShares(0, 3) got error %v", err)
+	}
+	if _, err := id.EscrowShares(4, 3); err != ErrInvalidThreshold {
+		t.Errorf("EscrowShares(4, 3) got error %v", err)
+	}
+
+	shares, err := id.EscrowShares(3, 5)
+	if err != nil {
+		t.Fatalf("EscrowShares error %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("got %d shares, want 5", len(shares))
+	}
+
+	// Every combination of three or more shares recovers the identity.
+	for mask := 0; mask < 1<<5; mask++ {
+		var subset []*EscrowShare
+		for i, s := range shares {
+			if mask&(1<<uint(i)) != 0 {
+				subset = append(subset, s)
+			}
+		}
+
+		recovered, err := RecoverEscrow(subset)
+		if len(subset) < 3 {
+			if err != ErrNotEnoughShares {
+				t.Errorf("RecoverEscrow with %d shares got error %v", len(subset), err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("RecoverEscrow with mask %b got error %v", mask, err)
+			continue
+		}
+		if recovered.Address().String() != id.Address().String() {
+			t.Errorf("RecoverEscrow with mask %b got address %s, want %s",
+				mask, recovered.Address(), id.Address())
+		}
+		s1, d1 := recovered.PrivateKey().ExportWIF()
+		s2, d2 := key.ExportWIF()
+		if s1 != s2 || d1 != d2 {
+			t.Errorf("RecoverEscrow with mask %b got the wrong keys", mask)
+		}
+	}
+
+	// Shares survive the text encoding.
+	var parsed []*EscrowShare
+	for _, s := range shares[2:] {
+		p, err := ParseEscrowShare(s.String())
+		if err != nil {
+			t.Fatalf("ParseEscrowShare error %v", err)
+		}
+		parsed = append(parsed, p)
+	}
+	recovered, err := RecoverEscrow(parsed)
+	if err != nil {
+		t.Fatalf("RecoverEscrow of parsed shares got error %v", err)
+	}
+	if recovered.Address().String() != id.Address().String() {
+		t.Errorf("RecoverEscrow of parsed shares got the wrong address")
+	}
+
+	// A duplicated share doesn't count twice.
+	if _, err := RecoverEscrow([]*EscrowShare{shares[0], shares[1], shares[0]}); err != ErrInconsistentShares {
+		t.Errorf("RecoverEscrow with duplicate got error %v", err)
+	}
+
+	// Shares of another identity can't be mixed in.
+	other, _ := NewRandom(1)
+	otherShares, _ := NewPrivateAddress(other, 4, 1).EscrowShares(3, 5)
+	if _, err := RecoverEscrow([]*EscrowShare{shares[0], shares[1], otherShares[2]}); err != ErrInconsistentShares {
+		t.Errorf("RecoverEscrow with mixed shares got error %v", err)
+	}
+
+	// Nor can shares of another split of the same identity, which have
+	// nothing in common with the first but the address.
+	again, _ := id.EscrowShares(3, 5)
+	if _, err := RecoverEscrow([]*EscrowShare{shares[0], shares[1], again[2]}); err != ErrInconsistentShares {
+		t.Errorf("RecoverEscrow with shares of two splits got error %v", err)
+	}
+
+	// A share which was changed is detected.
+	var b bytes.Buffer
+	shares[2].Encode(&b)
+	enc := b.Bytes()
+	enc[len(enc)-1] ^= 1
+	tampered, err := DecodeEscrowShare(bytes.NewReader(enc))
+	if err != nil {
+		t.Fatalf("DecodeEscrowShare error %v", err)
+	}
+	if _, err := RecoverEscrow([]*EscrowShare{shares[0], shares[1], tampered}); err != ErrInconsistentShares {
+		t.Errorf("RecoverEscrow with tampered share got error %v", err)
+	}
+
+	str := shares[0].String()
+	last := "z"
+	if str[len(str)-1] == 'z' {
+		last = "y"
+	}
+	if _, err := ParseEscrowShare(str[:len(str)-1] + last); err != ErrChecksumMismatch {
+		t.Errorf("ParseEscrowShare with bad checksum got error %v", err)
+	}
+}
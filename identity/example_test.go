@@ -0,0 +1,58 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"fmt"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// This example generates a new identity with a random address and gets the
+// keys for it in wallet import format to back it up.
+func ExampleNewRandom() {
+	key, err := identity.NewRandom(1)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	id := identity.NewPrivateAddress(key, bmutil.DefaultAddressVersion,
+		bmutil.DefaultStream)
+
+	addr, signing, decryption := id.ExportWIF()
+	imported, err := identity.ImportWIF(addr, signing, decryption)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(imported.Address().Equal(id.Address()))
+
+	// Output:
+	// true
+}
+
+// This example imports an identity from the WIF keys exported by another
+// client and makes a PrivateID from it, which is what is needed to send and
+// receive messages.
+func ExampleImportWIF() {
+	addr, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	id := identity.NewPrivateID(addr, identity.BehaviorAck, &pow.Default)
+
+	// The public half can be given to anyone.
+	fmt.Println(id.Public().Address())
+	fmt.Println(id.Pow())
+
+	// Output:
+	// BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B
+	// {1000, 1000}
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+
+	. "github.com/DanielKrawisz/bmutil"
+)
+
+// IdentityFingerprintSize is the number of bytes in the fingerprint of a
+// public identity.
+const IdentityFingerprintSize = 10
+
+// identityFingerprint returns the first IdentityFingerprintSize bytes of the
+// sha512 of the address version and stream and both public keys.
+func identityFingerprint(pub Public) []byte {
+	addr := pub.Address()
+	key := pub.Key()
+
+	var b bytes.Buffer
+	WriteVarInt(&b, addr.Version())
+	WriteVarInt(&b, addr.Stream())
+	b.Write(key.Verification.Wire()[:])
+	b.Write(key.Encryption.Wire()[:])
+	sum := sha512.Sum512(b.Bytes())
+	return sum[:IdentityFingerprintSize]
+}
+
+// IdentityFingerprint returns a short digest of the public identity in hex,
+// in groups of four digits, such as "7c1e 09d4 a3f2 5b60 e18d". Unlike the
+// fingerprint of an address, it covers the public keys themselves, so two
+// users who read theirs to each other know that they have the same keys as
+// well as the same address. It can be used by implementations of Public.
+func IdentityFingerprint(pub Public) string {
+	digits := hex.EncodeToString(identityFingerprint(pub))
+
+	groups := make([]string, 0, len(digits)/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// FingerprintWords returns the fingerprint of the public identity as words
+// from the PGP word list, one per byte, which are easier to read out over a
+// voice call than hex digits.
+func FingerprintWords(pub Public) string {
+	return fingerprintWords(identityFingerprint(pub))
+}
+
+func fingerprintWords(fp []byte) string {
+	words := make([]string, len(fp))
+	for i, c := range fp {
+		if i%2 == 0 {
+			words[i] = pgpEvenWords[c]
+		} else {
+			words[i] = pgpOddWords[c]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// MatchIdentityFingerprint says whether fp is the fingerprint of the public
+// identity, either as hex, as returned by IdentityFingerprint, or as words,
+// as returned by FingerprintWords. Case and any spaces, dashes or colons
+// separating the digits or words are ignored.
+func MatchIdentityFingerprint(pub Public, fp string) bool {
+	fields := strings.FieldsFunc(strings.ToLower(fp), func(r rune) bool {
+		switch r {
+		case ' ', '-', ':':
+			return true
+		}
+		return false
+	})
+
+	want := identityFingerprint(pub)
+	if got, ok := decodeFingerprintWords(fields); ok {
+		return bytes.Equal(got, want)
+	}
+	return strings.Join(fields, "") == hex.EncodeToString(want)
+}
+
+// decodeFingerprintWords returns the bytes that the words stand for, if they
+// are all in the right list for their position.
+func decodeFingerprintWords(words []string) ([]byte, bool) {
+	if len(words) != IdentityFingerprintSize {
+		return nil, false
+	}
+
+	b := make([]byte, len(words))
+	for i, w := range words {
+		list := &pgpEvenWords
+		if i%2 == 1 {
+			list = &pgpOddWords
+		}
+		c, ok := pgpWordIndex(list, w)
+		if !ok {
+			return nil, false
+		}
+		b[i] = c
+	}
+	return b, true
+}
+
+// pgpWordIndex finds a word, in lower case, in a list.
+func pgpWordIndex(list *[256]string, word string) (byte, bool) {
+	for i, w := range list {
+		if strings.ToLower(w) == word {
+			return byte(i), true
+		}
+	}
+	return 0, false
+}
+
+// Fingerprint returns the fingerprint of the identity.
+func (id *publicID) Fingerprint() string {
+	return IdentityFingerprint(id)
+}
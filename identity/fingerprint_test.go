@@ -0,0 +1,77 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestFingerprintWords(t *testing.T) {
+	// The example from the description of the PGP word list.
+	fp, _ := hex.DecodeString("E58294F2E9A227486E8B061B31CC528FD7FA3F19")
+	want := "topmost Istanbul Pluto vagabond treadmill Pacific brackish " +
+		"dictator goldfish Medusa afflict bravado chatter revolver Dupont " +
+		"midsummer stopwatch whimsical cowbell bottomless"
+	if got := identity.TstFingerprintWords(fp); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestIdentityFingerprint(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	pub := identity.NewPrivateID(priv, identity.BehaviorAck, &pow.Default).Public()
+	ch, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := pub.Fingerprint()
+	if len(fp) != identity.IdentityFingerprintSize*2+identity.IdentityFingerprintSize/2-1 {
+		t.Errorf("fingerprint %q has the wrong length", fp)
+	}
+	if fp == ch.Public().Fingerprint() {
+		t.Errorf("different identities have the same fingerprint")
+	}
+
+	// Behavior and proof of work are not part of it.
+	other := identity.NewPrivateID(priv, 0, &pow.Data{NonceTrialsPerByte: 5000,
+		ExtraBytes: 5000}).Public()
+	if other.Fingerprint() != fp {
+		t.Errorf("fingerprint depends on more than the keys and address")
+	}
+
+	words := identity.FingerprintWords(pub)
+	if n := len(strings.Fields(words)); n != identity.IdentityFingerprintSize {
+		t.Errorf("got %d words, want %d", n, identity.IdentityFingerprintSize)
+	}
+
+	for _, s := range []string{fp, strings.ToUpper(fp),
+		strings.Replace(fp, " ", ":", -1), words, strings.ToUpper(words),
+		strings.Replace(words, " ", "-", -1)} {
+		if !identity.MatchIdentityFingerprint(pub, s) {
+			t.Errorf("%q does not match", s)
+		}
+	}
+
+	// Swapping two words breaks the alternation of the lists.
+	w := strings.Fields(words)
+	w[0], w[1] = w[1], w[0]
+	for _, s := range []string{strings.Join(w, " "), ch.Public().Fingerprint(),
+		identity.FingerprintWords(ch.Public()), fp[:len(fp)-1]} {
+		if identity.MatchIdentityFingerprint(pub, s) {
+			t.Errorf("%q matches", s)
+		}
+	}
+}
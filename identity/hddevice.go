@@ -0,0 +1,119 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// HDDevice is a BIP32 master key which is kept somewhere else, such as on a
+// hardware wallet, and which can be asked for public keys and for
+// operations with private keys but which does not give the private keys
+// out. A path is a list of child indexes below the master key, with
+// hdkeychain.HardenedKeyStart added to those which are hardened.
+type HDDevice interface {
+	// PublicKey returns the public key at the given path.
+	PublicKey(path []uint32) (*btcec.PublicKey, error)
+
+	// Sign signs a hash with the private key at the given path.
+	Sign(path []uint32, hash []byte) (*btcec.Signature, error)
+
+	// Decrypt decrypts data which was encrypted to the public key at the
+	// given path, as by btcec.Decrypt.
+	Decrypt(path []uint32, data []byte) ([]byte, error)
+}
+
+// DeviceKey is the equivalent of a PrivateKey for keys held by an HDDevice.
+// It identifies the keys by their paths and sends everything requiring the
+// private keys to the device.
+type DeviceKey struct {
+	Device         HDDevice
+	SigningPath    []uint32
+	DecryptionPath []uint32
+
+	public *PublicKey
+}
+
+// Public returns the public keys.
+func (dk *DeviceKey) Public() *PublicKey {
+	return dk.public
+}
+
+// Hash returns the ripemd160 hash used in an address of
+// DefaultAddressVersion.
+func (dk *DeviceKey) Hash() *hash.Ripe {
+	return dk.public.HashForVersion(DefaultAddressVersion)
+}
+
+// Sign signs a hash with the signing key.
+func (dk *DeviceKey) Sign(hash []byte) (*btcec.Signature, error) {
+	return dk.Device.Sign(dk.SigningPath, hash)
+}
+
+// Decrypt decrypts data encrypted to the encryption key.
+func (dk *DeviceKey) Decrypt(data []byte) ([]byte, error) {
+	return dk.Device.Decrypt(dk.DecryptionPath, data)
+}
+
+// NewDeviceKey returns the DeviceKey whose keys are at the given paths on a
+// device.
+func NewDeviceKey(device HDDevice, signingPath, decryptionPath []uint32) (*DeviceKey, error) {
+	signing, err := device.PublicKey(signingPath)
+	if err != nil {
+		return nil, err
+	}
+	decryption, err := device.PublicKey(decryptionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeviceKey{
+		Device:         device,
+		SigningPath:    signingPath,
+		DecryptionPath: decryptionPath,
+		public: &PublicKey{
+			Verification: (*PubKey)(signing),
+			Encryption:   (*PubKey)(decryption),
+		},
+	}, nil
+}
+
+// NewHDFromDevice derives the same keys as NewHD would from the master key
+// held by a device. Only public keys are asked of the device, so it
+// doesn't have to sign anything to create the identity.
+func NewHDFromDevice(device HDDevice, n uint32, stream uint64) (*DeviceKey, error) {
+	// m / purpose' / identity' / stream' / address'
+	account := DefaultHDPath(n, stream)
+	child := account.child
+
+	// m / purpose' / identity' / stream' / address' / 0
+	signing, err := device.PublicKey(child(0))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint32(1); ; i++ {
+		decryption, err := device.PublicKey(child(i))
+		if err != nil {
+			return nil, err
+		}
+		public := &PublicKey{
+			Verification: (*PubKey)(signing),
+			Encryption:   (*PubKey)(decryption),
+		}
+
+		// First byte should be zero.
+		if public.HashForVersion(DefaultAddressVersion)[0] == 0x00 {
+			return &DeviceKey{
+				Device:         device,
+				SigningPath:    child(0),
+				DecryptionPath: child(i),
+				public:         public,
+			}, nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// softDevice is an HDDevice which keeps its master key in memory.
+type softDevice struct {
+	master *hdkeychain.ExtendedKey
+}
+
+func (d *softDevice) key(path []uint32) (*btcec.PrivateKey, error) {
+	k := d.master
+	for _, i := range path {
+		var err error
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k.ECPrivKey()
+}
+
+func (d *softDevice) PublicKey(path []uint32) (*btcec.PublicKey, error) {
+	k, err := d.key(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.PubKey(), nil
+}
+
+func (d *softDevice) Sign(path []uint32, hash []byte) (*btcec.Signature, error) {
+	k, err := d.key(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.Sign(hash)
+}
+
+func (d *softDevice) Decrypt(path []uint32, data []byte) ([]byte, error) {
+	k, err := d.key(path)
+	if err != nil {
+		return nil, err
+	}
+	return btcec.Decrypt(k, data)
+}
+
+func TestNewHDFromDevice(t *testing.T) {
+	master, err := hdkeychain.NewMaster([]byte("somegoodrandomseedwouldbeusefulhere"),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk, err := identity.NewHD(master, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dk, err := identity.NewHDFromDevice(&softDevice{master}, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dk.Hash().IsEqual(pk.Hash()) ||
+		!dk.Public().Verification.IsEqual(pk.Public().Verification) ||
+		!dk.Public().Encryption.IsEqual(pk.Public().Encryption) {
+		t.Fatalf("got keys %s, want %s", dk.Public(), pk.Public())
+	}
+
+	hash := bytes.Repeat([]byte{7}, 32)
+	sig, err := dk.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Verify(hash, pk.Signing.PubKey()) {
+		t.Error("device signature does not verify")
+	}
+
+	encrypted, err := btcec.Encrypt(pk.Decryption.PubKey(), []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := dk.Decrypt(encrypted)
+	if err != nil || string(decrypted) != "hello" {
+		t.Errorf("Decrypt got %q, %v", decrypted, err)
+	}
+
+	again, err := identity.NewDeviceKey(dk.Device, dk.SigningPath, dk.DecryptionPath)
+	if err != nil || !again.Hash().IsEqual(dk.Hash()) {
+		t.Errorf("NewDeviceKey got %v, %v", again, err)
+	}
+}
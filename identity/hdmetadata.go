@@ -0,0 +1,227 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+var (
+	// ErrDuplicateHDIndex is returned when HD metadata is added for an
+	// index and stream that are already described.
+	ErrDuplicateHDIndex = errors.New("HD index already in metadata")
+
+	// ErrHDMetadataMismatch is returned when HD metadata does not belong
+	// to the master key it is restored with.
+	ErrHDMetadataMismatch = errors.New("HD metadata does not match master key")
+)
+
+// HDAccount describes one identity derived from an HD master key with NewHD.
+type HDAccount struct {
+	Index  uint32
+	Stream uint64
+	Label  string
+
+	// Created is when the identity was first derived. The zero time means
+	// it is not known.
+	Created time.Time
+
+	// Address is the address derived at the index. It is used to check
+	// that the metadata belongs to the master key it is restored with.
+	Address string
+}
+
+// HDMetadata is a sidecar to an HD master key. The seed alone is enough to
+// get the keys back, but not to know which indices were in use or what the
+// user called them; HDMetadata records that so a restore from seed can put
+// the user's identities back the way they were.
+type HDMetadata struct {
+	accounts []*HDAccount
+}
+
+// NewHDMetadata returns empty metadata.
+func NewHDMetadata() *HDMetadata {
+	return &HDMetadata{}
+}
+
+// Add records an account. Its Address should be the one derived from the
+// master key at the account's index and stream.
+func (m *HDMetadata) Add(account *HDAccount) error {
+	if strings.ContainsAny(account.Label, "\r\n") {
+		return ErrInvalidLabel
+	}
+	if m.Get(account.Index, account.Stream) != nil {
+		return ErrDuplicateHDIndex
+	}
+
+	a := *account
+	m.accounts = append(m.accounts, &a)
+	sort.Slice(m.accounts, func(i, j int) bool {
+		if m.accounts[i].Index != m.accounts[j].Index {
+			return m.accounts[i].Index < m.accounts[j].Index
+		}
+		return m.accounts[i].Stream < m.accounts[j].Stream
+	})
+	return nil
+}
+
+// Get returns the account with the given index and stream, or nil if there
+// is none.
+func (m *HDMetadata) Get(index uint32, stream uint64) *HDAccount {
+	for _, a := range m.accounts {
+		if a.Index == index && a.Stream == stream {
+			return a
+		}
+	}
+	return nil
+}
+
+// Accounts returns the accounts in order of index.
+func (m *HDMetadata) Accounts() []*HDAccount {
+	list := make([]*HDAccount, len(m.accounts))
+	copy(list, m.accounts)
+	return list
+}
+
+// Export writes the metadata to w in an ini style, with one section per
+// account named by its index:
+//
+//	[3]
+//	stream = 1
+//	label = Work
+//	created = 2016-05-01T12:00:00Z
+//	address = BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ
+func (m *HDMetadata) Export(w io.Writer) error {
+	for i, a := range m.accounts {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "[%d]\nstream = %d\nlabel = %s\n",
+			a.Index, a.Stream, a.Label)
+		if err != nil {
+			return err
+		}
+		if !a.Created.IsZero() {
+			_, err = fmt.Fprintf(w, "created = %s\n", a.Created.UTC().Format(time.RFC3339))
+			if err != nil {
+				return err
+			}
+		}
+		if _, err = fmt.Fprintf(w, "address = %s\n", a.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportHDMetadata reads metadata in the format written by Export. Comments
+// and unknown options are skipped.
+func ImportHDMetadata(r io.Reader) (*HDMetadata, error) {
+	m := NewHDMetadata()
+	scanner := bufio.NewScanner(r)
+
+	var current *HDAccount
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		err := m.Add(current)
+		current = nil
+		return err
+	}
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			if text[len(text)-1] != ']' {
+				return nil, parseErrorf(line, "malformed section header")
+			}
+			if err := finish(); err != nil {
+				return nil, &ParseError{line, err}
+			}
+			index, err := strconv.ParseUint(strings.TrimSpace(text[1:len(text)-1]), 10, 32)
+			if err != nil {
+				return nil, parseErrorf(line, "invalid index")
+			}
+			current = &HDAccount{Index: uint32(index), Stream: DefaultStream}
+			continue
+		}
+
+		if current == nil {
+			return nil, parseErrorf(line, "option outside of section")
+		}
+
+		sep := strings.IndexAny(text, "=:")
+		if sep < 0 {
+			return nil, parseErrorf(line, "expected option")
+		}
+		key := strings.ToLower(strings.TrimSpace(text[:sep]))
+		value := strings.TrimSpace(text[sep+1:])
+
+		switch key {
+		case "stream":
+			stream, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, parseErrorf(line, "invalid stream %q", value)
+			}
+			current.Stream = stream
+		case "label":
+			current.Label = value
+		case "created":
+			created, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, parseErrorf(line, "invalid time %q", value)
+			}
+			current.Created = created
+		case "address":
+			current.Address = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, &ParseError{line, err}
+	}
+
+	return m, nil
+}
+
+// Restore derives the identity for each account from the master key, in the
+// order of Accounts. If an account's address does not match the one derived,
+// ErrHDMetadataMismatch is returned.
+func (m *HDMetadata) Restore(masterKey *hdkeychain.ExtendedKey) ([]*PrivateAddress, error) {
+	ids := make([]*PrivateAddress, 0, len(m.accounts))
+	for _, a := range m.accounts {
+		key, err := NewHD(masterKey, a.Index, a.Stream)
+		if err != nil {
+			return nil, err
+		}
+		id := NewPrivateAddress(key, DefaultAddressVersion, a.Stream)
+		if a.Address != "" && id.Address().String() != a.Address {
+			return nil, ErrHDMetadataMismatch
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestHDMetadata(t *testing.T) {
+	masterKey, err := hdkeychain.NewMaster([]byte("somegoodrandomseedwouldbeusefulhere"),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := NewHDMetadata()
+	for _, a := range []*HDAccount{
+		{Index: 3, Stream: DefaultStream, Label: "Work"},
+		{Index: 0, Stream: DefaultStream, Label: "Personal", Created: created,
+			Address: "BM-2cUqid7xty9zteYmu7aKxYiDTzL4k5YYn7"},
+	} {
+		if err = m.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = m.Add(&HDAccount{Index: 3, Stream: DefaultStream}); err != ErrDuplicateHDIndex {
+		t.Errorf("duplicate index: expected ErrDuplicateHDIndex, got %v", err)
+	}
+	if err = m.Add(&HDAccount{Index: 4, Label: "a\nb"}); err != ErrInvalidLabel {
+		t.Errorf("bad label: expected ErrInvalidLabel, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = m.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportHDMetadata(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := imported.Accounts()
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts))
+	}
+	if accounts[0].Index != 0 || accounts[0].Label != "Personal" ||
+		!accounts[0].Created.Equal(created) {
+		t.Errorf("first account: got %+v", accounts[0])
+	}
+	if accounts[1].Index != 3 || accounts[1].Label != "Work" ||
+		!accounts[1].Created.IsZero() {
+		t.Errorf("second account: got %+v", accounts[1])
+	}
+
+	ids, err := imported.Restore(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("expected 2 identities, got %d", len(ids))
+	}
+	if got := ids[0].Address().String(); got != "BM-2cUqid7xty9zteYmu7aKxYiDTzL4k5YYn7" {
+		t.Errorf("restored wrong address %s", got)
+	}
+
+	other, err := hdkeychain.NewMaster([]byte("some other seed which is long enough"),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = imported.Restore(other); err != ErrHDMetadataMismatch {
+		t.Errorf("wrong master key: expected ErrHDMetadataMismatch, got %v", err)
+	}
+}
+
+func TestImportHDMetadataErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		line int
+	}{
+		{"label = x\n", 1},
+		{"[x]\n", 1},
+		{"[1\n", 1},
+		{"[1]\nstream = abc\n", 2},
+		{"[1]\ncreated = yesterday\n", 2},
+		{"[1]\n\n[1]\n", 3},
+	}
+
+	for i, test := range tests {
+		_, err := ImportHDMetadata(strings.NewReader(test.in))
+		if e, ok := err.(*ParseError); !ok || e.Line != test.line {
+			t.Errorf("case %d: expected ParseError at line %d, got %v", i, test.line, err)
+		}
+	}
+
+	_, err := ImportHDMetadata(strings.NewReader("[1]\n[1]\n"))
+	if e, ok := err.(*ParseError); !ok || e.Err != ErrDuplicateHDIndex {
+		t.Errorf("duplicate: expected ErrDuplicateHDIndex, got %v", err)
+	}
+}
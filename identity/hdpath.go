@@ -0,0 +1,89 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// ErrInvalidHDPath is returned by ParseHDPath for a string which is not a
+// derivation path.
+var ErrInvalidHDPath = errors.New("invalid HD path")
+
+// HDPath is a list of child indexes below a master key, with
+// hdkeychain.HardenedKeyStart added to those which are hardened, as for an
+// HDDevice. The keys of an HD identity are children of the key at its path:
+// the signing key is child 0 and the encryption key is the first of
+// children 1, 2 and so on which gives an address with a leading zero.
+type HDPath []uint32
+
+// DefaultHDPath returns the path at which NewHD finds the keys of the n'th
+// identity in a stream, m / purpose' / identity' / stream' / address'.
+func DefaultHDPath(n uint32, stream uint64) HDPath {
+	return HDPath{
+		BMPurposeCode,
+		hdkeychain.HardenedKeyStart + n,
+		hdkeychain.HardenedKeyStart + uint32(stream),
+		hdkeychain.HardenedKeyStart + 0,
+	}
+}
+
+// String returns the path in the usual notation, such as m/82'/0'/1'/0'.
+func (p HDPath) String() string {
+	s := make([]string, 0, len(p)+1)
+	s = append(s, "m")
+	for _, i := range p {
+		if i >= hdkeychain.HardenedKeyStart {
+			s = append(s, strconv.FormatUint(uint64(i-hdkeychain.HardenedKeyStart), 10)+"'")
+		} else {
+			s = append(s, strconv.FormatUint(uint64(i), 10))
+		}
+	}
+	return strings.Join(s, "/")
+}
+
+// ParseHDPath reads a path in the notation returned by String. A hardened
+// index may be marked with h or H in place of '.
+func ParseHDPath(s string) (HDPath, error) {
+	parts := strings.Split(s, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidHDPath
+	}
+
+	path := make(HDPath, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		var hardened uint32
+		if n := len(part); n > 0 && strings.ContainsRune("'hH", rune(part[n-1])) {
+			part = part[:n-1]
+			hardened = hdkeychain.HardenedKeyStart
+		}
+		i, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, ErrInvalidHDPath
+		}
+		path = append(path, uint32(i)+hardened)
+	}
+	return path, nil
+}
+
+// child returns the path of the i'th child of the key at the path.
+func (p HDPath) child(i uint32) HDPath {
+	return append(append(HDPath(nil), p...), i)
+}
+
+// derive returns the key at the path below key.
+func (p HDPath) derive(key *hdkeychain.ExtendedKey) (*hdkeychain.ExtendedKey, error) {
+	var err error
+	for _, i := range p {
+		if key, err = key.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
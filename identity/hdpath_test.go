@@ -0,0 +1,85 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestHDPath(t *testing.T) {
+	path := DefaultHDPath(3, 1)
+	if s := path.String(); s != "m/82'/3'/1'/0'" {
+		t.Errorf("got %s want m/82'/3'/1'/0'", s)
+	}
+
+	tests := []struct {
+		in   string
+		want HDPath
+	}{
+		{"m", HDPath{}},
+		{"m/82'/3'/1'/0'", path},
+		{"m/82h/3H/1'/0'", path},
+		{"m/44'/0/7", HDPath{hdkeychain.HardenedKeyStart + 44, 0, 7}},
+	}
+	for _, test := range tests {
+		got, err := ParseHDPath(test.in)
+		if err != nil {
+			t.Errorf("ParseHDPath(%q) error %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseHDPath(%q) got %v want %v", test.in, got, test.want)
+		}
+	}
+	for _, in := range []string{"", "n/1", "m/", "m/x", "m/2147483648", "m/1''"} {
+		if _, err := ParseHDPath(in); err != ErrInvalidHDPath {
+			t.Errorf("ParseHDPath(%q) got %v want %v", in, err, ErrInvalidHDPath)
+		}
+	}
+}
+
+func TestNewHDWithPath(t *testing.T) {
+	seed := []byte("somegoodrandomseedwouldbeusefulhere")
+	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := NewHD(masterKey, 1, DefaultStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewHDWithPath(masterKey, DefaultHDPath(1, DefaultStream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.Hash() != *want.Hash() {
+		t.Errorf("NewHDWithPath with the default path differs from NewHD")
+	}
+
+	// Non-hardened levels are allowed too.
+	path, _ := ParseHDPath("m/0'/5")
+	custom, err := NewHDWithPath(masterKey, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.Hash()[0] != 0 {
+		t.Errorf("key has no leading zero")
+	}
+	if *custom.Hash() == *want.Hash() {
+		t.Errorf("different paths give the same keys")
+	}
+
+	public, _ := masterKey.Neuter()
+	if _, err = NewHDWithPath(public, path); err != ErrMasterKeyNotPrivate {
+		t.Errorf("got %v want %v", err, ErrMasterKeyNotPrivate)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// DefaultHDGapLimit is the number of unused identities in a row after which
+// ScanHD stops looking, as in BIP44 account discovery.
+const DefaultHDGapLimit = 20
+
+// HDIdentity is an identity found by ScanHD along with its index.
+type HDIdentity struct {
+	Index uint32
+	ID    *PrivateAddress
+}
+
+// ScanHD recovers the identities derived from an HD master key that are in
+// use, by deriving identities in the stream from index 0 up and asking seen
+// whether each address has been used, for example whether a pubkey or a
+// message for it is known. It stops after gap unused identities in a row,
+// which is DefaultHDGapLimit if gap is zero, and returns the used
+// identities in order of index.
+func ScanHD(masterKey *hdkeychain.ExtendedKey, stream uint64, gap uint32,
+	seen func(Address) bool) ([]HDIdentity, error) {
+	if err := checkStreams([]uint64{stream}); err != nil {
+		return nil, err
+	}
+	if gap == 0 {
+		gap = DefaultHDGapLimit
+	}
+
+	var found []HDIdentity
+	for n, unused := uint32(0), uint32(0); unused < gap; n++ {
+		key, err := NewHD(masterKey, n, stream)
+		if err != nil {
+			return nil, err
+		}
+		id := NewPrivateAddress(key, DefaultAddressVersion, stream)
+		if seen(id.Address()) {
+			found = append(found, HDIdentity{Index: n, ID: id})
+			unused = 0
+		} else {
+			unused++
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestScanHD(t *testing.T) {
+	seed := []byte("somegoodrandomseedwouldbeusefulhere")
+	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Identities 0, 2 and 5 have been used. With a gap limit of 2 the scan
+	// stops at 4, before 5 is reached.
+	used := make(map[string]bool)
+	for _, n := range []uint32{0, 2, 5} {
+		key, _ := NewHD(masterKey, n, DefaultStream)
+		used[NewPrivateAddress(key, DefaultAddressVersion, DefaultStream).Address().String()] = true
+	}
+	var checked int
+	seen := func(addr Address) bool {
+		checked++
+		return used[addr.String()]
+	}
+
+	found, err := ScanHD(masterKey, DefaultStream, 2, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Index != 0 || found[1].Index != 2 {
+		t.Fatalf("got %v", found)
+	}
+	if checked != 5 {
+		t.Errorf("checked %d addresses, want 5", checked)
+	}
+	if found[0].ID.Address().String() != "BM-2cUqid7xty9zteYmu7aKxYiDTzL4k5YYn7" {
+		t.Errorf("got address %s", found[0].ID.Address())
+	}
+
+	if _, err = ScanHD(masterKey, 2, 0, seen); err != ErrInvalidStream {
+		t.Errorf("got %v want %v", err, ErrInvalidStream)
+	}
+}
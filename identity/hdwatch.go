@@ -0,0 +1,88 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// hdAccountDepth is the depth of m / purpose' / identity' / stream' /
+// address'.
+const hdAccountDepth = 4
+
+// ErrNotHDAccountKey is returned when a key given to NewHDPublic is not at
+// the depth of the keys returned by HDAccountKey.
+var ErrNotHDAccountKey = errors.New("not an HD account key")
+
+// HDAccountKey returns the extended public key at m / purpose' / identity' /
+// stream' / address', below which NewHD finds the keys of the n'th identity
+// of a master key. The keys below it are not hardened, so it is enough to
+// derive the identity's public keys, and with them its address and tag,
+// without being able to sign or decrypt anything. It can be handed to audit
+// and monitoring tools along with the stream.
+func HDAccountKey(masterKey *hdkeychain.ExtendedKey, n uint32, stream uint64) (*hdkeychain.ExtendedKey, error) {
+	if !masterKey.IsPrivate() {
+		return nil, ErrMasterKeyNotPrivate
+	}
+
+	a, err := hdAccount(masterKey, n, stream)
+	if err != nil {
+		return nil, err
+	}
+	return a.Neuter()
+}
+
+// NewHDPublicKey derives the public keys of the identity that NewHD derives
+// from the master key, given the account key returned by HDAccountKey.
+func NewHDPublicKey(accountKey *hdkeychain.ExtendedKey) (*PublicKey, error) {
+	if accountKey.Depth() != hdAccountDepth {
+		return nil, ErrNotHDAccountKey
+	}
+
+	// m / purpose' / identity' / stream' / address' / 0
+	signKey, err := accountKey.Child(0)
+	if err != nil {
+		return nil, err
+	}
+	signing, err := signKey.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint32(1); ; i++ {
+		encKey, err := accountKey.Child(i)
+		if err != nil {
+			continue
+		}
+		encryption, err := encKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		pk := &PublicKey{
+			Verification: (*PubKey)(signing),
+			Encryption:   (*PubKey)(encryption),
+		}
+
+		// First byte should be zero.
+		if pk.HashForVersion(DefaultAddressVersion)[0] == 0x00 {
+			return pk, nil
+		}
+	}
+}
+
+// NewHDPublic returns the public identity of the HD identity with the given
+// account key, which must have been derived for the same stream.
+func NewHDPublic(accountKey *hdkeychain.ExtendedKey, stream uint64,
+	behavior uint32, data *pow.Data) (Public, error) {
+	pk, err := NewHDPublicKey(accountKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewPublic(pk, DefaultAddressVersion, stream, behavior, data)
+}
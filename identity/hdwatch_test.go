@@ -0,0 +1,65 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestNewHDPublic(t *testing.T) {
+	seed := []byte("somegoodrandomseedwouldbeusefulhere")
+	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := uint32(0); n < 3; n++ {
+		pvt, err := NewHD(masterKey, n, DefaultStream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		account, err := HDAccountKey(masterKey, n, DefaultStream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if account.IsPrivate() {
+			t.Errorf("HDAccountKey returned a private key")
+		}
+
+		// The account key can be passed around as a string.
+		account, err = hdkeychain.NewKeyFromString(account.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pub, err := NewHDPublic(account, DefaultStream, BehaviorAck, &pow.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := NewPrivateAddress(pvt, DefaultAddressVersion, DefaultStream).Address()
+		if !pub.Address().Equal(want) {
+			t.Errorf("identity %d: got address %s want %s", n, pub.Address(), want)
+		}
+		if n == 0 && want.String() != "BM-2cUqid7xty9zteYmu7aKxYiDTzL4k5YYn7" {
+			t.Errorf("identity 0 has address %s", want)
+		}
+	}
+
+	public, _ := masterKey.Neuter()
+	if _, err = HDAccountKey(public, 0, DefaultStream); err != ErrMasterKeyNotPrivate {
+		t.Errorf("HDAccountKey of public key: got %v want %v", err,
+			ErrMasterKeyNotPrivate)
+	}
+	if _, err = NewHDPublicKey(public); err != ErrNotHDAccountKey {
+		t.Errorf("NewHDPublicKey of master key: got %v want %v", err,
+			ErrNotHDAccountKey)
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+// TstHKDF exposes hkdf for testing.
+func TstHKDF(secret, salt, info []byte, length int) []byte {
+	return hkdf(secret, salt, info, length)
+}
+
+// TstFingerprintWords exposes fingerprintWords for testing.
+func TstFingerprintWords(fp []byte) string {
+	return fingerprintWords(fp)
+}
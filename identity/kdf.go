@@ -0,0 +1,53 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	. "github.com/DanielKrawisz/bmutil"
+	"golang.org/x/crypto/scrypt"
+)
+
+// kdfKeySize is the size of the secret returned by ScryptKDF.
+const kdfKeySize = 64
+
+// DeterministicKDF turns a passphrase into the secret from which
+// NewDeterministicWithKDF derives keys in place of the passphrase itself.
+type DeterministicKDF func(passphrase string) ([]byte, error)
+
+// ScryptKDF returns a DeterministicKDF which runs the passphrase through
+// scrypt with the given salt and costs. The salt and costs become part of
+// what is needed to recover the keys, just as the passphrase is, so an
+// application should fix them rather than generate them. A salt that is
+// particular to the application, or to the user, such as an email address,
+// keeps anyone from attacking the passphrases of all users at once.
+func ScryptKDF(salt []byte, params ScryptParams) DeterministicKDF {
+	salt = append([]byte(nil), salt...)
+	return func(passphrase string) ([]byte, error) {
+		return scrypt.Key([]byte(passphrase), salt, params.N, params.R,
+			params.P, kdfKeySize)
+	}
+}
+
+// NewDeterministicWithKDF is like NewDeterministic, but the passphrase is
+// run through kdf first, which makes guessing it far more expensive when
+// kdf is memory hard, as ScryptKDF is. Every attempt at a guess must pay for
+// the KDF, while the search for keys pays for it only once. The keys are
+// not those that PyBitmessage derives from the passphrase, except if kdf is
+// nil, in which case this is the same as NewDeterministic.
+func NewDeterministicWithKDF(passphrase string, kdf DeterministicKDF,
+	initialZeros uint64, n int) ([]*PrivateKey, error) {
+	if kdf == nil {
+		return NewDeterministic(passphrase, initialZeros, n)
+	}
+
+	secret, err := kdf(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(secret)
+
+	return newDeterministic(DefaultAddressVersion, string(secret),
+		initialZeros, n, nil)
+}
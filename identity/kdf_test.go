@@ -0,0 +1,60 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestNewDeterministicWithKDF(t *testing.T) {
+	legacy, err := identity.NewDeterministic("general", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := identity.NewDeterministicWithKDF("general", nil, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got[0].Hash() != *legacy[0].Hash() {
+		t.Errorf("nil KDF does not give the legacy keys")
+	}
+
+	kdf := identity.ScryptKDF([]byte("example.com"), testScrypt)
+	a, err := identity.NewDeterministicWithKDF("general", kdf, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := identity.NewDeterministicWithKDF("general", kdf, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range a {
+		if *a[i].Hash() != *b[i].Hash() {
+			t.Errorf("key %d is not deterministic", i)
+		}
+		if a[i].Hash()[0] != 0 {
+			t.Errorf("key %d has no initial zero", i)
+		}
+	}
+	if *a[0].Hash() == *legacy[0].Hash() {
+		t.Errorf("scrypt KDF gives the legacy keys")
+	}
+
+	salted, err := identity.NewDeterministicWithKDF("general",
+		identity.ScryptKDF([]byte("example.org"), testScrypt), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *salted[0].Hash() == *a[0].Hash() {
+		t.Errorf("different salts give the same keys")
+	}
+
+	bad := identity.ScryptKDF(nil, identity.ScryptParams{N: 3, R: 1, P: 1})
+	if _, err = identity.NewDeterministicWithKDF("general", bad, 1, 1); err == nil {
+		t.Errorf("invalid scrypt parameters gave no error")
+	}
+}
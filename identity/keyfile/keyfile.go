@@ -0,0 +1,324 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package keyfile reads and writes the keys.dat file in which PyBitmessage
+// keeps its identities, so that they can be moved between PyBitmessage and
+// programs using this package. The file is in ini style, with a section for
+// the program's settings and one for each identity:
+//
+//	[bitmessagesettings]
+//	settingsversion = 10
+//
+//	[BM-2cW67GEKkHGonXKZLCzouLLxnLym3azS8r]
+//	label = [chan] general
+//	enabled = true
+//	decoy = false
+//	chan = true
+//	noncetrialsperbyte = 1000
+//	payloadlengthextrabytes = 1000
+//	privsigningkey = 5K...
+//	privencryptionkey = 5K...
+package keyfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// SettingsSection is the name of the section which holds PyBitmessage's
+// settings.
+const SettingsSection = "bitmessagesettings"
+
+// The options of an identity that this package reads into an Entry.
+const (
+	optLabel      = "label"
+	optEnabled    = "enabled"
+	optChan       = "chan"
+	optNonce      = "noncetrialsperbyte"
+	optExtraBytes = "payloadlengthextrabytes"
+	optSigning    = "privsigningkey"
+	optEncryption = "privencryptionkey"
+)
+
+// ErrNotOneEntry is returned by ImportPyBitmessage when the text does not
+// hold exactly one identity.
+var ErrNotOneEntry = errors.New("expected exactly one identity")
+
+// Entry is one identity in a key file.
+type Entry struct {
+	ID      *identity.PrivateID
+	Label   string
+	Enabled bool
+	Chan    bool
+
+	// Other holds the options of the identity that are not read into the
+	// fields above, such as decoy or mailinglist, by their lower case
+	// names. They are written back unchanged.
+	Other map[string]string
+}
+
+// NewEntry returns an enabled entry for the identity.
+func NewEntry(id *identity.PrivateID, label string) *Entry {
+	return &Entry{
+		ID:      id,
+		Label:   label,
+		Enabled: true,
+	}
+}
+
+// NewChanEntry returns an enabled entry for a chan, labeled as PyBitmessage
+// labels chans.
+func NewChanEntry(id *identity.PrivateID, passphrase string) *Entry {
+	return &Entry{
+		ID:      id,
+		Label:   identity.ChanLabel(passphrase),
+		Enabled: true,
+		Chan:    true,
+	}
+}
+
+// File is the content of a key file.
+type File struct {
+	// Settings holds the options of the settings section by their lower
+	// case names.
+	Settings map[string]string
+
+	// Entries are the identities in the order they appear in the file.
+	Entries []*Entry
+}
+
+// Read reads a key file. Sections other than the settings and those named
+// by addresses are skipped, as are comments. An identity whose keys do not
+// match its address is an error. An identity with no pow options gets the
+// defaults.
+func Read(r io.Reader) (*File, error) {
+	f := &File{Settings: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+
+	// current is the options of the section being read, or nil if it is
+	// being skipped.
+	var current map[string]string
+	var address string
+	var start int
+	finish := func() error {
+		if address == "" {
+			return nil
+		}
+		e, err := newEntry(address, current)
+		if err != nil {
+			return &identity.ParseError{Line: start, Err: err}
+		}
+		f.Entries = append(f.Entries, e)
+		return nil
+	}
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			if text[len(text)-1] != ']' {
+				return nil, &identity.ParseError{Line: line,
+					Err: fmt.Errorf("malformed section header")}
+			}
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			current, address, start = nil, "", line
+			switch {
+			case name == SettingsSection:
+				current = f.Settings
+			case strings.HasPrefix(name, "BM-"):
+				current, address = make(map[string]string), name
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		sep := strings.IndexAny(text, "=:")
+		if sep < 0 {
+			return nil, &identity.ParseError{Line: line,
+				Err: fmt.Errorf("expected option")}
+		}
+		key := strings.ToLower(strings.TrimSpace(text[:sep]))
+		current[key] = strings.TrimSpace(text[sep+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// newEntry makes an entry from the options in a section.
+func newEntry(address string, opts map[string]string) (*Entry, error) {
+	id, err := identity.ImportWIF(address, opts[optSigning], opts[optEncryption])
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		Label:   opts[optLabel],
+		Enabled: true,
+		Other:   make(map[string]string),
+	}
+	data := pow.Default
+	for key, value := range opts {
+		var ok bool
+		switch key {
+		case optLabel, optSigning, optEncryption:
+			ok = true
+		case optEnabled:
+			e.Enabled, ok = parseBool(value)
+		case optChan:
+			e.Chan, ok = parseBool(value)
+		case optNonce:
+			data.NonceTrialsPerByte, ok = parseUint(value)
+		case optExtraBytes:
+			data.ExtraBytes, ok = parseUint(value)
+		default:
+			e.Other[key], ok = value, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %q for %s", value, key)
+		}
+	}
+
+	if e.Chan {
+		e.ID = identity.NewChanID(id, identity.BehaviorAck, &data)
+	} else {
+		e.ID = identity.NewPrivateID(id, identity.BehaviorAck, &data)
+	}
+	return e, nil
+}
+
+// Write writes the key file, with the settings first if there are any. The
+// options of each section that this package does not interpret are written
+// in order of name. If any label cannot be written so that it reads back the
+// same, nothing is written and identity.ErrInvalidLabel is returned.
+func (f *File) Write(w io.Writer) error {
+	for _, e := range f.Entries {
+		if !validLabel(e.Label) {
+			return identity.ErrInvalidLabel
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	first := true
+	if len(f.Settings) != 0 {
+		fmt.Fprintf(bw, "[%s]\n", SettingsSection)
+		writeOptions(bw, f.Settings)
+		first = false
+	}
+
+	for _, e := range f.Entries {
+		if !first {
+			bw.WriteString("\n")
+		}
+		first = false
+		e.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// ExportPyBitmessage returns the entry as a complete section of a key file,
+// which can be pasted into PyBitmessage's keys.dat to move the identity to
+// it. If the label cannot be written so that it reads back the same,
+// identity.ErrInvalidLabel is returned.
+func (e *Entry) ExportPyBitmessage() (string, error) {
+	if !validLabel(e.Label) {
+		return "", identity.ErrInvalidLabel
+	}
+
+	var b bytes.Buffer
+	e.write(&b)
+	return b.String(), nil
+}
+
+// ImportPyBitmessage reads a single identity from a section of a key file,
+// such as one copied out of PyBitmessage's keys.dat or returned by
+// ExportPyBitmessage. It returns ErrNotOneEntry unless there is exactly one
+// identity and no settings.
+func ImportPyBitmessage(s string) (*Entry, error) {
+	f, err := Read(strings.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Entries) != 1 || len(f.Settings) != 0 {
+		return nil, ErrNotOneEntry
+	}
+	return f.Entries[0], nil
+}
+
+// write writes the entry's section.
+func (e *Entry) write(w io.Writer) {
+	address, signing, encryption := e.ID.ExportWIF()
+	data := e.ID.Pow()
+
+	fmt.Fprintf(w, "[%s]\n", address)
+	fmt.Fprintf(w, "%s = %s\n", optLabel, e.Label)
+	fmt.Fprintf(w, "%s = %t\n", optEnabled, e.Enabled)
+	fmt.Fprintf(w, "%s = %t\n", optChan, e.Chan)
+	fmt.Fprintf(w, "%s = %d\n", optNonce, data.NonceTrialsPerByte)
+	fmt.Fprintf(w, "%s = %d\n", optExtraBytes, data.ExtraBytes)
+	fmt.Fprintf(w, "%s = %s\n", optSigning, signing)
+	fmt.Fprintf(w, "%s = %s\n", optEncryption, encryption)
+	writeOptions(w, e.Other)
+}
+
+// validLabel returns whether the label can be written as an option so that
+// it reads back the same.
+func validLabel(label string) bool {
+	return !strings.ContainsAny(label, "\r\n") &&
+		strings.TrimSpace(label) == label
+}
+
+// writeOptions writes options in order of name.
+func writeOptions(w io.Writer, opts map[string]string) {
+	keys := make([]string, 0, len(opts))
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s = %s\n", key, opts[key])
+	}
+}
+
+// parseBool accepts the same boolean spellings as python's ConfigParser.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "1", "yes", "true", "on":
+		return true, true
+	case "0", "no", "false", "off":
+		return false, true
+	}
+	return false, false
+}
+
+func parseUint(s string) (uint64, bool) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package keyfile_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/identity/keyfile"
+)
+
+const keysDat = `[bitmessagesettings]
+settingsversion = 10
+port = 8444
+
+[BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET]
+label = Alice
+enabled = false
+decoy = False
+noncetrialsperbyte = 2000
+payloadlengthextrabytes = 1500
+privsigningkey = 5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15
+privencryptionkey = 5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb
+lastpubkeysendtime = 1462838327
+
+[unrelated]
+privsigningkey = nothing
+`
+
+func TestRead(t *testing.T) {
+	f, err := keyfile.Read(strings.NewReader(keysDat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Settings["settingsversion"] != "10" || f.Settings["port"] != "8444" {
+		t.Errorf("got settings %v", f.Settings)
+	}
+	if len(f.Entries) != 1 {
+		t.Fatalf("got %d entries", len(f.Entries))
+	}
+	e := f.Entries[0]
+	if e.ID.Address().String() != "BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET" {
+		t.Errorf("got address %s", e.ID.Address())
+	}
+	if e.Label != "Alice" || e.Enabled || e.Chan {
+		t.Errorf("got label %q, enabled %v, chan %v", e.Label, e.Enabled, e.Chan)
+	}
+	if p := e.ID.Pow(); p.NonceTrialsPerByte != 2000 || p.ExtraBytes != 1500 {
+		t.Errorf("got pow %v", p)
+	}
+	if e.ID.Behavior() != identity.BehaviorAck {
+		t.Errorf("got behavior %d", e.ID.Behavior())
+	}
+	if len(e.Other) != 2 || e.Other["decoy"] != "False" ||
+		e.Other["lastpubkeysendtime"] != "1462838327" {
+		t.Errorf("got other options %v", e.Other)
+	}
+
+	// What is written reads back the same.
+	var buf bytes.Buffer
+	if err = f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.String()
+	again, err := keyfile.Read(&buf)
+	if err != nil {
+		t.Fatalf("reading written file: %v\n%s", err, written)
+	}
+	buf.Reset()
+	again.Write(&buf)
+	if buf.String() != written {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), written)
+	}
+}
+
+func TestChanEntry(t *testing.T) {
+	id, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	f := &keyfile.File{Entries: []*keyfile.Entry{keyfile.NewChanEntry(id, "general")}}
+	if err = f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "[BM-2cW67GEKkHGonXKZLCzouLLxnLym3azS8r]\n"+
+		"label = [chan] general\nenabled = true\nchan = true\n") {
+		t.Errorf("got\n%s", buf.String())
+	}
+
+	f, err = keyfile.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Entries) != 1 || !f.Entries[0].Chan || !f.Entries[0].ID.IsChan() ||
+		!f.Entries[0].Enabled ||
+		!f.Entries[0].ID.Address().Equal(id.Address()) {
+		t.Errorf("got entries %v", f.Entries)
+	}
+}
+
+func TestExportPyBitmessage(t *testing.T) {
+	f, err := keyfile.Read(strings.NewReader(keysDat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := f.Entries[0].ExportPyBitmessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(exported, "[BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET]\n"+
+		"label = Alice\nenabled = false\nchan = false\n"+
+		"noncetrialsperbyte = 2000\npayloadlengthextrabytes = 1500\n") {
+		t.Errorf("got\n%s", exported)
+	}
+
+	e, err := keyfile.ImportPyBitmessage("# pasted\n\n" + exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := e.ExportPyBitmessage()
+	if again != exported {
+		t.Errorf("got\n%s\nwant\n%s", again, exported)
+	}
+
+	if _, err = keyfile.ImportPyBitmessage(keysDat); err != keyfile.ErrNotOneEntry {
+		t.Errorf("whole file: got %v want %v", err, keyfile.ErrNotOneEntry)
+	}
+	if _, err = keyfile.ImportPyBitmessage(exported + "\n" + exported); err != keyfile.ErrNotOneEntry {
+		t.Errorf("two entries: got %v want %v", err, keyfile.ErrNotOneEntry)
+	}
+
+	e.Label = " padded"
+	if _, err = e.ExportPyBitmessage(); err != identity.ErrInvalidLabel {
+		t.Errorf("got %v want %v", err, identity.ErrInvalidLabel)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	const section = "[BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET]\n" +
+		"privsigningkey = 5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15\n"
+	const encryption = "privencryptionkey = 5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb\n"
+
+	tests := []struct {
+		file string
+		line int
+	}{
+		{"[bitmessagesettings\n", 1},
+		{"\n" + section + encryption + "enabled = maybe\n", 2},
+		{section + encryption + "noncetrialsperbyte = -1\n", 1},
+		// The keys are swapped.
+		{"[BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET]\n" +
+			"privencryptionkey = 5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15\n" +
+			"privsigningkey = 5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb\n", 1},
+		{section + "no separator\n", 3},
+	}
+	for i, test := range tests {
+		_, err := keyfile.Read(strings.NewReader(test.file))
+		e, ok := err.(*identity.ParseError)
+		if !ok {
+			t.Errorf("#%d: got error %v, want *identity.ParseError", i, err)
+			continue
+		}
+		if e.Line != test.line {
+			t.Errorf("#%d: got error on line %d want %d", i, e.Line, test.line)
+		}
+	}
+
+	f := &keyfile.File{Entries: []*keyfile.Entry{{Label: "two\nlines"}}}
+	if err := f.Write(&bytes.Buffer{}); err != identity.ErrInvalidLabel {
+		t.Errorf("Write got %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+// ErrDuplicateIdentity is returned when an identity is added to a keyring
+// which already holds an identity for the same address.
+var ErrDuplicateIdentity = errors.New("identity already in keyring")
+
+// Keyring holds the private identities of a user along with the public
+// identities of their correspondents and the addresses they are subscribed
+// to. Identities can be looked up by address string, by ripe hash and by
+// tag. It is safe for concurrent use.
+type Keyring struct {
+	mtx     sync.RWMutex
+	private []*PrivateID
+	public  []Public
+	subs    *Subscriptions
+	stats   map[string]*Stats
+
+	// The indexes for looking up by ripe hash and by tag. Where two
+	// entries share a ripe hash, the one added first is found.
+	privateRipes map[hash.Ripe]*PrivateID
+	privateTags  map[hash.Sha]*PrivateID
+	publicRipes  map[hash.Ripe]Public
+	subTags      map[hash.Sha]*Subscription
+
+	rotations map[string]rotation
+}
+
+// rotation is a move from one address to another recorded in a keyring.
+type rotation struct {
+	to      Address
+	created time.Time
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		subs:         NewSubscriptions(),
+		privateRipes: make(map[hash.Ripe]*PrivateID),
+		privateTags:  make(map[hash.Sha]*PrivateID),
+		publicRipes:  make(map[hash.Ripe]Public),
+		subTags:      make(map[hash.Sha]*Subscription),
+	}
+}
+
+// AddPrivate adds a private identity to the keyring. It returns
+// ErrDuplicateIdentity if there is already one for the same address.
+func (k *Keyring) AddPrivate(id *PrivateID) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.lookupPrivate(id.Address().String()) >= 0 {
+		return ErrDuplicateIdentity
+	}
+	k.private = append(k.private, id)
+	k.indexPrivate(id)
+	return nil
+}
+
+// Private returns the private identity for the given address string, or
+// nil if the keyring does not hold it.
+func (k *Keyring) Private(addr string) *PrivateID {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	if i := k.lookupPrivate(addr); i >= 0 {
+		return k.private[i]
+	}
+	return nil
+}
+
+// RemovePrivate removes the private identity for the given address string
+// and reports whether one was found.
+func (k *Keyring) RemovePrivate(addr string) bool {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	i := k.lookupPrivate(addr)
+	if i < 0 {
+		return false
+	}
+	k.private = append(k.private[:i], k.private[i+1:]...)
+	delete(k.stats, addr)
+	k.reindex()
+	return true
+}
+
+// WipePrivate removes all the private identities from the keyring and wipes
+// their keys, which cannot be used afterwards by anything still holding
+// them. The public identities and subscriptions are kept.
+func (k *Keyring) WipePrivate() {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	for _, id := range k.private {
+		delete(k.stats, id.Address().String())
+		id.Zero()
+	}
+	k.private = nil
+	k.reindex()
+}
+
+// Privates returns the private identities in the order they were added.
+func (k *Keyring) Privates() []*PrivateID {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	list := make([]*PrivateID, len(k.private))
+	copy(list, k.private)
+	return list
+}
+
+func (k *Keyring) lookupPrivate(addr string) int {
+	for i, id := range k.private {
+		if id.Address().String() == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// PrivateByRipe returns the private identity with the given ripe hash, or
+// nil if the keyring does not hold one.
+func (k *Keyring) PrivateByRipe(ripe *hash.Ripe) *PrivateID {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	return k.privateRipes[*ripe]
+}
+
+// PrivateByTag returns the private identity whose address has the given
+// tag, as found in a getpubkey request for it, or nil if the keyring does
+// not hold one.
+func (k *Keyring) PrivateByTag(tag *hash.Sha) *PrivateID {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	return k.privateTags[*tag]
+}
+
+// AddPublic adds the public identity of a correspondent to the keyring. It
+// returns ErrDuplicateIdentity if there is already one for the same address.
+func (k *Keyring) AddPublic(pub Public) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.lookupPublic(pub.Address().String()) >= 0 {
+		return ErrDuplicateIdentity
+	}
+	k.public = append(k.public, pub)
+	k.indexPublic(pub)
+	return nil
+}
+
+// Public returns the public identity for the given address string, or nil
+// if the keyring does not hold it.
+func (k *Keyring) Public(addr string) Public {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	if i := k.lookupPublic(addr); i >= 0 {
+		return k.public[i]
+	}
+	return nil
+}
+
+// PublicByRipe returns the public identity with the given ripe hash, as
+// found in a getpubkey request for a version 2 or 3 address, or nil if the
+// keyring does not hold one.
+func (k *Keyring) PublicByRipe(ripe *hash.Ripe) Public {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	return k.publicRipes[*ripe]
+}
+
+// RemovePublic removes the public identity for the given address string and
+// reports whether one was found.
+func (k *Keyring) RemovePublic(addr string) bool {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	i := k.lookupPublic(addr)
+	if i < 0 {
+		return false
+	}
+	k.public = append(k.public[:i], k.public[i+1:]...)
+	k.reindex()
+	return true
+}
+
+// Publics returns the public identities in the order they were added.
+func (k *Keyring) Publics() []Public {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	list := make([]Public, len(k.public))
+	copy(list, k.public)
+	return list
+}
+
+func (k *Keyring) lookupPublic(addr string) int {
+	for i, pub := range k.public {
+		if pub.Address().String() == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+func (k *Keyring) indexPrivate(id *PrivateID) {
+	addr := id.Address()
+	if _, ok := k.privateRipes[*addr.RipeHash()]; !ok {
+		k.privateRipes[*addr.RipeHash()] = id
+	}
+	if tag := Tag(addr); k.privateTags[*tag] == nil {
+		k.privateTags[*tag] = id
+	}
+}
+
+func (k *Keyring) indexPublic(pub Public) {
+	ripe := pub.Address().RipeHash()
+	if _, ok := k.publicRipes[*ripe]; !ok {
+		k.publicRipes[*ripe] = pub
+	}
+}
+
+func (k *Keyring) indexSubscription(sub *Subscription) {
+	if tag := Tag(sub.Address); k.subTags[*tag] == nil {
+		k.subTags[*tag] = sub
+	}
+}
+
+// reindex rebuilds the indexes after something has been removed.
+func (k *Keyring) reindex() {
+	k.privateRipes = make(map[hash.Ripe]*PrivateID)
+	k.privateTags = make(map[hash.Sha]*PrivateID)
+	k.publicRipes = make(map[hash.Ripe]Public)
+	k.subTags = make(map[hash.Sha]*Subscription)
+
+	for _, id := range k.private {
+		k.indexPrivate(id)
+	}
+	for _, pub := range k.public {
+		k.indexPublic(pub)
+	}
+	for _, sub := range k.subs.list {
+		k.indexSubscription(sub)
+	}
+}
+
+// AddSubscription subscribes the keyring to broadcasts from addr. It
+// returns ErrDuplicateSubscription if it is already subscribed.
+func (k *Keyring) AddSubscription(addr Address, label string) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if err := k.subs.Add(addr, label, true); err != nil {
+		return err
+	}
+	k.indexSubscription(k.subs.list[len(k.subs.list)-1])
+	return nil
+}
+
+// RemoveSubscription unsubscribes from the given address string and reports
+// whether a subscription was found.
+func (k *Keyring) RemoveSubscription(addr string) bool {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if !k.subs.Remove(addr) {
+		return false
+	}
+	delete(k.stats, addr)
+	k.reindex()
+	return true
+}
+
+// SubscriptionByTag returns a copy of the subscription whose address has
+// the given tag, as found in a tagged broadcast from it, or nil if the
+// keyring is not subscribed to one.
+func (k *Keyring) SubscriptionByTag(tag *hash.Sha) *Subscription {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	sub := k.subTags[*tag]
+	if sub == nil {
+		return nil
+	}
+	c := *sub
+	return &c
+}
+
+// Subscriptions returns a copy of the keyring's subscriptions list.
+func (k *Keyring) Subscriptions() *Subscriptions {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	s := NewSubscriptions()
+	for _, sub := range k.subs.list {
+		c := *sub
+		s.list = append(s.list, &c)
+	}
+	return s
+}
+
+// ImportSubscriptions adds every subscription in s to the keyring, leaving
+// out addresses it is already subscribed to.
+func (k *Keyring) ImportSubscriptions(s *Subscriptions) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	for _, sub := range s.list {
+		if k.subs.Add(sub.Address, sub.Label, sub.Enabled) == nil {
+			k.indexSubscription(k.subs.list[len(k.subs.list)-1])
+		}
+	}
+}
+
+// PrecomputeTags calculates the tags of the addresses the keyring is
+// subscribed to, so that they are ready before broadcasts arrive.
+func (k *Keyring) PrecomputeTags() {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	addrs := make([]Address, len(k.subs.list))
+	for i, sub := range k.subs.list {
+		addrs[i] = sub.Address
+	}
+	PrecomputeTags(addrs)
+}
+
+// AddRotation records that the correspondent at the old address of r has
+// moved to its new one, so that Resolve gives the new address from then on.
+// A rotation which does not verify is ignored, as is one older than a
+// rotation already recorded for the same address and one which would lead
+// back to where it started. It returns whether the rotation was recorded.
+func (k *Keyring) AddRotation(r *Rotation) bool {
+	if r.Verify() != nil {
+		return false
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	old := r.Old.Address().String()
+	if last, ok := k.rotations[old]; ok && !r.Created.After(last.created) {
+		return false
+	}
+	for to := r.New; to != nil; to = k.rotatedTo(to) {
+		if to.String() == old {
+			return false
+		}
+	}
+
+	if k.rotations == nil {
+		k.rotations = make(map[string]rotation)
+	}
+	k.rotations[old] = rotation{r.New, r.Created}
+	return true
+}
+
+// Resolve returns the address that messages meant for recipient should be
+// sent to, which is recipient unless it has been rotated with AddRotation.
+// Rotations are followed to the latest address.
+func (k *Keyring) Resolve(recipient Address) Address {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	for to := k.rotatedTo(recipient); to != nil; to = k.rotatedTo(to) {
+		recipient = to
+	}
+	return recipient
+}
+
+// rotatedTo returns the address addr has been rotated to, or nil.
+func (k *Keyring) rotatedTo(addr Address) Address {
+	r, ok := k.rotations[addr.String()]
+	if !ok {
+		return nil
+	}
+	return r.to
+}
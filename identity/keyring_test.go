@@ -0,0 +1,150 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestKeyring(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	id := identity.NewPrivateID(priv, 0, &pow.Default)
+	addr := id.Address().String()
+
+	k := identity.NewKeyring()
+	if err = k.AddPrivate(id); err != nil {
+		t.Fatalf("AddPrivate error %v", err)
+	}
+	if err = k.AddPrivate(id); err != identity.ErrDuplicateIdentity {
+		t.Errorf("AddPrivate duplicate got %v want %v", err,
+			identity.ErrDuplicateIdentity)
+	}
+	if k.Private(addr) != id {
+		t.Errorf("Private did not return the identity")
+	}
+
+	sub, _ := bmutil.DecodeAddress("BM-2DBXxtaBSV37DsHjN978mRiMbX5rdKNvJ6")
+	if err = k.AddSubscription(sub, "news"); err != nil {
+		t.Fatalf("AddSubscription error %v", err)
+	}
+
+	// Nothing is recorded until stats are enabled.
+	k.RecordMessage(addr)
+	if k.StatsSnapshot() != nil {
+		t.Errorf("StatsSnapshot without stats got %v", k.StatsSnapshot())
+	}
+
+	k.EnableStats()
+	k.RecordMessage(addr)
+	k.RecordMessage(addr)
+	k.RecordBroadcast(sub.String())
+	k.RecordBroadcast("BM-2cUf7Tnhybdf2i3ZbQuTs6ygWhMWJwgHG5")
+
+	s, ok := k.StatsFor(addr)
+	if !ok || s.MessagesReceived != 2 || s.BroadcastsDecrypted != 0 ||
+		s.LastActivity.IsZero() {
+		t.Errorf("StatsFor identity got %+v, %v", s, ok)
+	}
+	s, ok = k.StatsFor(sub.String())
+	if !ok || s.BroadcastsDecrypted != 1 {
+		t.Errorf("StatsFor subscription got %+v, %v", s, ok)
+	}
+	if len(k.StatsSnapshot()) != 2 {
+		t.Errorf("StatsSnapshot got %v", k.StatsSnapshot())
+	}
+
+	// Removing an identity forgets its stats.
+	if !k.RemovePrivate(addr) || k.Private(addr) != nil {
+		t.Errorf("RemovePrivate failed")
+	}
+	if _, ok = k.StatsFor(addr); ok {
+		t.Errorf("StatsFor removed identity still has stats")
+	}
+	if !k.RemoveSubscription(sub.String()) || k.Subscriptions().Len() != 0 {
+		t.Errorf("RemoveSubscription failed")
+	}
+
+	k.AddPrivate(id)
+	k.WipePrivate()
+	if len(k.Privates()) != 0 || k.Private(addr) != nil {
+		t.Errorf("WipePrivate left %v", k.Privates())
+	}
+	if id.PrivateKey().Signing.D.Sign() != 0 {
+		t.Errorf("WipePrivate did not wipe the keys")
+	}
+}
+
+func TestKeyringLookup(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	id := identity.NewPrivateID(priv, 0, &pow.Default)
+	addr := id.Address()
+
+	other, err := identity.ImportWIF("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	pub := identity.NewPublicFromWIF(other, identity.BehaviorAck, nil)
+	sub, _ := bmutil.DecodeAddress("BM-2DBXxtaBSV37DsHjN978mRiMbX5rdKNvJ6")
+
+	k := identity.NewKeyring()
+	k.AddPrivate(id)
+	if err = k.AddPublic(pub); err != nil {
+		t.Fatalf("AddPublic error %v", err)
+	}
+	if err = k.AddPublic(pub); err != identity.ErrDuplicateIdentity {
+		t.Errorf("AddPublic duplicate got %v want %v", err,
+			identity.ErrDuplicateIdentity)
+	}
+	k.AddSubscription(sub, "news")
+
+	if k.PrivateByRipe(addr.RipeHash()) != id || k.PrivateByTag(bmutil.Tag(addr)) != id {
+		t.Error("private identity not found by ripe and tag")
+	}
+	if k.Public(pub.Address().String()) != pub || k.PublicByRipe(pub.Address().RipeHash()) != pub {
+		t.Error("public identity not found by address and ripe")
+	}
+	if s := k.SubscriptionByTag(bmutil.Tag(sub)); s == nil || !s.Address.Equal(sub) {
+		t.Errorf("SubscriptionByTag got %v", s)
+	}
+	if k.PrivateByTag(bmutil.Tag(sub)) != nil || k.PublicByRipe(addr.RipeHash()) != nil {
+		t.Error("found identity that is not in the keyring")
+	}
+	if len(k.Publics()) != 1 {
+		t.Errorf("Publics got %v", k.Publics())
+	}
+
+	k.RemovePrivate(addr.String())
+	if !k.RemovePublic(pub.Address().String()) || k.RemovePublic(pub.Address().String()) {
+		t.Error("RemovePublic failed")
+	}
+	k.RemoveSubscription(sub.String())
+	if k.PrivateByRipe(addr.RipeHash()) != nil || k.PrivateByTag(bmutil.Tag(addr)) != nil ||
+		k.PublicByRipe(pub.Address().RipeHash()) != nil || k.SubscriptionByTag(bmutil.Tag(sub)) != nil {
+		t.Error("removed identities are still found")
+	}
+
+	subs := identity.NewSubscriptions()
+	subs.Add(sub, "imported", true)
+	k.ImportSubscriptions(subs)
+	if s := k.SubscriptionByTag(bmutil.Tag(sub)); s == nil || s.Label != "imported" {
+		t.Errorf("imported subscription got %v", s)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import "time"
+
+// Stats is a record of the activity seen for one identity or subscription
+// in a keyring.
+type Stats struct {
+	// MessagesReceived is the number of msg objects decrypted with the
+	// identity.
+	MessagesReceived uint64
+
+	// BroadcastsDecrypted is the number of broadcasts decrypted for the
+	// address.
+	BroadcastsDecrypted uint64
+
+	// LastActivity is when a message or broadcast was last recorded. It is
+	// the zero time if nothing has been.
+	LastActivity time.Time
+}
+
+// EnableStats turns on activity counters for the keyring. Until it is
+// called, RecordMessage and RecordBroadcast do nothing, so keyrings which do
+// not need the counters pay nothing for them.
+func (k *Keyring) EnableStats() {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.stats == nil {
+		k.stats = make(map[string]*Stats)
+	}
+}
+
+// RecordMessage notes that a msg object was decrypted with the identity for
+// the given address string. Whatever decrypts objects for the keyring's
+// identities should call it; the keyring does not decrypt anything itself.
+func (k *Keyring) RecordMessage(addr string) {
+	k.record(addr, func(s *Stats) { s.MessagesReceived++ })
+}
+
+// RecordBroadcast notes that a broadcast from the given address string was
+// decrypted. It is called in the same way as RecordMessage.
+func (k *Keyring) RecordBroadcast(addr string) {
+	k.record(addr, func(s *Stats) { s.BroadcastsDecrypted++ })
+}
+
+func (k *Keyring) record(addr string, f func(*Stats)) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.stats == nil {
+		return
+	}
+	if k.lookupPrivate(addr) < 0 && k.subs.Get(addr) == nil {
+		return
+	}
+
+	s, ok := k.stats[addr]
+	if !ok {
+		s = &Stats{}
+		k.stats[addr] = s
+	}
+	f(s)
+	s.LastActivity = time.Now()
+}
+
+// StatsFor returns the activity recorded for the given address string and
+// whether any has been.
+func (k *Keyring) StatsFor(addr string) (Stats, bool) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	s, ok := k.stats[addr]
+	if !ok {
+		return Stats{}, false
+	}
+	return *s, true
+}
+
+// StatsSnapshot returns a copy of the activity recorded for every address in
+// the keyring, keyed by address string. It returns nil if stats are not
+// enabled.
+func (k *Keyring) StatsSnapshot() map[string]Stats {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	if k.stats == nil {
+		return nil
+	}
+	snapshot := make(map[string]Stats, len(k.stats))
+	for addr, s := range k.stats {
+		snapshot[addr] = *s
+	}
+	return snapshot
+}
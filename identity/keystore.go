@@ -0,0 +1,540 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/internal/lengthcheck"
+	"golang.org/x/crypto/scrypt"
+)
+
+// keystoreMagic begins an encoded keystore.
+const keystoreMagic = "bmkeystore\x01"
+
+const (
+	keystoreSaltSize = 16
+	keystoreKeySize  = 32
+
+	// maxKeystoreEntry is the largest encrypted identity accepted when
+	// reading a keystore.
+	maxKeystoreEntry = 1024
+
+	// maxKeystoreMetadata is the largest metadata key or value accepted
+	// when reading a keystore.
+	maxKeystoreMetadata = 1 << 16
+)
+
+var (
+	// ErrKeystoreLocked is returned when a keystore must be unlocked to do
+	// what was asked.
+	ErrKeystoreLocked = errors.New("keystore is locked")
+
+	// ErrWrongPassphrase is returned by Unlock when the passphrase does
+	// not open the keystore.
+	ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+
+	// ErrMalformedKeystore is returned when a keystore cannot be read or an
+	// identity in it cannot be decrypted with the right passphrase, which
+	// means it has been damaged or tampered with.
+	ErrMalformedKeystore = errors.New("malformed keystore")
+
+	// ErrNotInKeystore is returned by SetMetadata for an address which is
+	// not in the keystore.
+	ErrNotInKeystore = errors.New("identity not in keystore")
+)
+
+// ScryptParams are the costs of deriving the key of a keystore from its
+// passphrase. See golang.org/x/crypto/scrypt.
+type ScryptParams struct {
+	N, R, P int
+}
+
+// DefaultScryptParams take about a tenth of a second and 32 MB of memory on
+// a desktop computer.
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// maxScryptParams are the largest costs accepted when reading a keystore,
+// so that a made up file can't run a computer out of memory. Scrypt takes
+// 128·N·R bytes, which is 1 GiB at these limits.
+var maxScryptParams = ScryptParams{N: 1 << 20, R: 8, P: 16}
+
+// Keystore holds private identities encrypted with AES-256-GCM under a key
+// derived from a passphrase with scrypt, so that they can be saved to disk.
+// Each identity has metadata, such as a label, which is stored in the clear
+// so that it can be read while the keystore is locked, but it is
+// authenticated with the identity and a change to it is caught by Unlock.
+// A Keystore is safe for concurrent use.
+type Keystore struct {
+	mtx     sync.RWMutex
+	params  ScryptParams
+	salt    []byte
+	check   []byte
+	entries []*keystoreEntry
+
+	// aead is nil while the keystore is locked.
+	aead cipher.AEAD
+}
+
+// keystoreEntry is an identity in a keystore. id is nil while the keystore
+// is locked.
+type keystoreEntry struct {
+	address  string
+	metadata map[string]string
+	sealed   []byte
+	id       *PrivateID
+}
+
+// NewKeystore returns an empty keystore encrypted with the passphrase. It
+// is unlocked.
+func NewKeystore(passphrase string, params ScryptParams) (*Keystore, error) {
+	ks := &Keystore{params: params}
+	if err := ks.rekey(passphrase); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// OpenKeystore reads a keystore written by Save. It is locked.
+func OpenKeystore(r io.Reader) (*Keystore, error) {
+	magic := make([]byte, len(keystoreMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != keystoreMagic {
+		return nil, ErrMalformedKeystore
+	}
+
+	ks := &Keystore{}
+	var n [3]uint64
+	for i := range n {
+		v, err := ReadVarInt(r)
+		if err != nil {
+			return nil, ErrMalformedKeystore
+		}
+		n[i] = v
+	}
+	if n[0] > uint64(maxScryptParams.N) || n[1] > uint64(maxScryptParams.R) ||
+		n[2] > uint64(maxScryptParams.P) {
+		return nil, ErrMalformedKeystore
+	}
+	ks.params = ScryptParams{N: int(n[0]), R: int(n[1]), P: int(n[2])}
+
+	var err error
+	if ks.salt, err = ReadVarBytes(r, keystoreSaltSize, "salt"); err != nil {
+		return nil, ErrMalformedKeystore
+	}
+	if ks.check, err = ReadVarBytes(r, maxKeystoreEntry, "check"); err != nil {
+		return nil, ErrMalformedKeystore
+	}
+
+	count, err := ReadVarInt(r)
+	if err != nil || lengthcheck.ExceedsInput(r, count) {
+		return nil, ErrMalformedKeystore
+	}
+	for i := uint64(0); i < count; i++ {
+		e, err := readKeystoreEntry(r)
+		if err != nil {
+			return nil, ErrMalformedKeystore
+		}
+		ks.entries = append(ks.entries, e)
+	}
+	return ks, nil
+}
+
+func readKeystoreEntry(r io.Reader) (*keystoreEntry, error) {
+	address, err := ReadVarString(r, maxKeystoreMetadata)
+	if err != nil {
+		return nil, err
+	}
+	count, err := ReadVarInt(r)
+	if err != nil || lengthcheck.ExceedsInput(r, count) {
+		return nil, ErrMalformedKeystore
+	}
+	e := &keystoreEntry{
+		address:  address,
+		metadata: make(map[string]string),
+	}
+	for j := uint64(0); j < count; j++ {
+		key, err := ReadVarString(r, maxKeystoreMetadata)
+		if err != nil {
+			return nil, err
+		}
+		if e.metadata[key], err = ReadVarString(r, maxKeystoreMetadata); err != nil {
+			return nil, err
+		}
+	}
+	if e.sealed, err = ReadVarBytes(r, maxKeystoreEntry, "identity"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Save writes the keystore to w. It does not need to be unlocked.
+func (ks *Keystore) Save(w io.Writer) error {
+	ks.mtx.RLock()
+	defer ks.mtx.RUnlock()
+
+	var b bytes.Buffer
+	b.WriteString(keystoreMagic)
+	for _, n := range []int{ks.params.N, ks.params.R, ks.params.P} {
+		WriteVarInt(&b, uint64(n))
+	}
+	WriteVarBytes(&b, ks.salt)
+	WriteVarBytes(&b, ks.check)
+	WriteVarInt(&b, uint64(len(ks.entries)))
+	for _, e := range ks.entries {
+		WriteVarString(&b, e.address)
+		b.Write(encodeMetadata(e.metadata))
+		WriteVarBytes(&b, e.sealed)
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Unlock decrypts the identities in the keystore with the passphrase. It
+// returns ErrWrongPassphrase if the passphrase is wrong.
+func (ks *Keystore) Unlock(passphrase string) error {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	key, err := scrypt.Key([]byte(passphrase), ks.salt, ks.params.N,
+		ks.params.R, ks.params.P, keystoreKeySize)
+	if err != nil {
+		return err
+	}
+	aead, err := newKeystoreAEAD(key)
+	if err != nil {
+		return err
+	}
+	if _, err = open(aead, ks.check, ks.salt); err != nil {
+		return ErrWrongPassphrase
+	}
+
+	ids := make([]*PrivateID, len(ks.entries))
+	for i, e := range ks.entries {
+		plain, err := open(aead, e.sealed, e.additionalData())
+		if err != nil {
+			zeroIDs(ids[:i])
+			return ErrMalformedKeystore
+		}
+		id := &PrivateID{}
+		err = id.UnmarshalBinary(plain)
+		zero(plain)
+		if err != nil || id.Address().String() != e.address {
+			zeroIDs(ids[:i])
+			return ErrMalformedKeystore
+		}
+		ids[i] = id
+	}
+
+	for i, e := range ks.entries {
+		e.id = ids[i]
+	}
+	ks.aead = aead
+	return nil
+}
+
+// Lock forgets the key and wipes the decrypted identities. The keystore
+// keeps its own copies, so identities given to Add or returned by Private are
+// left alone, and it is up to the caller to Zero them when done with them.
+func (ks *Keystore) Lock() {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	ks.aead = nil
+	for _, e := range ks.entries {
+		if e.id != nil {
+			e.id.Zero()
+			e.id = nil
+		}
+	}
+}
+
+// Locked returns whether the keystore is locked.
+func (ks *Keystore) Locked() bool {
+	ks.mtx.RLock()
+	defer ks.mtx.RUnlock()
+
+	return ks.aead == nil
+}
+
+// ChangePassphrase encrypts the keystore again with a new passphrase and
+// fresh salt. The keystore must be unlocked.
+func (ks *Keystore) ChangePassphrase(passphrase string) error {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	if ks.aead == nil {
+		return ErrKeystoreLocked
+	}
+	salt, check, aead := ks.salt, ks.check, ks.aead
+	if err := ks.rekey(passphrase); err != nil {
+		return err
+	}
+
+	// Nothing is changed unless every identity can be encrypted again.
+	sealed := make([][]byte, len(ks.entries))
+	for i, e := range ks.entries {
+		var err error
+		if sealed[i], err = ks.seal(e); err != nil {
+			ks.salt, ks.check, ks.aead = salt, check, aead
+			return err
+		}
+	}
+	for i, e := range ks.entries {
+		e.sealed = sealed[i]
+	}
+	return nil
+}
+
+// Add adds a copy of an identity with the given metadata, which may be nil.
+// The keystore must be unlocked. It returns ErrDuplicateIdentity if there is
+// already an identity for the same address.
+func (ks *Keystore) Add(id *PrivateID, metadata map[string]string) error {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	if ks.aead == nil {
+		return ErrKeystoreLocked
+	}
+	addr := id.Address().String()
+	if ks.lookup(addr) != nil {
+		return ErrDuplicateIdentity
+	}
+
+	own, err := copyID(id)
+	if err != nil {
+		return err
+	}
+	e := &keystoreEntry{
+		address:  addr,
+		metadata: copyMetadata(metadata),
+		id:       own,
+	}
+	if e.sealed, err = ks.seal(e); err != nil {
+		own.Zero()
+		return err
+	}
+	ks.entries = append(ks.entries, e)
+	return nil
+}
+
+// Private returns a copy of the identity for the given address string, or
+// nil if there is none. The keystore must be unlocked.
+func (ks *Keystore) Private(addr string) (*PrivateID, error) {
+	ks.mtx.RLock()
+	defer ks.mtx.RUnlock()
+
+	if ks.aead == nil {
+		return nil, ErrKeystoreLocked
+	}
+	if e := ks.lookup(addr); e != nil {
+		return copyID(e.id)
+	}
+	return nil, nil
+}
+
+// Remove removes the identity for the given address string and reports
+// whether there was one. The keystore does not need to be unlocked.
+func (ks *Keystore) Remove(addr string) bool {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	for i, e := range ks.entries {
+		if e.address == addr {
+			if e.id != nil {
+				e.id.Zero()
+			}
+			ks.entries = append(ks.entries[:i], ks.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Addresses returns the addresses of the identities in the order they were
+// added. The keystore does not need to be unlocked.
+func (ks *Keystore) Addresses() []string {
+	ks.mtx.RLock()
+	defer ks.mtx.RUnlock()
+
+	addrs := make([]string, len(ks.entries))
+	for i, e := range ks.entries {
+		addrs[i] = e.address
+	}
+	return addrs
+}
+
+// Metadata returns a copy of the metadata of the identity for the given
+// address string, or nil if there is none. The keystore does not need to be
+// unlocked, but the metadata has only been checked if it has been.
+func (ks *Keystore) Metadata(addr string) map[string]string {
+	ks.mtx.RLock()
+	defer ks.mtx.RUnlock()
+
+	if e := ks.lookup(addr); e != nil {
+		return copyMetadata(e.metadata)
+	}
+	return nil
+}
+
+// SetMetadata replaces the metadata of the identity for the given address
+// string. The keystore must be unlocked.
+func (ks *Keystore) SetMetadata(addr string, metadata map[string]string) error {
+	ks.mtx.Lock()
+	defer ks.mtx.Unlock()
+
+	if ks.aead == nil {
+		return ErrKeystoreLocked
+	}
+	e := ks.lookup(addr)
+	if e == nil {
+		return ErrNotInKeystore
+	}
+	old := e.metadata
+	e.metadata = copyMetadata(metadata)
+	sealed, err := ks.seal(e)
+	if err != nil {
+		e.metadata = old
+		return err
+	}
+	e.sealed = sealed
+	return nil
+}
+
+func (ks *Keystore) lookup(addr string) *keystoreEntry {
+	for _, e := range ks.entries {
+		if e.address == addr {
+			return e
+		}
+	}
+	return nil
+}
+
+// rekey derives a new key from the passphrase with fresh salt.
+func (ks *Keystore) rekey(passphrase string) error {
+	salt := make([]byte, keystoreSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, ks.params.N, ks.params.R,
+		ks.params.P, keystoreKeySize)
+	if err != nil {
+		return err
+	}
+	aead, err := newKeystoreAEAD(key)
+	if err != nil {
+		return err
+	}
+	check, err := seal(aead, nil, salt)
+	if err != nil {
+		return err
+	}
+
+	ks.salt, ks.check, ks.aead = salt, check, aead
+	return nil
+}
+
+// seal encrypts the identity of an entry along with its address and
+// metadata, and returns it for the caller to store in the entry.
+func (ks *Keystore) seal(e *keystoreEntry) ([]byte, error) {
+	plain, err := e.id.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(plain)
+
+	return seal(ks.aead, plain, e.additionalData())
+}
+
+// copyID returns a copy of id with keys of its own, so that wiping one
+// leaves the other as it was.
+func copyID(id *PrivateID) (*PrivateID, error) {
+	plain, err := id.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	defer zero(plain)
+
+	c := &PrivateID{}
+	if err = c.UnmarshalBinary(plain); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// zeroIDs wipes the keys of the identities.
+func zeroIDs(ids []*PrivateID) {
+	for _, id := range ids {
+		id.Zero()
+	}
+}
+
+// additionalData is what is authenticated along with the identity.
+func (e *keystoreEntry) additionalData() []byte {
+	var b bytes.Buffer
+	WriteVarString(&b, e.address)
+	b.Write(encodeMetadata(e.metadata))
+	return b.Bytes()
+}
+
+func newKeystoreAEAD(key []byte) (cipher.AEAD, error) {
+	defer zero(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain with a random nonce, which is put in front.
+func seal(aead cipher.AEAD, plain, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+// open decrypts what was encrypted by seal.
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedKeystore
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():],
+		additional)
+}
+
+// encodeMetadata encodes metadata in order of key.
+func encodeMetadata(metadata map[string]string) []byte {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	WriteVarInt(&b, uint64(len(keys)))
+	for _, k := range keys {
+		WriteVarString(&b, k)
+		WriteVarString(&b, metadata[k])
+	}
+	return b.Bytes()
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	c := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,175 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+// testScrypt keeps the tests fast.
+var testScrypt = identity.ScryptParams{N: 16, R: 1, P: 1}
+
+func TestKeystore(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	id := identity.NewPrivateID(priv, identity.BehaviorAck,
+		&pow.Data{NonceTrialsPerByte: 2000, ExtraBytes: 3000})
+	addr := id.Address().String()
+	meta := map[string]string{"label": "work"}
+
+	ks, err := identity.NewKeystore("correct horse", testScrypt)
+	if err != nil {
+		t.Fatalf("NewKeystore error %v", err)
+	}
+	if err = ks.Add(id, meta); err != nil {
+		t.Fatalf("Add error %v", err)
+	}
+	if err = ks.Add(id, nil); err != identity.ErrDuplicateIdentity {
+		t.Errorf("Add duplicate got %v want %v", err, identity.ErrDuplicateIdentity)
+	}
+
+	var b bytes.Buffer
+	if err = ks.Save(&b); err != nil {
+		t.Fatalf("Save error %v", err)
+	}
+	saved := b.Bytes()
+
+	ks, err = identity.OpenKeystore(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatalf("OpenKeystore error %v", err)
+	}
+	if !ks.Locked() {
+		t.Errorf("opened keystore is not locked")
+	}
+	if got := ks.Addresses(); !reflect.DeepEqual(got, []string{addr}) {
+		t.Errorf("Addresses got %v want %v", got, []string{addr})
+	}
+	if got := ks.Metadata(addr); !reflect.DeepEqual(got, meta) {
+		t.Errorf("Metadata got %v want %v", got, meta)
+	}
+	if _, err = ks.Private(addr); err != identity.ErrKeystoreLocked {
+		t.Errorf("Private while locked got %v want %v", err,
+			identity.ErrKeystoreLocked)
+	}
+	if err = ks.Unlock("wrong horse"); err != identity.ErrWrongPassphrase {
+		t.Errorf("Unlock got %v want %v", err, identity.ErrWrongPassphrase)
+	}
+	if err = ks.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock error %v", err)
+	}
+
+	got, err := ks.Private(addr)
+	if err != nil {
+		t.Fatalf("Private error %v", err)
+	}
+	if got == nil {
+		t.Fatalf("Private returned nil")
+	}
+	_, gotSigning, gotDecryption := got.ExportWIF()
+	_, wantSigning, wantDecryption := id.ExportWIF()
+	if gotSigning != wantSigning || gotDecryption != wantDecryption {
+		t.Errorf("Private returned different keys")
+	}
+	if got.Behavior() != id.Behavior() || *got.Pow() != *id.Pow() {
+		t.Errorf("Private got behavior %d pow %v want %d %v", got.Behavior(),
+			got.Pow(), id.Behavior(), id.Pow())
+	}
+
+	if err = ks.ChangePassphrase("battery staple"); err != nil {
+		t.Fatalf("ChangePassphrase error %v", err)
+	}
+	if err = ks.SetMetadata(addr, map[string]string{"label": "home"}); err != nil {
+		t.Fatalf("SetMetadata error %v", err)
+	}
+	// Lock only wipes the keystore's own copies, so identities which are
+	// also held elsewhere, such as in a Keyring, can still be used.
+	held, _ := ks.Private(addr)
+	if again, _ := ks.Private(addr); again == held {
+		t.Errorf("Private returned the same identity twice")
+	}
+	ks.Lock()
+	for _, k := range []*identity.PrivateID{held, id} {
+		if k.PrivateKey().Signing.D.Sign() == 0 || k.PrivateKey().Decryption.D.Sign() == 0 {
+			t.Errorf("Lock wiped an identity the keystore does not own")
+		}
+	}
+	if err = ks.SetMetadata(addr, nil); err != identity.ErrKeystoreLocked {
+		t.Errorf("SetMetadata while locked got %v want %v", err,
+			identity.ErrKeystoreLocked)
+	}
+	if err = ks.Unlock("correct horse"); err != identity.ErrWrongPassphrase {
+		t.Errorf("Unlock with old passphrase got %v want %v", err,
+			identity.ErrWrongPassphrase)
+	}
+	if err = ks.Unlock("battery staple"); err != nil {
+		t.Errorf("Unlock with new passphrase error %v", err)
+	}
+	if got := ks.Metadata(addr)["label"]; got != "home" {
+		t.Errorf("Metadata label got %q want %q", got, "home")
+	}
+
+	if !ks.Remove(addr) || ks.Remove(addr) {
+		t.Errorf("Remove did not remove the identity exactly once")
+	}
+	if got, _ := ks.Private(addr); got != nil {
+		t.Errorf("Private returned a removed identity")
+	}
+}
+
+func TestKeystoreTampering(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	ks, err := identity.NewKeystore("passphrase", testScrypt)
+	if err != nil {
+		t.Fatalf("NewKeystore error %v", err)
+	}
+	err = ks.Add(identity.NewPrivateID(priv, 0, &pow.Default),
+		map[string]string{"label": "aaaa"})
+	if err != nil {
+		t.Fatalf("Add error %v", err)
+	}
+	var b bytes.Buffer
+	ks.Save(&b)
+
+	// Changing the label in the file is caught when the keystore is
+	// unlocked.
+	tampered := bytes.Replace(b.Bytes(), []byte("aaaa"), []byte("bbbb"), 1)
+	ks, err = identity.OpenKeystore(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("OpenKeystore error %v", err)
+	}
+	if err = ks.Unlock("passphrase"); err != identity.ErrMalformedKeystore {
+		t.Errorf("Unlock got %v want %v", err, identity.ErrMalformedKeystore)
+	}
+
+	// A keystore with costs that would take too much memory is rejected
+	// before scrypt is ever run: N of 1<<21 with R of 8, then N of 1<<10
+	// with R of 9. The saved keystore has costs of 16, 1 and 1.
+	header := len("bmkeystore\x01")
+	rest := b.Bytes()[header+3:]
+	tooCostly := append([]byte("bmkeystore\x01\xfe\x00\x20\x00\x00\x08\x01"), rest...)
+	tooWide := append([]byte("bmkeystore\x01\xfd\x04\x00\x09\x01"), rest...)
+
+	for _, data := range [][]byte{nil, []byte("bmkeystore\x02"),
+		b.Bytes()[:len(b.Bytes())-10], tooCostly, tooWide} {
+		if _, err = identity.OpenKeystore(bytes.NewReader(data)); err != identity.ErrMalformedKeystore {
+			t.Errorf("OpenKeystore(%x) got %v want %v", data, err,
+				identity.ErrMalformedKeystore)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// privateIDEncoding is the first byte of a PrivateID encoded by
+// MarshalBinary.
+const privateIDEncoding = 1
+
+// privateIDChan is set in the flags of an encoded PrivateID which is a chan.
+const privateIDChan = 1
+
+// ErrMalformedIdentity is returned when an encoded identity cannot be
+// decoded.
+var ErrMalformedIdentity = errors.New("malformed identity")
+
+// MarshalBinary encodes everything about the identity: its address version
+// and stream, behavior, proof of work parameters, whether it is a chan and
+// both private keys.
+func (id *PrivateID) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte(privateIDEncoding)
+	WriteVarInt(&b, id.version)
+	WriteVarInt(&b, id.stream)
+	binary.Write(&b, binary.BigEndian, id.behavior)
+	data := id.Pow()
+	WriteVarInt(&b, data.NonceTrialsPerByte)
+	WriteVarInt(&b, data.ExtraBytes)
+	var flags byte
+	if id.isChan {
+		flags |= privateIDChan
+	}
+	b.WriteByte(flags)
+	b.Write(paddedKey(id.private.Signing))
+	b.Write(paddedKey(id.private.Decryption))
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes an identity encoded by MarshalBinary.
+func (id *PrivateID) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	if e, err := r.ReadByte(); err != nil || e != privateIDEncoding {
+		return ErrMalformedIdentity
+	}
+
+	var n [2]uint64
+	for i := range n {
+		v, err := ReadVarInt(r)
+		if err != nil {
+			return ErrMalformedIdentity
+		}
+		n[i] = v
+	}
+	var behavior uint32
+	if err := binary.Read(r, binary.BigEndian, &behavior); err != nil {
+		return ErrMalformedIdentity
+	}
+	p := &pow.Data{}
+	var err error
+	if p.NonceTrialsPerByte, err = ReadVarInt(r); err != nil {
+		return ErrMalformedIdentity
+	}
+	if p.ExtraBytes, err = ReadVarInt(r); err != nil {
+		return ErrMalformedIdentity
+	}
+	flags, err := r.ReadByte()
+	if err != nil || flags&^privateIDChan != 0 || r.Len() != 64 {
+		return ErrMalformedIdentity
+	}
+
+	keys := data[len(data)-64:]
+	signing, _ := btcec.PrivKeyFromBytes(btcec.S256(), keys[:32])
+	decryption, _ := btcec.PrivKeyFromBytes(btcec.S256(), keys[32:])
+	return id.set(&PrivateKey{Signing: signing, Decryption: decryption},
+		n[0], n[1], behavior, p, flags&privateIDChan != 0)
+}
+
+// set sets id after checking that the keys make an address of the given
+// version and stream.
+func (id *PrivateID) set(key *PrivateKey, version, stream uint64,
+	behavior uint32, data *pow.Data, isChan bool) error {
+	address := NewPrivateAddress(key, version, stream)
+	if _, err := address.public().address(); err != nil {
+		return err
+	}
+
+	*id = *NewPrivateID(address, behavior, data)
+	id.isChan = isChan
+	return nil
+}
+
+// privateIDJSON is the JSON form of a PrivateID. The keys are in hex.
+type privateIDJSON struct {
+	Address            string `json:"address"`
+	SigningKey         string `json:"signingKey"`
+	DecryptionKey      string `json:"decryptionKey"`
+	Behavior           uint32 `json:"behavior"`
+	NonceTrialsPerByte uint64 `json:"nonceTrialsPerByte"`
+	ExtraBytes         uint64 `json:"extraBytes"`
+	Chan               bool   `json:"chan,omitempty"`
+}
+
+// MarshalJSON encodes the same things as MarshalBinary, along with the
+// address, which is checked against the keys by UnmarshalJSON.
+func (id *PrivateID) MarshalJSON() ([]byte, error) {
+	data := id.Pow()
+	return json.Marshal(&privateIDJSON{
+		Address:            id.Address().String(),
+		SigningKey:         hex.EncodeToString(paddedKey(id.private.Signing)),
+		DecryptionKey:      hex.EncodeToString(paddedKey(id.private.Decryption)),
+		Behavior:           id.behavior,
+		NonceTrialsPerByte: data.NonceTrialsPerByte,
+		ExtraBytes:         data.ExtraBytes,
+		Chan:               id.isChan,
+	})
+}
+
+// UnmarshalJSON decodes an identity encoded by MarshalJSON. It returns
+// ErrAddressMismatch if the keys do not belong to the address.
+func (id *PrivateID) UnmarshalJSON(b []byte) error {
+	var j privateIDJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	addr, err := DecodeAddress(j.Address)
+	if err != nil {
+		return err
+	}
+
+	key := &PrivateKey{}
+	for _, k := range []struct {
+		hex string
+		key **btcec.PrivateKey
+	}{{j.SigningKey, &key.Signing}, {j.DecryptionKey, &key.Decryption}} {
+		raw, err := hex.DecodeString(k.hex)
+		if err != nil || len(raw) != 32 {
+			return ErrMalformedIdentity
+		}
+		*k.key, _ = btcec.PrivKeyFromBytes(btcec.S256(), raw)
+	}
+
+	var u PrivateID
+	if err = u.set(key, addr.Version(), addr.Stream(), j.Behavior,
+		&pow.Data{
+			NonceTrialsPerByte: j.NonceTrialsPerByte,
+			ExtraBytes:         j.ExtraBytes,
+		}, j.Chan); err != nil {
+		return err
+	}
+	if !ConstantTimeAddressEqual(u.Address(), addr) {
+		return ErrAddressMismatch
+	}
+	*id = u
+	return nil
+}
+
+// MarshalBinary encodes the identity as Encode does.
+func (id *publicID) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if err := Encode(&b, id); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes an identity encoded by MarshalBinary.
+func (id *publicID) UnmarshalBinary(data []byte) error {
+	pub, err := UnmarshalPublic(data)
+	if err != nil {
+		return err
+	}
+	*id = *pub.(*publicID)
+	return nil
+}
+
+// publicIDJSON is the JSON form of a Public identity. The keys are in hex,
+// as in a pubkey object.
+type publicIDJSON struct {
+	Address            string `json:"address"`
+	VerificationKey    string `json:"verificationKey"`
+	EncryptionKey      string `json:"encryptionKey"`
+	Behavior           uint32 `json:"behavior"`
+	NonceTrialsPerByte uint64 `json:"nonceTrialsPerByte"`
+	ExtraBytes         uint64 `json:"extraBytes"`
+}
+
+// MarshalJSON encodes the identity with its address, which is checked
+// against the keys by UnmarshalJSON.
+func (id *publicID) MarshalJSON() ([]byte, error) {
+	key := id.Key()
+	data := id.Pow()
+	return json.Marshal(&publicIDJSON{
+		Address:            id.Address().String(),
+		VerificationKey:    key.Verification.Wire().String(),
+		EncryptionKey:      key.Encryption.Wire().String(),
+		Behavior:           id.behavior,
+		NonceTrialsPerByte: data.NonceTrialsPerByte,
+		ExtraBytes:         data.ExtraBytes,
+	})
+}
+
+// UnmarshalJSON decodes an identity encoded by MarshalJSON.
+func (id *publicID) UnmarshalJSON(b []byte) error {
+	pub, err := UnmarshalPublicJSON(b)
+	if err != nil {
+		return err
+	}
+	*id = *pub.(*publicID)
+	return nil
+}
+
+// UnmarshalPublic decodes a Public identity encoded by its MarshalBinary
+// method, which is the same as Encode but must not be followed by anything.
+func UnmarshalPublic(data []byte) (Public, error) {
+	r := bytes.NewReader(data)
+	pub, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, ErrMalformedIdentity
+	}
+	return pub, nil
+}
+
+// UnmarshalPublicJSON decodes a Public identity encoded by its MarshalJSON
+// method. It returns ErrAddressMismatch if the keys do not belong to the
+// address.
+func UnmarshalPublicJSON(b []byte) (Public, error) {
+	var j publicIDJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, err
+	}
+	addr, err := DecodeAddress(j.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [2]*wire.PubKey
+	for i, s := range []string{j.VerificationKey, j.EncryptionKey} {
+		raw, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, ErrMalformedIdentity
+		}
+		if keys[i], err = wire.NewPubKey(raw); err != nil {
+			return nil, ErrMalformedIdentity
+		}
+	}
+	key, err := NewPublicKey(keys[0], keys[1])
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := NewPublic(key, addr.Version(), addr.Stream(), j.Behavior,
+		&pow.Data{
+			NonceTrialsPerByte: j.NonceTrialsPerByte,
+			ExtraBytes:         j.ExtraBytes,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if !ConstantTimeAddressEqual(pub.Address(), addr) {
+		return nil, ErrAddressMismatch
+	}
+	return pub, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestMarshalPrivate(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	ch, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []*identity.PrivateID{
+		identity.NewPrivateID(priv, identity.BehaviorAck,
+			&pow.Data{NonceTrialsPerByte: 2000, ExtraBytes: 3000}),
+		ch,
+	} {
+		b, err := id.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary error %v", err)
+		}
+		got := &identity.PrivateID{}
+		if err = got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary error %v", err)
+		}
+		if !reflect.DeepEqual(got, id) {
+			t.Errorf("binary round trip got %v want %v", got, id)
+		}
+
+		j, err := json.Marshal(id)
+		if err != nil {
+			t.Fatalf("json.Marshal error %v", err)
+		}
+		got = &identity.PrivateID{}
+		if err = json.Unmarshal(j, got); err != nil {
+			t.Fatalf("json.Unmarshal error %v", err)
+		}
+		if !reflect.DeepEqual(got, id) {
+			t.Errorf("JSON round trip got %v want %v", got, id)
+		}
+
+		if err = got.UnmarshalBinary(b[:len(b)-1]); err != identity.ErrMalformedIdentity {
+			t.Errorf("UnmarshalBinary of short data got %v want %v", err,
+				identity.ErrMalformedIdentity)
+		}
+	}
+
+	// The address must match the keys.
+	j, _ := json.Marshal(identity.NewPrivateID(priv, 0, nil))
+	j = bytes.Replace(j, []byte(priv.Address().String()),
+		[]byte(ch.Address().String()), 1)
+	if err = json.Unmarshal(j, &identity.PrivateID{}); err != identity.ErrAddressMismatch {
+		t.Errorf("json.Unmarshal with wrong address got %v want %v", err,
+			identity.ErrAddressMismatch)
+	}
+}
+
+func TestMarshalPublic(t *testing.T) {
+	ch, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := ch.Public()
+
+	b, err := pub.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary error %v", err)
+	}
+	got, err := identity.UnmarshalPublic(b)
+	if err != nil {
+		t.Fatalf("UnmarshalPublic error %v", err)
+	}
+	if !reflect.DeepEqual(got, pub) {
+		t.Errorf("binary round trip got %v want %v", got, pub)
+	}
+	if _, err = identity.UnmarshalPublic(append(b, 0)); err != identity.ErrMalformedIdentity {
+		t.Errorf("UnmarshalPublic with trailing data got %v want %v", err,
+			identity.ErrMalformedIdentity)
+	}
+
+	j, err := json.Marshal(pub)
+	if err != nil {
+		t.Fatalf("json.Marshal error %v", err)
+	}
+	if !strings.Contains(string(j), ch.Address().String()) {
+		t.Errorf("JSON %s does not contain the address", j)
+	}
+	if got, err = identity.UnmarshalPublicJSON(j); err != nil {
+		t.Fatalf("UnmarshalPublicJSON error %v", err)
+	}
+	if !reflect.DeepEqual(got, pub) {
+		t.Errorf("JSON round trip got %v want %v", got, pub)
+	}
+
+	// A decoded identity can be decoded into again.
+	if err = json.Unmarshal(j, got); err != nil {
+		t.Errorf("json.Unmarshal error %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// mineChunk is the number of attempts a worker of MineShorterAddress takes
+// at a time.
+const mineChunk = 256
+
+// KeySearch returns the keys to try on the given attempt of a search for a
+// shorter address, counting from zero. It returns nil keys for an attempt
+// that should be skipped. It is called from several goroutines at once.
+type KeySearch func(attempt uint64) (*PrivateKey, error)
+
+// NewRandomSearch returns a KeySearch which tries random encryption keys with
+// one random signing key, as NewRandom does. Keys are read from r as by
+// NewRandomFromReader, or from crypto/rand if r is nil.
+func NewRandomSearch(r io.Reader) (KeySearch, error) {
+	newKey := newKeySource(r)
+	if r != nil {
+		// The reader and the checks on it are not safe for concurrent use.
+		var mtx sync.Mutex
+		source := newKey
+		newKey = func() (*btcec.PrivateKey, error) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			return source()
+		}
+	}
+
+	signing, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	return func(uint64) (*PrivateKey, error) {
+		decryption, err := newKey()
+		if err != nil {
+			return nil, err
+		}
+		return &PrivateKey{Signing: signing, Decryption: decryption}, nil
+	}, nil
+}
+
+// DeterministicSearch returns a KeySearch which tries the keys that
+// NewDeterministic derives from passphrase.
+func DeterministicSearch(passphrase string) KeySearch {
+	return func(attempt uint64) (*PrivateKey, error) {
+		return deterministicKey(passphrase, attempt), nil
+	}
+}
+
+// NewHDSearch returns a KeySearch which tries the keys that NewHDWithPath
+// derives below path.
+func NewHDSearch(masterKey *hdkeychain.ExtendedKey, path HDPath) (KeySearch, error) {
+	if !masterKey.IsPrivate() {
+		return nil, ErrMasterKeyNotPrivate
+	}
+	a, err := path.derive(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	signKey, err := a.Child(0)
+	if err != nil {
+		return nil, err
+	}
+	signing, _ := signKey.ECPrivKey()
+
+	return func(attempt uint64) (*PrivateKey, error) {
+		encKey, err := a.Child(uint32(attempt + 1))
+		if err != nil {
+			// Some children are invalid keys and are skipped.
+			return nil, nil
+		}
+		decryption, _ := encKey.ECPrivKey()
+		return &PrivateKey{Signing: signing, Decryption: decryption}, nil
+	}, nil
+}
+
+// MineOptions are the options for MineShorterAddress.
+type MineOptions struct {
+	// Version is the address version whose ripe hash must have the initial
+	// zeros. If zero, DefaultAddressVersion is used.
+	Version uint64
+
+	// Workers is the number of goroutines searching. If not positive,
+	// runtime.NumCPU() is used.
+	Workers int
+
+	// Progress, if not nil, is called every so often as by
+	// NewRandomWithProgress. Attempts counts those made in order from the
+	// first, so it may lag behind the number actually made by the workers.
+	Progress ProgressFunc
+}
+
+// MineShorterAddress searches the keys given by source for a pair whose ripe
+// hash has at least zeroBytes initial zero bytes. Each zero byte makes the
+// address about one character shorter and the search 256 times longer. The
+// first such keys in order of attempt are returned whatever the number of
+// workers, so that the result for a deterministic source is always the
+// same. It returns with ctx.Err() if ctx is done first.
+func MineShorterAddress(ctx context.Context, source KeySearch, zeroBytes int,
+	opts *MineOptions) (*PrivateKey, error) {
+	if zeroBytes < 1 || zeroBytes > hash.RipeSize {
+		return nil, ErrInitialZeros
+	}
+	var o MineOptions
+	if opts != nil {
+		o = *opts
+	}
+	p := (&ParallelOptions{Version: o.Version, Workers: o.Workers}).withDefaults()
+	report := newProgressReporter(o.Progress, false)
+	if !report.attempt(0) {
+		return nil, ErrGenerationStopped
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// A chunk is done when a key has been found in it, or all of its
+	// attempts have failed, or there was an error.
+	type chunk struct {
+		index uint64
+		key   *PrivateKey
+		err   error
+	}
+	chunks := make(chan chunk)
+
+	var next uint64
+	var wg sync.WaitGroup
+	wg.Add(p.Workers)
+	for i := 0; i < p.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				c := chunk{index: atomic.AddUint64(&next, 1) - 1}
+				start := c.index * mineChunk
+				for a := start; a < start+mineChunk; a++ {
+					if ctx.Err() != nil {
+						return
+					}
+					pk, err := source(a)
+					if err != nil {
+						c.err = err
+						break
+					}
+					if pk != nil && hasInitialZeros(pk.HashForVersion(p.Version), zeroBytes) {
+						c.key = pk
+						break
+					}
+				}
+				select {
+				case chunks <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	pending := make(map[uint64]chunk)
+	var want uint64
+	for {
+		select {
+		case c := <-chunks:
+			pending[c.index] = c
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		for c, ok := pending[want]; ok; c, ok = pending[want] {
+			delete(pending, want)
+			if c.err != nil {
+				return nil, c.err
+			}
+			if c.key != nil {
+				return c.key, nil
+			}
+			want++
+			if !report.attempt(want * mineChunk) {
+				return nil, ErrGenerationStopped
+			}
+		}
+	}
+}
+
+// hasInitialZeros returns whether the hash starts with the given number of
+// zero bytes.
+func hasInitialZeros(h *hash.Ripe, zeros int) bool {
+	for _, b := range h[:zeros] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestMineShorterAddress(t *testing.T) {
+	ctx := context.Background()
+	opts := &MineOptions{Workers: 3}
+
+	// A deterministic search finds the keys NewDeterministic does, however
+	// many workers there are.
+	want, err := NewDeterministic("mine", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := MineShorterAddress(ctx, DeterministicSearch("mine"), 1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *pk.Hash() != *want[0].Hash() {
+		t.Errorf("deterministic: got %x want %x", pk.Hash(), want[0].Hash())
+	}
+
+	masterKey, err := hdkeychain.NewMaster([]byte("somegoodrandomseedwouldbeusefulhere"),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search, err := NewHDSearch(masterKey, DefaultHDPath(0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd, _ := NewHD(masterKey, 0, 1)
+	if pk, err = MineShorterAddress(ctx, search, 1, opts); err != nil {
+		t.Fatal(err)
+	}
+	if *pk.Hash() != *hd.Hash() {
+		t.Errorf("HD: got %x want %x", pk.Hash(), hd.Hash())
+	}
+
+	search, err = NewRandomSearch(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk, err = MineShorterAddress(ctx, search, 1, opts); err != nil {
+		t.Fatal(err)
+	}
+	if pk.Hash()[0] != 0 {
+		t.Errorf("random: got hash %x", pk.Hash())
+	}
+
+	// Five zero bytes take far too long to find, so the search can only
+	// end by being stopped.
+	var calls int
+	stop := &MineOptions{Progress: func(p Progress) bool {
+		calls++
+		return p.Attempts < 1024
+	}}
+	if _, err = MineShorterAddress(ctx, DeterministicSearch("mine"), 5, stop); err != ErrGenerationStopped {
+		t.Errorf("got %v want %v", err, ErrGenerationStopped)
+	}
+	if calls != 5 {
+		t.Errorf("progress called %d times, want 5", calls)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = MineShorterAddress(cancelled, DeterministicSearch("mine"), 5, nil); err != context.Canceled {
+		t.Errorf("got %v want %v", err, context.Canceled)
+	}
+	if _, err = MineShorterAddress(ctx, DeterministicSearch("mine"), 0, nil); err != ErrInitialZeros {
+		t.Errorf("got %v want %v", err, ErrInitialZeros)
+	}
+}
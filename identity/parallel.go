@@ -0,0 +1,201 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// deterministicChunk is the number of attempts a worker of
+// NewDeterministicParallel takes at a time. About one attempt in 256 has an
+// initial zero.
+const deterministicChunk = 256
+
+// ParallelOptions are the options for NewRandomParallel and
+// NewDeterministicParallel.
+type ParallelOptions struct {
+	// Version is the address version whose ripe hash must have the initial
+	// zeros. If zero, DefaultAddressVersion is used.
+	Version uint64
+
+	// Workers is the number of goroutines searching. If not positive,
+	// runtime.NumCPU() is used.
+	Workers int
+}
+
+func (o *ParallelOptions) withDefaults() ParallelOptions {
+	var d ParallelOptions
+	if o != nil {
+		d = *o
+	}
+	if d.Version == 0 {
+		d.Version = DefaultAddressVersion
+	}
+	if d.Workers <= 0 {
+		d.Workers = runtime.NumCPU()
+	}
+	return d
+}
+
+// NewRandomParallel is like NewRandom, but searches with several workers and
+// returns with ctx.Err() if ctx is done before any of them finds keys.
+func NewRandomParallel(ctx context.Context, initialZeros int, opts *ParallelOptions) (*PrivateKey, error) {
+	if initialZeros < 1 {
+		return nil, ErrInitialZeros
+	}
+	o := opts.withDefaults()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan *PrivateKey, 1)
+	failed := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(o.Workers)
+	for i := 0; i < o.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			pk, err := randomWorker(ctx, o.Version, initialZeros)
+			switch {
+			case err != nil:
+				select {
+				case failed <- err:
+				default:
+				}
+			case pk != nil:
+				select {
+				case found <- pk:
+				default:
+				}
+			default:
+				return
+			}
+			cancel()
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case pk := <-found:
+		return pk, nil
+	case err := <-failed:
+		return nil, err
+	default:
+		return nil, ctx.Err()
+	}
+}
+
+// randomWorker does what NewRandomForVersion does until ctx is done.
+func randomWorker(ctx context.Context, version uint64, initialZeros int) (*PrivateKey, error) {
+	signing, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		default:
+		}
+
+		decryption, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		pk := &PrivateKey{Signing: signing, Decryption: decryption}
+		if hasInitialZeros(pk.HashForVersion(version), initialZeros) {
+			return pk, nil
+		}
+	}
+}
+
+// NewDeterministicParallel returns the same keys as NewDeterministic, but
+// searches with several workers and returns with ctx.Err() if ctx is done
+// before all n have been found. The workers take turns at consecutive runs
+// of nonces, so the keys are found in the same order as by
+// NewDeterministic whatever the number of workers.
+func NewDeterministicParallel(ctx context.Context, passphrase string,
+	initialZeros uint64, n int, opts *ParallelOptions) ([]*PrivateKey, error) {
+	if initialZeros < 1 {
+		return nil, ErrInitialZeros
+	}
+	o := opts.withDefaults()
+	if n <= 0 {
+		return make([]*PrivateKey, 0), nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type chunk struct {
+		index uint64
+		keys  []*PrivateKey
+	}
+	chunks := make(chan chunk)
+
+	var next uint64
+	var wg sync.WaitGroup
+	wg.Add(o.Workers)
+	for i := 0; i < o.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				c := chunk{index: atomic.AddUint64(&next, 1) - 1}
+				start := c.index * deterministicChunk
+				for a := start; a < start+deterministicChunk; a++ {
+					if ctx.Err() != nil {
+						return
+					}
+					pk := deterministicKey(passphrase, a)
+					if hasInitialZeros(pk.HashForVersion(o.Version), int(initialZeros)) {
+						c.keys = append(c.keys, pk)
+					}
+				}
+				select {
+				case chunks <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// Chunks arrive in any order, but the keys are taken from them in
+	// order until there are enough.
+	pks := make([]*PrivateKey, 0, n)
+	pending := make(map[uint64][]*PrivateKey)
+	var want uint64
+	for {
+		select {
+		case c := <-chunks:
+			pending[c.index] = c.keys
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		for keys, ok := pending[want]; ok; keys, ok = pending[want] {
+			delete(pending, want)
+			want++
+			for _, pk := range keys {
+				pks = append(pks, pk)
+				if len(pks) == n {
+					return pks, nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestNewDeterministicParallel(t *testing.T) {
+	want, err := NewDeterministic("general", 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for workers := 1; workers <= 3; workers++ {
+		got, err := NewDeterministicParallel(context.Background(), "general", 1,
+			len(want), &ParallelOptions{Workers: workers})
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		for i := range want {
+			if *got[i].Hash() != *want[i].Hash() {
+				t.Errorf("%d workers: key %d differs from NewDeterministic",
+					workers, i)
+			}
+		}
+	}
+
+	if _, err = NewDeterministicParallel(context.Background(), "general", 0, 1,
+		nil); err != ErrInitialZeros {
+		t.Errorf("0 initial zeros: got %v want %v", err, ErrInitialZeros)
+	}
+}
+
+func TestNewRandomParallel(t *testing.T) {
+	pk, err := NewRandomParallel(context.Background(), 1,
+		&ParallelOptions{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk.HashForVersion(DefaultAddressVersion)[0] != 0 {
+		t.Errorf("key has no initial zero")
+	}
+
+	if _, err = NewRandomParallel(context.Background(), 0, nil); err != ErrInitialZeros {
+		t.Errorf("0 initial zeros: got %v want %v", err, ErrInitialZeros)
+	}
+}
+
+func TestParallelCancel(t *testing.T) {
+	// Twenty initial zeros will not be found before the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := NewRandomParallel(ctx, 20, nil); err != context.DeadlineExceeded {
+		t.Errorf("NewRandomParallel got %v want %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := NewDeterministicParallel(ctx, "general", 20, 1, nil); err != context.DeadlineExceeded {
+		t.Errorf("NewDeterministicParallel got %v want %v", err,
+			context.DeadlineExceeded)
+	}
+}
@@ -19,8 +19,8 @@ import (
 func TestNewRandom(t *testing.T) {
 	// At least one zero in the beginning
 	_, err := NewRandom(0)
-	if err == nil {
-		t.Error("for requiredZeros=0 expected error got none")
+	if err != ErrInitialZeros {
+		t.Errorf("for requiredZeros=0 expected ErrInitialZeros got %v", err)
 	}
 	v, err := NewRandom(1)
 	if err != nil {
@@ -32,6 +32,26 @@ func TestNewRandom(t *testing.T) {
 	fmt.Println("Encryption Key:", encryptionkey)
 }
 
+func TestPrivateKeyZero(t *testing.T) {
+	priv, err := ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := NewPrivateID(priv, BehaviorAck, nil)
+
+	// Wiping the identity wipes the keys it shares with the address, but
+	// the address itself can still be found.
+	id.Zero()
+	if priv.PrivateKey().Signing.D.Sign() != 0 || priv.PrivateKey().Decryption.D.Sign() != 0 {
+		t.Error("keys not wiped")
+	}
+	if got := priv.Address().String(); got != "BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B" {
+		t.Errorf("got address %s", got)
+	}
+}
+
 type deterministicAddressTest struct {
 	passphrase string
 	address    []string
@@ -93,13 +113,44 @@ func TestNewHD(t *testing.T) {
 		t.Errorf("invalid address, expected %s got %s", expectedAddr, addr)
 	}
 
+	public, err := masterKey.Neuter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewHD(public, 0, DefaultStream); err != ErrMasterKeyNotPrivate {
+		t.Errorf("public master key: expected ErrMasterKeyNotPrivate, got %v", err)
+	}
+
 	// TODO add more test cases with key derivations
 }
 
+func TestNewHDFromSeed(t *testing.T) {
+	pvt, err := NewHDFromSeed([]byte("somegoodrandomseedwouldbeusefulhere"), 0,
+		DefaultStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, _ := NewAddress(DefaultAddressVersion, DefaultStream, pvt.Hash())
+	if addr.String() != "BM-2cUqid7xty9zteYmu7aKxYiDTzL4k5YYn7" {
+		t.Errorf("got address %s", addr)
+	}
+
+	seed, err := NewHDSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewHDFromSeed(seed, 0, DefaultStream); err != nil {
+		t.Errorf("NewHDFromSeed with a new seed got error %v", err)
+	}
+	if _, err = NewHDFromSeed(seed[:15], 0, DefaultStream); err != hdkeychain.ErrInvalidSeedLen {
+		t.Errorf("short seed got error %v want %v", err, hdkeychain.ErrInvalidSeedLen)
+	}
+}
+
 func TestNewDeterministicErrors(t *testing.T) {
 	// NewDeterministic
 	_, err := NewDeterministic("abcabc", 0, 1) // 0 initial zeros
-	if err == nil {
-		t.Error("NewDeterministic: 0 initial zeros, got no error")
+	if err != ErrInitialZeros {
+		t.Errorf("NewDeterministic: 0 initial zeros, got %v", err)
 	}
 }
@@ -1,12 +1,27 @@
 package identity
 
 import (
-	"bytes"
 	"errors"
 
 	. "github.com/DanielKrawisz/bmutil"
 )
 
+// ErrAddressMismatch is returned when an address does not belong to the
+// private keys it is imported with.
+var ErrAddressMismatch = errors.New("address does not correspond to private keys")
+
+// KeyDecodeError is returned by ImportWIF when one of the keys cannot be
+// decoded. Key is "signing" or "encryption".
+type KeyDecodeError struct {
+	Key string
+	Err error
+}
+
+// Error returns a human-readable description of the error.
+func (e *KeyDecodeError) Error() string {
+	return e.Key + " key decode failed: " + e.Err.Error()
+}
+
 // PrivateAddress contains private keys and the parameters necessary
 // to derive an address from it.
 type PrivateAddress struct {
@@ -26,11 +41,13 @@ func NewPrivateAddress(key *PrivateKey, version, stream uint64) *PrivateAddress
 
 // public turns a PrivateAddress  object into publicAddress.
 func (id *PrivateAddress) public() *publicAddress {
-	return &publicAddress{
+	pa := &publicAddress{
 		PublicKey: *id.private.Public(),
 		version:   id.version,
 		stream:    id.stream,
 	}
+	pa.addr, _ = pa.address()
+	return pa
 }
 
 // Address constructs the Bitmessage address object corresponding to
@@ -39,6 +56,12 @@ func (id *PrivateAddress) Address() Address {
 	return id.public().Address()
 }
 
+// Zero wipes the private keys as PrivateKey.Zero does. Keys are shared
+// with any PrivateID made from the address, and the other way round.
+func (id *PrivateAddress) Zero() {
+	id.private.Zero()
+}
+
 // PrivateKey returns the private key corresponding to this address.
 func (id *PrivateAddress) PrivateKey() *PrivateKey {
 	return id.private
@@ -69,13 +92,11 @@ func ImportWIF(addrStr, signingKeyWif, decryptionKeyWif string) (*PrivateAddress
 
 	privSigningKey, err := DecodeWIF(signingKeyWif)
 	if err != nil {
-		err = errors.New("signing key decode failed: " + err.Error())
-		return nil, err
+		return nil, &KeyDecodeError{"signing", err}
 	}
 	privDecryptionKey, err := DecodeWIF(decryptionKeyWif)
 	if err != nil {
-		err = errors.New("encryption key decode failed: " + err.Error())
-		return nil, err
+		return nil, &KeyDecodeError{"encryption", err}
 	}
 
 	priv := &PrivateAddress{
@@ -89,8 +110,8 @@ func ImportWIF(addrStr, signingKeyWif, decryptionKeyWif string) (*PrivateAddress
 
 	// check if the address given is consistent with the private keys.
 	address := priv.Address()
-	if !bytes.Equal(address.RipeHash()[:], addr.RipeHash()[:]) {
-		return nil, errors.New("address does not correspond to private keys")
+	if !ConstantTimeAddressEqual(address, addr) {
+		return nil, ErrAddressMismatch
 	}
 	return priv, nil
 }
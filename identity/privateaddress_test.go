@@ -61,23 +61,23 @@ func TestImportWIFErrors(t *testing.T) {
 	// invalid signing key
 	_, err = identity.ImportWIF("BM-2cWgt4u3shyzQ8vP56uzMSe2iajy8r4Hxe",
 		"sd5f48erdfoiopadsfa5d6sf405", "")
-	if err == nil {
-		t.Error("ImportWIF: invalid signing key, got no error")
+	if e, ok := err.(*identity.KeyDecodeError); !ok || e.Key != "signing" {
+		t.Errorf("ImportWIF: invalid signing key, got %v", err)
 	}
 
 	// invalid encryption key
 	_, err = identity.ImportWIF("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
 		"5KHBtHsy9eWz6fFZzJCNMVVJ3r4m7AbuzYRE3hwkKZ2H7BEZrGU",
 		"sd5f48erdfoiopadsfa5d6sf405")
-	if err == nil {
-		t.Error("ImportWIF: invalid encryption key, got no error")
+	if e, ok := err.(*identity.KeyDecodeError); !ok || e.Key != "encryption" {
+		t.Errorf("ImportWIF: invalid encryption key, got %v", err)
 	}
 
 	// address does not match
 	_, err = identity.ImportWIF("BM-2DB6AzjZvzM8NkS3HMYWMP9R1Rt778mhN8",
 		"5JXVjG9CNFh17kCawPxCtekJBei9gv6hzmawBGFkuciTCMaxeJD",
 		"5KQC3fHBCUNyBoXeEpgphrqa314Cvy4beS21Zg1rvrj1FY3Tgqb")
-	if err == nil {
-		t.Error("ImportWIF: address mismatch, got no error")
+	if err != identity.ErrAddressMismatch {
+		t.Errorf("ImportWIF: address mismatch, got %v", err)
 	}
 }
@@ -11,6 +11,7 @@ type PrivateID struct {
 	PrivateAddress
 	behavior uint32
 	pow      *pow.Data
+	isChan   bool
 }
 
 // Public turns a Private identity object into Public identity object.
@@ -41,6 +42,12 @@ func (id *PrivateID) Behavior() uint32 {
 	return id.behavior
 }
 
+// IsChan returns whether the identity is a chan, whose private keys are
+// shared by everyone who knows its passphrase.
+func (id *PrivateID) IsChan() bool {
+	return id.isChan
+}
+
 // NewPrivateID constructs a PrivateID.
 func NewPrivateID(id *PrivateAddress, behavior uint32, data *pow.Data) *PrivateID {
 	return &PrivateID{
@@ -49,3 +56,11 @@ func NewPrivateID(id *PrivateAddress, behavior uint32, data *pow.Data) *PrivateI
 		pow:            data,
 	}
 }
+
+// NewChanID constructs a PrivateID which is marked as a chan, for chans
+// whose keys were stored rather than derived with NewChan.
+func NewChanID(id *PrivateAddress, behavior uint32, data *pow.Data) *PrivateID {
+	p := NewPrivateID(id, behavior, data)
+	p.isChan = true
+	return p
+}
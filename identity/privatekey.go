@@ -13,12 +13,23 @@ import (
 	. "github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
 )
 
 // BMPurposeCode is the purpose code used for HD key derivation.
 const BMPurposeCode = 0x80000052
 
+var (
+	// ErrInitialZeros is returned when fewer than one initial zero is
+	// asked of a new key, or more than a ripe hash has.
+	ErrInitialZeros = errors.New("minimum 1 initial zero needed")
+
+	// ErrMasterKeyNotPrivate is returned when an HD key is derived from a
+	// public master key.
+	ErrMasterKeyNotPrivate = errors.New("master key must be private")
+)
+
 // PrivateKey contains the private keys.
 type PrivateKey struct {
 	Signing    *btcec.PrivateKey
@@ -33,9 +44,35 @@ func (pk *PrivateKey) Public() *PublicKey {
 	}
 }
 
-// Hash returns the ripemd160 hash used in the address
+// Hash returns the ripemd160 hash used in an address of
+// DefaultAddressVersion.
 func (pk *PrivateKey) Hash() *hash.Ripe {
-	return pk.Public().Hash()
+	return pk.HashForVersion(DefaultAddressVersion)
+}
+
+// HashForVersion returns the ripemd160 hash used in an address of the given
+// version. See AddressHash.
+func (pk *PrivateKey) HashForVersion(version uint64) *hash.Ripe {
+	return pk.Public().HashForVersion(version)
+}
+
+// Zero wipes the private keys, so that they do not stay in memory once they
+// are no longer needed. The public keys are kept. The keys cannot be used to
+// sign or decrypt afterwards.
+func (pk *PrivateKey) Zero() {
+	zeroPrivateKey(pk.Signing)
+	zeroPrivateKey(pk.Decryption)
+}
+
+func zeroPrivateKey(key *btcec.PrivateKey) {
+	if key == nil || key.D == nil {
+		return
+	}
+	words := key.D.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	key.D.SetInt64(0)
 }
 
 // ExportWIF exports the private keys in WIF format.
@@ -49,30 +86,49 @@ func (pk *PrivateKey) ExportWIF() (SigningWif, DecryptionWif string) {
 // number of initial zeros in front (minimum 1). Each initial zero requires
 // exponentially more work. Note that this does not create an address.
 func NewRandom(initialZeros int) (*PrivateKey, error) {
+	return NewRandomForVersion(DefaultAddressVersion, initialZeros)
+}
+
+// NewRandomForVersion is like NewRandom except that the initial zeros are
+// those of the ripe hash in an address of the given version, which may differ
+// from that of DefaultAddressVersion if another hash is registered for it
+// with RegisterAddressHash.
+func NewRandomForVersion(version uint64, initialZeros int) (*PrivateKey, error) {
+	return newRandom(version, initialZeros, newKeySource(nil), nil)
+}
+
+// newRandom does the work of NewRandomForVersion with keys from newKey,
+// reporting to progress if it is not nil.
+func newRandom(version uint64, initialZeros int, newKey keySource,
+	progress ProgressFunc) (*PrivateKey, error) {
 	if initialZeros < 1 { // Cannot take this
-		return nil, errors.New("minimum 1 initial zero needed")
+		return nil, ErrInitialZeros
 	}
 
 	var pk = new(PrivateKey)
 	var err error
 
 	// Create signing key
-	pk.Signing, err = btcec.NewPrivateKey(btcec.S256())
+	pk.Signing, err = newKey()
 	if err != nil {
 		return nil, err
 	}
 
-	initialZeroBytes := make([]byte, initialZeros) // used for comparison
+	report := newProgressReporter(progress, false)
 	// Go through loop to encryption keys with required num. of zeros
-	for {
+	for attempt := uint64(0); ; attempt++ {
+		if !report.attempt(attempt) {
+			return nil, ErrGenerationStopped
+		}
+
 		// Generate encryption keys
-		pk.Decryption, err = btcec.NewPrivateKey(btcec.S256())
+		pk.Decryption, err = newKey()
 		if err != nil {
 			return nil, err
 		}
 
 		// We found our hash!
-		if bytes.Equal(pk.Hash()[0:initialZeros], initialZeroBytes) {
+		if hasInitialZeros(pk.HashForVersion(version), initialZeros) {
 			break // stop calculations
 		}
 	}
@@ -83,95 +139,119 @@ func NewRandom(initialZeros int) (*PrivateKey, error) {
 // NewDeterministic creates n identities based on a deterministic passphrase.
 // Note that this does not create an address.
 func NewDeterministic(passphrase string, initialZeros uint64, n int) ([]*PrivateKey, error) {
+	return NewDeterministicForVersion(DefaultAddressVersion, passphrase, initialZeros, n)
+}
+
+// NewDeterministicForVersion is like NewDeterministic except that the
+// initial zeros are those of the ripe hash in an address of the given
+// version.
+func NewDeterministicForVersion(version uint64, passphrase string,
+	initialZeros uint64, n int) ([]*PrivateKey, error) {
+	return newDeterministic(version, passphrase, initialZeros, n, nil)
+}
+
+// newDeterministic does the work of NewDeterministicForVersion, reporting to
+// progress if it is not nil.
+func newDeterministic(version uint64, passphrase string, initialZeros uint64,
+	n int, progress ProgressFunc) ([]*PrivateKey, error) {
 	if initialZeros < 1 { // Cannot take this
-		return nil, errors.New("minimum 1 initial zero needed")
+		return nil, ErrInitialZeros
 	}
 
 	pks := make([]*PrivateKey, n)
 
-	var b bytes.Buffer
-
-	// set the nonces
-	var SigningNonce, DecryptionNonce uint64 = 0, 1
-
-	initialZeroBytes := make([]byte, initialZeros) // used for comparison
-	sha := sha512.New()
+	report := newProgressReporter(progress, true)
 
 	// Generate n identities.
+	var attempt uint64
 	for i := 0; i < n; i++ {
-		pk := new(PrivateKey)
-
 		// Go through loop to encryption keys with required num. of zeros
 		for {
-			// Create signing keys
-			b.WriteString(passphrase)
-			WriteVarInt(&b, SigningNonce)
-			sha.Reset()
-			sha.Write(b.Bytes())
-			b.Reset()
-			pk.Signing, _ = btcec.PrivKeyFromBytes(btcec.S256(),
-				sha.Sum(nil)[:32])
-
-			// Create encryption keys
-			b.WriteString(passphrase)
-			WriteVarInt(&b, DecryptionNonce)
-			sha.Reset()
-			sha.Write(b.Bytes())
-			b.Reset()
-			pk.Decryption, _ = btcec.PrivKeyFromBytes(btcec.S256(),
-				sha.Sum(nil)[:32])
-
-			// Increment nonces
-			SigningNonce += 2
-			DecryptionNonce += 2
+			if !report.attempt(attempt) {
+				return nil, ErrGenerationStopped
+			}
+			pk := deterministicKey(passphrase, attempt)
+			attempt++
 
 			// We found our hash!
-			if bytes.Equal(pk.Hash()[0:initialZeros], initialZeroBytes) {
+			if hasInitialZeros(pk.HashForVersion(version), int(initialZeros)) {
+				pks[i] = pk
 				break // stop calculations
 			}
 		}
-
-		pks[i] = pk
 	}
 
 	return pks, nil
 }
 
+// deterministicKey returns the keys that NewDeterministic tries on the given
+// attempt, counting from zero. The signing key comes from the nonce 2*attempt
+// and the encryption key from the one after.
+func deterministicKey(passphrase string, attempt uint64) *PrivateKey {
+	var b bytes.Buffer
+	sum := make([]byte, 0, sha512.Size)
+	sha := sha512.New()
+	key := func(nonce uint64) *btcec.PrivateKey {
+		b.Reset()
+		b.WriteString(passphrase)
+		WriteVarInt(&b, nonce)
+		sha.Reset()
+		sha.Write(b.Bytes())
+		zero(b.Bytes())
+		sum = sha.Sum(sum[:0])
+		k, _ := btcec.PrivKeyFromBytes(btcec.S256(), sum[:32])
+		zero(sum)
+		return k
+	}
+
+	return &PrivateKey{
+		Signing:    key(2 * attempt),
+		Decryption: key(2*attempt + 1),
+	}
+}
+
 // NewHD generates a new hierarchically deterministic key based on BIP-BM01.
 // Master key must be a private master key generated according to BIP32. `n' is
 // the n'th identity to generate. NewHD also generates a v4 address based on the
 // specified stream.
 func NewHD(masterKey *hdkeychain.ExtendedKey, n uint32, stream uint64) (*PrivateKey, error) {
+	return NewHDWithPath(masterKey, DefaultHDPath(n, stream))
+}
 
-	if !masterKey.IsPrivate() {
-		return nil, errors.New("master key must be private")
-	}
+// NewHDSeed returns a random seed of the length recommended by BIP32, for
+// use with NewHDFromSeed.
+func NewHDSeed() ([]byte, error) {
+	return hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+}
 
-	// m / purpose'
-	p, err := masterKey.Child(BMPurposeCode)
+// NewHDFromSeed is like NewHD, but takes the seed of the master key, which
+// must be between 16 and 64 bytes long, so that the master key need not be
+// made by the caller. Derivation doesn't depend on the network that a BIP32
+// master key is made for, so the keys are the same as NewHD derives from a
+// master key made from the same seed for any network.
+func NewHDFromSeed(seed []byte, n uint32, stream uint64) (*PrivateKey, error) {
+	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
 	if err != nil {
 		return nil, err
 	}
+	return NewHD(masterKey, n, stream)
+}
 
-	// m / purpose' / identity'
-	i, err := p.Child(hdkeychain.HardenedKeyStart + n)
-	if err != nil {
-		return nil, err
-	}
+// NewHDWithPath is like NewHD, but finds the keys below the key at the given
+// path instead of the one given by DefaultHDPath, for applications with
+// derivation schemes of their own.
+func NewHDWithPath(masterKey *hdkeychain.ExtendedKey, path HDPath) (*PrivateKey, error) {
 
-	// m / purpose' / identity' / stream'
-	s, err := i.Child(hdkeychain.HardenedKeyStart + uint32(stream))
-	if err != nil {
-		return nil, err
+	if !masterKey.IsPrivate() {
+		return nil, ErrMasterKeyNotPrivate
 	}
 
-	// m / purpose' / identity' / stream' / address'
-	a, err := s.Child(hdkeychain.HardenedKeyStart + 0)
+	a, err := path.derive(masterKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// m / purpose' / identity' / stream' / address' / 0
+	// path / 0
 	signKey, err := a.Child(0)
 	if err != nil {
 		return nil, err
@@ -188,10 +268,16 @@ func NewHD(masterKey *hdkeychain.ExtendedKey, n uint32, stream uint64) (*Private
 		pk.Decryption, _ = encKey.ECPrivKey()
 
 		// We found our hash!
-		if h := pk.Hash(); h[0] == 0x00 { // First byte should be zero.
+		if hasInitialZeros(pk.HashForVersion(DefaultAddressVersion), 1) {
 			break // stop calculations
 		}
 	}
 
 	return pk, nil
 }
+
+// hdAccount derives m / purpose' / identity' / stream' / address', below
+// which are the keys of an HD identity.
+func hdAccount(masterKey *hdkeychain.ExtendedKey, n uint32, stream uint64) (*hdkeychain.ExtendedKey, error) {
+	return DefaultHDPath(n, stream).derive(masterKey)
+}
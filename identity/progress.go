@@ -0,0 +1,88 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+)
+
+// progressInterval is how many attempts are made between calls to a
+// ProgressFunc.
+const progressInterval = 64
+
+// ErrGenerationStopped is returned when a ProgressFunc asks for the search
+// for keys to stop.
+var ErrGenerationStopped = errors.New("key generation stopped")
+
+// Progress describes how far a search for keys has got.
+type Progress struct {
+	// Attempts is the number of pairs of keys tried so far.
+	Attempts uint64
+
+	// Elapsed is the time since the search started.
+	Elapsed time.Duration
+
+	// Nonce is the nonce from which the signing key of the next attempt
+	// will be derived by NewDeterministicWithProgress. The encryption key
+	// comes from the one after. It is always zero for random keys.
+	Nonce uint64
+}
+
+// ProgressFunc is called now and then during a search for keys. The search
+// goes on while it returns true and stops with ErrGenerationStopped when it
+// returns false, so that a user can be offered a way to cancel.
+type ProgressFunc func(Progress) bool
+
+// NewRandomWithProgress is like NewRandom, but calls progress every so
+// often, starting before the first attempt.
+func NewRandomWithProgress(initialZeros int, progress ProgressFunc) (*PrivateKey, error) {
+	return newRandom(DefaultAddressVersion, initialZeros, newKeySource(nil), progress)
+}
+
+// NewDeterministicWithProgress is like NewDeterministic, but calls progress
+// every so often, starting before the first attempt.
+func NewDeterministicWithProgress(passphrase string, initialZeros uint64, n int,
+	progress ProgressFunc) ([]*PrivateKey, error) {
+	return newDeterministic(DefaultAddressVersion, passphrase, initialZeros, n,
+		progress)
+}
+
+// progressReporter calls a ProgressFunc once every progressInterval
+// attempts. A nil one does nothing.
+type progressReporter struct {
+	progress      ProgressFunc
+	deterministic bool
+	start         time.Time
+}
+
+func newProgressReporter(progress ProgressFunc, deterministic bool) *progressReporter {
+	if progress == nil {
+		return nil
+	}
+	return &progressReporter{
+		progress:      progress,
+		deterministic: deterministic,
+		start:         time.Now(),
+	}
+}
+
+// attempt is called before each attempt, counting from zero, and returns
+// whether to go on.
+func (r *progressReporter) attempt(attempt uint64) bool {
+	if r == nil || attempt%progressInterval != 0 {
+		return true
+	}
+	p := Progress{
+		Attempts: attempt,
+		Elapsed:  time.Since(r.start),
+	}
+	if r.deterministic {
+		p.Nonce = 2 * attempt
+	}
+	return r.progress(p)
+}
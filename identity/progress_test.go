@@ -0,0 +1,60 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestProgress(t *testing.T) {
+	want, err := NewDeterministic("general", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []Progress
+	got, err := NewDeterministicWithProgress("general", 1, 2, func(p Progress) bool {
+		reports = append(reports, p)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if *got[i].Hash() != *want[i].Hash() {
+			t.Errorf("key %d differs from NewDeterministic", i)
+		}
+	}
+	if len(reports) == 0 {
+		t.Fatal("progress was never called")
+	}
+	for i, p := range reports {
+		if i > 0 && p.Attempts <= reports[i-1].Attempts {
+			t.Errorf("attempts went from %d to %d", reports[i-1].Attempts,
+				p.Attempts)
+		}
+		if p.Nonce != 2*p.Attempts {
+			t.Errorf("report %d has nonce %d for %d attempts", i, p.Nonce,
+				p.Attempts)
+		}
+	}
+
+	// Twenty initial zeros take far longer than the calls allowed here.
+	calls := 0
+	stop := func(p Progress) bool {
+		calls++
+		return calls < 3
+	}
+	if _, err = NewRandomWithProgress(20, stop); err != ErrGenerationStopped {
+		t.Errorf("NewRandomWithProgress got %v want %v", err, ErrGenerationStopped)
+	}
+	calls = 0
+	if _, err = NewDeterministicWithProgress("general", 20, 1, stop); err != ErrGenerationStopped {
+		t.Errorf("NewDeterministicWithProgress got %v want %v", err,
+			ErrGenerationStopped)
+	}
+}
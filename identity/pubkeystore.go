@@ -0,0 +1,114 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+)
+
+// PubKeyStore holds the public identities of correspondents, each for as
+// long as it is good for. Messages are encrypted to the identities found in
+// it, and a pubkey that is missing or has expired should be asked for with
+// a getpubkey.
+type PubKeyStore interface {
+	// Get returns the identity with the given address and the time it was
+	// obtained. ok is false if there is none or it has expired.
+	Get(addr Address) (pub Public, obtained time.Time, ok bool)
+
+	// Put stores an identity which was obtained at the given time and
+	// which is good for ttl after that.
+	Put(pub Public, obtained time.Time, ttl time.Duration) error
+}
+
+// MemoryPubKeyStore is a PubKeyStore which keeps everything in memory. It
+// is safe for concurrent use.
+type MemoryPubKeyStore struct {
+	mtx     sync.Mutex
+	entries map[string]*storedPubKey
+}
+
+type storedPubKey struct {
+	pub      Public
+	obtained time.Time
+	expires  time.Time
+}
+
+// NewMemoryPubKeyStore returns an empty MemoryPubKeyStore.
+func NewMemoryPubKeyStore() *MemoryPubKeyStore {
+	return &MemoryPubKeyStore{
+		entries: make(map[string]*storedPubKey),
+	}
+}
+
+// Get returns the identity with the given address if it has not expired.
+func (s *MemoryPubKeyStore) Get(addr Address) (Public, time.Time, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e, ok := s.entries[addr.String()]
+	if !ok || !time.Now().Before(e.expires) {
+		return nil, time.Time{}, false
+	}
+	return e.pub, e.obtained, true
+}
+
+// Put stores an identity, replacing any with the same address which was
+// obtained earlier.
+func (s *MemoryPubKeyStore) Put(pub Public, obtained time.Time, ttl time.Duration) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	addr := pub.Address().String()
+	if e, ok := s.entries[addr]; ok && e.obtained.After(obtained) {
+		return nil
+	}
+	s.entries[addr] = &storedPubKey{
+		pub:      pub,
+		obtained: obtained,
+		expires:  obtained.Add(ttl),
+	}
+	return nil
+}
+
+// Remove removes the identity with the given address and reports whether
+// there was one.
+func (s *MemoryPubKeyStore) Remove(addr Address) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	key := addr.String()
+	_, ok := s.entries[key]
+	delete(s.entries, key)
+	return ok
+}
+
+// Prune removes the identities which have expired and returns how many
+// there were.
+func (s *MemoryPubKeyStore) Prune() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := time.Now()
+	n := 0
+	for addr, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, addr)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of identities held, including any which have
+// expired but not been pruned.
+func (s *MemoryPubKeyStore) Len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.entries)
+}
@@ -0,0 +1,56 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestMemoryPubKeyStore(t *testing.T) {
+	ch, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := ch.Public()
+	addr := pub.Address()
+
+	var store identity.PubKeyStore = identity.NewMemoryPubKeyStore()
+	if _, _, ok := store.Get(addr); ok {
+		t.Errorf("empty store returned an identity")
+	}
+
+	obtained := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err = store.Put(pub, obtained, 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	got, when, ok := store.Get(addr)
+	if !ok || got != pub || !when.Equal(obtained) {
+		t.Errorf("Get got %v %v %v", got, when, ok)
+	}
+
+	// An older copy does not replace a newer one.
+	store.Put(pub, obtained.Add(-time.Hour), 4*time.Hour)
+	if _, when, _ = store.Get(addr); !when.Equal(obtained) {
+		t.Errorf("older identity replaced newer one")
+	}
+
+	// An expired identity is not returned and is pruned.
+	s := store.(*identity.MemoryPubKeyStore)
+	store.Put(pub, obtained.Add(time.Minute), time.Minute)
+	if _, _, ok = store.Get(addr); ok {
+		t.Errorf("expired identity was returned")
+	}
+	if n := s.Prune(); n != 1 || s.Len() != 0 {
+		t.Errorf("Prune removed %d, %d left", n, s.Len())
+	}
+
+	store.Put(pub, time.Now(), time.Hour)
+	if !s.Remove(addr) || s.Remove(addr) {
+		t.Errorf("Remove did not remove the identity exactly once")
+	}
+}
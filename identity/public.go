@@ -19,7 +19,34 @@ type Public interface {
 	Data() *obj.PubKeyData
 	Behavior() uint32
 	Pow() *pow.Data
+	Fingerprint() string
 	String() string
+
+	// Equal returns whether the identity is the same as another. See
+	// EqualPublic.
+	Equal(Public) bool
+}
+
+// EqualPublic returns whether two public identities have the same keys,
+// address version and stream, behavior and pow parameters. It can be used
+// by implementations of Public.
+func EqualPublic(a, b Public) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ka, kb := a.Key(), b.Key()
+	return a.Address().Equal(b.Address()) &&
+		ka.Verification.IsEqual(kb.Verification) &&
+		ka.Encryption.IsEqual(kb.Encryption) &&
+		a.Behavior() == b.Behavior() &&
+		powOrDefault(a.Pow()) == powOrDefault(b.Pow())
+}
+
+func powOrDefault(data *pow.Data) pow.Data {
+	if data == nil {
+		return pow.Default
+	}
+	return *data
 }
 
 // Encode serializes the public identity.
@@ -63,28 +90,48 @@ func Decode(r io.Reader) (Public, error) {
 		return nil, err
 	}
 
-	pk, err := NewPublicKey(data.Verification, data.Encryption)
+	return NewPublicFromWire(data, version, stream)
+}
+
+// NewPublic creates and initializes an *identity.PublicID object.
+func NewPublic(public *PublicKey, version, stream uint64, behavior uint32,
+	data *pow.Data) (Public, error) {
+	address, err := newPublicAddress(public, version, stream)
 	if err != nil {
 		return nil, err
 	}
 
-	pa, err := newPublicAddress(pk, version, stream)
+	return newPublicID(address, behavior, data), nil
+}
+
+// NewPublicFromWire creates a public identity of the given version and
+// stream from the data in a pubkey as received off the wire. An error is
+// returned if either key is not a valid point on the curve.
+func NewPublicFromWire(data *obj.PubKeyData, version, stream uint64) (Public, error) {
+	public, err := NewPublicKey(data.Verification, data.Encryption)
 	if err != nil {
 		return nil, err
 	}
 
-	return newPublicID(pa, data.Behavior, data.Pow), nil
+	return NewPublic(public, version, stream, data.Behavior, data.Pow)
 }
 
-// NewPublic creates and initializes an *identity.PublicID object.
-func NewPublic(public *PublicKey, version, stream uint64, behavior uint32,
-	data *pow.Data) (Public, error) {
-	address, err := newPublicAddress(public, version, stream)
+// NewAddressFromPubKeyData returns the address of the given version and
+// stream for the keys in a pubkey as received off the wire. The
+// bmutil package cannot do this itself because it is below wire/obj, so this
+// is the shortest route from a pubkey object to its address. The keys must
+// be valid points on the curve.
+func NewAddressFromPubKeyData(data *obj.PubKeyData, version, stream uint64) (Address, error) {
+	public, err := NewPublicKey(data.Verification, data.Encryption)
 	if err != nil {
 		return nil, err
 	}
 
-	return newPublicID(address, behavior, data), nil
+	return (&publicAddress{
+		PublicKey: *public,
+		version:   version,
+		stream:    stream,
+	}).address()
 }
 
 // NewPublicFromWIF creates an *identity.Public object from a PrivateAddress
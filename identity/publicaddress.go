@@ -10,21 +10,29 @@ type publicAddress struct {
 	PublicKey
 	version uint64
 	stream  uint64
+
+	// addr is the address, which is made when the publicAddress is
+	// created so that the keys need not be hashed again.
+	addr Address
 }
 
 // address generates an address from the public id.
 func (id *publicAddress) address() (Address, error) {
+	ripe := id.HashForVersion(id.version)
 	if id.version < 4 {
-		return NewDepricatedAddress(id.version, id.stream, id.Hash())
+		return NewDepricatedAddress(id.version, id.stream, ripe)
 	}
 
-	return NewAddress(id.version, id.stream, id.Hash())
+	return NewAddress(id.version, id.stream, ripe)
 }
 
 // Address generates an address from the public id. We don't have to
 // check for errors because when the publicAddress object is created,
 // we checked whether the address was valid.
 func (id *publicAddress) Address() Address {
+	if id.addr != nil {
+		return id.addr
+	}
 	var a Address
 	a, _ = id.address()
 	return a
@@ -40,7 +48,8 @@ func newPublicAddress(public *PublicKey, version, stream uint64) (*publicAddress
 	}
 
 	// Check whether the address can be generated without an error.
-	_, err := id.address()
+	var err error
+	id.addr, err = id.address()
 	if err != nil {
 		return nil, err
 	}
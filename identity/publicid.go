@@ -58,6 +58,11 @@ func (id *publicID) Behavior() uint32 {
 	return id.behavior
 }
 
+// Equal returns whether the identity is the same as another.
+func (id *publicID) Equal(other Public) bool {
+	return EqualPublic(id, other)
+}
+
 // newPublicID creates and initializes an *identity.Public object.
 func newPublicID(address *publicAddress, behavior uint32, data *pow.Data) *publicID {
 	id := publicID{
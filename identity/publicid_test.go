@@ -11,6 +11,8 @@ import (
 
 	"github.com/DanielKrawisz/bmutil/identity"
 	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
 )
 
 func TestNewPublic(t *testing.T) {
@@ -40,3 +42,90 @@ func TestNewPublic(t *testing.T) {
 		t.Errorf("Created public identity not equal to original.")
 	}
 }
+
+func TestNewAddressFromPubKeyData(t *testing.T) {
+	privAddr, err := identity.ImportWIF("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	if err != nil {
+		t.Fatal("Could not create ID: ", err)
+	}
+	address := privAddr.Address()
+	data := identity.NewPublicFromWIF(privAddr, identity.BehaviorAck, nil).Data()
+
+	addr, err := identity.NewAddressFromPubKeyData(data, address.Version(), address.Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !addr.Equal(address) {
+		t.Errorf("got address %s, want %s", addr, address)
+	}
+
+	if _, err = identity.NewAddressFromPubKeyData(data, 1, address.Stream()); err == nil {
+		t.Error("version 1: expected error got none")
+	}
+
+	bad := &obj.PubKeyData{
+		Verification: &wire.PubKey{},
+		Encryption:   data.Encryption,
+	}
+	if _, err = identity.NewAddressFromPubKeyData(bad, address.Version(), address.Stream()); err == nil {
+		t.Error("invalid key: expected error got none")
+	}
+}
+
+func TestNewPublicFromWire(t *testing.T) {
+	privAddr, err := identity.ImportWIF("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	if err != nil {
+		t.Fatal("Could not create ID: ", err)
+	}
+	address := privAddr.Address()
+	id := identity.NewPublicFromWIF(privAddr, identity.BehaviorAck, nil)
+
+	pub, err := identity.NewPublicFromWire(id.Data(), address.Version(), address.Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub, id) {
+		t.Errorf("got %s, want %s", pub, id)
+	}
+
+	bad := &obj.PubKeyData{
+		Verification: id.Data().Verification,
+		Encryption:   &wire.PubKey{},
+	}
+	if _, err = identity.NewPublicFromWire(bad, address.Version(), address.Stream()); err == nil {
+		t.Error("invalid key: expected error got none")
+	}
+}
+
+func TestPublicEqual(t *testing.T) {
+	privAddr, err := identity.ImportWIF("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	if err != nil {
+		t.Fatal("Could not create ID: ", err)
+	}
+	id := identity.NewPublicFromWIF(privAddr, identity.BehaviorAck, nil)
+	same, err := identity.NewPublicFromWire(id.Data(), 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !id.Equal(same) || !same.Equal(id) {
+		t.Error("identities with the same keys and parameters are not equal")
+	}
+
+	others := []identity.Public{
+		identity.NewPublicFromWIF(privAddr, 0, nil),
+		identity.NewPublicFromWIF(privAddr, identity.BehaviorAck,
+			&pow.Data{NonceTrialsPerByte: 2000, ExtraBytes: 1000}),
+		nil,
+	}
+	for i, other := range others {
+		if id.Equal(other) {
+			t.Errorf("#%d: got equal", i)
+		}
+	}
+}
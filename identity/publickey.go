@@ -6,12 +6,11 @@
 package identity
 
 import (
-	"crypto/sha512"
 	"fmt"
 
+	. "github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/DanielKrawisz/bmutil/wire"
-	"golang.org/x/crypto/ripemd160"
 )
 
 // PublicKey contains the identity of the remote user, which includes public
@@ -21,19 +20,17 @@ type PublicKey struct {
 	Encryption   *PubKey
 }
 
-// Hash returns the ripemd160 hash used in the address
+// Hash returns the ripemd160 hash used in an address of
+// DefaultAddressVersion.
 func (k *PublicKey) Hash() *hash.Ripe {
-	sha := sha512.New()
-	ripemd := ripemd160.New()
-
-	sha.Write(k.Verification.uncompressed())
-	sha.Write(k.Encryption.uncompressed())
-
-	ripemd.Write(sha.Sum(nil)) // take ripemd160 of required elements
+	return k.HashForVersion(DefaultAddressVersion)
+}
 
-	// Get the hash
-	r, _ := hash.NewRipe(ripemd.Sum(nil))
-	return r
+// HashForVersion returns the ripe hash used in an address of the given
+// version. See AddressHash.
+func (k *PublicKey) HashForVersion(version uint64) *hash.Ripe {
+	return AddressHash(version)(k.Verification.uncompressed(),
+		k.Encryption.uncompressed())
 }
 
 // String creates a human-readible string of a PublicKey.
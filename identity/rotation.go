@@ -0,0 +1,193 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/base58"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// rotationVersion is the first byte of an encoded Rotation.
+const rotationVersion = 1
+
+// rotationDomain is hashed in front of everything a Rotation signs, so that
+// its signature cannot be taken for one over a message or a pubkey.
+const rotationDomain = "bitmessage identity rotation\x00"
+
+// maxSignatureSize is the largest DER encoded signature.
+const maxSignatureSize = 72
+
+// maxAddressBytesSize is the largest address in the form returned by
+// AddressBytes, with two nine byte varints.
+const maxAddressBytesSize = 18 + hash.RipeSize
+
+var (
+	// ErrInvalidRotation is returned when a rotation statement is not
+	// signed by the key of the address it supersedes, or would replace an
+	// address with itself.
+	ErrInvalidRotation = errors.New("invalid identity rotation")
+
+	// ErrMalformedRotation is returned when a rotation statement cannot be
+	// decoded.
+	ErrMalformedRotation = errors.New("malformed identity rotation")
+)
+
+// Rotation is a statement that the address New supersedes the identity
+// Old, signed by Old's signing key. A user moving to new keys can send it
+// to their contacts, who can check that it really came from the old
+// identity before switching to the new address. Keyring.AddRotation records
+// one so that Keyring.Resolve gives the new address.
+type Rotation struct {
+	Old     Public
+	New     Address
+	Created time.Time
+
+	signature []byte
+}
+
+// NewRotation creates a signed statement that newAddress supersedes old.
+// Created is kept to the second.
+func NewRotation(old *PrivateID, newAddress Address, created time.Time) (*Rotation, error) {
+	r := &Rotation{
+		Old:     old.Public(),
+		New:     newAddress,
+		Created: time.Unix(created.Unix(), 0),
+	}
+	if r.Old.Address().Equal(newAddress) {
+		return nil, ErrInvalidRotation
+	}
+
+	sig, err := old.PrivateKey().Signing.Sign(r.hash())
+	if err != nil {
+		return nil, err
+	}
+	r.signature = sig.Serialize()
+	return r, nil
+}
+
+// Verify returns ErrInvalidRotation unless the statement is signed by the old
+// identity.
+func (r *Rotation) Verify() error {
+	if r.Old.Address().Equal(r.New) {
+		return ErrInvalidRotation
+	}
+	sig, err := btcec.ParseSignature(r.signature, btcec.S256())
+	if err != nil {
+		return ErrInvalidRotation
+	}
+	if !sig.Verify(r.hash(), r.Old.Key().Verification.Btcec()) {
+		return ErrInvalidRotation
+	}
+	return nil
+}
+
+// hash returns the hash that is signed.
+func (r *Rotation) hash() []byte {
+	h := sha256.New()
+	h.Write([]byte(rotationDomain))
+	r.encodeForSigning(h)
+	return h.Sum(nil)
+}
+
+func (r *Rotation) encodeForSigning(w io.Writer) error {
+	var err error
+	if _, err = w.Write([]byte{rotationVersion}); err != nil {
+		return err
+	}
+	if err = Encode(w, r.Old); err != nil {
+		return err
+	}
+	if err = WriteVarBytes(w, AddressBytes(r.New)); err != nil {
+		return err
+	}
+	var created [8]byte
+	binary.BigEndian.PutUint64(created[:], uint64(r.Created.Unix()))
+	_, err = w.Write(created[:])
+	return err
+}
+
+// Encode writes the statement to w.
+func (r *Rotation) Encode(w io.Writer) error {
+	if err := r.encodeForSigning(w); err != nil {
+		return err
+	}
+	return WriteVarBytes(w, r.signature)
+}
+
+// DecodeRotation reads a statement written by Encode. The statement is not
+// verified.
+func DecodeRotation(rd io.Reader) (*Rotation, error) {
+	var version [1]byte
+	if _, err := io.ReadFull(rd, version[:]); err != nil {
+		return nil, err
+	}
+	if version[0] != rotationVersion {
+		return nil, ErrMalformedRotation
+	}
+
+	old, err := Decode(rd)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ReadVarBytes(rd, maxAddressBytesSize, "address")
+	if err != nil {
+		return nil, err
+	}
+	newAddress, err := AddressFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	var created [8]byte
+	if _, err = io.ReadFull(rd, created[:]); err != nil {
+		return nil, err
+	}
+	sig, err := ReadVarBytes(rd, maxSignatureSize, "signature")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rotation{
+		Old:       old,
+		New:       newAddress,
+		Created:   time.Unix(int64(binary.BigEndian.Uint64(created[:])), 0),
+		signature: sig,
+	}, nil
+}
+
+// String returns the statement as base58 text with a checksum, which can be
+// pasted into a message or a web page.
+func (r *Rotation) String() string {
+	var b bytes.Buffer
+	r.Encode(&b)
+	return base58.EncodeCheck(b.Bytes())
+}
+
+// ParseRotation reads a statement in the form returned by String. The
+// statement is not verified.
+func ParseRotation(s string) (*Rotation, error) {
+	body, err := base58.DecodeCheck(s)
+	if err == base58.ErrInvalidFormat {
+		return nil, ErrMalformedRotation
+	}
+	if err != nil {
+		return nil, ErrChecksumMismatch
+	}
+
+	rd := bytes.NewReader(body)
+	r, err := DecodeRotation(rd)
+	if err != nil || rd.Len() != 0 {
+		return nil, ErrMalformedRotation
+	}
+	return r, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestRotation(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	old := identity.NewPrivateID(priv, identity.BehaviorAck, &pow.Default)
+	ch, err := identity.NewChan("general", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2016, 5, 10, 12, 0, 0, 0, time.UTC)
+	r, err := identity.NewRotation(old, ch.Address(), created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Verify(); err != nil {
+		t.Errorf("Verify got %v", err)
+	}
+
+	parsed, err := identity.ParseRotation(r.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = parsed.Verify(); err != nil {
+		t.Errorf("Verify parsed got %v", err)
+	}
+	if !parsed.Old.Address().Equal(old.Address()) || !parsed.New.Equal(ch.Address()) ||
+		!parsed.Created.Equal(created) {
+		t.Errorf("got %s -> %s at %s", parsed.Old.Address(), parsed.New, parsed.Created)
+	}
+
+	// Changing anything that was signed breaks the signature.
+	parsed.Created = created.Add(time.Second)
+	if err = parsed.Verify(); err != identity.ErrInvalidRotation {
+		t.Errorf("changed time: got %v want %v", err, identity.ErrInvalidRotation)
+	}
+	parsed, _ = identity.ParseRotation(r.String())
+	parsed.Old = ch.Public()
+	parsed.New = old.Address()
+	if err = parsed.Verify(); err != identity.ErrInvalidRotation {
+		t.Errorf("changed identity: got %v want %v", err, identity.ErrInvalidRotation)
+	}
+
+	if _, err = identity.NewRotation(old, old.Address(), created); err != identity.ErrInvalidRotation {
+		t.Errorf("same address: got %v want %v", err, identity.ErrInvalidRotation)
+	}
+	if _, err = identity.ParseRotation(r.String()[1:]); err == nil {
+		t.Error("ParseRotation of damaged text: expected error got none")
+	}
+}
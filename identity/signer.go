@@ -0,0 +1,34 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// Signer signs with the signing key of an identity. The key need not be in
+// memory: a Signer may be backed by a hardware token, an HSM or a remote
+// service. A *btcec.PrivateKey is a Signer, so the Signing key of a
+// PrivateKey can be used wherever one is wanted. It picks the nonce of each
+// signature as described in RFC 6979, so that the same key always gives the
+// same signature of the same hash and a bad random number generator cannot
+// leak the key; a Signer backed by something else should do the same.
+type Signer interface {
+	// Sign signs a hash.
+	Sign(hash []byte) (*btcec.Signature, error)
+
+	// PubKey returns the public key that verifies the signatures.
+	PubKey() *btcec.PublicKey
+}
+
+// PubKey returns the verification key, so that a DeviceKey is a Signer.
+func (dk *DeviceKey) PubKey() *btcec.PublicKey {
+	return dk.public.Verification.Btcec()
+}
+
+var (
+	_ Signer = (*btcec.PrivateKey)(nil)
+	_ Signer = (*DeviceKey)(nil)
+)
@@ -0,0 +1,76 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// NewHDStreams derives the first n identities in each of the streams from
+// an HD master key, as NewHD does, and returns them by stream. The keys are
+// different in every stream, since the stream is part of the path.
+func NewHDStreams(masterKey *hdkeychain.ExtendedKey, streams []uint64,
+	n uint32) (map[uint64][]*PrivateAddress, error) {
+	if err := checkStreams(streams); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uint64][]*PrivateAddress, len(streams))
+	for _, stream := range streams {
+		if _, ok := ids[stream]; ok {
+			continue
+		}
+		s := make([]*PrivateAddress, n)
+		for i := range s {
+			key, err := NewHD(masterKey, uint32(i), stream)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = NewPrivateAddress(key, DefaultAddressVersion, stream)
+		}
+		ids[stream] = s
+	}
+	return ids, nil
+}
+
+// NewDeterministicStreams derives n identities from a passphrase, as
+// NewDeterministic does, and returns them in each of the streams by stream.
+// As in PyBitmessage, the stream is not part of the derivation, so the keys
+// are searched for only once and an identity has the same keys, and the
+// same ripe hash, in every stream.
+func NewDeterministicStreams(passphrase string, initialZeros uint64,
+	streams []uint64, n int) (map[uint64][]*PrivateAddress, error) {
+	if err := checkStreams(streams); err != nil {
+		return nil, err
+	}
+
+	keys, err := NewDeterministic(passphrase, initialZeros, n)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[uint64][]*PrivateAddress, len(streams))
+	for _, stream := range streams {
+		s := make([]*PrivateAddress, n)
+		for i, key := range keys {
+			s[i] = NewPrivateAddress(key, DefaultAddressVersion, stream)
+		}
+		ids[stream] = s
+	}
+	return ids, nil
+}
+
+// checkStreams returns an error if an address of the default version cannot
+// be made in one of the streams.
+func checkStreams(streams []uint64) error {
+	for _, stream := range streams {
+		if _, err := NewAddress(DefaultAddressVersion, stream, &hash.Ripe{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
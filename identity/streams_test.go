@@ -0,0 +1,65 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestNewHDStreams(t *testing.T) {
+	seed := []byte("somegoodrandomseedwouldbeusefulhere")
+	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := NewHDStreams(masterKey, []uint64{DefaultStream, DefaultStream}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || len(ids[DefaultStream]) != 2 {
+		t.Fatalf("got %v", ids)
+	}
+	for i, id := range ids[DefaultStream] {
+		key, _ := NewHD(masterKey, uint32(i), DefaultStream)
+		if *id.PrivateKey().Hash() != *key.Hash() {
+			t.Errorf("identity %d differs from NewHD", i)
+		}
+	}
+
+	// Only stream 1 addresses can be made.
+	if _, err = NewHDStreams(masterKey, []uint64{1, 2}, 1); err != ErrInvalidStream {
+		t.Errorf("got %v want %v", err, ErrInvalidStream)
+	}
+}
+
+func TestNewDeterministicStreams(t *testing.T) {
+	ids, err := NewDeterministicStreams("general", 1, []uint64{DefaultStream}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := NewDeterministic("general", 1, 2)
+	if len(ids[DefaultStream]) != len(keys) {
+		t.Fatalf("got %d identities want %d", len(ids[DefaultStream]), len(keys))
+	}
+	for i, id := range ids[DefaultStream] {
+		if *id.PrivateKey().Hash() != *keys[i].Hash() ||
+			id.Address().Stream() != DefaultStream {
+			t.Errorf("identity %d is %s", i, id.Address())
+		}
+	}
+	if ids[DefaultStream][0].Address().String() != "BM-2cW67GEKkHGonXKZLCzouLLxnLym3azS8r" {
+		t.Errorf("got %s", ids[DefaultStream][0].Address())
+	}
+
+	if _, err = NewDeterministicStreams("general", 1, []uint64{3}, 1); err != ErrInvalidStream {
+		t.Errorf("got %v want %v", err, ErrInvalidStream)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// Purposes for DeriveSubkey. Applications may use their own, but a purpose
+// must never be used for two different things.
+const (
+	// PurposeURIAuth is for keys proving control of an identity to a
+	// service, such as in answer to a challenge in a URI.
+	PurposeURIAuth = "bitmessage uri auth v1"
+
+	// PurposeSyncEncryption is for keys encrypting data which an identity
+	// keeps in sync between its devices.
+	PurposeSyncEncryption = "bitmessage sync encryption v1"
+)
+
+// maxSubkeyLength is the most HKDF-SHA256 can produce.
+const maxSubkeyLength = 255 * sha256.Size
+
+// subkeySalt separates the keys derived by DeriveSubkey from any other use of
+// the private keys.
+var subkeySalt = []byte("bmutil subkey")
+
+var (
+	// ErrSubkeyLength is returned by DeriveSubkey when the length asked for
+	// is not positive or is more than HKDF-SHA256 can produce.
+	ErrSubkeyLength = errors.New("invalid subkey length")
+
+	// ErrEmptyPurpose is returned for subkeys derived with no purpose.
+	ErrEmptyPurpose = errors.New("subkey purpose is empty")
+)
+
+// hkdf derives length bytes from a secret as in RFC 5869 with SHA256.
+func hkdf(secret, salt, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	out := make([]byte, 0, length+sha256.Size)
+	var t []byte
+	for i := byte(1); len(out) < length; i++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		t = expand.Sum(t[:0])
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+// DeriveSubkey derives a key of the given length for some purpose other
+// than signing and decrypting objects, such as one of the Purpose constants.
+// The key is derived with HKDF from both private keys, with the purpose as
+// the info, so keys for different purposes are unrelated to each other and
+// reveal nothing about the identity's keys.
+func (pk *PrivateKey) DeriveSubkey(purpose string, length int) ([]byte, error) {
+	if purpose == "" {
+		return nil, ErrEmptyPurpose
+	}
+	if length <= 0 || length > maxSubkeyLength {
+		return nil, ErrSubkeyLength
+	}
+
+	secret := make([]byte, 0, 64)
+	secret = append(secret, pk.Signing.Serialize()...)
+	secret = append(secret, pk.Decryption.Serialize()...)
+	return hkdf(secret, subkeySalt, []byte(purpose), length), nil
+}
+
+// DeriveSubkeyPrivate derives a private key on the curve for some purpose,
+// as by DeriveSubkey, for purposes which need a public key as well, such as
+// signing a challenge.
+func (pk *PrivateKey) DeriveSubkeyPrivate(purpose string) (*btcec.PrivateKey, error) {
+	// Derive more bytes than needed so that the reduction mod N is
+	// uniform enough.
+	b, err := pk.DeriveSubkey(purpose, 48)
+	if err != nil {
+		return nil, err
+	}
+
+	n := new(big.Int).Sub(s256.N, big.NewInt(1))
+	d := new(big.Int).SetBytes(b)
+	d.Mod(d, n).Add(d, big.NewInt(1))
+
+	key := make([]byte, 32)
+	db := d.Bytes()
+	copy(key[32-len(db):], db)
+	priv, _ := btcec.PrivKeyFromBytes(s256, key)
+	return priv, nil
+}
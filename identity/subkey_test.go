@@ -0,0 +1,74 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestHKDF(t *testing.T) {
+	// Test case 1 of RFC 5869.
+	secret, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	want, _ := hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a" +
+		"2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+
+	if got := identity.TstHKDF(secret, salt, info, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("hkdf got %x want %x", got, want)
+	}
+}
+
+func TestDeriveSubkey(t *testing.T) {
+	priv, err := identity.ImportWIF("BM-2cVLR8vzEu6QUjGkYAPHQQTUenPVC62f9B",
+		"5JvnKKDF1vWDBnnjCPGMVVzsX2EinsXbiiJj7JUwZ9La4xJ9FWt",
+		"5JTYsHKSzDx6636UatMppek1QzKYL8b5RLeZdayHoi1Qa5yJjJS")
+	if err != nil {
+		t.Fatalf("ImportWIF error %v", err)
+	}
+	pk := priv.PrivateKey()
+
+	auth, err := pk.DeriveSubkey(identity.PurposeURIAuth, 32)
+	if err != nil {
+		t.Fatalf("DeriveSubkey error %v", err)
+	}
+	again, _ := pk.DeriveSubkey(identity.PurposeURIAuth, 32)
+	if !bytes.Equal(auth, again) {
+		t.Error("DeriveSubkey is not deterministic")
+	}
+	sync, _ := pk.DeriveSubkey(identity.PurposeSyncEncryption, 32)
+	if bytes.Equal(auth, sync) {
+		t.Error("subkeys for different purposes are equal")
+	}
+	if bytes.Equal(auth, pk.Decryption.Serialize()) ||
+		bytes.Equal(auth, pk.Signing.Serialize()) {
+		t.Error("subkey is one of the identity's keys")
+	}
+
+	if _, err = pk.DeriveSubkey("", 32); err != identity.ErrEmptyPurpose {
+		t.Errorf("empty purpose got %v want %v", err, identity.ErrEmptyPurpose)
+	}
+	for _, length := range []int{0, -1, 255*32 + 1} {
+		if _, err = pk.DeriveSubkey(identity.PurposeURIAuth, length); err != identity.ErrSubkeyLength {
+			t.Errorf("length %d got %v want %v", length, err, identity.ErrSubkeyLength)
+		}
+	}
+
+	key, err := pk.DeriveSubkeyPrivate(identity.PurposeURIAuth)
+	if err != nil {
+		t.Fatalf("DeriveSubkeyPrivate error %v", err)
+	}
+	if key.PubKey().IsEqual(pk.Signing.PubKey()) {
+		t.Error("DeriveSubkeyPrivate returned the signing key")
+	}
+	key2, _ := pk.DeriveSubkeyPrivate(identity.PurposeURIAuth)
+	if !bytes.Equal(key.Serialize(), key2.Serialize()) {
+		t.Error("DeriveSubkeyPrivate is not deterministic")
+	}
+}
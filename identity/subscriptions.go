@@ -0,0 +1,219 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/DanielKrawisz/bmutil"
+)
+
+var (
+	// ErrDuplicateSubscription is returned when a subscription is added for
+	// an address that is already in the list.
+	ErrDuplicateSubscription = errors.New("already subscribed to address")
+
+	// ErrInvalidLabel is returned when a label cannot be represented in
+	// the exported subscriptions format, because it contains a line break
+	// or begins or ends with whitespace, which would be lost on import.
+	ErrInvalidLabel = errors.New("label may not contain line breaks or surrounding whitespace")
+)
+
+// ParseError is returned when an ini-style file, such as exported
+// subscriptions or HD metadata, cannot be read. Err is the cause, which may
+// be one of the package's errors, such as ErrDuplicateSubscription.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+// Error returns a human-readable description of the error.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func parseErrorf(line int, format string, a ...interface{}) *ParseError {
+	return &ParseError{line, fmt.Errorf(format, a...)}
+}
+
+// Subscription is an address whose broadcasts the user follows.
+type Subscription struct {
+	Address Address
+	Label   string
+	Enabled bool
+}
+
+// Subscriptions is a list of followed addresses. The order in which
+// subscriptions are added is preserved by Export.
+type Subscriptions struct {
+	list []*Subscription
+}
+
+// NewSubscriptions returns an empty subscriptions list.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{}
+}
+
+// Add adds a subscription to the list. It returns ErrDuplicateSubscription
+// if the address is already present.
+func (s *Subscriptions) Add(addr Address, label string, enabled bool) error {
+	if s.Get(addr.String()) != nil {
+		return ErrDuplicateSubscription
+	}
+	s.list = append(s.list, &Subscription{
+		Address: addr,
+		Label:   label,
+		Enabled: enabled,
+	})
+	return nil
+}
+
+// Get returns the subscription for the given address string, or nil if
+// there is none.
+func (s *Subscriptions) Get(addr string) *Subscription {
+	for _, sub := range s.list {
+		if sub.Address.String() == addr {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Remove removes the subscription for the given address string and reports
+// whether one was found.
+func (s *Subscriptions) Remove(addr string) bool {
+	for i, sub := range s.list {
+		if sub.Address.String() == addr {
+			s.list = append(s.list[:i], s.list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the subscriptions in the order they were added.
+func (s *Subscriptions) List() []*Subscription {
+	list := make([]*Subscription, len(s.list))
+	copy(list, s.list)
+	return list
+}
+
+// Len returns the number of subscriptions.
+func (s *Subscriptions) Len() int {
+	return len(s.list)
+}
+
+// Export writes the subscriptions to w in the ini style used by
+// PyBitmessage, with one section per address:
+//
+//	[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]
+//	label = Some channel
+//	enabled = true
+//
+// If any label cannot be written so that it reads back the same, nothing is
+// written and ErrInvalidLabel is returned.
+func (s *Subscriptions) Export(w io.Writer) error {
+	for _, sub := range s.list {
+		if strings.ContainsAny(sub.Label, "\r\n") ||
+			strings.TrimSpace(sub.Label) != sub.Label {
+			return ErrInvalidLabel
+		}
+	}
+
+	for i, sub := range s.list {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "[%s]\nlabel = %s\nenabled = %t\n",
+			sub.Address.String(), sub.Label, sub.Enabled)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportSubscriptions reads a subscriptions list in the format written by
+// Export. Sections whose names are not Bitmessage addresses, such as
+// [bitmessagesettings], are skipped, as are comments and unknown options.
+// A subscription with no enabled option is treated as enabled.
+func ImportSubscriptions(r io.Reader) (*Subscriptions, error) {
+	s := NewSubscriptions()
+	scanner := bufio.NewScanner(r)
+
+	var current *Subscription
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+
+		if text[0] == '[' {
+			if text[len(text)-1] != ']' {
+				return nil, parseErrorf(line, "malformed section header")
+			}
+			current = nil
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			if !strings.HasPrefix(name, "BM-") {
+				continue
+			}
+			addr, err := DecodeAddress(name)
+			if err != nil {
+				return nil, &ParseError{line, err}
+			}
+			if err = s.Add(addr, "", true); err != nil {
+				return nil, &ParseError{line, err}
+			}
+			current = s.list[len(s.list)-1]
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		sep := strings.IndexAny(text, "=:")
+		if sep < 0 {
+			return nil, parseErrorf(line, "expected option")
+		}
+		key := strings.ToLower(strings.TrimSpace(text[:sep]))
+		value := strings.TrimSpace(text[sep+1:])
+
+		switch key {
+		case "label":
+			current.Label = value
+		case "enabled":
+			enabled, ok := parseBool(value)
+			if !ok {
+				return nil, parseErrorf(line, "invalid boolean %q", value)
+			}
+			current.Enabled = enabled
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// parseBool accepts the same boolean spellings as python's ConfigParser.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "1", "yes", "true", "on":
+		return true, true
+	case "0", "no", "false", "off":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestSubscriptionsExportImport(t *testing.T) {
+	addrs := []string{
+		"BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
+		"BM-2DBXxtaBSV37DsHjN978mRiMbX5rdKNvJ6",
+	}
+
+	s := identity.NewSubscriptions()
+	for i, str := range addrs {
+		addr, err := bmutil.DecodeAddress(str)
+		if err != nil {
+			t.Fatalf("DecodeAddress #%d error %v", i, err)
+		}
+		if err = s.Add(addr, "label "+str[3:8], i == 0); err != nil {
+			t.Fatalf("Add #%d error %v", i, err)
+		}
+	}
+
+	addr, _ := bmutil.DecodeAddress(addrs[0])
+	if err := s.Add(addr, "again", true); err != identity.ErrDuplicateSubscription {
+		t.Errorf("Add duplicate: got %v want %v", err,
+			identity.ErrDuplicateSubscription)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Export(&buf); err != nil {
+		t.Fatalf("Export error %v", err)
+	}
+
+	want := "[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\nlabel = label 2cV9R\nenabled = true\n" +
+		"\n[BM-2DBXxtaBSV37DsHjN978mRiMbX5rdKNvJ6]\nlabel = label 2DBXx\nenabled = false\n"
+	if buf.String() != want {
+		t.Errorf("Export\n got: %q\nwant: %q", buf.String(), want)
+	}
+
+	imported, err := identity.ImportSubscriptions(&buf)
+	if err != nil {
+		t.Fatalf("ImportSubscriptions error %v", err)
+	}
+	if imported.Len() != s.Len() {
+		t.Fatalf("ImportSubscriptions got %d subscriptions want %d",
+			imported.Len(), s.Len())
+	}
+	for i, sub := range s.List() {
+		got := imported.List()[i]
+		if got.Address.String() != sub.Address.String() ||
+			got.Label != sub.Label || got.Enabled != sub.Enabled {
+			t.Errorf("ImportSubscriptions #%d got %v want %v", i, got, sub)
+		}
+	}
+
+	if !imported.Remove(addrs[0]) || imported.Get(addrs[0]) != nil {
+		t.Errorf("Remove failed")
+	}
+	if imported.Remove(addrs[0]) {
+		t.Errorf("Remove of missing address reported success")
+	}
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	tests := []struct {
+		in    string
+		count int
+		fail  bool
+	}{
+		{"", 0, false},
+		{"[bitmessagesettings]\nlabel = x\n\n[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\n" +
+			"; comment\nlabel: foo\nenabled = no\nunknown = 1\n", 1, false},
+		{"[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\n", 1, false},
+		{"[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ\n", 0, true},
+		{"[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BK]\n", 0, true},
+		{"[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\nenabled = maybe\n", 0, true},
+		{"[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\nlabel\n", 0, true},
+		{"[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\n[BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ]\n", 0, true},
+	}
+
+	for i, test := range tests {
+		s, err := identity.ImportSubscriptions(strings.NewReader(test.in))
+		if test.fail {
+			if err == nil {
+				t.Errorf("ImportSubscriptions #%d expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ImportSubscriptions #%d error %v", i, err)
+			continue
+		}
+		if s.Len() != test.count {
+			t.Errorf("ImportSubscriptions #%d got %d subscriptions want %d",
+				i, s.Len(), test.count)
+		}
+	}
+
+	s, _ := identity.ImportSubscriptions(strings.NewReader(tests[1].in))
+	sub := s.Get("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	if sub == nil || sub.Label != "foo" || sub.Enabled {
+		t.Errorf("ImportSubscriptions got %v", sub)
+	}
+
+	_, err := identity.ImportSubscriptions(strings.NewReader(tests[7].in))
+	if e, ok := err.(*identity.ParseError); !ok || e.Line != 2 ||
+		e.Err != identity.ErrDuplicateSubscription {
+		t.Errorf("ImportSubscriptions got %v want ErrDuplicateSubscription at line 2", err)
+	}
+}
+
+func TestSubscriptionsExportInvalidLabel(t *testing.T) {
+	first, _ := bmutil.DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	second, _ := bmutil.DecodeAddress("BM-2DAV89w336ovy6BUJnfVRD5B9qipFbRgmr")
+
+	for _, label := range []string{"two\nlines", " padded", "padded\t"} {
+		s := identity.NewSubscriptions()
+		s.Add(first, "fine", true)
+		s.Add(second, label, true)
+
+		var b bytes.Buffer
+		if err := s.Export(&b); err != identity.ErrInvalidLabel {
+			t.Errorf("Export of %q got %v want %v", label, err, identity.ErrInvalidLabel)
+		}
+		if b.Len() != 0 {
+			t.Errorf("Export of %q wrote %q before failing", label, b.String())
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// ErrInvalidVanityPrefix is returned by VanityPrefix for a prefix which no
+// address can have.
+var ErrInvalidVanityPrefix = errors.New("prefix contains characters not used in addresses")
+
+// base58Alphabet is the alphabet addresses are encoded with.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// VanityPredicate reports whether an address is the one wanted.
+type VanityPredicate func(addr Address) bool
+
+// VanityPrefix returns a predicate which matches addresses whose base58
+// part, after BM-, starts with prefix. A BM- at the start of prefix is
+// ignored. If ignoreCase is set, letters match in either case. Note that the
+// first characters are determined by the version and stream, so version 4
+// addresses on stream 1 all start with 2c or 2D.
+func VanityPrefix(prefix string, ignoreCase bool) (VanityPredicate, error) {
+	prefix = strings.TrimPrefix(prefix, "BM-")
+	for _, r := range prefix {
+		s := string(r)
+		if strings.Contains(base58Alphabet, s) {
+			continue
+		}
+		if ignoreCase && (strings.Contains(base58Alphabet, strings.ToUpper(s)) ||
+			strings.Contains(base58Alphabet, strings.ToLower(s))) {
+			continue
+		}
+		return nil, ErrInvalidVanityPrefix
+	}
+
+	if ignoreCase {
+		prefix = strings.ToLower(prefix)
+		return func(addr Address) bool {
+			return strings.HasPrefix(strings.ToLower(addr.String()[3:]), prefix)
+		}, nil
+	}
+	return func(addr Address) bool {
+		return strings.HasPrefix(addr.String()[3:], prefix)
+	}, nil
+}
+
+// VanityOptions are the options for Vanity.
+type VanityOptions struct {
+	// Version and Stream are those of the address. If zero,
+	// DefaultAddressVersion and DefaultStream are used.
+	Version, Stream uint64
+
+	// Workers is the number of goroutines searching. If not positive,
+	// runtime.NumCPU() is used.
+	Workers int
+
+	// Progress, if not nil, is called every ProgressInterval, or every
+	// second if that is zero, with the number of keys tried so far and the
+	// number tried per second. The calls are made one at a time.
+	Progress         func(tried uint64, rate float64)
+	ProgressInterval time.Duration
+}
+
+// Vanity searches for an address which match accepts, with random keys as by
+// NewRandom(1). It returns when one is found, or with ctx.Err() if ctx is
+// done first. Each character of a prefix makes the search take about 58
+// times as long.
+func Vanity(ctx context.Context, match VanityPredicate, opts *VanityOptions) (*PrivateAddress, error) {
+	var o VanityOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Version == 0 {
+		o.Version = DefaultAddressVersion
+	}
+	if o.Stream == 0 {
+		o.Stream = DefaultStream
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.ProgressInterval <= 0 {
+		o.ProgressInterval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var tried uint64
+	found := make(chan *PrivateAddress, 1)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(o.Workers)
+	for i := 0; i < o.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			id, err := vanityWorker(ctx, match, &o, &tried)
+			switch {
+			case err != nil:
+				select {
+				case failed <- err:
+				default:
+				}
+			case id != nil:
+				select {
+				case found <- id:
+				default:
+				}
+			default:
+				return
+			}
+			cancel()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var tick <-chan time.Time
+	if o.Progress != nil {
+		ticker := time.NewTicker(o.ProgressInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	start := time.Now()
+wait:
+	for {
+		select {
+		case <-tick:
+			n := atomic.LoadUint64(&tried)
+			o.Progress(n, float64(n)/time.Since(start).Seconds())
+		case <-done:
+			break wait
+		}
+	}
+
+	select {
+	case id := <-found:
+		return id, nil
+	case err := <-failed:
+		return nil, err
+	default:
+		return nil, ctx.Err()
+	}
+}
+
+// vanityWorker tries keys until one gives an address that matches or ctx is
+// done. The signing key is kept and only the encryption key is changed, as
+// in NewRandom.
+func vanityWorker(ctx context.Context, match VanityPredicate, o *VanityOptions,
+	tried *uint64) (*PrivateAddress, error) {
+
+	signing, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		default:
+		}
+
+		decryption, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddUint64(tried, 1)
+
+		pk := &PrivateKey{Signing: signing, Decryption: decryption}
+		ripe := pk.Public().HashForVersion(o.Version)
+		if ripe[0] != 0 {
+			continue
+		}
+		addr, err := NewAddress(o.Version, o.Stream, ripe)
+		if err != nil {
+			return nil, err
+		}
+		if match(addr) {
+			return NewPrivateAddress(pk, o.Version, o.Stream), nil
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/DanielKrawisz/bmutil"
+	. "github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestVanityPrefix(t *testing.T) {
+	addr, _ := DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+
+	tests := []struct {
+		prefix     string
+		ignoreCase bool
+		match      bool
+	}{
+		{"2cV9", false, true},
+		{"BM-2cV9R", false, true},
+		{"2cv9r", false, false},
+		{"2cv9r", true, true},
+		{"2cV8", true, false},
+	}
+	for i, test := range tests {
+		match, err := VanityPrefix(test.prefix, test.ignoreCase)
+		if err != nil {
+			t.Errorf("case %d: got error %v", i, err)
+			continue
+		}
+		if match(addr) != test.match {
+			t.Errorf("case %d: got %v, want %v", i, !test.match, test.match)
+		}
+	}
+
+	if _, err := VanityPrefix("2c0", false); err != ErrInvalidVanityPrefix {
+		t.Errorf("got %v for 0", err)
+	}
+	if _, err := VanityPrefix("2cl", false); err != ErrInvalidVanityPrefix {
+		t.Errorf("got %v for l", err)
+	}
+	if _, err := VanityPrefix("2cl", true); err != nil {
+		t.Errorf("got %v for l ignoring case", err)
+	}
+}
+
+func TestVanity(t *testing.T) {
+	// Every address found already has a ripe starting with a zero byte, so
+	// checking one more bit keeps the test quick.
+	match := func(addr Address) bool {
+		return addr.RipeHash()[19]&1 == 0
+	}
+	var calls int
+	id, err := Vanity(context.Background(), match, &VanityOptions{
+		Workers:          2,
+		Progress:         func(tried uint64, rate float64) { calls++ },
+		ProgressInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := id.Address()
+	if !match(addr) || addr.Version() != DefaultAddressVersion {
+		t.Errorf("got %s", addr)
+	}
+
+	// The keys must work like any other.
+	_, signing, encryption := id.ExportWIF()
+	if _, err = ImportWIF(addr.String(), signing, encryption); err != nil {
+		t.Errorf("ImportWIF got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	never := func(Address) bool { return false }
+	if _, err = Vanity(ctx, never, nil); err != context.DeadlineExceeded {
+		t.Errorf("got %v after timeout", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrDuplicateRecord is returned for a record in a batch import whose
+// address already appeared earlier in the batch.
+var ErrDuplicateRecord = errors.New("address appears earlier in batch")
+
+// WIFRecord is one identity to import with ImportWIFBatch, in the form taken
+// by ImportWIF.
+type WIFRecord struct {
+	Address       string
+	SigningKey    string
+	DecryptionKey string
+}
+
+// WIFImportError describes why a record in a batch could not be imported.
+type WIFImportError struct {
+	// Index is the position of the record in the batch.
+	Index   int
+	Address string
+	Err     error
+}
+
+// Error returns a human-readable description of the error.
+func (e *WIFImportError) Error() string {
+	return fmt.Sprintf("record %d (%s): %v", e.Index, e.Address, e.Err)
+}
+
+// ImportWIFBatch imports many identities at once, as when migrating the keys
+// of a gateway or an archive. Each record is checked as by ImportWIF. The
+// returned identities are in the same order as the records, with nil where a
+// record failed, and the failures are in order of index. A failed record
+// does not stop the others from being imported.
+func ImportWIFBatch(records []WIFRecord) ([]*PrivateAddress, []*WIFImportError) {
+	return ImportWIFBatchWithProgress(records, 0, nil)
+}
+
+// ImportWIFBatchWithProgress is like ImportWIFBatch, except that the records
+// are checked with the given number of goroutines, or runtime.NumCPU() if it
+// is not positive, and progress, if not nil, is called after each record
+// with the number done so far. The calls to progress are made one at a
+// time.
+func ImportWIFBatchWithProgress(records []WIFRecord, workers int,
+	progress func(done, total int)) ([]*PrivateAddress, []*WIFImportError) {
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	type result struct {
+		index int
+		id    *PrivateAddress
+		err   error
+	}
+
+	jobs := make(chan int)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r := records[j]
+				id, err := ImportWIF(r.Address, r.SigningKey, r.DecryptionKey)
+				results <- result{j, id, err}
+			}
+		}()
+	}
+	go func() {
+		for j := range records {
+			jobs <- j
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	ids := make([]*PrivateAddress, len(records))
+	errs := make([]error, len(records))
+	done := 0
+	for r := range results {
+		ids[r.index], errs[r.index] = r.id, r.err
+		done++
+		if progress != nil {
+			progress(done, len(records))
+		}
+	}
+
+	// Duplicates are found afterwards so that the earliest record of an
+	// address is the one kept, whatever order the workers finished in.
+	var failures []*WIFImportError
+	seen := make(map[string]struct{})
+	for i, id := range ids {
+		if errs[i] == nil {
+			addr := id.Address().String()
+			if _, ok := seen[addr]; ok {
+				ids[i], errs[i] = nil, ErrDuplicateRecord
+			} else {
+				seen[addr] = struct{}{}
+			}
+		}
+		if errs[i] != nil {
+			failures = append(failures, &WIFImportError{
+				Index:   i,
+				Address: records[i].Address,
+				Err:     errs[i],
+			})
+		}
+	}
+
+	return ids, failures
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+func TestImportWIFBatch(t *testing.T) {
+	var records []identity.WIFRecord
+	for _, pair := range addressImportExportTests {
+		records = append(records, identity.WIFRecord{pair.address,
+			pair.signingkey, pair.encryptionkey})
+	}
+	good := len(records)
+	records = append(records,
+		identity.WIFRecord{"BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
+			"sd5f48erdfoiopadsfa5d6sf405", ""},
+		records[0])
+
+	calls := 0
+	ids, failures := identity.ImportWIFBatchWithProgress(records, 2,
+		func(done, total int) {
+			calls++
+			if done != calls || total != len(records) {
+				t.Errorf("progress got %d of %d on call %d", done, total, calls)
+			}
+		})
+	if calls != len(records) {
+		t.Errorf("progress was called %d times, want %d", calls, len(records))
+	}
+
+	if len(ids) != len(records) {
+		t.Fatalf("got %d identities, want %d", len(ids), len(records))
+	}
+	for i := 0; i < good; i++ {
+		if ids[i] == nil || ids[i].Address().String() != records[i].Address {
+			t.Errorf("record %d was not imported", i)
+		}
+	}
+	if ids[good] != nil || ids[good+1] != nil {
+		t.Errorf("bad records were imported")
+	}
+
+	if len(failures) != 2 {
+		t.Fatalf("got %d failures, want 2", len(failures))
+	}
+	if failures[0].Index != good || failures[0].Err == nil {
+		t.Errorf("first failure got %v", failures[0])
+	}
+	if failures[1].Index != good+1 || failures[1].Err != identity.ErrDuplicateRecord {
+		t.Errorf("second failure got %v", failures[1])
+	}
+
+	ids, failures = identity.ImportWIFBatch(nil)
+	if len(ids) != 0 || len(failures) != 0 {
+		t.Errorf("empty batch got %v, %v", ids, failures)
+	}
+}
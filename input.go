@@ -0,0 +1,262 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// ErrSuspiciousInput is returned by the Parse functions when user input
+// contains characters outside ASCII, which at best do not belong there and
+// at worst only look like the ones that do.
+var ErrSuspiciousInput = errors.New("input contains non-ASCII characters")
+
+// WarningKind says what is wrong with a character in user input.
+type WarningKind int
+
+const (
+	// WarnInvisible is a zero-width or formatting character, such as a
+	// zero-width space or a bidirectional override. These are removed.
+	WarnInvisible WarningKind = iota
+
+	// WarnWhitespace is whitespace inside the input, as left by a line
+	// break in a pasted address. It is removed.
+	WarnWhitespace
+
+	// WarnHomoglyph is a character from another script which looks like
+	// an ASCII letter or digit, such as a Cyrillic 'а'. It is kept, so
+	// that decoding fails rather than quietly accepting something other
+	// than what the user sees.
+	WarnHomoglyph
+
+	// WarnNonASCII is any other character outside ASCII. It is kept.
+	WarnNonASCII
+)
+
+var warningKindStrings = map[WarningKind]string{
+	WarnInvisible:  "invisible character",
+	WarnWhitespace: "whitespace",
+	WarnHomoglyph:  "look-alike character",
+	WarnNonASCII:   "non-ASCII character",
+}
+
+func (k WarningKind) String() string {
+	if s, ok := warningKindStrings[k]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// InputWarning describes a suspicious character found in user input.
+type InputWarning struct {
+	Kind WarningKind
+
+	// Offset is the position of the character in the input in runes,
+	// which is what a user would count.
+	Offset int
+	Rune   rune
+
+	// LooksLike is the ASCII character that a WarnHomoglyph resembles.
+	LooksLike rune
+}
+
+func (w InputWarning) String() string {
+	if w.Kind == WarnHomoglyph {
+		return fmt.Sprintf("%s %U at %d looks like %q", w.Kind, w.Rune,
+			w.Offset, w.LooksLike)
+	}
+	return fmt.Sprintf("%s %U at %d", w.Kind, w.Rune, w.Offset)
+}
+
+// homoglyphs maps characters which are easily mistaken for ASCII letters and
+// digits to the characters they resemble. Fullwidth forms are handled
+// separately.
+var homoglyphs = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'B', 'е': 'e', 'к': 'k', 'м': 'M', 'н': 'H', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 'T', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i',
+	'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J',
+
+	// Greek
+	'α': 'a', 'ο': 'o', 'ρ': 'p', 'ν': 'v', 'υ': 'u', 'ι': 'i',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+
+	// Latin
+	'ı': 'i', 'ℓ': 'l', 'ɡ': 'g',
+
+	// Dashes, which can turn up in place of the one in "BM-".
+	'\u2010': '-', '\u2011': '-', '\u2012': '-', '\u2013': '-', '\u2212': '-',
+}
+
+// lookAlike returns the ASCII character that r resembles, if any.
+func lookAlike(r rune) (rune, bool) {
+	// Fullwidth ASCII, as typed by some East Asian input methods.
+	if r >= 0xFF01 && r <= 0xFF5E {
+		return r - 0xFF01 + '!', true
+	}
+	a, ok := homoglyphs[r]
+	return a, ok
+}
+
+// CleanInput prepares a string typed or pasted by a user for decoding. It
+// trims whitespace at both ends, including Unicode spaces, and removes
+// whitespace and invisible characters from the middle. It returns the
+// cleaned string along with a warning for every character which was removed
+// from the middle or which is not ASCII. Warnings about characters that were
+// removed are worth showing to the user, since invisible characters in an
+// address are a sign that someone is trying to deceive them.
+func CleanInput(s string) (string, []InputWarning) {
+	trim := func(r rune) bool {
+		return unicode.IsSpace(r) || invisible(r)
+	}
+	trimmed := strings.TrimLeftFunc(s, trim)
+
+	// Offsets count from the start of the original input.
+	offset := utf8.RuneCountInString(s[:len(s)-len(trimmed)])
+	s = strings.TrimRightFunc(trimmed, trim)
+
+	var warnings []InputWarning
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case invisible(r):
+			warnings = append(warnings, InputWarning{Kind: WarnInvisible,
+				Offset: offset, Rune: r})
+		case unicode.IsSpace(r):
+			warnings = append(warnings, InputWarning{Kind: WarnWhitespace,
+				Offset: offset, Rune: r})
+		case r >= utf8.RuneSelf:
+			if a, ok := lookAlike(r); ok {
+				warnings = append(warnings, InputWarning{Kind: WarnHomoglyph,
+					Offset: offset, Rune: r, LooksLike: a})
+			} else {
+				warnings = append(warnings, InputWarning{Kind: WarnNonASCII,
+					Offset: offset, Rune: r})
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+		offset++
+	}
+
+	return b.String(), warnings
+}
+
+// invisible returns whether r is a character which takes up no space, such
+// as a zero-width joiner, a byte order mark or a bidirectional control.
+func invisible(r rune) bool {
+	switch r {
+	case '\u034f', '\u115f', '\u1160', '\u3164', '\uffa0':
+		// The combining grapheme joiner and the Hangul fillers.
+		return true
+	}
+	return unicode.Is(unicode.Cf, r)
+}
+
+// suspicious returns whether any of the warnings is about a character which
+// was kept but does not belong in an address or key.
+func suspicious(warnings []InputWarning) bool {
+	for _, w := range warnings {
+		if w.Kind == WarnHomoglyph || w.Kind == WarnNonASCII {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixFold is like strings.HasPrefix, but ignores the case of ASCII
+// letters. Unlike strings.EqualFold it never treats a non-ASCII character as
+// equal to an ASCII one, so the result does not depend on Unicode case rules.
+func hasPrefixFold(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		a, b := s[i], prefix[i]
+		if 'A' <= a && a <= 'Z' {
+			a += 'a' - 'A'
+		}
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseAddress decodes an address entered by a user. The input is cleaned
+// with CleanInput and the warnings are returned whatever the outcome. The
+// "BM-" prefix may be in any case, and a bitmessage: link is accepted in
+// place of the address, in which case anything after the address is
+// ignored. If the input has characters outside ASCII, which may merely look
+// like those of an address, ErrSuspiciousInput is returned rather than a
+// checksum error.
+func ParseAddress(s string) (Address, []InputWarning, error) {
+	s, warnings := CleanInput(s)
+	if suspicious(warnings) {
+		return nil, warnings, ErrSuspiciousInput
+	}
+
+	if hasPrefixFold(s, "bitmessage:") {
+		s = s[len("bitmessage:"):]
+		if i := strings.IndexAny(s, "?#"); i >= 0 {
+			s = s[:i]
+		}
+	}
+	if hasPrefixFold(s, "BM-") {
+		s = s[3:]
+	}
+
+	addr, err := DecodeAddress(s)
+	return addr, warnings, err
+}
+
+// addressPunctuation is what DecodeAddressLenient trims from around an
+// address. None of it is in the base58 alphabet.
+const addressPunctuation = "\"'`<>()[]{}.,;:!?"
+
+// DecodeAddressLenient decodes an address copied from an email, a forum post
+// or a web page, where it may have lost its "BM-" prefix, have its prefix in
+// another case, or have picked up whitespace or punctuation around it, such
+// as quotes, angle brackets or the full stop at the end of a sentence. The
+// address is otherwise checked as by ParseAddress, including the rejection of
+// suspicious characters. Along with the address, it returns its canonical
+// form, which is what should be stored and shown from then on.
+func DecodeAddressLenient(s string) (Address, string, error) {
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(addressPunctuation, r)
+	})
+
+	addr, _, err := ParseAddress(s)
+	if err != nil {
+		return nil, "", err
+	}
+	return addr, addr.String(), nil
+}
+
+// ParseWIF decodes a private key in wallet import format entered by a user,
+// in the same way that ParseAddress decodes an address.
+func ParseWIF(s string) (*btcec.PrivateKey, []InputWarning, error) {
+	s, warnings := CleanInput(s)
+	if suspicious(warnings) {
+		return nil, warnings, ErrSuspiciousInput
+	}
+
+	key, err := DecodeWIF(s)
+	return key, warnings, err
+}
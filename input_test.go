@@ -0,0 +1,126 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+)
+
+func TestCleanInput(t *testing.T) {
+	tests := []struct {
+		in       string
+		out      string
+		warnings []bmutil.InputWarning
+	}{
+		{"  BM-2cV9\n\t", "BM-2cV9", nil},
+		{"\ufeffBM-2c\u200bV9", "BM-2cV9", []bmutil.InputWarning{
+			{Kind: bmutil.WarnInvisible, Offset: 6, Rune: '\u200b'},
+		}},
+		{"BM-2c\r\nV9", "BM-2cV9", []bmutil.InputWarning{
+			{Kind: bmutil.WarnWhitespace, Offset: 5, Rune: '\r'},
+			{Kind: bmutil.WarnWhitespace, Offset: 6, Rune: '\n'},
+		}},
+		{"BM‐2сV９", "BM‐2сV９", []bmutil.InputWarning{
+			{Kind: bmutil.WarnHomoglyph, Offset: 2, Rune: '‐', LooksLike: '-'},
+			{Kind: bmutil.WarnHomoglyph, Offset: 4, Rune: 'с', LooksLike: 'c'},
+			{Kind: bmutil.WarnHomoglyph, Offset: 6, Rune: '９', LooksLike: '9'},
+		}},
+		{"BM-2é", "BM-2é", []bmutil.InputWarning{
+			{Kind: bmutil.WarnNonASCII, Offset: 4, Rune: 'é'},
+		}},
+	}
+
+	for i, test := range tests {
+		out, warnings := bmutil.CleanInput(test.in)
+		if out != test.out {
+			t.Errorf("case %d: got %q, want %q", i, out, test.out)
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("case %d: got warnings %v, want %v", i, warnings, test.warnings)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	const want = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ"
+
+	for i, in := range []string{
+		want,
+		"  bm-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ\n",
+		"2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
+		"BM-2cV9Rshwouu\u200dVKWLBoyH5cghj3kMfw5G7BJ",
+		"BITMESSAGE:BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ?subject=hi",
+	} {
+		addr, _, err := bmutil.ParseAddress(in)
+		if err != nil {
+			t.Errorf("case %d: got error %v", i, err)
+			continue
+		}
+		if addr.String() != want {
+			t.Errorf("case %d: got %s, want %s", i, addr, want)
+		}
+	}
+
+	// A Cyrillic 'с' in place of the first 'c'.
+	addr, warnings, err := bmutil.ParseAddress("BM-2сV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	if err != bmutil.ErrSuspiciousInput || addr != nil {
+		t.Errorf("homoglyph: got %v, %v", addr, err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != bmutil.WarnHomoglyph {
+		t.Errorf("homoglyph: got warnings %v", warnings)
+	}
+}
+
+func TestDecodeAddressLenient(t *testing.T) {
+	const want = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ"
+
+	for i, in := range []string{
+		want,
+		"2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
+		" Bm-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ\t",
+		"<BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ>",
+		"\"bm-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ\".",
+		"(2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ),",
+	} {
+		addr, canonical, err := bmutil.DecodeAddressLenient(in)
+		if err != nil {
+			t.Errorf("case %d: got error %v", i, err)
+			continue
+		}
+		if canonical != want || addr.String() != want {
+			t.Errorf("case %d: got %s, %s, want %s", i, addr, canonical, want)
+		}
+	}
+
+	for i, in := range []string{
+		"",
+		"BM-",
+		"BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BK",
+		"BM-2сV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ",
+	} {
+		if addr, canonical, err := bmutil.DecodeAddressLenient(in); err == nil {
+			t.Errorf("invalid case %d: got %s, %s", i, addr, canonical)
+		}
+	}
+}
+
+func TestParseWIF(t *testing.T) {
+	const wif = "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"
+
+	key, warnings, err := bmutil.ParseWIF(" " + wif + " ")
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("got warnings %v, error %v", warnings, err)
+	}
+	if got := bmutil.EncodeWIF(key); got != wif {
+		t.Errorf("got %s, want %s", got, wif)
+	}
+
+	if _, _, err = bmutil.ParseWIF("5HueＣGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"); err != bmutil.ErrSuspiciousInput {
+		t.Errorf("fullwidth: got error %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package lengthcheck checks length fields against the input they were read
+// from, for the packages of bmutil which decode untrusted data.
+package lengthcheck
+
+import "io"
+
+// ExceedsInput reports whether a length field read from r claims more bytes
+// than r has left, so that a corrupt length can be rejected before a buffer
+// is allocated for it. Only readers that know their remaining length, such
+// as bytes.Reader and bytes.Buffer, can be checked; for any other reader it
+// returns false.
+func ExceedsInput(r io.Reader, length uint64) bool {
+	l, ok := r.(interface {
+		Len() int
+	})
+	return ok && length > uint64(l.Len())
+}
@@ -0,0 +1,34 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package lengthcheck_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/internal/lengthcheck"
+)
+
+func TestExceedsInput(t *testing.T) {
+	tests := []struct {
+		r      io.Reader
+		length uint64
+		want   bool
+	}{
+		{bytes.NewReader(make([]byte, 5)), 5, false},
+		{bytes.NewReader(make([]byte, 5)), 6, true},
+		{bytes.NewBuffer(make([]byte, 5)), 6, true},
+		// Readers which don't know their length are never rejected.
+		{io.LimitReader(strings.NewReader(""), 0), 1 << 20, false},
+	}
+
+	for i, test := range tests {
+		if got := lengthcheck.ExceedsInput(test.r, test.length); got != test.want {
+			t.Errorf("ExceedsInput #%d got %v want %v", i, got, test.want)
+		}
+	}
+}
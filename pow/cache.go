@@ -0,0 +1,151 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/DanielKrawisz/bmutil"
+)
+
+// maxCacheEntries limits the number of entries DecodePowCache will read so
+// that a corrupt file can't cause a huge allocation.
+const maxCacheEntries = 1 << 20
+
+// ErrCacheTooLarge is returned when an encoded PowCache claims more entries
+// than can be decoded.
+var ErrCacheTooLarge = errors.New("pow cache has too many entries")
+
+type cacheKey struct {
+	initialHash string
+	target      Target
+}
+
+// PowCache remembers the nonces found for previous proofs of work, keyed by
+// initial hash and target. If an object has to be sent again, for example
+// because the program stopped before it was broadcast, consulting the cache
+// avoids redoing the work. It is safe for concurrent use.
+type PowCache struct {
+	mtx     sync.RWMutex
+	entries map[cacheKey]Nonce
+}
+
+// NewPowCache returns an empty PowCache.
+func NewPowCache() *PowCache {
+	return &PowCache{
+		entries: make(map[cacheKey]Nonce),
+	}
+}
+
+// Get returns the nonce stored for the given initial hash and target, if
+// any. Stored nonces are checked before being returned, so a corrupt entry
+// is never handed out.
+func (c *PowCache) Get(target Target, initialHash []byte) (Nonce, bool) {
+	c.mtx.RLock()
+	nonce, ok := c.entries[cacheKey{string(initialHash), target}]
+	c.mtx.RUnlock()
+
+	if !ok || !Check(target, nonce, initialHash) {
+		return 0, false
+	}
+	return nonce, true
+}
+
+// Put stores the nonce found for the given initial hash and target.
+func (c *PowCache) Put(target Target, initialHash []byte, nonce Nonce) {
+	c.mtx.Lock()
+	c.entries[cacheKey{string(initialHash), target}] = nonce
+	c.mtx.Unlock()
+}
+
+// Remove deletes the entry for the given initial hash and target. It should
+// be called once an object has been sent and will not be retried.
+func (c *PowCache) Remove(target Target, initialHash []byte) {
+	c.mtx.Lock()
+	delete(c.entries, cacheKey{string(initialHash), target})
+	c.mtx.Unlock()
+}
+
+// Len returns the number of stored entries.
+func (c *PowCache) Len() int {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return len(c.entries)
+}
+
+// Encode writes the cache to w so that it can survive a restart.
+func (c *PowCache) Encode(w io.Writer) error {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if err := bmutil.WriteVarInt(w, uint64(len(c.entries))); err != nil {
+		return err
+	}
+
+	var b [16]byte
+	for k, nonce := range c.entries {
+		if err := bmutil.WriteVarBytes(w, []byte(k.initialHash)); err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint64(b[:8], uint64(k.target))
+		binary.BigEndian.PutUint64(b[8:], uint64(nonce))
+		if _, err := w.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodePowCache reads a cache written by Encode.
+func DecodePowCache(r io.Reader) (*PowCache, error) {
+	count, err := bmutil.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxCacheEntries {
+		return nil, ErrCacheTooLarge
+	}
+
+	c := NewPowCache()
+	var b [16]byte
+	for i := uint64(0); i < count; i++ {
+		// Initial hashes are sha512 digests.
+		initialHash, err := bmutil.ReadVarBytes(r, 64, "initial hash")
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			return nil, err
+		}
+		c.Put(Target(binary.BigEndian.Uint64(b[:8])), initialHash,
+			Nonce(binary.BigEndian.Uint64(b[8:])))
+	}
+	return c, nil
+}
+
+// DoSequentialCached is like DoSequential, except that the cache is consulted
+// first and any nonce found is stored in it.
+func DoSequentialCached(cache *PowCache, target Target, initialHash []byte) Nonce {
+	if nonce, ok := cache.Get(target, initialHash); ok {
+		return nonce
+	}
+	nonce := DoSequential(target, initialHash)
+	cache.Put(target, initialHash, nonce)
+	return nonce
+}
+
+// DoParallelCached is like DoParallel, except that the cache is consulted
+// first and any nonce found is stored in it.
+func DoParallelCached(cache *PowCache, target Target, initialHash []byte, parallelCount int) Nonce {
+	if nonce, ok := cache.Get(target, initialHash); ok {
+		return nonce
+	}
+	nonce := DoParallel(target, initialHash, parallelCount)
+	cache.Put(target, initialHash, nonce)
+	return nonce
+}
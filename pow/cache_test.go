@@ -0,0 +1,87 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestPowCache(t *testing.T) {
+	cache := pow.NewPowCache()
+
+	tc := doTests[2]
+	initialHash, _ := hex.DecodeString(tc.initialHashStr)
+	target := pow.Target(tc.target)
+
+	if _, ok := cache.Get(target, initialHash); ok {
+		t.Errorf("Get on empty cache returned a nonce")
+	}
+
+	nonce := pow.DoSequentialCached(cache, target, initialHash)
+	if nonce != tc.nonce {
+		t.Errorf("DoSequentialCached got %d expected %d", nonce, tc.nonce)
+	}
+	if got, ok := cache.Get(target, initialHash); !ok || got != tc.nonce {
+		t.Errorf("Get got %d, %v expected %d", got, ok, tc.nonce)
+	}
+
+	// A different target is a different entry.
+	if _, ok := cache.Get(target-1, initialHash); ok {
+		t.Errorf("Get returned a nonce for a different target")
+	}
+
+	// An invalid nonce is never returned from the cache.
+	other, _ := hex.DecodeString(doTests[0].initialHashStr)
+	cache.Put(target, other, 1)
+	if _, ok := cache.Get(target, other); ok {
+		t.Errorf("Get returned an invalid nonce")
+	}
+
+	// Round trip through the encoding.
+	var buf bytes.Buffer
+	if err := cache.Encode(&buf); err != nil {
+		t.Fatalf("Encode error %v", err)
+	}
+	decoded, err := pow.DecodePowCache(&buf)
+	if err != nil {
+		t.Fatalf("DecodePowCache error %v", err)
+	}
+	if decoded.Len() != 2 {
+		t.Errorf("DecodePowCache got %d entries want 2", decoded.Len())
+	}
+
+	// Work is replayed from the cache. A parallel search would generally
+	// find a larger nonce, so getting the exact one back shows it came
+	// from the cache.
+	if got := pow.DoParallelCached(decoded, target, initialHash, 4); got != tc.nonce {
+		t.Errorf("DoParallelCached got %d expected %d", got, tc.nonce)
+	}
+
+	decoded.Remove(target, initialHash)
+	if decoded.Len() != 1 {
+		t.Errorf("Remove left %d entries want 1", decoded.Len())
+	}
+}
+
+func TestDecodePowCacheErrors(t *testing.T) {
+	tests := []struct {
+		in []byte
+	}{
+		{[]byte{}},
+		{[]byte{0xfe, 0xff, 0xff, 0xff, 0xff}},
+		{[]byte{0x01, 0x41}},
+		{[]byte{0x01, 0x01, 0xaa, 0, 0, 0}},
+	}
+
+	for i, test := range tests {
+		if _, err := pow.DecodePowCache(bytes.NewReader(test.in)); err == nil {
+			t.Errorf("DecodePowCache #%d expected error", i)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// asmSha512 lists the architectures for which the standard library has an
+// assembly implementation of SHA-512. Everywhere else, including wasm, it
+// falls back to pure Go, which is several times slower.
+var asmSha512 = map[string]bool{
+	"amd64":   true,
+	"arm64":   true,
+	"ppc64":   true,
+	"ppc64le": true,
+	"s390x":   true,
+}
+
+// Features describes how proof of work is done on this platform.
+type Features struct {
+	GOOS, GOARCH string
+
+	// AcceleratedHash is whether SHA-512 uses an assembly implementation.
+	// The proof of work loop itself is always written in Go, so it runs on
+	// any platform, but without acceleration it is a good deal slower.
+	AcceleratedHash bool
+
+	// Parallel is the number of goroutines which Do uses. It is 1 where
+	// goroutines cannot run at the same time, as on js/wasm.
+	Parallel int
+}
+
+// String returns a short description of the features for logging.
+func (f Features) String() string {
+	hash := "pure Go sha512"
+	if f.AcceleratedHash {
+		hash = "assembly sha512"
+	}
+	return fmt.Sprintf("%s/%s, %s, %d parallel", f.GOOS, f.GOARCH, hash, f.Parallel)
+}
+
+// Capabilities returns the proof of work features of this platform, so that
+// callers can tell the user what performance to expect.
+func Capabilities() Features {
+	parallel := runtime.GOMAXPROCS(0)
+	if runtime.GOARCH == "wasm" || parallel < 1 {
+		parallel = 1
+	}
+
+	return Features{
+		GOOS:            runtime.GOOS,
+		GOARCH:          runtime.GOARCH,
+		AcceleratedHash: asmSha512[runtime.GOARCH],
+		Parallel:        parallel,
+	}
+}
+
+// Do does the proof of work in the way best suited to the platform, which is
+// in parallel with Capabilities().Parallel goroutines if that is more than one
+// and sequentially otherwise.
+func Do(target Target, initialHash []byte) Nonce {
+	return DoParallel(target, initialHash, Capabilities().Parallel)
+}
+
+// EstimateHashRate tries nonces for about the given duration on a single
+// goroutine and returns how many it tried per second. Multiplied by
+// Capabilities().Parallel, it gives a rough idea of how fast Do will be.
+func EstimateHashRate(d time.Duration) float64 {
+	initialHash := make([]byte, 64)
+	start := time.Now()
+	var trials uint64
+	for time.Since(start) < d {
+		// An impossible target, so that every nonce is tried.
+		for i := 0; i < 256; i++ {
+			trials++
+			Check(0, Nonce(trials), initialHash)
+		}
+	}
+	elapsed := time.Since(start)
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(trials) / elapsed.Seconds()
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Multiplier scales the difficulty of proof of work by Num / Den.
+type Multiplier struct {
+	Num uint64 `json:"num"`
+	Den uint64 `json:"den"`
+}
+
+// Policy changes the proof of work required of objects according to their
+// type, for private networks which want some kinds of object to be cheaper
+// or more expensive than others. The public network requires the same of
+// every object, so a node using a Policy cannot talk to it. Object types are
+// the numbers used in the object header, as given by wire.ObjectType.
+type Policy struct {
+	// Multipliers maps object types to the multiplier of the difficulty
+	// asked of objects of that type. Types which are not listed keep the
+	// usual difficulty.
+	Multipliers map[uint32]Multiplier `json:"multipliers"`
+}
+
+// Apply returns the parameters to demand of an object of the given type in
+// place of data. The multiplier is applied to the nonce trials per byte,
+// which the difficulty is proportional to, rounding down but never below one
+// trial. A nil Policy leaves data as it is, as does a multiplier with a zero
+// denominator.
+func (p *Policy) Apply(objType uint32, data Data) Data {
+	if p == nil {
+		return data
+	}
+	m, ok := p.Multipliers[objType]
+	if !ok || m.Den == 0 {
+		return data
+	}
+
+	hi, lo := bits.Mul64(data.NonceTrialsPerByte, m.Num)
+	if hi >= m.Den {
+		data.NonceTrialsPerByte = math.MaxUint64
+	} else {
+		data.NonceTrialsPerByte, _ = bits.Div64(hi, lo, m.Den)
+	}
+	if data.NonceTrialsPerByte == 0 {
+		data.NonceTrialsPerByte = 1
+	}
+	return data
+}
@@ -8,6 +8,8 @@ package pow
 import (
 	"encoding/binary"
 	"math"
+	"math/bits"
+	"sync"
 
 	"github.com/DanielKrawisz/bmutil/hash"
 )
@@ -17,12 +19,35 @@ import (
 // ttl is the time difference (in seconds) between ExpiresTime and time.Now().
 // Information about nonceTrials and extraBytes can be found at:
 // https://bitmessage.org/wiki/Proof_of_work
+//
+// The calculation is done in 128-bit integer arithmetic, with each division
+// rounding down as Python's does. If the difficulty is so great that it does
+// not fit in 64 bits the target is zero, and if it is zero (because
+// NonceTrialsPerByte is zero) the target is math.MaxUint64.
 func CalculateTarget(payloadLength, ttl uint64, data Data) Target {
-	// All these type conversions are needed for interoperability with Python
-	// which casts types back to int after performing division.
-	return Target(math.MaxUint64 / (data.NonceTrialsPerByte * (payloadLength + data.ExtraBytes +
-		uint64(float64(ttl)*(float64(payloadLength)+float64(data.ExtraBytes))/
-			math.Pow(2, 16)))))
+	length, carry := bits.Add64(payloadLength, data.ExtraBytes, 0)
+	if carry != 0 {
+		return 0
+	}
+
+	// ttl * length / 2^16
+	hi, lo := bits.Mul64(ttl, length)
+	if hi>>16 != 0 {
+		return 0
+	}
+	length, carry = bits.Add64(length, hi<<48|lo>>16, 0)
+	if carry != 0 {
+		return 0
+	}
+
+	hi, difficulty := bits.Mul64(data.NonceTrialsPerByte, length)
+	if hi != 0 {
+		return 0
+	}
+	if difficulty == 0 {
+		return Target(math.MaxUint64)
+	}
+	return Target(math.MaxUint64 / difficulty)
 }
 
 // Check whether the given message and nonce satisfy the given pow target.
@@ -58,9 +83,15 @@ func DoSequential(target Target, initialHash []byte) Nonce {
 }
 
 // DoParallel does the POW using parallelCount number of goroutines and returns
-// the nonce value.
+// the nonce value. If parallelCount is less than two, the work is done with
+// DoSequential, which finds the same nonce without starting any goroutines.
 func DoParallel(target Target, initialHash []byte, parallelCount int) Nonce {
+	if parallelCount < 2 {
+		return DoSequential(target, initialHash)
+	}
+
 	done := make(chan bool)
+	var finish sync.Once
 	nonceValue := make(chan Nonce, 1)
 
 	for i := 0; i < parallelCount; i++ {
@@ -80,8 +111,14 @@ func DoParallel(target Target, initialHash []byte, parallelCount int) Nonce {
 					trialValue = binary.BigEndian.Uint64(resultHash[:8])
 
 					if trialValue <= uint64(target) {
-						nonceValue <- Nonce(nonce)
-						close(done)
+						// More than one goroutine may find a nonce
+						// before they see done, so only the first
+						// result is kept.
+						finish.Do(func() {
+							nonceValue <- Nonce(nonce)
+							close(done)
+						})
+						return
 					}
 
 					nonce += uint64(parallelCount) // increment by parallelCount
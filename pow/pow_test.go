@@ -7,8 +7,11 @@ package pow_test
 
 import (
 	"encoding/hex"
+	"math"
+	"math/big"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/DanielKrawisz/bmutil/pow"
 )
@@ -58,6 +61,55 @@ func TestCalculateTarget(t *testing.T) {
 	}
 }
 
+// bigTarget calculates the target with arbitrary precision.
+func bigTarget(payloadLen, ttl uint64, data pow.Data) uint64 {
+	length := new(big.Int).Add(new(big.Int).SetUint64(payloadLen),
+		new(big.Int).SetUint64(data.ExtraBytes))
+	ttlTerm := new(big.Int).Mul(new(big.Int).SetUint64(ttl), length)
+	ttlTerm.Rsh(ttlTerm, 16)
+	difficulty := new(big.Int).Add(length, ttlTerm)
+	difficulty.Mul(difficulty, new(big.Int).SetUint64(data.NonceTrialsPerByte))
+	if difficulty.Sign() == 0 {
+		return math.MaxUint64
+	}
+	return new(big.Int).Div(new(big.Int).SetUint64(math.MaxUint64), difficulty).Uint64()
+}
+
+func TestCalculateTargetOverflow(t *testing.T) {
+	tests := []struct {
+		payloadLen, ttl uint64
+		data            pow.Data
+	}{
+		{math.MaxUint64, 0, data},
+		{math.MaxUint64 - extraBytes, 0, pow.Data{1, extraBytes}},
+		{math.MaxUint64 - extraBytes + 1, 0, pow.Data{1, extraBytes}},
+		{1, math.MaxUint64, data},
+		{1 << 20, 1 << 60, pow.Data{1, 0}},
+		{1 << 32, 1 << 32, pow.Data{1, 0}},
+		{1 << 24, 1 << 40, pow.Data{1, 0}},
+		{1<<24 - 1, 1 << 40, pow.Data{1, 0}},
+		{4000000, 60 * 60 * 24 * 365 * 100, pow.Data{math.MaxUint32, math.MaxUint32}},
+		{100, 10000, pow.Data{math.MaxUint64, 0}},
+		{0, math.MaxUint64, pow.Data{1, 0}},
+		{100, 10000, pow.Data{0, extraBytes}},
+		{1<<16 - 1, 1, pow.Data{1, 1}},
+	}
+
+	for n, tc := range tests {
+		target := pow.CalculateTarget(tc.payloadLen, tc.ttl, tc.data)
+		expected := bigTarget(tc.payloadLen, tc.ttl, tc.data)
+		if target != pow.Target(expected) {
+			t.Errorf("for test #%d got %d expected %d", n, target, expected)
+		}
+	}
+}
+
+func BenchmarkCalculateTarget(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		pow.CalculateTarget(563421, 60*60*24*28, data)
+	}
+}
+
 type doTest struct {
 	target         uint64
 	initialHashStr string
@@ -73,6 +125,41 @@ var doTests = []doTest{
 	{32101869570011, "84582938b2e4d4a224170fb079a2494b0e4a0d16665d91b44bc1f2cdf595f5f31bdec6acbd7386dba4b619507af2e3291635828ae12a156c46d8c9dea868c3de", pow.Nonce(2434185)},
 }
 
+func TestPolicy(t *testing.T) {
+	policy := &pow.Policy{Multipliers: map[uint32]pow.Multiplier{
+		0: {Num: 1, Den: 4},
+		1: {Num: 3, Den: 1},
+		2: {Num: 1, Den: 1 << 20},
+		3: {Num: math.MaxUint64, Den: 2},
+		4: {Num: 5, Den: 0},
+	}}
+
+	tests := []struct {
+		objType uint32
+		trials  uint64
+	}{
+		{0, 250},
+		{1, 3000},
+		{2, 1}, // Never less than one trial.
+		{3, math.MaxUint64},
+		{4, nonceTrials}, // No denominator.
+		{5, nonceTrials}, // Not listed.
+	}
+
+	for _, test := range tests {
+		got := policy.Apply(test.objType, data)
+		if got.NonceTrialsPerByte != test.trials || got.ExtraBytes != extraBytes {
+			t.Errorf("Apply for type %d got %v want {%d, %d}", test.objType,
+				&got, test.trials, extraBytes)
+		}
+	}
+
+	var none *pow.Policy
+	if got := none.Apply(1, data); got != data {
+		t.Errorf("Apply of nil policy got %v", &got)
+	}
+}
+
 func TestDoSequential(t *testing.T) {
 	for n, tc := range doTests {
 		initialHash, _ := hex.DecodeString(tc.initialHashStr)
@@ -94,7 +181,49 @@ func TestDoParallel(t *testing.T) {
 		}
 	}
 
+	// With the easiest target every goroutine finds a nonce at once, and
+	// only one of them may be taken.
+	initialHash := make([]byte, 64)
+	for i := 0; i < 100; i++ {
+		nonce := pow.DoParallel(pow.Target(math.MaxUint64), initialHash, 8)
+		if nonce < 1 || nonce > 8 {
+			t.Fatalf("got nonce %d with the easiest target", nonce)
+		}
+	}
+	time.Sleep(10 * time.Millisecond) // let the other goroutines finish
+
 	runtime.GOMAXPROCS(1)
 }
 
+func TestCapabilities(t *testing.T) {
+	f := pow.Capabilities()
+	if f.GOOS != runtime.GOOS || f.GOARCH != runtime.GOARCH {
+		t.Errorf("got platform %s/%s", f.GOOS, f.GOARCH)
+	}
+	if f.Parallel < 1 {
+		t.Errorf("got parallel %d", f.Parallel)
+	}
+	if f.String() == "" {
+		t.Error("empty description")
+	}
+	if rate := pow.EstimateHashRate(10 * time.Millisecond); rate <= 0 {
+		t.Errorf("got hash rate %f", rate)
+	}
+}
+
+func TestDo(t *testing.T) {
+	for n, tc := range doTests[:3] {
+		initialHash, _ := hex.DecodeString(tc.initialHashStr)
+		nonce := pow.Do(pow.Target(tc.target), initialHash)
+		if !pow.Check(pow.Target(tc.target), nonce, initialHash) {
+			t.Errorf("for test #%d Do returned an invalid nonce %d", n, nonce)
+		}
+
+		// With fewer than two goroutines, the work is sequential.
+		if nonce = pow.DoParallel(pow.Target(tc.target), initialHash, 0); nonce != tc.nonce {
+			t.Errorf("for test #%d got %d expected %d", n, nonce, tc.nonce)
+		}
+	}
+}
+
 // TODO add benchmarks
@@ -0,0 +1,180 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sample describes one proof of work job.
+type Sample struct {
+	PayloadLength      uint64        `json:"payloadLength"`
+	TTL                uint64        `json:"ttl"`
+	NonceTrialsPerByte uint64        `json:"nonceTrialsPerByte"`
+	ExtraBytes         uint64        `json:"extraBytes"`
+	Target             Target        `json:"target"`
+	Duration           time.Duration `json:"duration"`
+
+	// Trials is the number of nonces that were tried. For parallel jobs it
+	// is estimated from the value of the winning nonce.
+	Trials uint64 `json:"trials"`
+}
+
+// HashRate returns the number of nonces tried per second.
+func (s *Sample) HashRate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Trials) / s.Duration.Seconds()
+}
+
+// MarshalJSON encodes the sample with its hash rate, in nonces per second,
+// as hash_rate. The hash rate is ignored when the sample is decoded, since it
+// follows from the other fields.
+func (s Sample) MarshalJSON() ([]byte, error) {
+	type sample Sample
+	return json.Marshal(struct {
+		sample
+		HashRate float64 `json:"hash_rate"`
+	}{sample(s), s.HashRate()})
+}
+
+// Recorder collects samples from proof of work jobs so that the effect of
+// different difficulty parameters can be measured. A nil *Recorder does the
+// work without recording anything. It is safe for concurrent use.
+type Recorder struct {
+	mtx     sync.Mutex
+	samples []Sample
+	max     int
+}
+
+// NewRecorder returns a Recorder which keeps the most recent max samples, or
+// every sample if max is not positive.
+func NewRecorder(max int) *Recorder {
+	return &Recorder{max: max}
+}
+
+// Record adds a sample to the recorder.
+func (r *Recorder) Record(s Sample) {
+	if r == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.max > 0 && len(r.samples) >= r.max {
+		n := copy(r.samples, r.samples[len(r.samples)-r.max+1:])
+		r.samples = r.samples[:n]
+	}
+	r.samples = append(r.samples, s)
+}
+
+// Samples returns a copy of the recorded samples, oldest first.
+func (r *Recorder) Samples() []Sample {
+	if r == nil {
+		return nil
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	samples := make([]Sample, len(r.samples))
+	copy(samples, r.samples)
+	return samples
+}
+
+// Reset discards all recorded samples.
+func (r *Recorder) Reset() {
+	if r == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	r.samples = nil
+	r.mtx.Unlock()
+}
+
+// DoSequential calculates the target for the given payload length, ttl and
+// parameters, does the proof of work with DoSequential and records how long
+// it took.
+func (r *Recorder) DoSequential(payloadLength, ttl uint64, data Data, initialHash []byte) Nonce {
+	return r.do(payloadLength, ttl, data, func(target Target) Nonce {
+		return DoSequential(target, initialHash)
+	})
+}
+
+// DoParallel is like DoSequential, except that the work is done with
+// DoParallel.
+func (r *Recorder) DoParallel(payloadLength, ttl uint64, data Data, initialHash []byte, parallelCount int) Nonce {
+	return r.do(payloadLength, ttl, data, func(target Target) Nonce {
+		return DoParallel(target, initialHash, parallelCount)
+	})
+}
+
+func (r *Recorder) do(payloadLength, ttl uint64, data Data, work func(Target) Nonce) Nonce {
+	target := CalculateTarget(payloadLength, ttl, data)
+
+	start := time.Now()
+	nonce := work(target)
+
+	r.Record(Sample{
+		PayloadLength:      payloadLength,
+		TTL:                ttl,
+		NonceTrialsPerByte: data.NonceTrialsPerByte,
+		ExtraBytes:         data.ExtraBytes,
+		Target:             target,
+		Duration:           time.Since(start),
+		Trials:             uint64(nonce),
+	})
+	return nonce
+}
+
+// csvHeader names the columns written by WriteCSV.
+var csvHeader = []string{"payload_length", "ttl", "nonce_trials_per_byte",
+	"extra_bytes", "target", "seconds", "trials", "hash_rate"}
+
+// WriteCSV writes the recorded samples to w as CSV with a header row.
+// Durations are in seconds and hash rates in nonces per second.
+func (r *Recorder) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, s := range r.Samples() {
+		err := cw.Write([]string{
+			strconv.FormatUint(s.PayloadLength, 10),
+			strconv.FormatUint(s.TTL, 10),
+			strconv.FormatUint(s.NonceTrialsPerByte, 10),
+			strconv.FormatUint(s.ExtraBytes, 10),
+			strconv.FormatUint(uint64(s.Target), 10),
+			strconv.FormatFloat(s.Duration.Seconds(), 'f', -1, 64),
+			strconv.FormatUint(s.Trials, 10),
+			strconv.FormatFloat(s.HashRate(), 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the recorded samples to w as a JSON array. Durations are
+// in nanoseconds, as with time.Duration, and hash rates in nonces per second.
+func (r *Recorder) WriteJSON(w io.Writer) error {
+	samples := r.Samples()
+	if samples == nil {
+		samples = []Sample{}
+	}
+	return json.NewEncoder(w).Encode(samples)
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pow_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/pow"
+)
+
+func TestRecorder(t *testing.T) {
+	easy := pow.Data{NonceTrialsPerByte: 1, ExtraBytes: 10}
+	initialHash := []byte("initial hash")
+
+	r := pow.NewRecorder(2)
+	nonce := r.DoSequential(100, 60, easy, initialHash)
+	target := pow.CalculateTarget(100, 60, easy)
+	if !pow.Check(target, nonce, initialHash) {
+		t.Errorf("DoSequential returned an invalid nonce %d", nonce)
+	}
+	nonce = r.DoParallel(200, 60, easy, initialHash, 2)
+	if !pow.Check(pow.CalculateTarget(200, 60, easy), nonce, initialHash) {
+		t.Errorf("DoParallel returned an invalid nonce %d", nonce)
+	}
+
+	samples := r.Samples()
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(samples))
+	}
+	s := samples[0]
+	if s.PayloadLength != 100 || s.TTL != 60 || s.NonceTrialsPerByte != 1 ||
+		s.ExtraBytes != 10 || s.Target != target || s.Trials == 0 {
+		t.Errorf("wrong sample %+v", s)
+	}
+
+	// Only the most recent samples are kept.
+	r.Record(pow.Sample{PayloadLength: 300})
+	samples = r.Samples()
+	if len(samples) != 2 || samples[0].PayloadLength != 200 ||
+		samples[1].PayloadLength != 300 {
+		t.Errorf("wrong samples after overflow %+v", samples)
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteCSV(&b); err != nil {
+		t.Fatalf("WriteCSV error %v", err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("could not read CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "payload_length" ||
+		rows[2][0] != "300" || rows[2][6] != "0" {
+		t.Errorf("wrong CSV output %v", rows)
+	}
+	if _, err := strconv.ParseFloat(rows[1][7], 64); err != nil {
+		t.Errorf("hash rate %q is not a number", rows[1][7])
+	}
+
+	b.Reset()
+	if err := r.WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON error %v", err)
+	}
+	var decoded []pow.Sample
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatalf("could not read JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0] != samples[0] || decoded[1] != samples[1] {
+		t.Errorf("JSON got %+v, want %+v", decoded, samples)
+	}
+	var rates []map[string]interface{}
+	json.Unmarshal(b.Bytes(), &rates)
+	if rate, ok := rates[0]["hash_rate"].(float64); !ok || rate != samples[0].HashRate() {
+		t.Errorf("JSON hash rate got %v, want %v", rates[0]["hash_rate"],
+			samples[0].HashRate())
+	}
+
+	r.Reset()
+	b.Reset()
+	r.WriteJSON(&b)
+	if b.String() != "[]\n" {
+		t.Errorf("WriteJSON after Reset got %q", b.String())
+	}
+
+	// A nil recorder still does the work.
+	var none *pow.Recorder
+	nonce = none.DoSequential(100, 60, easy, initialHash)
+	if !pow.Check(target, nonce, initialHash) {
+		t.Errorf("nil Recorder returned an invalid nonce %d", nonce)
+	}
+	if none.Samples() != nil {
+		t.Errorf("nil Recorder has samples")
+	}
+}
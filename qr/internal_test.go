@@ -0,0 +1,10 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package qr
+
+// TstPBKDF2 makes the internal PBKDF2 function available to the test package.
+func TstPBKDF2(password, salt []byte, iterations, length int) []byte {
+	return pbkdf2(password, salt, iterations, length)
+}
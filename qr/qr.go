@@ -0,0 +1,284 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package qr makes and reads the text held in QR codes for Bitmessage
+// addresses and for encrypted backups of identities, so that wallets which
+// use this package can scan each other's codes. It does not draw the codes.
+//
+// An address is a bitmessage: link, optionally with a label:
+//
+//	bitmessage:BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ?label=Alice
+//
+// A link to subscribe to the broadcasts of an address has broadcast=1 too:
+//
+//	bitmessage:BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ?broadcast=1&label=News
+//
+// A backup is the link scheme bmbackup: followed by base58 with a checksum,
+// as used by addresses. The data is a version byte, the number of PBKDF2
+// iterations as four bytes big endian, a random salt, a random nonce and
+// the identity's address and keys in wallet import format, sealed with
+// AES-256-GCM under a key derived from the passphrase with
+// PBKDF2-HMAC-SHA512.
+package qr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/base58"
+	"github.com/DanielKrawisz/bmutil/identity"
+)
+
+const (
+	// AddressScheme is the link scheme of an address payload.
+	AddressScheme = "bitmessage"
+
+	// BackupScheme is the link scheme of a backup payload.
+	BackupScheme = "bmbackup"
+
+	// BackupIterations is the number of PBKDF2 iterations used for new
+	// backups.
+	BackupIterations = 100000
+
+	// MaxBackupIterations is the most PBKDF2 iterations accepted when
+	// reading a backup, so that a made up code can't keep a phone busy for
+	// hours.
+	MaxBackupIterations = 10000000
+
+	backupVersion = 1
+	saltSize      = 16
+	keySize       = 32
+)
+
+var (
+	// ErrNotAddress is returned by ParseAddress for a payload which is not
+	// an address.
+	ErrNotAddress = errors.New("not an address payload")
+
+	// ErrNotSubscription is returned by ParseSubscription for a payload
+	// which is not a subscription link.
+	ErrNotSubscription = errors.New("not a subscription payload")
+
+	// ErrNotBackup is returned by ParseBackup for a payload which is not a
+	// backup.
+	ErrNotBackup = errors.New("not a backup payload")
+
+	// ErrUnsupportedBackup is returned by ParseBackup for a backup of a
+	// version or with parameters that it does not support.
+	ErrUnsupportedBackup = errors.New("unsupported backup version")
+
+	// ErrWrongPassphrase is returned by ParseBackup when the backup cannot
+	// be decrypted, which is usually because the passphrase is wrong.
+	ErrWrongPassphrase = errors.New("wrong passphrase or damaged backup")
+)
+
+// Address returns the payload for an address, with the label if it is not
+// empty.
+func Address(addr bmutil.Address, label string) string {
+	params := url.Values{}
+	if label != "" {
+		params.Set("label", label)
+	}
+	return link(addr, params)
+}
+
+// ParseAddress reads a payload made by Address and returns the address and
+// its label, which is empty if there was none. The scheme may be in any
+// case and may be followed by "//", and other parameters are ignored.
+func ParseAddress(payload string) (bmutil.Address, string, error) {
+	addr, params, err := parseLink(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	return addr, params.Get("label"), nil
+}
+
+// SubscriptionRequest is a request to subscribe to the broadcasts of an
+// address, as read from a subscription link.
+type SubscriptionRequest struct {
+	Address bmutil.Address
+	Label   string
+}
+
+// Subscription returns the payload of a link to subscribe to the broadcasts
+// of an address, with the label if it is not empty.
+func Subscription(addr bmutil.Address, label string) string {
+	params := url.Values{"broadcast": {"1"}}
+	if label != "" {
+		params.Set("label", label)
+	}
+	return link(addr, params)
+}
+
+// ParseSubscription reads a payload made by Subscription. It returns
+// ErrNotSubscription for an address link without broadcast=1, which is a
+// link to write to the address instead.
+func ParseSubscription(payload string) (*SubscriptionRequest, error) {
+	addr, params, err := parseLink(payload)
+	if err == ErrNotAddress {
+		return nil, ErrNotSubscription
+	}
+	if err != nil {
+		return nil, err
+	}
+	if params.Get("broadcast") != "1" {
+		return nil, ErrNotSubscription
+	}
+	return &SubscriptionRequest{
+		Address: addr,
+		Label:   params.Get("label"),
+	}, nil
+}
+
+// Add subscribes to the address in the given list, enabled, and returns
+// identity.ErrDuplicateSubscription if it is already there.
+func (r *SubscriptionRequest) Add(subs *identity.Subscriptions) error {
+	return subs.Add(r.Address, r.Label, true)
+}
+
+// link returns a bitmessage: link to the address with the given parameters.
+func link(addr bmutil.Address, params url.Values) string {
+	payload := AddressScheme + ":" + addr.String()
+	if len(params) != 0 {
+		payload += "?" + params.Encode()
+	}
+	return payload
+}
+
+// parseLink reads a bitmessage: link and returns the address and the
+// parameters.
+func parseLink(payload string) (bmutil.Address, url.Values, error) {
+	u, err := url.Parse(strings.TrimSpace(payload))
+	if err != nil || u.Scheme != AddressScheme {
+		return nil, nil, ErrNotAddress
+	}
+
+	str := u.Opaque
+	if str == "" {
+		str = u.Host
+	}
+	addr, err := bmutil.DecodeAddress(str)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, u.Query(), nil
+}
+
+// Backup returns the payload for an encrypted backup of the identity.
+func Backup(id *identity.PrivateAddress, passphrase string) (string, error) {
+	var plain bytes.Buffer
+	address, signing, decryption := id.ExportWIF()
+	for _, s := range []string{address, signing, decryption} {
+		if err := bmutil.WriteVarString(&plain, s); err != nil {
+			return "", err
+		}
+	}
+
+	head := make([]byte, 5+saltSize)
+	head[0] = backupVersion
+	binary.BigEndian.PutUint32(head[1:5], BackupIterations)
+	if _, err := io.ReadFull(rand.Reader, head[5:]); err != nil {
+		return "", err
+	}
+
+	aead, err := backupCipher(passphrase, head[5:], BackupIterations)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	data := append(head, nonce...)
+	data = aead.Seal(data, nonce, plain.Bytes(), head)
+	return BackupScheme + ":" + base58.EncodeCheck(data), nil
+}
+
+// ParseBackup decrypts a payload made by Backup.
+func ParseBackup(payload, passphrase string) (*identity.PrivateAddress, error) {
+	payload = strings.TrimSpace(payload)
+	prefix := BackupScheme + ":"
+	if len(payload) < len(prefix) || !strings.EqualFold(payload[:len(prefix)], prefix) {
+		return nil, ErrNotBackup
+	}
+
+	data, err := base58.DecodeCheck(payload[len(prefix):])
+	if err != nil {
+		return nil, ErrNotBackup
+	}
+	if len(data) < 5+saltSize {
+		return nil, ErrNotBackup
+	}
+	iterations := binary.BigEndian.Uint32(data[1:5])
+	if data[0] != backupVersion || iterations == 0 || iterations > MaxBackupIterations {
+		return nil, ErrUnsupportedBackup
+	}
+
+	head, sealed := data[:5+saltSize], data[5+saltSize:]
+	aead, err := backupCipher(passphrase, head[5:], int(iterations))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrNotBackup
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], head)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	r := bytes.NewReader(plain)
+	var strs [3]string
+	for i := range strs {
+		if strs[i], err = bmutil.ReadVarString(r, len(plain)); err != nil {
+			return nil, ErrNotBackup
+		}
+	}
+	return identity.ImportWIF(strs[0], strs[1], strs[2])
+}
+
+// backupCipher returns the AEAD for a backup with the given passphrase, salt
+// and number of iterations.
+func backupCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2([]byte(passphrase), salt, iterations, keySize))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 is PBKDF2 from RFC 8018 with HMAC-SHA512.
+func pbkdf2(password, salt []byte, iterations, length int) []byte {
+	prf := hmac.New(sha512.New, password)
+	var key []byte
+	var u []byte
+	for block := uint32(1); len(key) < length; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:length]
+}
@@ -0,0 +1,162 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package qr_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/identity"
+	"github.com/DanielKrawisz/bmutil/qr"
+)
+
+func TestAddress(t *testing.T) {
+	const str = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ"
+	addr, _ := bmutil.DecodeAddress(str)
+
+	tests := []struct {
+		label   string
+		payload string
+	}{
+		{"", "bitmessage:" + str},
+		{"Alice & Bob", "bitmessage:" + str + "?label=Alice+%26+Bob"},
+	}
+	for i, test := range tests {
+		if payload := qr.Address(addr, test.label); payload != test.payload {
+			t.Errorf("#%d: got payload %s want %s", i, payload, test.payload)
+		}
+		got, label, err := qr.ParseAddress(test.payload)
+		if err != nil {
+			t.Errorf("#%d: got error %v", i, err)
+			continue
+		}
+		if !got.Equal(addr) || label != test.label {
+			t.Errorf("#%d: got %s, %q", i, got, label)
+		}
+	}
+
+	for i, payload := range []string{
+		"BITMESSAGE:" + str,
+		"bitmessage://" + str + "?subject=hi",
+	} {
+		if got, _, err := qr.ParseAddress(payload); err != nil || !got.Equal(addr) {
+			t.Errorf("payload %d: got %v, %v", i, got, err)
+		}
+	}
+
+	if _, _, err := qr.ParseAddress(str); err != qr.ErrNotAddress {
+		t.Errorf("bare address: got %v", err)
+	}
+	if _, _, err := qr.ParseAddress("bitmessage:BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BK"); err == nil {
+		t.Error("bad checksum: expected error got none")
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	const str = "BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ"
+	addr, _ := bmutil.DecodeAddress(str)
+
+	payload := qr.Subscription(addr, "News")
+	if want := "bitmessage:" + str + "?broadcast=1&label=News"; payload != want {
+		t.Errorf("got payload %s want %s", payload, want)
+	}
+	req, err := qr.ParseSubscription(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.Address.Equal(addr) || req.Label != "News" {
+		t.Errorf("got %s, %q", req.Address, req.Label)
+	}
+
+	// A subscription link is still a link to the address.
+	if got, label, err := qr.ParseAddress(payload); err != nil || !got.Equal(addr) || label != "News" {
+		t.Errorf("ParseAddress got %v, %q, %v", got, label, err)
+	}
+
+	subs := identity.NewSubscriptions()
+	if err = req.Add(subs); err != nil {
+		t.Fatal(err)
+	}
+	if sub := subs.Get(str); sub == nil || sub.Label != "News" || !sub.Enabled {
+		t.Errorf("got subscription %v", sub)
+	}
+	if err = req.Add(subs); err != identity.ErrDuplicateSubscription {
+		t.Errorf("second Add got %v", err)
+	}
+
+	for i, bad := range []string{
+		qr.Address(addr, "News"),
+		"bitmessage:" + str + "?broadcast=0",
+		str,
+	} {
+		if _, err = qr.ParseSubscription(bad); err != qr.ErrNotSubscription {
+			t.Errorf("#%d: got %v, want %v", i, err, qr.ErrNotSubscription)
+		}
+	}
+}
+
+func TestBackup(t *testing.T) {
+	id, err := identity.ImportWIF("BM-2cXm1jokUVp9Nn1kBtkeMjpxaLJuP3FwET",
+		"5K3oNuMzVEWdrtyBAZXrPQwQTSmCGrAZS1groRDQVGDeccLim15",
+		"5HzhkuimkuizxJyw9b7qnFEMtUrAXD25Y5AV1sZ964dSSXReKnb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := qr.Backup(id, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(payload, "bmbackup:") {
+		t.Errorf("got payload %s", payload)
+	}
+	if strings.Contains(payload, "5K3oNuMz") {
+		t.Error("payload contains the signing key")
+	}
+
+	got, err := qr.ParseBackup(payload, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a1, s1, d1 := id.ExportWIF()
+	a2, s2, d2 := got.ExportWIF()
+	if a1 != a2 || s1 != s2 || d1 != d2 {
+		t.Errorf("got %s, %s, %s", a2, s2, d2)
+	}
+
+	if _, err = qr.ParseBackup(payload, "wrong horse"); err != qr.ErrWrongPassphrase {
+		t.Errorf("wrong passphrase: got %v", err)
+	}
+	for i, bad := range []string{
+		qr.Address(id.Address(), ""),
+		"bmbackup:",
+		payload[:len(payload)-1],
+	} {
+		if _, err = qr.ParseBackup(bad, "correct horse"); err != qr.ErrNotBackup {
+			t.Errorf("#%d: got %v, want %v", i, err, qr.ErrNotBackup)
+		}
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		iterations int
+		key        string
+	}{
+		{1, "867f70cf1ade02cff3752599a3a53dc4af34c7a669815ae5d513554e1c8cf252" +
+			"c02d470a285a0501bad999bfe943c08f050235d7d68b1da55e63f73b60a57fce"},
+		{2, "e1d9c16aa681708a45f5c7c4e215ceb66e011a2e9f0040713f18aefdb866d53c" +
+			"f76cab2868a39b9f7840edce4fef5a82be67335c77a6068e04112754f27ccf4e"},
+	}
+	for _, test := range tests {
+		key := hex.EncodeToString(qr.TstPBKDF2([]byte("password"), []byte("salt"),
+			test.iterations, 64))
+		if key != test.key {
+			t.Errorf("%d iterations: got %s", test.iterations, key)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil
+
+import (
+	"sort"
+)
+
+// Stream is a Bitmessage stream number. The streams form a binary tree with
+// stream 1 at the root, in which stream n is the parent of streams 2n and
+// 2n+1. When a stream becomes too busy, new addresses are put in its
+// children, and a node that serves a stream also connects to its children so
+// that they stay connected to the rest of the network. Stream 0 is not a
+// stream.
+type Stream uint64
+
+// RootStream is the stream at the top of the tree.
+const RootStream Stream = 1
+
+// Valid says whether s is a stream number.
+func (s Stream) Valid() bool {
+	return s != 0
+}
+
+// Parent returns the stream above s in the tree, or 0 for the root stream,
+// which has no parent.
+func (s Stream) Parent() Stream {
+	return s / 2
+}
+
+// Children returns the two streams below s in the tree. A stream too far
+// down to have children, near the top of the uint64 range, returns nil.
+func (s Stream) Children() []Stream {
+	if !s.Valid() || s > (^Stream(0)-1)/2 {
+		return nil
+	}
+	return []Stream{2 * s, 2*s + 1}
+}
+
+// IsAncestorOf says whether s is above t in the tree. A stream is not its own
+// ancestor.
+func (s Stream) IsAncestorOf(t Stream) bool {
+	if !s.Valid() {
+		return false
+	}
+	for t = t.Parent(); t >= s; t = t.Parent() {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Depth returns the number of streams between s and the root stream, which
+// has depth 0.
+func (s Stream) Depth() int {
+	d := 0
+	for ; s > RootStream; s = s.Parent() {
+		d++
+	}
+	return d
+}
+
+// SubscribeStreams returns the streams that a node holding the given
+// addresses should connect to, in increasing order: the stream of each
+// address and that stream's children.
+func SubscribeStreams(addrs []Address) []Stream {
+	set := make(map[Stream]struct{})
+	for _, addr := range addrs {
+		s := Stream(addr.Stream())
+		if !s.Valid() {
+			continue
+		}
+		set[s] = struct{}{}
+		for _, c := range s.Children() {
+			set[c] = struct{}{}
+		}
+	}
+
+	streams := make([]Stream, 0, len(set))
+	for s := range set {
+		streams = append(streams, s)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i] < streams[j] })
+	return streams
+}
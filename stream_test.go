@@ -0,0 +1,74 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bmutil_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil"
+)
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		stream   bmutil.Stream
+		parent   bmutil.Stream
+		children []bmutil.Stream
+		depth    int
+	}{
+		{0, 0, nil, 0},
+		{1, 0, []bmutil.Stream{2, 3}, 0},
+		{2, 1, []bmutil.Stream{4, 5}, 1},
+		{3, 1, []bmutil.Stream{6, 7}, 1},
+		{13, 6, []bmutil.Stream{26, 27}, 3},
+		{^bmutil.Stream(0), ^bmutil.Stream(0) / 2, nil, 63},
+	}
+
+	for i, test := range tests {
+		if p := test.stream.Parent(); p != test.parent {
+			t.Errorf("#%d: Parent got %d want %d", i, p, test.parent)
+		}
+		if c := test.stream.Children(); !reflect.DeepEqual(c, test.children) {
+			t.Errorf("#%d: Children got %v want %v", i, c, test.children)
+		}
+		if d := test.stream.Depth(); d != test.depth {
+			t.Errorf("#%d: Depth got %d want %d", i, d, test.depth)
+		}
+	}
+
+	ancestors := []struct {
+		s, t bmutil.Stream
+		want bool
+	}{
+		{1, 1, false},
+		{1, 2, true},
+		{1, 27, true},
+		{3, 13, true},
+		{2, 13, false},
+		{13, 3, false},
+		{0, 5, false},
+		{5, 0, false},
+	}
+	for _, test := range ancestors {
+		if got := test.s.IsAncestorOf(test.t); got != test.want {
+			t.Errorf("%d.IsAncestorOf(%d) got %v", test.s, test.t, got)
+		}
+	}
+}
+
+func TestSubscribeStreams(t *testing.T) {
+	addr, err := bmutil.DecodeAddress("BM-2cV9RshwouuVKWLBoyH5cghj3kMfw5G7BJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := bmutil.SubscribeStreams([]bmutil.Address{addr, addr})
+	if want := []bmutil.Stream{1, 2, 3}; !reflect.DeepEqual(streams, want) {
+		t.Errorf("got %v want %v", streams, want)
+	}
+	if streams = bmutil.SubscribeStreams(nil); len(streams) != 0 {
+		t.Errorf("no addresses: got %v", streams)
+	}
+}
@@ -9,18 +9,26 @@
 package bmutil
 
 import (
+	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"math"
+
+	"github.com/DanielKrawisz/bmutil/internal/lengthcheck"
 )
 
 // MaxVarIntSize is the maximum size of a variable length integer.
 const MaxVarIntSize = 9
 
 // ReadVarInt reads a variable length integer from r and returns it as a uint64.
+// If r is an io.ByteReader, such as a *bytes.Reader, it is read a byte at a
+// time so that nothing is allocated.
 func ReadVarInt(r io.Reader) (uint64, error) {
+	if br, ok := r.(io.ByteReader); ok {
+		return readVarIntBytes(br)
+	}
+
 	var b [8]byte
 	_, err := io.ReadFull(r, b[0:1])
 	if err != nil {
@@ -58,34 +66,107 @@ func ReadVarInt(r io.Reader) (uint64, error) {
 	return rv, nil
 }
 
-// WriteVarInt serializes val to w using a variable number of bytes depending
-// on its value.
-func WriteVarInt(w io.Writer, val uint64) error {
-	if val < 0xfd {
-		_, err := w.Write([]byte{uint8(val)})
-		return err
+// readVarIntBytes is ReadVarInt for an io.ByteReader. The errors are the
+// same as those io.ReadFull would give.
+func readVarIntBytes(r io.ByteReader) (uint64, error) {
+	discriminant, err := r.ReadByte()
+	if err != nil {
+		return 0, err
 	}
 
-	if val <= math.MaxUint16 {
+	n := varIntPayloadSize(discriminant)
+	rv := uint64(discriminant)
+	if n > 0 {
+		rv = 0
+	}
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err == io.EOF && i > 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		rv = rv<<8 | uint64(b)
+	}
+	return rv, nil
+}
+
+// varIntPayloadSize returns the number of bytes which follow the given
+// discriminant in a variable length integer.
+func varIntPayloadSize(discriminant byte) int {
+	switch discriminant {
+	case 0xff:
+		return 8
+	case 0xfe:
+		return 4
+	case 0xfd:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// ReadVarIntBuf reads a variable length integer from the start of b. It
+// returns the integer and the number of bytes it took up. If b is too short
+// the error is the one ReadVarInt would give: io.EOF if b ends before the
+// discriminant or just after it, and io.ErrUnexpectedEOF otherwise.
+func ReadVarIntBuf(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, io.EOF
+	}
+
+	switch n := varIntPayloadSize(b[0]); {
+	case n == 0:
+		return uint64(b[0]), 1, nil
+	case len(b) == 1:
+		return 0, 0, io.EOF
+	case len(b) < n+1:
+		return 0, 0, io.ErrUnexpectedEOF
+	case n == 2:
+		return uint64(binary.BigEndian.Uint16(b[1:])), 3, nil
+	case n == 4:
+		return uint64(binary.BigEndian.Uint32(b[1:])), 5, nil
+	default:
+		return binary.BigEndian.Uint64(b[1:]), 9, nil
+	}
+}
+
+// AppendVarInt appends val to b as a variable length integer and returns the
+// extended slice.
+func AppendVarInt(b []byte, val uint64) []byte {
+	switch {
+	case val < 0xfd:
+		return append(b, uint8(val))
+	case val <= math.MaxUint16:
 		var buf [3]byte
 		buf[0] = 0xfd
 		binary.BigEndian.PutUint16(buf[1:], uint16(val))
-		_, err := w.Write(buf[:])
-		return err
-	}
-
-	if val <= math.MaxUint32 {
+		return append(b, buf[:]...)
+	case val <= math.MaxUint32:
 		var buf [5]byte
 		buf[0] = 0xfe
 		binary.BigEndian.PutUint32(buf[1:], uint32(val))
-		_, err := w.Write(buf[:])
-		return err
+		return append(b, buf[:]...)
+	default:
+		var buf [9]byte
+		buf[0] = 0xff
+		binary.BigEndian.PutUint64(buf[1:], val)
+		return append(b, buf[:]...)
 	}
+}
 
-	var buf [9]byte
-	buf[0] = 0xff
-	binary.BigEndian.PutUint64(buf[1:], val)
-	_, err := w.Write(buf[:])
+// WriteVarInt serializes val to w using a variable number of bytes depending
+// on its value. If w is a *bytes.Buffer, nothing is allocated.
+func WriteVarInt(w io.Writer, val uint64) error {
+	if b, ok := w.(*bytes.Buffer); ok {
+		var buf [MaxVarIntSize]byte
+		b.Write(AppendVarInt(buf[:0], val))
+		return nil
+	}
+
+	var buf [MaxVarIntSize]byte
+	_, err := w.Write(AppendVarInt(buf[:0], val))
 	return err
 }
 
@@ -112,26 +193,55 @@ func VarIntSerializeSize(val uint64) int {
 	return 9
 }
 
-// ReadVarString reads a variable length string from r and returns it as a Go
-// string. A varString is encoded as a varInt containing the length of the
-// string, and the bytes that represent the string itself. An error is returned
-// if the length is greater than the maximum block payload size, since it would
-// not be possible to put a varString of that size into a block anyways and it
-// also helps protect against memory exhaustion attacks and forced panics
-// through malformed messages.
-func ReadVarString(r io.Reader, maxAllowed int) (string, error) {
+// LengthError is returned by ReadVarString and ReadVarBytes when the length
+// read is greater than the maximum allowed.
+type LengthError struct {
+	Field  string
+	Length uint64
+	Max    int
+}
+
+// Error returns a human-readable description of the error.
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("%s is larger than the max allowed size "+
+		"[count %d, max %d]", e.Field, e.Length, e.Max)
+}
+
+// readVarLength reads the length of a variable length string or byte array
+// and checks it against maxAllowed and against what is left in r.
+func readVarLength(r io.Reader, maxAllowed int, fieldName string) (uint64, error) {
 	count, err := ReadVarInt(r)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
-	// Prevent variable length strings that are larger than the specified limit.
-	// It would be possible to cause memory exhaustion and panics without a sane
-	// upper bound on this count.
+	// Prevent lengths larger than the specified limit. It would be
+	// possible to cause memory exhaustion and panics without a sane upper
+	// bound on this count.
 	if count > uint64(maxAllowed) {
-		str := fmt.Sprintf("variable length string is too long "+
-			"[count %d, max %d]", count, maxAllowed)
-		return "", errors.New(str)
+		return 0, &LengthError{fieldName, count, maxAllowed}
+	}
+	// Don't allocate more than the rest of the input can fill. The error
+	// is the one io.ReadFull would give.
+	if lengthcheck.ExceedsInput(r, count) {
+		if lengthcheck.ExceedsInput(r, 1) {
+			// Nothing is left at all.
+			return 0, io.EOF
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return count, nil
+}
+
+// ReadVarString reads a variable length string from r and returns it as a Go
+// string. A varString is encoded as a varInt containing the length of the
+// string, and the bytes that represent the string itself. A *LengthError is
+// returned if the length is greater than maxAllowed, which protects against
+// memory exhaustion attacks and forced panics through malformed messages.
+func ReadVarString(r io.Reader, maxAllowed int) (string, error) {
+	count, err := readVarLength(r, maxAllowed, "variable length string")
+	if err != nil {
+		return "", err
 	}
 
 	buf := make([]byte, count)
@@ -158,7 +268,7 @@ func WriteVarString(w io.Writer, str string) error {
 
 // ReadVarBytes reads a variable length byte array. A byte array is encoded
 // as a varInt containing the length of the array followed by the bytes
-// themselves. An error is returned if the length is greater than the
+// themselves. A *LengthError is returned if the length is greater than the
 // passed maxAllowed parameter which helps protect against memory exhuastion
 // attacks and forced panics thorugh malformed messages. The fieldName
 // parameter is only used for the error message so it provides more context in
@@ -166,20 +276,11 @@ func WriteVarString(w io.Writer, str string) error {
 func ReadVarBytes(r io.Reader, maxAllowed int,
 	fieldName string) ([]byte, error) {
 
-	count, err := ReadVarInt(r)
+	count, err := readVarLength(r, maxAllowed, fieldName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Prevent byte array larger than the max message size.  It would
-	// be possible to cause memory exhaustion and panics without a sane
-	// upper bound on this count.
-	if count > uint64(maxAllowed) {
-		str := fmt.Sprintf("%s is larger than the max allowed size "+
-			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, errors.New(str)
-	}
-
 	b := make([]byte, count)
 	_, err = io.ReadFull(r, b)
 	if err != nil {
@@ -84,6 +84,100 @@ func TestVarIntWire(t *testing.T) {
 	}
 }
 
+// TestVarIntBuf tests the byte slice forms of variable length integer
+// encoding against the io forms.
+func TestVarIntBuf(t *testing.T) {
+	values := []uint64{0, 0xfc, 0xfd, 0xffff, 0x10000, 0xffffffff,
+		0x100000000, 0xffffffffffffffff}
+
+	for i, val := range values {
+		var buf bytes.Buffer
+		bmutil.WriteVarInt(&buf, val)
+		encoded := bmutil.AppendVarInt([]byte{0xaa}, val)
+		if !bytes.Equal(encoded[1:], buf.Bytes()) || encoded[0] != 0xaa {
+			t.Errorf("AppendVarInt #%d got %x, want %x", i, encoded[1:], buf.Bytes())
+		}
+
+		got, n, err := bmutil.ReadVarIntBuf(append(encoded[1:], 0xbb))
+		if err != nil || got != val || n != len(encoded)-1 {
+			t.Errorf("ReadVarIntBuf #%d got %d, %d, %v", i, got, n, err)
+		}
+
+		if _, _, err = bmutil.ReadVarIntBuf(encoded[1 : len(encoded)-1]); len(encoded) > 2 &&
+			err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadVarIntBuf #%d of truncated input got error %v", i, err)
+		}
+
+		// A truncated varint gives the same error from a bytes.Reader.
+		if len(encoded) > 2 {
+			_, err = bmutil.ReadVarInt(bytes.NewReader(encoded[1 : len(encoded)-1]))
+			if err != io.ErrUnexpectedEOF {
+				t.Errorf("ReadVarInt #%d of truncated input got error %v", i, err)
+			}
+		}
+	}
+
+	if _, _, err := bmutil.ReadVarIntBuf(nil); err != io.EOF {
+		t.Errorf("ReadVarIntBuf of empty input got error %v", err)
+	}
+	if _, err := bmutil.ReadVarInt(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("ReadVarInt of empty input got error %v", err)
+	}
+}
+
+// TestVarIntShortInput checks that a truncated varint gives the same error
+// whether it is read from a plain io.Reader, an io.ByteReader or a slice.
+func TestVarIntShortInput(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		err error
+	}{
+		{nil, io.EOF},
+		{[]byte{0xfd}, io.EOF},
+		{[]byte{0xfe}, io.EOF},
+		{[]byte{0xff}, io.EOF},
+		{[]byte{0xfd, 0x01}, io.ErrUnexpectedEOF},
+		{[]byte{0xfe, 0x01, 0x02}, io.ErrUnexpectedEOF},
+		{[]byte{0xff, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}, io.ErrUnexpectedEOF},
+	}
+
+	for i, test := range tests {
+		// Hide the ReadByte method of the bytes.Reader.
+		plain := struct{ io.Reader }{bytes.NewReader(test.in)}
+		if _, err := bmutil.ReadVarInt(plain); err != test.err {
+			t.Errorf("ReadVarInt #%d from a reader got %v, want %v", i, err, test.err)
+		}
+		if _, err := bmutil.ReadVarInt(bytes.NewReader(test.in)); err != test.err {
+			t.Errorf("ReadVarInt #%d from a byte reader got %v, want %v", i, err, test.err)
+		}
+		if _, _, err := bmutil.ReadVarIntBuf(test.in); err != test.err {
+			t.Errorf("ReadVarIntBuf #%d got %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+// TestVarIntAllocs checks that reading from a bytes.Reader and writing to a
+// bytes.Buffer allocate nothing.
+func TestVarIntAllocs(t *testing.T) {
+	encoded := []byte{0xfe, 0x00, 0x01, 0x00, 0x00}
+	r := bytes.NewReader(encoded)
+	if n := testing.AllocsPerRun(100, func() {
+		r.Reset(encoded)
+		bmutil.ReadVarInt(r)
+	}); n != 0 {
+		t.Errorf("ReadVarInt made %v allocations", n)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(bmutil.MaxVarIntSize)
+	if n := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		bmutil.WriteVarInt(&buf, 0x10000)
+	}); n != 0 {
+		t.Errorf("WriteVarInt made %v allocations", n)
+	}
+}
+
 // TestVarIntWireErrors performs negative tests against encode and decode
 // of variable length integers to confirm error paths work correctly.
 func TestVarIntWireErrors(t *testing.T) {
@@ -396,3 +490,27 @@ func TestVarBytesOverflowErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestVarBytesLengthError checks that a length over the maximum is reported
+// as a *LengthError naming the field.
+func TestVarBytesLengthError(t *testing.T) {
+	buf := []byte{0x05, 0x01, 0x02, 0x03, 0x04, 0x05}
+
+	_, err := bmutil.ReadVarBytes(bytes.NewReader(buf), 4, "test payload")
+	le, ok := err.(*bmutil.LengthError)
+	if !ok {
+		t.Fatalf("ReadVarBytes got error %v, want *LengthError", err)
+	}
+	if le.Field != "test payload" || le.Length != 5 || le.Max != 4 {
+		t.Errorf("ReadVarBytes got %+v", le)
+	}
+
+	_, err = bmutil.ReadVarString(bytes.NewReader(buf), 4)
+	if _, ok := err.(*bmutil.LengthError); !ok {
+		t.Errorf("ReadVarString got error %v, want *LengthError", err)
+	}
+
+	if _, err = bmutil.ReadVarBytes(bytes.NewReader(buf), 5, "test payload"); err != nil {
+		t.Errorf("ReadVarBytes got error %v", err)
+	}
+}
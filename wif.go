@@ -9,12 +9,12 @@
 package bmutil
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
 
+	"github.com/DanielKrawisz/bmutil/base58"
 	"github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcutil/base58"
 )
 
 // ErrMalformedPrivateKey describes an error where a WIF-encoded private
@@ -23,66 +23,130 @@ import (
 // encountered.
 var ErrMalformedPrivateKey = errors.New("malformed private key")
 
-const wifPrefix = 0x80
+// WIFPrefix is the prefix byte of the keys used by Bitmessage, which is the
+// same as that of Bitcoin's main network.
+const WIFPrefix = 0x80
 
-// DecodeWIF creates a btcec.PrivateKey by decoding the string encoding of
-// the import format. It only supports uncompressed keys.
+// wifCompressMagic follows the private key in a WIF string for a key whose
+// public key is to be compressed.
+const wifCompressMagic = 0x01
+
+// WIF is a private key in the Wallet Import Format along with the prefix
+// byte and compression flag it is encoded with. Bitmessage itself uses only
+// uncompressed keys with WIFPrefix, but keys exchanged with other tools may
+// have either flag and a different prefix, such as that of a test network.
+type WIF struct {
+	PrivKey        *btcec.PrivateKey
+	CompressPubKey bool
+	Prefix         byte
+}
+
+// NewWIF returns a WIF for a private key with the given prefix byte and
+// compression flag.
+func NewWIF(privKey *btcec.PrivateKey, prefix byte, compress bool) *WIF {
+	return &WIF{
+		PrivKey:        privKey,
+		CompressPubKey: compress,
+		Prefix:         prefix,
+	}
+}
+
+// DecodeWIFExtended decodes a WIF string with any prefix byte, with or
+// without the compression flag.
 //
 // The WIF string must be a base58-encoded string of the following byte
 // sequence:
 //
-//  * 1 byte to identify the network, must be 0x80
+//  * 1 byte to identify the network, such as WIFPrefix
 //  * 32 bytes of a binary-encoded, big-endian, zero-padded private key
+//  * optionally 1 byte equal to 0x01 if the public key is compressed
 //  * 4 bytes of checksum, must equal the first four bytes of the double SHA256
 //    of every byte before the checksum in this sequence
 //
-// If the base58-decoded byte sequence does not match this, DecodeWIF will
-// return a non-nil error. ErrMalformedPrivateKey is returned when the WIF
-// is of an impossible length or the expected compressed pubkey magic number
-// does not equal the expected value of 0x01. ErrChecksumMismatch is returned
-// if the expected WIF checksum does not match the calculated checksum.
-func DecodeWIF(wif string) (*btcec.PrivateKey, error) {
+// If the base58-decoded byte sequence does not match this, DecodeWIFExtended
+// will return a non-nil error. ErrMalformedPrivateKey is returned when the WIF
+// is of an impossible length or the compressed pubkey magic number does not
+// equal the expected value of 0x01. ErrChecksumMismatch is returned if the
+// expected WIF checksum does not match the calculated checksum.
+func DecodeWIFExtended(wif string) (*WIF, error) {
 	decoded := base58.Decode(wif)
 	decodedLen := len(decoded)
 
-	// Length of base58 decoded WIF must be 32 bytes + 1 byte for netID +
-	// 4 bytes of checksum.
-	if decodedLen != 1+btcec.PrivKeyBytesLen+4 || decoded[0] != wifPrefix {
+	var compress bool
+	switch decodedLen {
+	case 1 + btcec.PrivKeyBytesLen + 1 + 4:
+		if decoded[1+btcec.PrivKeyBytesLen] != wifCompressMagic {
+			return nil, ErrMalformedPrivateKey
+		}
+		compress = true
+	case 1 + btcec.PrivKeyBytesLen + 4:
+	default:
 		return nil, ErrMalformedPrivateKey
 	}
 
-	// Checksum is first four bytes of double SHA256 of the identifier byte
-	// and privKey.  Verify this matches the final 4 bytes of the decoded
-	// private key.
-	tosum := decoded[:1+btcec.PrivKeyBytesLen]
+	// Checksum is first four bytes of double SHA256 of everything before
+	// it. Verify this matches the final 4 bytes of the decoded private key.
+	tosum := decoded[:decodedLen-4]
 
 	cksum := doubleSha256(tosum)[:4]
-	if !bytes.Equal(cksum, decoded[decodedLen-4:]) {
+	if subtle.ConstantTimeCompare(cksum, decoded[decodedLen-4:]) != 1 {
 		return nil, ErrChecksumMismatch
 	}
 
 	privKeyBytes := decoded[1 : 1+btcec.PrivKeyBytesLen]
 	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privKeyBytes)
-	return privKey, nil
+	return NewWIF(privKey, decoded[0], compress), nil
 }
 
-// EncodeWIF creates the Wallet Import Format string encoding of a WIF
-// structure. See DecodeWIF for a detailed breakdown of the format and
-// requirements of a valid WIF string.
-func EncodeWIF(privKey *btcec.PrivateKey) string {
+// String returns the Wallet Import Format string encoding of the key. See
+// DecodeWIFExtended for a detailed breakdown of the format.
+func (w *WIF) String() string {
 	// Precalculate size. Number of bytes before base58 encoding
-	// is one byte for the network, 32 bytes of private key and four
-	// bytes of checksum.
-	a := make([]byte, 0, 1+btcec.PrivKeyBytesLen+4)
-	a = append(a, wifPrefix)
+	// is one byte for the network, 32 bytes of private key, possibly one
+	// extra byte if the pubkey is to be compressed, and finally four bytes
+	// of checksum.
+	encodeLen := 1 + btcec.PrivKeyBytesLen + 4
+	if w.CompressPubKey {
+		encodeLen++
+	}
+
+	a := make([]byte, 0, encodeLen)
+	a = append(a, w.Prefix)
 	// Pad and append bytes manually, instead of using Serialize, to
 	// avoid another call to make.
-	a = paddedAppend(btcec.PrivKeyBytesLen, a, privKey.D.Bytes())
+	a = paddedAppend(btcec.PrivKeyBytesLen, a, w.PrivKey.D.Bytes())
+	if w.CompressPubKey {
+		a = append(a, wifCompressMagic)
+	}
 	cksum := doubleSha256(a)[:4]
 	a = append(a, cksum...)
 	return base58.Encode(a)
 }
 
+// DecodeWIF creates a btcec.PrivateKey by decoding the string encoding of
+// the import format. The prefix byte must be WIFPrefix. Keys with the
+// compression flag are accepted, since the flag only says how the public key
+// is to be serialized and Bitmessage always uses uncompressed public keys.
+// Use DecodeWIFExtended for keys with other prefixes or to see the flag.
+func DecodeWIF(wif string) (*btcec.PrivateKey, error) {
+	w, err := DecodeWIFExtended(wif)
+	if err != nil {
+		return nil, err
+	}
+	if w.Prefix != WIFPrefix {
+		return nil, ErrMalformedPrivateKey
+	}
+	return w.PrivKey, nil
+}
+
+// EncodeWIF creates the Wallet Import Format string encoding of a private
+// key with WIFPrefix and without the compression flag, which is the form
+// Bitmessage uses. See DecodeWIFExtended for a detailed breakdown of the
+// format.
+func EncodeWIF(privKey *btcec.PrivateKey) string {
+	return NewWIF(privKey, WIFPrefix, false).String()
+}
+
 // paddedAppend appends the src byte slice to dst, returning the new slice.
 // If the length of the source is smaller than the passed size, leading zero
 // bytes are appended to the dst slice before appending src.
@@ -65,3 +65,71 @@ func TestEncodeDecodeWIF(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeWIFExtended(t *testing.T) {
+	priv1, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{
+		0x0c, 0x28, 0xfc, 0xa3, 0x86, 0xc7, 0xa2, 0x27,
+		0x60, 0x0b, 0x2f, 0xe5, 0x0b, 0x7c, 0xae, 0x11,
+		0xec, 0x86, 0xd3, 0xbf, 0x1f, 0xbe, 0x47, 0x1b,
+		0xe8, 0x98, 0x27, 0xe1, 0x9d, 0x72, 0xaa, 0x1d})
+
+	priv2, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{
+		0xdd, 0xa3, 0x5a, 0x14, 0x88, 0xfb, 0x97, 0xb6,
+		0xeb, 0x3f, 0xe6, 0xe9, 0xef, 0x2a, 0x25, 0x81,
+		0x4e, 0x39, 0x6f, 0xb5, 0xdc, 0x29, 0x5f, 0xe9,
+		0x94, 0xb9, 0x67, 0x89, 0xb2, 0x1a, 0x03, 0x98})
+
+	tests := []struct {
+		wif     *bmutil.WIF
+		encoded string
+		decodes bool // Whether DecodeWIF accepts it.
+	}{
+		{
+			bmutil.NewWIF(priv1, bmutil.WIFPrefix, false),
+			"5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ",
+			true,
+		},
+		{
+			bmutil.NewWIF(priv1, bmutil.WIFPrefix, true),
+			"KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617",
+			true,
+		},
+		{
+			// Bitcoin's test network.
+			bmutil.NewWIF(priv2, 0xef, true),
+			"cV1Y7ARUr9Yx7BR55nTdnR7ZXNJphZtCCMBTEZBJe1hXt2kB684q",
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		if s := test.wif.String(); s != test.encoded {
+			t.Errorf("String #%d got %s want %s", i, s, test.encoded)
+			continue
+		}
+
+		w, err := bmutil.DecodeWIFExtended(test.encoded)
+		if err != nil {
+			t.Errorf("DecodeWIFExtended #%d error %v", i, err)
+			continue
+		}
+		if w.Prefix != test.wif.Prefix || w.CompressPubKey != test.wif.CompressPubKey ||
+			!bytes.Equal(w.PrivKey.D.Bytes(), test.wif.PrivKey.D.Bytes()) {
+			t.Errorf("DecodeWIFExtended #%d got %+v want %+v", i, w, test.wif)
+		}
+
+		_, err = bmutil.DecodeWIF(test.encoded)
+		if test.decodes && err != nil {
+			t.Errorf("DecodeWIF #%d error %v", i, err)
+		}
+		if !test.decodes && err != bmutil.ErrMalformedPrivateKey {
+			t.Errorf("DecodeWIF #%d got %v want %v", i, err,
+				bmutil.ErrMalformedPrivateKey)
+		}
+	}
+
+	// A compressed key with the wrong magic byte.
+	if _, err := bmutil.DecodeWIFExtended("KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98618"); err == nil {
+		t.Error("DecodeWIFExtended accepted a corrupted key")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// byteOrder is the byte order of every fixed size integer in the bitmessage
+// protocol. All integer serialization in this package goes through the
+// helpers below so that there is exactly one place where it is decided.
+var byteOrder = binary.BigEndian
+
+// readUint16 reads a big endian uint16 from r.
+func readUint16(r io.Reader) (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return byteOrder.Uint16(b[:]), nil
+}
+
+// readUint32 reads a big endian uint32 from r.
+func readUint32(r io.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return byteOrder.Uint32(b[:]), nil
+}
+
+// readUint64 reads a big endian uint64 from r.
+func readUint64(r io.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return byteOrder.Uint64(b[:]), nil
+}
+
+// writeUint16 writes v to w in big endian order.
+func writeUint16(w io.Writer, v uint16) error {
+	var b [2]byte
+	byteOrder.PutUint16(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// writeUint32 writes v to w in big endian order.
+func writeUint32(w io.Writer, v uint32) error {
+	var b [4]byte
+	byteOrder.PutUint32(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// writeUint64 writes v to w in big endian order.
+func writeUint64(w io.Writer, v uint64) error {
+	var b [8]byte
+	byteOrder.PutUint64(b[:], v)
+	_, err := w.Write(b[:])
+	return err
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/fixed"
+)
+
+// TestCodecByteOrder ensures that the integer helpers write the most
+// significant byte first and read back exactly what they wrote.
+func TestCodecByteOrder(t *testing.T) {
+	tests := []struct {
+		in  uint64
+		u16 []byte
+		u32 []byte
+		u64 []byte
+	}{
+		{0, []byte{0, 0}, []byte{0, 0, 0, 0}, []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+		{1, []byte{0, 1}, []byte{0, 0, 0, 1}, []byte{0, 0, 0, 0, 0, 0, 0, 1}},
+		{0x0102, []byte{1, 2}, []byte{0, 0, 1, 2}, []byte{0, 0, 0, 0, 0, 0, 1, 2}},
+		{0x01020304, nil, []byte{1, 2, 3, 4}, []byte{0, 0, 0, 0, 1, 2, 3, 4}},
+		{0x0102030405060708, nil, nil, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{math.MaxUint16, []byte{0xff, 0xff}, []byte{0, 0, 0xff, 0xff},
+			[]byte{0, 0, 0, 0, 0, 0, 0xff, 0xff}},
+		{math.MaxUint32, nil, []byte{0xff, 0xff, 0xff, 0xff},
+			[]byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}},
+		{math.MaxUint64, nil, nil,
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if test.u16 != nil {
+			wire.TstWriteUint16(&buf, uint16(test.in))
+			if !bytes.Equal(buf.Bytes(), test.u16) {
+				t.Errorf("writeUint16 #%d got %x want %x", i, buf.Bytes(), test.u16)
+			}
+			v, err := wire.TstReadUint16(&buf)
+			if err != nil || uint64(v) != test.in {
+				t.Errorf("readUint16 #%d got %d, %v want %d", i, v, err, test.in)
+			}
+		}
+
+		if test.u32 != nil {
+			wire.TstWriteUint32(&buf, uint32(test.in))
+			if !bytes.Equal(buf.Bytes(), test.u32) {
+				t.Errorf("writeUint32 #%d got %x want %x", i, buf.Bytes(), test.u32)
+			}
+			v, err := wire.TstReadUint32(&buf)
+			if err != nil || uint64(v) != test.in {
+				t.Errorf("readUint32 #%d got %d, %v want %d", i, v, err, test.in)
+			}
+		}
+
+		wire.TstWriteUint64(&buf, test.in)
+		if !bytes.Equal(buf.Bytes(), test.u64) {
+			t.Errorf("writeUint64 #%d got %x want %x", i, buf.Bytes(), test.u64)
+		}
+		v, err := wire.TstReadUint64(&buf)
+		if err != nil || v != test.in {
+			t.Errorf("readUint64 #%d got %d, %v want %d", i, v, err, test.in)
+		}
+	}
+}
+
+// TestCodecRoundTrip writes and reads back every 16 bit value and a spread
+// of wider ones.
+func TestCodecRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 0; i <= math.MaxUint16; i++ {
+		wire.TstWriteUint16(&buf, uint16(i))
+		if v, _ := wire.TstReadUint16(&buf); v != uint16(i) {
+			t.Fatalf("uint16 round trip got %d want %d", v, i)
+		}
+	}
+
+	for shift := uint(0); shift < 64; shift++ {
+		for _, delta := range []uint64{0, 1, math.MaxUint64} {
+			n := uint64(1)<<shift + delta
+
+			wire.TstWriteUint32(&buf, uint32(n))
+			if v, _ := wire.TstReadUint32(&buf); v != uint32(n) {
+				t.Errorf("uint32 round trip got %d want %d", v, uint32(n))
+			}
+
+			wire.TstWriteUint64(&buf, n)
+			if v, _ := wire.TstReadUint64(&buf); v != n {
+				t.Errorf("uint64 round trip got %d want %d", v, n)
+			}
+		}
+	}
+}
+
+// TestCodecErrors ensures that short reads and writes are reported.
+func TestCodecErrors(t *testing.T) {
+	tests := []struct {
+		size int
+		max  int
+		err  error
+	}{
+		{2, 0, io.EOF},
+		{2, 1, io.ErrUnexpectedEOF},
+		{4, 0, io.EOF},
+		{4, 3, io.ErrUnexpectedEOF},
+		{8, 0, io.EOF},
+		{8, 7, io.ErrUnexpectedEOF},
+	}
+
+	for i, test := range tests {
+		var werr, rerr error
+		w, r := fixed.NewWriter(test.max), fixed.NewReader(test.max, nil)
+		switch test.size {
+		case 2:
+			werr = wire.TstWriteUint16(w, 1)
+			_, rerr = wire.TstReadUint16(r)
+		case 4:
+			werr = wire.TstWriteUint32(w, 1)
+			_, rerr = wire.TstReadUint32(r)
+		case 8:
+			werr = wire.TstWriteUint64(w, 1)
+			_, rerr = wire.TstReadUint64(r)
+		}
+		if werr != io.ErrShortWrite {
+			t.Errorf("write #%d got error %v want %v", i, werr, io.ErrShortWrite)
+		}
+		if rerr != test.err {
+			t.Errorf("read #%d got error %v want %v", i, rerr, test.err)
+		}
+	}
+}
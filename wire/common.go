@@ -13,65 +13,81 @@ import (
 	"encoding/binary"
 	"io"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 )
 
+// ReadVarBytes reads a variable length byte array like bmutil.ReadVarBytes,
+// except that a length greater than maxAllowed is reported as a
+// *MessageError from the function fn.
+func ReadVarBytes(r io.Reader, maxAllowed int, fieldName, fn string) ([]byte, error) {
+	b, err := bmutil.ReadVarBytes(r, maxAllowed, fieldName)
+	if le, ok := err.(*bmutil.LengthError); ok {
+		return nil, NewMessageError(fn, le.Error())
+	}
+	return b, err
+}
+
 // ReadElement reads the next sequence of bytes from r using big endian
-// depending on the concrete type of element pointed to.
+// depending on the concrete type of element pointed to. Integer and boolean
+// elements are left untouched if the read fails.
 func ReadElement(r io.Reader, element interface{}) error {
-	var scratch [8]byte
+	var err error
 
 	// Attempt to read the element based on the concrete type via fast
 	// type assertions first.
 	switch e := element.(type) {
+	case *uint16:
+		v, err := readUint16(r)
+		if err != nil {
+			return err
+		}
+		*e = v
+		return nil
+
 	case *int32:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint32(r)
 		if err != nil {
 			return err
 		}
-		*e = int32(binary.BigEndian.Uint32(b))
+		*e = int32(v)
 		return nil
 
 	case *uint32:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint32(r)
 		if err != nil {
 			return err
 		}
-		*e = binary.BigEndian.Uint32(b)
+		*e = v
 		return nil
 
 	case *ObjectType:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint32(r)
 		if err != nil {
 			return err
 		}
-		*e = ObjectType(binary.BigEndian.Uint32(b))
+		*e = ObjectType(v)
 		return nil
 
 	case *int64:
-		b := scratch[0:8]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint64(r)
 		if err != nil {
 			return err
 		}
-		*e = int64(binary.BigEndian.Uint64(b))
+		*e = int64(v)
 		return nil
 
 	case *uint64:
-		b := scratch[0:8]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint64(r)
 		if err != nil {
 			return err
 		}
-		*e = binary.BigEndian.Uint64(b)
+		*e = v
 		return nil
 
 	case *bool:
-		b := scratch[0:1]
-		_, err := io.ReadFull(r, b)
+		var b [1]byte
+		_, err = io.ReadFull(r, b[:])
 		if err != nil {
 			return err
 		}
@@ -84,71 +100,51 @@ func ReadElement(r io.Reader, element interface{}) error {
 
 	// Message header checksum.
 	case *[4]byte:
-		_, err := io.ReadFull(r, e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = io.ReadFull(r, e[:])
+		return err
 
 	// Message header command.
 	case *[CommandSize]byte:
-		_, err := io.ReadFull(r, e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = io.ReadFull(r, e[:])
+		return err
 
 	// IP address.
 	case *[16]byte:
-		_, err := io.ReadFull(r, e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = io.ReadFull(r, e[:])
+		return err
 
 	case *hash.Sha:
-		_, err := io.ReadFull(r, e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = io.ReadFull(r, e[:])
+		return err
 
 	case *hash.Ripe:
-		_, err := io.ReadFull(r, e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = io.ReadFull(r, e[:])
+		return err
 
 	case *PubKey:
-		_, err := io.ReadFull(r, e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = io.ReadFull(r, e[:])
+		return err
 
 	case *ServiceFlag:
-		b := scratch[0:8]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint64(r)
 		if err != nil {
 			return err
 		}
-		*e = ServiceFlag(binary.BigEndian.Uint64(b))
+		*e = ServiceFlag(v)
 		return nil
 
 	case *BitmessageNet:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		v, err := readUint32(r)
 		if err != nil {
 			return err
 		}
-		*e = BitmessageNet(binary.BigEndian.Uint32(b))
+		*e = BitmessageNet(v)
 		return nil
 	}
 
 	// Fall back to the slower binary.Read if a fast path was not available
 	// above.
-	return binary.Read(r, binary.BigEndian, element)
+	return binary.Read(r, byteOrder, element)
 }
 
 // ReadElements reads multiple items from r.  It is equivalent to multiple
@@ -165,136 +161,74 @@ func ReadElements(r io.Reader, elements ...interface{}) error {
 
 // WriteElement writes the big endian representation of element to w.
 func WriteElement(w io.Writer, element interface{}) error {
-	var scratch [8]byte
+	var err error
 
 	// Attempt to write the element based on the concrete type via fast
 	// type assertions first.
 	switch e := element.(type) {
+	case uint16:
+		return writeUint16(w, e)
+
 	case int32:
-		b := scratch[0:4]
-		binary.BigEndian.PutUint32(b, uint32(e))
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint32(w, uint32(e))
 
 	case uint32:
-		b := scratch[0:4]
-		binary.BigEndian.PutUint32(b, e)
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint32(w, e)
 
 	case ObjectType:
-		b := scratch[0:4]
-		binary.BigEndian.PutUint32(b, uint32(e))
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint32(w, uint32(e))
 
 	case int64:
-		b := scratch[0:8]
-		binary.BigEndian.PutUint64(b, uint64(e))
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint64(w, uint64(e))
 
 	case uint64:
-		b := scratch[0:8]
-		binary.BigEndian.PutUint64(b, e)
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint64(w, e)
 
 	case bool:
-		b := scratch[0:1]
-		if e == true {
+		b := [1]byte{0x00}
+		if e {
 			b[0] = 0x01
-		} else {
-			b[0] = 0x00
-		}
-		_, err := w.Write(b)
-		if err != nil {
-			return err
 		}
-		return nil
+		_, err = w.Write(b[:])
+		return err
 
 	// Message header checksum.
 	case [4]byte:
-		_, err := w.Write(e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = w.Write(e[:])
+		return err
 
 	// Message header command.
 	case [CommandSize]uint8:
-		_, err := w.Write(e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = w.Write(e[:])
+		return err
 
 	// IP address.
 	case [16]byte:
-		_, err := w.Write(e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = w.Write(e[:])
+		return err
 
 	case *hash.Sha:
-		_, err := w.Write(e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = w.Write(e[:])
+		return err
 
 	case *hash.Ripe:
-		_, err := w.Write(e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = w.Write(e[:])
+		return err
 
 	case *PubKey:
-		_, err := w.Write(e[:])
-		if err != nil {
-			return err
-		}
-		return nil
+		_, err = w.Write(e[:])
+		return err
 
 	case ServiceFlag:
-		b := scratch[0:8]
-		binary.BigEndian.PutUint64(b, uint64(e))
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint64(w, uint64(e))
 
 	case BitmessageNet:
-		b := scratch[0:4]
-		binary.BigEndian.PutUint32(b, uint32(e))
-		_, err := w.Write(b)
-		if err != nil {
-			return err
-		}
-		return nil
+		return writeUint32(w, uint32(e))
 	}
 
 	// Fall back to the slower binary.Write if a fast path was not available
 	// above.
-	return binary.Write(w, binary.BigEndian, element)
+	return binary.Write(w, byteOrder, element)
 }
 
 // WriteElements writes multiple items to w.  It is equivalent to multiple
@@ -313,12 +247,7 @@ func WriteElements(w io.Writer, elements ...interface{}) error {
 // unexported version takes a reader primarily to ensure the error paths
 // can be properly tested by passing a fake reader in the tests.
 func randomUint64(r io.Reader) (uint64, error) {
-	var b [8]byte
-	_, err := io.ReadFull(r, b[:])
-	if err != nil {
-		return 0, err
-	}
-	return binary.BigEndian.Uint64(b[:]), nil
+	return readUint64(r)
 }
 
 // RandomUint64 returns a cryptographically random uint64 value.
@@ -48,6 +48,7 @@ func TestElementWire(t *testing.T) {
 		in  interface{} // Value to encode
 		buf []byte      // Wire encoding
 	}{
+		{uint16(258), []byte{0x01, 0x02}},
 		{int32(1), []byte{0x00, 0x00, 0x00, 0x01}},
 		{uint32(256), []byte{0x00, 0x00, 0x01, 0x00}},
 		{
@@ -182,9 +183,13 @@ func TestElementWireErrors(t *testing.T) {
 		writeErr error       // Expected write error
 		readErr  error       // Expected read error
 	}{
+		{uint16(258), 0, io.ErrShortWrite, io.EOF},
+		{uint16(258), 1, io.ErrShortWrite, io.ErrUnexpectedEOF},
 		{int32(1), 0, io.ErrShortWrite, io.EOF},
 		{uint32(256), 0, io.ErrShortWrite, io.EOF},
+		{uint32(256), 3, io.ErrShortWrite, io.ErrUnexpectedEOF},
 		{int64(65536), 0, io.ErrShortWrite, io.EOF},
+		{uint64(65536), 7, io.ErrShortWrite, io.ErrUnexpectedEOF},
 		{true, 0, io.ErrShortWrite, io.EOF},
 		{[4]byte{0x01, 0x02, 0x03, 0x04}, 0, io.ErrShortWrite, io.EOF},
 		{
@@ -248,6 +253,38 @@ func TestElementWireErrors(t *testing.T) {
 	}
 }
 
+// TestElementErrorsUntouched ensures that a failed read leaves the element as
+// it was.
+func TestElementErrorsUntouched(t *testing.T) {
+	u16, i32, u32, i64, u64 := uint16(1), int32(2), uint32(3), int64(4), uint64(5)
+	ot, b := wire.ObjectType(6), true
+	sf, net := wire.SFNodeNetwork, wire.MainNet
+	tests := []struct {
+		element interface{}
+		want    interface{}
+	}{
+		{&u16, uint16(1)},
+		{&i32, int32(2)},
+		{&u32, uint32(3)},
+		{&ot, wire.ObjectType(6)},
+		{&i64, int64(4)},
+		{&u64, uint64(5)},
+		{&b, true},
+		{&sf, wire.SFNodeNetwork},
+		{&net, wire.MainNet},
+	}
+
+	for i, test := range tests {
+		err := wire.TstReadElement(bytes.NewReader(nil), test.element)
+		if err != io.ErrUnexpectedEOF && err != io.EOF {
+			t.Errorf("readElement #%d got error %v", i, err)
+		}
+		if got := reflect.ValueOf(test.element).Elem().Interface(); got != test.want {
+			t.Errorf("readElement #%d changed element to %v", i, got)
+		}
+	}
+}
+
 // TestRandomUint64 exercises the randomness of the random number generator on
 // the system by ensuring the probability of the generated numbers. If the RNG
 // is evenly distributed as a proper cryptographic RNG should be, there really
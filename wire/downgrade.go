@@ -0,0 +1,130 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PeerVersion is what is remembered about the version messages of a peer
+// between connections.
+type PeerVersion struct {
+	// ProtocolVersion is the highest protocol version the peer has
+	// advertised.
+	ProtocolVersion int32
+
+	// Services are the services the peer advertised along with its
+	// highest protocol version, the last time it did so.
+	Services ServiceFlag
+}
+
+// PeerVersionStore is storage, provided by the caller, for the PeerVersion of
+// each peer. Peers are identified by whatever string the caller uses for
+// them, such as host:port.
+type PeerVersionStore interface {
+	PeerVersion(peer string) (PeerVersion, bool)
+	SetPeerVersion(peer string, v PeerVersion)
+}
+
+// DowngradeWarning is returned by CheckDowngrade when a peer advertises a
+// lower protocol version or fewer services than it did before. This can mean
+// that someone between us and the peer is tampering with the handshake to
+// stop features from being used. It can also be innocent, as when the peer
+// rolls back its software, so it is left to the caller to decide what to do.
+type DowngradeWarning struct {
+	Peer     string
+	Previous PeerVersion
+	Current  PeerVersion
+}
+
+// Missing returns the services the peer advertised before but not now.
+func (w *DowngradeWarning) Missing() ServiceFlag {
+	return w.Previous.Services &^ w.Current.Services
+}
+
+// Error returns a human-readable description of the warning.
+func (w *DowngradeWarning) Error() string {
+	if w.Current.ProtocolVersion < w.Previous.ProtocolVersion {
+		return fmt.Sprintf("peer %s downgraded from protocol version %d to %d",
+			w.Peer, w.Previous.ProtocolVersion, w.Current.ProtocolVersion)
+	}
+	return fmt.Sprintf("peer %s stopped advertising services %s", w.Peer,
+		w.Missing())
+}
+
+// CheckDowngrade compares the version message received from a peer with
+// what the store remembers about it and updates the store. It returns a
+// *DowngradeWarning if the protocol version is lower than the highest seen
+// before, or if services advertised along with that version are missing.
+//
+// A lower protocol version is reported every time until the caller removes
+// the peer from the store, since the highest version is kept. Missing
+// services are only reported once, since a peer may turn a service off.
+func CheckDowngrade(store PeerVersionStore, peer string, msg *MsgVersion) *DowngradeWarning {
+	current := PeerVersion{
+		ProtocolVersion: msg.ProtocolVersion,
+		Services:        msg.Services,
+	}
+
+	prev, ok := store.PeerVersion(peer)
+	if !ok || current.ProtocolVersion > prev.ProtocolVersion {
+		store.SetPeerVersion(peer, current)
+		return nil
+	}
+
+	w := &DowngradeWarning{
+		Peer:     peer,
+		Previous: prev,
+		Current:  current,
+	}
+	if current.ProtocolVersion < prev.ProtocolVersion {
+		return w
+	}
+
+	store.SetPeerVersion(peer, current)
+	if w.Missing() != 0 {
+		return w
+	}
+	return nil
+}
+
+// PeerVersions is a PeerVersionStore kept in memory. It is safe for
+// concurrent use.
+type PeerVersions struct {
+	mtx   sync.Mutex
+	peers map[string]PeerVersion
+}
+
+// NewPeerVersions returns an empty PeerVersions.
+func NewPeerVersions() *PeerVersions {
+	return &PeerVersions{
+		peers: make(map[string]PeerVersion),
+	}
+}
+
+// PeerVersion returns what is stored for the peer. This is part of the
+// PeerVersionStore interface implementation.
+func (p *PeerVersions) PeerVersion(peer string) (PeerVersion, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	v, ok := p.peers[peer]
+	return v, ok
+}
+
+// SetPeerVersion stores v for the peer. This is part of the PeerVersionStore
+// interface implementation.
+func (p *PeerVersions) SetPeerVersion(peer string, v PeerVersion) {
+	p.mtx.Lock()
+	p.peers[peer] = v
+	p.mtx.Unlock()
+}
+
+// Forget removes the peer, so that a downgrade is no longer reported.
+func (p *PeerVersions) Forget(peer string) {
+	p.mtx.Lock()
+	delete(p.peers, peer)
+	p.mtx.Unlock()
+}
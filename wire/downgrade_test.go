@@ -0,0 +1,58 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestCheckDowngrade(t *testing.T) {
+	const peer = "127.0.0.1:8444"
+	store := wire.NewPeerVersions()
+
+	msg := func(version int32, services wire.ServiceFlag) *wire.MsgVersion {
+		return &wire.MsgVersion{ProtocolVersion: version, Services: services}
+	}
+	both := wire.SFNodeNetwork | wire.SFExtInvCap
+
+	tests := []struct {
+		msg       *wire.MsgVersion
+		downgrade bool
+		missing   wire.ServiceFlag
+	}{
+		{msg(3, both), false, 0},
+		{msg(3, both), false, 0},
+		{msg(2, both), true, 0},
+		{msg(2, both), true, 0}, // Still lower than the highest seen.
+		{msg(3, wire.SFNodeNetwork), true, wire.SFExtInvCap},
+		{msg(3, wire.SFNodeNetwork), false, 0}, // Only reported once.
+		{msg(4, 0), false, 0},
+	}
+
+	for i, test := range tests {
+		w := wire.CheckDowngrade(store, peer, test.msg)
+		if (w != nil) != test.downgrade {
+			t.Errorf("case %d: got warning %v", i, w)
+			continue
+		}
+		if w == nil {
+			continue
+		}
+		if w.Peer != peer || w.Missing() != test.missing || w.Error() == "" {
+			t.Errorf("case %d: got warning %+v", i, w)
+		}
+	}
+
+	// Once forgotten, a lower version is accepted.
+	store.Forget(peer)
+	if w := wire.CheckDowngrade(store, peer, msg(2, 0)); w != nil {
+		t.Errorf("forgotten peer got warning %v", w)
+	}
+	if v, ok := store.PeerVersion(peer); !ok || v.ProtocolVersion != 2 {
+		t.Errorf("got stored version %+v", v)
+	}
+}
@@ -32,6 +32,29 @@ func (e *MessageError) Error() string {
 	return e.Description
 }
 
+// OversizedMessageError is returned when reading a message with
+// DecodeOptions.SkipOversized set, for a message whose payload is larger than
+// the limit for it. If Discarded is true, the payload has been read and thrown
+// away and the next message can be read from the stream. Otherwise the
+// payload was too large to skip and the stream is no longer usable.
+type OversizedMessageError struct {
+	Command   string
+	Length    uint32
+	Max       int
+	Discarded bool
+}
+
+// Error returns a human-readable description of the error.
+func (e *OversizedMessageError) Error() string {
+	str := fmt.Sprintf("payload of [%s] message is too large - header "+
+		"indicates %d bytes, but max payload is %d bytes", e.Command,
+		e.Length, e.Max)
+	if e.Discarded {
+		str += " (discarded)"
+	}
+	return str
+}
+
 // NewMessageError creates an error for the given function and description.
 func NewMessageError(f string, desc string) *MessageError {
 	return &MessageError{Func: f, Description: desc}
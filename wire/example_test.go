@@ -0,0 +1,58 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// This example shows one node telling another about an object it has with
+// an inv message, and the other asking for it with getdata. A buffer stands
+// in for the connection between them.
+func Example_invExchange() {
+	var conn bytes.Buffer
+
+	// The first node announces the hash of an object.
+	var iv wire.InvVect
+	copy(iv[:], hash.Sha512([]byte("an object"))[:hash.ShaSize])
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(&iv)
+	if err := wire.WriteMessage(&conn, inv, wire.MainNet); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// The second reads it and asks for everything it doesn't have.
+	msg, _, err := wire.ReadMessage(&conn, wire.MainNet)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	getData := wire.NewMsgGetData()
+	for _, iv := range msg.(*wire.MsgInv).InvList {
+		getData.AddInvVect(iv)
+	}
+	if err = wire.WriteMessage(&conn, getData, wire.MainNet); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// The first node receives the request.
+	msg, _, err = wire.ReadMessage(&conn, wire.MainNet)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(msg.Command(), len(msg.(*wire.MsgGetData).InvList))
+	fmt.Println(*msg.(*wire.MsgGetData).InvList[0] == iv)
+
+	// Output:
+	// getdata 1
+	// true
+}
@@ -37,6 +37,42 @@ func TstWriteElement(w io.Writer, element interface{}) error {
 	return WriteElement(w, element)
 }
 
+// TstReadUint16 makes the internal readUint16 function available to the
+// test package.
+func TstReadUint16(r io.Reader) (uint16, error) {
+	return readUint16(r)
+}
+
+// TstReadUint32 makes the internal readUint32 function available to the
+// test package.
+func TstReadUint32(r io.Reader) (uint32, error) {
+	return readUint32(r)
+}
+
+// TstReadUint64 makes the internal readUint64 function available to the
+// test package.
+func TstReadUint64(r io.Reader) (uint64, error) {
+	return readUint64(r)
+}
+
+// TstWriteUint16 makes the internal writeUint16 function available to the
+// test package.
+func TstWriteUint16(w io.Writer, v uint16) error {
+	return writeUint16(w, v)
+}
+
+// TstWriteUint32 makes the internal writeUint32 function available to the
+// test package.
+func TstWriteUint32(w io.Writer, v uint32) error {
+	return writeUint32(w, v)
+}
+
+// TstWriteUint64 makes the internal writeUint64 function available to the
+// test package.
+func TstWriteUint64(w io.Writer, v uint64) error {
+	return writeUint64(w, v)
+}
+
 // TstReadNetAddress makes the internal readNetAddress function available to
 // the test package.
 func TstReadNetAddress(r io.Reader, na *NetAddress, ts bool) error {
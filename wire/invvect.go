@@ -9,7 +9,9 @@
 package wire
 
 import (
+	"bytes"
 	"io"
+	"sort"
 
 	"github.com/DanielKrawisz/bmutil/hash"
 )
@@ -46,3 +48,43 @@ func writeInvVect(w io.Writer, iv *InvVect) error {
 	}
 	return nil
 }
+
+// CompareInvVect returns an integer comparing two inventory vectors
+// lexicographically by their hash bytes. The result is 0 if a == b, -1 if
+// a < b, and +1 if a > b. This is the canonical ordering used when a
+// deterministic inventory list is required.
+func CompareInvVect(a, b *InvVect) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+// InvVectSlice implements sort.Interface over a list of inventory vectors
+// using the canonical ordering defined by CompareInvVect.
+type InvVectSlice []*InvVect
+
+// Len is part of the sort.Interface implementation.
+func (s InvVectSlice) Len() int {
+	return len(s)
+}
+
+// Less is part of the sort.Interface implementation.
+func (s InvVectSlice) Less(i, j int) bool {
+	return CompareInvVect(s[i], s[j]) < 0
+}
+
+// Swap is part of the sort.Interface implementation.
+func (s InvVectSlice) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// SortInvVects sorts the given list of inventory vectors in place into
+// canonical order. The sort is stable, so duplicate entries keep their
+// relative positions.
+func SortInvVects(list []*InvVect) {
+	sort.Stable(InvVectSlice(list))
+}
+
+// InvVectsSorted reports whether the given list of inventory vectors is in
+// canonical order.
+func InvVectsSorted(list []*InvVect) bool {
+	return sort.IsSorted(InvVectSlice(list))
+}
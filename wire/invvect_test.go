@@ -80,3 +80,25 @@ func TestInvVectWire(t *testing.T) {
 		}
 	}
 }
+
+// TestCompareInvVect tests the canonical ordering of inventory vectors.
+func TestCompareInvVect(t *testing.T) {
+	tests := []struct {
+		a, b wire.InvVect
+		want int
+	}{
+		{wire.InvVect{}, wire.InvVect{}, 0},
+		{wire.InvVect{0x01}, wire.InvVect{0x02}, -1},
+		{wire.InvVect{0x02}, wire.InvVect{0x01}, 1},
+		{wire.InvVect{0x01, 0x02}, wire.InvVect{0x01, 0x01}, 1},
+		{wire.InvVect{31: 0x01}, wire.InvVect{31: 0x02}, -1},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := wire.CompareInvVect(&test.a, &test.b)
+		if got != test.want {
+			t.Errorf("CompareInvVect #%d got: %d want: %d", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+// Limits holds the per-peer limits which are agreed on during the version
+// handshake. A peer with little memory can ask for smaller messages than the
+// protocol allows, and the other side keeps to them when sending.
+type Limits struct {
+	// MaxInvPerMsg is the largest number of inventory vectors which may be
+	// sent to the peer in one inv or getdata message.
+	MaxInvPerMsg int
+}
+
+// DefaultLimits are the limits of a peer which has not asked for anything
+// lower than the protocol maximums.
+var DefaultLimits = Limits{
+	MaxInvPerMsg: MaxInvPerMsg,
+}
+
+// NegotiateLimits returns the limits that apply to a connection once the local
+// and remote version messages have been exchanged. Each limit is the lower of
+// the values the two sides advertised.
+func NegotiateLimits(local, remote *MsgVersion) Limits {
+	limits := DefaultLimits
+	for _, v := range []*MsgVersion{local, remote} {
+		if v == nil || !v.HasService(SFExtInvCap) || v.InvCap == 0 {
+			continue
+		}
+		if int(v.InvCap) < limits.MaxInvPerMsg {
+			limits.MaxInvPerMsg = int(v.InvCap)
+		}
+	}
+	return limits
+}
+
+// invCap returns the inventory cap for the limits, falling back on the
+// protocol maximum if it is unset or out of range.
+func (l Limits) invCap() int {
+	if l.MaxInvPerMsg <= 0 || l.MaxInvPerMsg > MaxInvPerMsg {
+		return MaxInvPerMsg
+	}
+	return l.MaxInvPerMsg
+}
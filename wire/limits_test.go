@@ -0,0 +1,44 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestNegotiateLimits(t *testing.T) {
+	capped := func(n int) *wire.MsgVersion {
+		msg := *baseVersion
+		msg.SetInvCap(n)
+		return &msg
+	}
+
+	// A cap which is present without the flag is not used.
+	unflagged := *baseVersion
+	unflagged.InvCap = 10
+
+	tests := []struct {
+		local, remote *wire.MsgVersion
+		want          int
+	}{
+		{nil, nil, wire.MaxInvPerMsg},
+		{baseVersion, baseVersion, wire.MaxInvPerMsg},
+		{baseVersion, &unflagged, wire.MaxInvPerMsg},
+		{baseVersion, capped(500), 500},
+		{capped(500), baseVersion, 500},
+		{capped(500), capped(200), 200},
+		{capped(200), capped(500), 200},
+		{capped(wire.MaxInvPerMsg + 1), baseVersion, wire.MaxInvPerMsg},
+	}
+
+	for i, test := range tests {
+		got := wire.NegotiateLimits(test.local, test.remote).MaxInvPerMsg
+		if got != test.want {
+			t.Errorf("NegotiateLimits #%d: got %d, want %d", i, got, test.want)
+		}
+	}
+}
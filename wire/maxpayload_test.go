@@ -0,0 +1,154 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// largestMessageTests holds, for every command, the largest message of that
+// type which can be encoded, along with the number of bytes by which
+// MaxPayloadLength is allowed to overestimate its size. A message whose
+// MaxPayloadLength is a loose upper bound, like one which counts MaxVarIntSize
+// for a count that always fits in fewer bytes, has a non-zero slack; the
+// slack is checked exactly so that changing a limit without updating
+// MaxPayloadLength, or the other way around, breaks the test.
+var largestMessageTests = []struct {
+	cmd   string
+	msg   func() wire.Message
+	slack int
+}{
+	{wire.CmdVersion, largestVersion, 0},
+	{wire.CmdVerAck, func() wire.Message { return wire.NewMsgVerAck() }, 0},
+	{wire.CmdPong, func() wire.Message { return wire.NewMsgPong() }, 0},
+	{wire.CmdAddr, largestAddr, 0},
+	{wire.CmdInv, func() wire.Message {
+		msg := wire.NewMsgInvSizeHint(wire.MaxInvPerMsg)
+		for i := 0; i < wire.MaxInvPerMsg; i++ {
+			msg.AddInvVect(largestInvVect(i))
+		}
+		return msg
+	}, bmutil.MaxVarIntSize - 3},
+	{wire.CmdGetData, func() wire.Message {
+		msg := wire.NewMsgGetDataSizeHint(wire.MaxInvPerMsg)
+		for i := 0; i < wire.MaxInvPerMsg; i++ {
+			msg.AddInvVect(largestInvVect(i))
+		}
+		return msg
+	}, bmutil.MaxVarIntSize - 3},
+	{wire.CmdInvDigest, func() wire.Message {
+		return wire.NewMsgInvDigest(math.MaxUint64, wire.MaxDigestCells)
+	}, bmutil.MaxVarIntSize - 3},
+	{wire.CmdObject, func() wire.Message { return largestObject(0) }, 0},
+}
+
+func largestNetAddress() *wire.NetAddress {
+	return wire.NewNetAddressIPPort(net.ParseIP("2001:db8::1"), 8444,
+		math.MaxUint32, wire.SFNodeNetwork)
+}
+
+func largestVersion() wire.Message {
+	msg := wire.NewMsgVersion(largestNetAddress(), largestNetAddress(),
+		math.MaxUint64, []uint32{math.MaxUint32})
+	msg.UserAgent = strings.Repeat("x", wire.MaxUserAgentLen)
+	msg.SetInvCap(1)
+	return msg
+}
+
+func largestAddr() wire.Message {
+	msg := wire.NewMsgAddr()
+	for i := 0; i < wire.MaxAddrPerMsg; i++ {
+		msg.AddAddress(largestNetAddress())
+	}
+	return msg
+}
+
+func largestInvVect(i int) *wire.InvVect {
+	var iv wire.InvVect
+	iv[0], iv[1], iv[2] = byte(i), byte(i>>8), byte(i>>16)
+	return &iv
+}
+
+// largestObject returns an object of MaxPayloadOfMsgObject bytes plus extra.
+func largestObject(extra int) wire.Message {
+	header := wire.NewObjectHeader(pow.Nonce(math.MaxUint64),
+		time.Unix(math.MaxInt32, 0), wire.ObjectTypeMsg, math.MaxUint64,
+		math.MaxUint64)
+	var buf bytes.Buffer
+	header.Encode(&buf)
+	size := wire.MaxPayloadOfMsgObject - buf.Len() + extra
+	return wire.NewMsgObject(header, make([]byte, size))
+}
+
+// TestMaxPayloadLength checks the MaxPayloadLength of every message against
+// the encoding of the largest message of its type, and checks that such a
+// message can be sent and received.
+func TestMaxPayloadLength(t *testing.T) {
+	tested := make(map[string]bool)
+	for _, test := range largestMessageTests {
+		tested[test.cmd] = true
+
+		msg := test.msg()
+		if msg.Command() != test.cmd {
+			t.Errorf("%s: got message of type %s", test.cmd, msg.Command())
+			continue
+		}
+
+		encoded := len(wire.Encode(msg))
+		max := msg.MaxPayloadLength()
+		if max > wire.MaxMessagePayload {
+			t.Errorf("%s: MaxPayloadLength %d is more than MaxMessagePayload %d",
+				test.cmd, max, wire.MaxMessagePayload)
+		}
+		if encoded > max {
+			t.Errorf("%s: largest message is %d bytes, but MaxPayloadLength is %d",
+				test.cmd, encoded, max)
+		} else if max-encoded != test.slack {
+			t.Errorf("%s: MaxPayloadLength %d overestimates largest message "+
+				"of %d bytes by %d, want %d", test.cmd, max, encoded,
+				max-encoded, test.slack)
+		}
+
+		var buf bytes.Buffer
+		if err := wire.WriteMessage(&buf, msg, wire.MainNet); err != nil {
+			t.Errorf("%s: WriteMessage failed: %v", test.cmd, err)
+			continue
+		}
+		read, _, err := wire.ReadMessage(&buf, wire.MainNet)
+		if err != nil {
+			t.Errorf("%s: ReadMessage failed: %v", test.cmd, err)
+			continue
+		}
+		if got := len(wire.Encode(read)); got != encoded {
+			t.Errorf("%s: message was %d bytes after reading, want %d",
+				test.cmd, got, encoded)
+		}
+	}
+
+	for _, cmd := range []string{wire.CmdVersion, wire.CmdVerAck,
+		wire.CmdAddr, wire.CmdInv, wire.CmdGetData, wire.CmdObject,
+		wire.CmdPong, wire.CmdInvDigest} {
+
+		if !tested[cmd] {
+			t.Errorf("no largest message test for %s", cmd)
+		}
+	}
+
+	// Objects have no structure which limits their size, so make sure that
+	// one byte more than the limit is refused.
+	var buf bytes.Buffer
+	if err := wire.WriteMessage(&buf, largestObject(1), wire.MainNet); err == nil {
+		t.Error("object larger than MaxPayloadOfMsgObject was written")
+	}
+}
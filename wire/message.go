@@ -12,8 +12,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"unicode/utf8"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 )
 
@@ -31,6 +33,14 @@ const CommandSize = 12
 // the maximum possible size of an inv message.
 const MaxMessagePayload = 1600100
 
+// The largest messages whose size is fixed by constants must fit in
+// MaxMessagePayload. These fail to compile if a limit is raised too far.
+const (
+	_ = uint(MaxMessagePayload - (bmutil.MaxVarIntSize + MaxInvPerMsg*maxInvVectPayload))
+	_ = uint(MaxMessagePayload - (2*bmutil.MaxVarIntSize + MaxDigestCells*digestCellPayload))
+	_ = uint(MaxMessagePayload - MaxPayloadOfMsgObject)
+)
+
 // Commands used in bitmessage message headers which describe the type of message.
 const (
 	CmdVersion = "version"
@@ -40,6 +50,10 @@ const (
 	CmdGetData = "getdata"
 	CmdObject  = "object"
 	CmdPong    = "pong"
+
+	// CmdInvDigest is an extension which is only sent to peers which
+	// advertise SFExtInvDigest.
+	CmdInvDigest = "invdigest"
 )
 
 // Encodable represents a type that can be written to or read from a stream.
@@ -84,6 +98,9 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdObject:
 		msg = &MsgObject{}
 
+	case CmdInvDigest:
+		msg = &MsgInvDigest{}
+
 	default:
 		return nil, NewMessageError("makeEmptyMessage", fmt.Sprintf("unhandled command [%s]", command))
 	}
@@ -122,24 +139,34 @@ func readMessageHeader(r io.Reader) (int, *messageHeader, error) {
 	return n, &hdr, nil
 }
 
-// discardInput reads n bytes from reader r in chunks and discards the read
-// bytes.  This is used to skip payloads when various errors occur and helps
-// prevent rogue nodes from causing massive memory allocation through forging
-// header length.
-func discardInput(r io.Reader, n uint32) {
-	maxSize := uint32(10 * 1024) // 10k at a time
-	numReads := n / maxSize
-	bytesRemaining := n % maxSize
-	if n > 0 {
-		buf := make([]byte, maxSize)
-		for i := uint32(0); i < numReads; i++ {
-			io.ReadFull(r, buf)
-		}
+// discardInput reads n bytes from reader r without keeping them and returns
+// the number of bytes read.  This is used to skip payloads when various errors
+// occur and helps prevent rogue nodes from causing massive memory allocation
+// through forging header length.
+func discardInput(r io.Reader, n uint32) (int, error) {
+	m, err := io.CopyN(ioutil.Discard, r, int64(n))
+	return int(m), err
+}
+
+// skipOversized discards the payload of a message which is larger than max,
+// if it is no larger than the limit given by DecodeOptions.SkipOversized,
+// and returns the number of bytes read along with an OversizedMessageError.
+func skipOversized(r io.Reader, hdr *messageHeader, max, limit int) (int, error) {
+	e := &OversizedMessageError{
+		Command: hdr.command,
+		Length:  hdr.length,
+		Max:     max,
+	}
+	if int64(hdr.length) > int64(limit) {
+		return 0, e
 	}
-	if bytesRemaining > 0 {
-		buf := make([]byte, bytesRemaining)
-		io.ReadFull(r, buf)
+
+	n, err := discardInput(r, hdr.length)
+	if err != nil {
+		return n, err
 	}
+	e.Discarded = true
+	return n, e
 }
 
 // WriteMessageN writes a bitmessage Message to w including the necessary header
@@ -233,6 +260,13 @@ func WriteMessage(w io.Writer, msg Message, bmnet BitmessageNet) error {
 // message.  This function is the same as ReadMessage except it also returns the
 // number of bytes read.
 func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error) {
+	return ReadMessageNWithOptions(r, bmnet, nil)
+}
+
+// ReadMessageNWithOptions is like ReadMessageN except that the payload is
+// also checked against the given decode options. A nil opts behaves the same
+// as ReadMessageN.
+func ReadMessageNWithOptions(r io.Reader, bmnet BitmessageNet, opts *bmutil.DecodeOptions) (int, Message, []byte, error) {
 	totalBytes := 0
 	n, hdr, err := readMessageHeader(r)
 	if err != nil {
@@ -242,6 +276,19 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 
 	totalBytes += n
 
+	// In skip mode, oversized payloads are discarded so that the stream
+	// stays in step. The payload is not kept, so memory is not a concern.
+	if opts != nil && opts.SkipOversized > 0 {
+		max := MaxMessagePayload
+		if opts.MaxSize != 0 && opts.MaxSize < max {
+			max = opts.MaxSize
+		}
+		if int64(hdr.length) > int64(max) {
+			n, err = skipOversized(r, hdr, max, opts.SkipOversized)
+			return totalBytes + n, nil, nil, err
+		}
+	}
+
 	// Enforce maximum message payload as a malicious client could
 	// otherwise create a well-formed header and set the length to max numbers
 	// in order to exhaust the machine's memory.
@@ -251,6 +298,9 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 			"bytes", hdr.length, MaxMessagePayload)
 		return totalBytes, nil, nil, NewMessageError("ReadMessage", str)
 	}
+	if err = opts.CheckSize(int(hdr.length), "ReadMessage"); err != nil {
+		return totalBytes, nil, nil, err
+	}
 
 	// Check for messages from the wrong bitmessage network.
 	if hdr.magic != bmnet {
@@ -285,6 +335,15 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 	// against malicious users and malformed messages.
 	mpl := msg.MaxPayloadLength()
 	if int(hdr.length) > mpl {
+		if opts != nil && opts.SkipOversized > 0 {
+			// The payload has been read already.
+			return totalBytes, nil, nil, &OversizedMessageError{
+				Command:   command,
+				Length:    hdr.length,
+				Max:       mpl,
+				Discarded: true,
+			}
+		}
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v", hdr.length, command, mpl)
@@ -306,6 +365,12 @@ func ReadMessageN(r io.Reader, bmnet BitmessageNet) (int, Message, []byte, error
 		return totalBytes, nil, nil, err
 	}
 
+	if opts != nil {
+		if err = opts.CheckEncoding(payload, Encode(msg)); err != nil {
+			return totalBytes, nil, nil, err
+		}
+	}
+
 	return totalBytes, msg, payload, nil
 }
 
@@ -319,6 +384,13 @@ func ReadMessage(r io.Reader, bmnet BitmessageNet) (Message, []byte, error) {
 	return msg, buf, err
 }
 
+// ReadMessageWithOptions is like ReadMessage except that the payload is also
+// checked against the given decode options.
+func ReadMessageWithOptions(r io.Reader, bmnet BitmessageNet, opts *bmutil.DecodeOptions) (Message, []byte, error) {
+	_, msg, buf, err := ReadMessageNWithOptions(r, bmnet, opts)
+	return msg, buf, err
+}
+
 // Encode takes a message and returns a representation of it as a byte
 // array as the message would appear in the database. This array is missing the
 // the standard bitmessage header that goes along with every message sent over
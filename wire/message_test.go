@@ -100,6 +100,7 @@ func TestMessage(t *testing.T) {
 	msgAddr := wire.NewMsgAddr()
 	msgInv := wire.NewMsgInv()
 	msgGetData := wire.NewMsgGetData()
+	msgInvDigest := wire.NewMsgInvDigest(1, wire.DigestHashCount)
 
 	// ripe-based getpubkey message
 	ripeBytes := make([]byte, 20)
@@ -136,6 +137,7 @@ func TestMessage(t *testing.T) {
 		{msgAddr, msgAddr, wire.MainNet, 25},
 		{msgInv, msgInv, wire.MainNet, 25},
 		{msgGetData, msgGetData, wire.MainNet, 25},
+		{msgInvDigest, msgInvDigest, wire.MainNet, 158},
 		{msgGetPubKey.MsgObject(), msgGetPubKey.MsgObject(), wire.MainNet, 66},
 		{msgPubKey.MsgObject(), msgPubKey.MsgObject(), wire.MainNet, 178},
 		{msgMsg.MsgObject(), msgMsg.MsgObject(), wire.MainNet, 145},
@@ -582,3 +584,115 @@ func TestEncodeMessageAndMessageHash(t *testing.T) {
 		}
 	}
 }
+
+// TestReadMessageWithOptions ensures that strict decode options reject
+// payloads which the default options accept.
+func TestReadMessageWithOptions(t *testing.T) {
+	iv := make([]byte, 32)
+	canonical := append([]byte{0x01}, iv...)
+	nonCanonical := append([]byte{0xfd, 0x00, 0x01}, iv...)
+	trailing := append(append([]byte{}, canonical...), 0x00)
+
+	makeMsg := func(payload []byte) []byte {
+		checksum := binary.BigEndian.Uint32(hash.Sha512(payload)[:4])
+		b := makeHeader(wire.MainNet, "inv", uint32(len(payload)), checksum)
+		return append(b, payload...)
+	}
+
+	tests := []struct {
+		payload []byte
+		opts    *DecodeOptions
+		err     error
+	}{
+		{canonical, nil, nil},
+		{canonical, &StrictDecodeOptions, nil},
+		{nonCanonical, nil, nil},
+		{nonCanonical, &StrictDecodeOptions, ErrNonCanonicalEncoding},
+		{trailing, nil, nil},
+		{trailing, &StrictDecodeOptions, ErrTrailingData},
+		{trailing, &DecodeOptions{StrictVarInts: true}, nil},
+	}
+
+	for i, test := range tests {
+		r := bytes.NewReader(makeMsg(test.payload))
+		msg, _, err := wire.ReadMessageWithOptions(r, wire.MainNet, test.opts)
+		if err != test.err {
+			t.Errorf("ReadMessageWithOptions #%d got error %v want %v",
+				i, err, test.err)
+			continue
+		}
+		if err == nil && len(msg.(*wire.MsgInv).InvList) != 1 {
+			t.Errorf("ReadMessageWithOptions #%d got %v", i, msg)
+		}
+	}
+
+	// A size limit below the payload length is enforced before decoding.
+	r := bytes.NewReader(makeMsg(canonical))
+	_, _, err := wire.ReadMessageWithOptions(r, wire.MainNet,
+		&DecodeOptions{MaxSize: len(canonical) - 1})
+	if err == nil {
+		t.Errorf("ReadMessageWithOptions with size limit got no error")
+	}
+}
+
+// TestReadMessageSkipOversized ensures that oversized messages are discarded
+// in skip mode, leaving the stream at the start of the next message.
+func TestReadMessageSkipOversized(t *testing.T) {
+	makeMsg := func(command string, payload []byte) []byte {
+		checksum := binary.BigEndian.Uint32(hash.Sha512(payload)[:4])
+		b := makeHeader(wire.MainNet, command, uint32(len(payload)), checksum)
+		return append(b, payload...)
+	}
+	verack := makeMsg("verack", nil)
+
+	tests := []struct {
+		msg       []byte
+		opts      DecodeOptions
+		discarded bool
+	}{
+		// Over the size limit given by the options.
+		{makeMsg("inv", make([]byte, 100)), DecodeOptions{MaxSize: 50, SkipOversized: 200}, true},
+		// Over the limit for the type of message.
+		{makeMsg("verack", make([]byte, 10)), DecodeOptions{SkipOversized: 200}, true},
+		// Too large to skip.
+		{makeMsg("inv", make([]byte, 100)), DecodeOptions{MaxSize: 50, SkipOversized: 80}, false},
+	}
+
+	for i, test := range tests {
+		r := bytes.NewReader(append(append([]byte{}, test.msg...), verack...))
+		n, _, _, err := wire.ReadMessageNWithOptions(r, wire.MainNet, &test.opts)
+		oe, ok := err.(*wire.OversizedMessageError)
+		if !ok {
+			t.Errorf("ReadMessageNWithOptions #%d got error %v, want "+
+				"*OversizedMessageError", i, err)
+			continue
+		}
+		if oe.Discarded != test.discarded {
+			t.Errorf("ReadMessageNWithOptions #%d got discarded %v want %v",
+				i, oe.Discarded, test.discarded)
+		}
+		if !test.discarded {
+			continue
+		}
+		if n != len(test.msg) {
+			t.Errorf("ReadMessageNWithOptions #%d read %d bytes want %d",
+				i, n, len(test.msg))
+		}
+
+		msg, _, err := wire.ReadMessageWithOptions(r, wire.MainNet, &test.opts)
+		if err != nil {
+			t.Errorf("ReadMessageWithOptions #%d next message error %v", i, err)
+			continue
+		}
+		if _, ok := msg.(*wire.MsgVerAck); !ok {
+			t.Errorf("ReadMessageWithOptions #%d next message got %v", i, msg)
+		}
+	}
+
+	// Without skip mode, the usual error is returned.
+	r := bytes.NewReader(makeMsg("inv", make([]byte, 100)))
+	_, _, err := wire.ReadMessageWithOptions(r, wire.MainNet, &DecodeOptions{MaxSize: 50})
+	if _, ok := err.(*wire.OversizedMessageError); ok || err == nil {
+		t.Errorf("ReadMessageWithOptions without skip mode got error %v", err)
+	}
+}
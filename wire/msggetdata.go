@@ -93,6 +93,41 @@ func (msg *MsgGetData) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodeWithLimits encodes the receiver to w like Encode, but fails if the
+// message holds more inventory vectors than the peer it is going to has
+// agreed to accept. Use Split to break up messages which are too long.
+func (msg *MsgGetData) EncodeWithLimits(w io.Writer, limits Limits) error {
+	max := limits.invCap()
+	if count := len(msg.InvList); count > max {
+		str := fmt.Sprintf("too many invvect in message [%v] for peer "+
+			"limit [%v]", count, max)
+		return NewMessageError("MsgGetData.EncodeWithLimits", str)
+	}
+
+	return msg.Encode(w)
+}
+
+// Split breaks the receiver into as few getdata messages as possible which
+// are each within the given limits, keeping the inventory vectors in order.
+// The resulting messages share the receiver's inventory vectors.
+func (msg *MsgGetData) Split(limits Limits) []*MsgGetData {
+	max := limits.invCap()
+	if len(msg.InvList) <= max {
+		return []*MsgGetData{msg}
+	}
+
+	msgs := make([]*MsgGetData, 0, (len(msg.InvList)+max-1)/max)
+	for list := msg.InvList; len(list) > 0; {
+		n := max
+		if len(list) < n {
+			n = len(list)
+		}
+		msgs = append(msgs, &MsgGetData{InvList: list[:n:n]})
+		list = list[n:]
+	}
+	return msgs
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgGetData) Command() string {
@@ -11,6 +11,7 @@ package wire_test
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"testing"
 
@@ -257,3 +258,58 @@ func TestGetDataWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestGetDataLimits tests that getdata messages are kept within a peer's
+// limits.
+func TestGetDataLimits(t *testing.T) {
+	msg := wire.NewMsgGetData()
+	for i := 0; i < 5; i++ {
+		msg.AddInvVect(&wire.InvVect{byte(i)})
+	}
+
+	var buf bytes.Buffer
+	err := msg.EncodeWithLimits(&buf, wire.Limits{MaxInvPerMsg: 2})
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("EncodeWithLimits: got error %v, want MessageError", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("EncodeWithLimits: wrote %d bytes on error", buf.Len())
+	}
+	if err := msg.EncodeWithLimits(&buf, wire.Limits{}); err != nil {
+		t.Errorf("EncodeWithLimits: unexpected error %v", err)
+	}
+
+	tests := []struct {
+		max  int
+		want []int // Number of inventory vectors in each message
+	}{
+		{0, []int{5}},
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+	}
+
+	for i, test := range tests {
+		limits := wire.Limits{MaxInvPerMsg: test.max}
+		msgs := msg.Split(limits)
+		if len(msgs) != len(test.want) {
+			t.Errorf("Split #%d: got %d messages, want %d", i, len(msgs),
+				len(test.want))
+			continue
+		}
+
+		var list []*wire.InvVect
+		for j, m := range msgs {
+			if len(m.InvList) != test.want[j] {
+				t.Errorf("Split #%d: message %d has %d vectors, want %d",
+					i, j, len(m.InvList), test.want[j])
+			}
+			if err := m.EncodeWithLimits(ioutil.Discard, limits); err != nil {
+				t.Errorf("Split #%d: EncodeWithLimits error %v", i, err)
+			}
+			list = append(list, m.InvList...)
+		}
+		if !reflect.DeepEqual(list, msg.InvList) {
+			t.Errorf("Split #%d: vectors out of order", i)
+		}
+	}
+}
@@ -99,6 +99,63 @@ func (msg *MsgInv) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodeWithLimits encodes the receiver to w like Encode, but fails if the
+// message holds more inventory vectors than the peer it is going to has
+// agreed to accept. Use Split to break up messages which are too long.
+func (msg *MsgInv) EncodeWithLimits(w io.Writer, limits Limits) error {
+	max := limits.invCap()
+	if count := len(msg.InvList); count > max {
+		str := fmt.Sprintf("too many invvect in message [%v] for peer "+
+			"limit [%v]", count, max)
+		return NewMessageError("MsgInv.EncodeWithLimits", str)
+	}
+
+	return msg.Encode(w)
+}
+
+// Split breaks the receiver into as few inv messages as possible which are
+// each within the given limits, keeping the inventory vectors in order. The
+// resulting messages share the receiver's inventory vectors.
+func (msg *MsgInv) Split(limits Limits) []*MsgInv {
+	max := limits.invCap()
+	if len(msg.InvList) <= max {
+		return []*MsgInv{msg}
+	}
+
+	msgs := make([]*MsgInv, 0, (len(msg.InvList)+max-1)/max)
+	for list := msg.InvList; len(list) > 0; {
+		n := max
+		if len(list) < n {
+			n = len(list)
+		}
+		msgs = append(msgs, &MsgInv{InvList: list[:n:n]})
+		list = list[n:]
+	}
+	return msgs
+}
+
+// Sort puts the inventory vectors of the message into canonical order, which
+// is lexicographic on the hash bytes. See CompareInvVect.
+func (msg *MsgInv) Sort() {
+	SortInvVects(msg.InvList)
+}
+
+// EncodeSorted encodes the receiver to w like Encode, except that the
+// inventory vectors are written in canonical order. The receiver's InvList is
+// left untouched, so the same message always produces the same bytes no
+// matter what order the vectors were added in.
+func (msg *MsgInv) EncodeSorted(w io.Writer) error {
+	if InvVectsSorted(msg.InvList) {
+		return msg.Encode(w)
+	}
+
+	list := make([]*InvVect, len(msg.InvList))
+	copy(list, msg.InvList)
+	SortInvVects(list)
+
+	return (&MsgInv{InvList: list}).Encode(w)
+}
+
 // Command returns the protocol command string for the message. This is part
 // of the Message interface implementation.
 func (msg *MsgInv) Command() string {
@@ -11,6 +11,7 @@ package wire_test
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"testing"
 
@@ -257,3 +258,124 @@ func TestInvWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestInvSorted tests that EncodeSorted and Sort produce the canonical
+// ordering of inventory vectors regardless of insertion order.
+func TestInvSorted(t *testing.T) {
+	a := &wire.InvVect{0x01, 0xff}
+	b := &wire.InvVect{0x01, 0x00, 0x01}
+	c := &wire.InvVect{0x80}
+
+	sorted := []*wire.InvVect{b, a, c}
+
+	tests := []struct {
+		in []*wire.InvVect // Inventory vectors in insertion order
+	}{
+		{[]*wire.InvVect{}},
+		{[]*wire.InvVect{a, b, c}},
+		{[]*wire.InvVect{c, b, a}},
+		{[]*wire.InvVect{b, a, c}},
+		{[]*wire.InvVect{c, a, b}},
+	}
+
+	var want bytes.Buffer
+	err := (&wire.MsgInv{InvList: sorted}).Encode(&want)
+	if err != nil {
+		t.Fatalf("Encode error %v", err)
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		msg := wire.NewMsgInv()
+		for _, iv := range test.in {
+			msg.AddInvVect(iv)
+		}
+		orig := make([]*wire.InvVect, len(msg.InvList))
+		copy(orig, msg.InvList)
+
+		var buf bytes.Buffer
+		err := msg.EncodeSorted(&buf)
+		if err != nil {
+			t.Errorf("EncodeSorted #%d error %v", i, err)
+			continue
+		}
+		if len(test.in) == len(sorted) && !bytes.Equal(buf.Bytes(), want.Bytes()) {
+			t.Errorf("EncodeSorted #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(want.Bytes()))
+			continue
+		}
+
+		// EncodeSorted must not reorder the message itself.
+		if !reflect.DeepEqual(msg.InvList, orig) {
+			t.Errorf("EncodeSorted #%d modified InvList", i)
+			continue
+		}
+
+		msg.Sort()
+		if !wire.InvVectsSorted(msg.InvList) {
+			t.Errorf("Sort #%d\n got: %s", i, spew.Sdump(msg.InvList))
+			continue
+		}
+		if len(test.in) == len(sorted) && !reflect.DeepEqual(msg.InvList, sorted) {
+			t.Errorf("Sort #%d\n got: %s want: %s", i,
+				spew.Sdump(msg.InvList), spew.Sdump(sorted))
+		}
+	}
+}
+
+// TestInvLimits tests that inv messages are kept within a peer's limits.
+func TestInvLimits(t *testing.T) {
+	msg := wire.NewMsgInv()
+	for i := 0; i < 7; i++ {
+		msg.AddInvVect(&wire.InvVect{byte(i)})
+	}
+
+	var buf bytes.Buffer
+	err := msg.EncodeWithLimits(&buf, wire.Limits{MaxInvPerMsg: 3})
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("EncodeWithLimits: got error %v, want MessageError", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("EncodeWithLimits: wrote %d bytes on error", buf.Len())
+	}
+
+	// Unset limits fall back on the protocol maximum.
+	if err := msg.EncodeWithLimits(&buf, wire.Limits{}); err != nil {
+		t.Errorf("EncodeWithLimits: unexpected error %v", err)
+	}
+
+	tests := []struct {
+		max  int
+		want []int // Number of inventory vectors in each message
+	}{
+		{0, []int{7}},
+		{3, []int{3, 3, 1}},
+		{7, []int{7}},
+		{1, []int{1, 1, 1, 1, 1, 1, 1}},
+	}
+
+	for i, test := range tests {
+		limits := wire.Limits{MaxInvPerMsg: test.max}
+		msgs := msg.Split(limits)
+		if len(msgs) != len(test.want) {
+			t.Errorf("Split #%d: got %d messages, want %d", i, len(msgs),
+				len(test.want))
+			continue
+		}
+
+		var list []*wire.InvVect
+		for j, m := range msgs {
+			if len(m.InvList) != test.want[j] {
+				t.Errorf("Split #%d: message %d has %d vectors, want %d",
+					i, j, len(m.InvList), test.want[j])
+			}
+			if err := m.EncodeWithLimits(ioutil.Discard, limits); err != nil {
+				t.Errorf("Split #%d: EncodeWithLimits error %v", i, err)
+			}
+			list = append(list, m.InvList...)
+		}
+		if !reflect.DeepEqual(list, msg.InvList) {
+			t.Errorf("Split #%d: vectors out of order", i)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+)
+
+const (
+	// MaxDigestCells is the maximum number of cells that can be in a single
+	// invdigest message. It is a multiple of DigestHashCount.
+	MaxDigestCells = 32766
+
+	// DigestHashCount is the number of cells each inventory vector is
+	// added to in an inventory digest.
+	DigestHashCount = 3
+
+	// Size of a single digest cell on the wire: count + key sum + check sum.
+	digestCellPayload = 4 + hash.ShaSize + 8
+)
+
+// DigestCell is one cell of an invertible Bloom lookup table.
+type DigestCell struct {
+	Count    int32
+	KeySum   hash.Sha
+	CheckSum uint64
+}
+
+// empty returns whether the cell holds nothing.
+func (c *DigestCell) empty() bool {
+	return c.Count == 0 && c.CheckSum == 0 && c.KeySum == hash.Sha{}
+}
+
+// pure returns whether the cell holds exactly one inventory vector, either
+// added or removed.
+func (c *DigestCell) pure() bool {
+	return (c.Count == 1 || c.Count == -1) &&
+		c.CheckSum == digestCheckSum((*InvVect)(&c.KeySum))
+}
+
+// MsgInvDigest implements the Message interface and represents an invdigest
+// message. It is an extension to the bitmessage protocol which carries a
+// compact digest of a peer's inventory for a single stream in the form of an
+// invertible Bloom lookup table. Two peers holding digests of the same size
+// can subtract one from the other and recover the inventory vectors which
+// only one of them has, as long as the difference is small compared to the
+// number of cells. Peers should only send this message to peers which
+// advertise SFExtInvDigest.
+type MsgInvDigest struct {
+	StreamNumber uint64
+	Cells        []DigestCell
+}
+
+// digestCheckSum returns the value used to tell whether a cell holds a
+// single inventory vector.
+func digestCheckSum(iv *InvVect) uint64 {
+	h := sha256.Sum256(iv[:])
+	return binary.BigEndian.Uint64(h[:8])
+}
+
+// validDigestSize returns whether a digest can have count cells. There must
+// be at least one cell in each of the DigestHashCount parts of the table,
+// and the parts must be the same size.
+func validDigestSize(count uint64) bool {
+	return count >= DigestHashCount && count <= MaxDigestCells &&
+		count%DigestHashCount == 0
+}
+
+// cellIndexes returns the cells an inventory vector belongs in. The table is
+// split into DigestHashCount equal parts and the vector is placed once in
+// each, so the indexes are always distinct. The number of cells must be
+// valid, as it is for digests made by NewMsgInvDigest or Decode.
+func (msg *MsgInvDigest) cellIndexes(iv *InvVect) [DigestHashCount]int {
+	var idx [DigestHashCount]int
+	part := len(msg.Cells) / DigestHashCount
+	for i := 0; i < DigestHashCount; i++ {
+		n := binary.BigEndian.Uint32(iv[4*i : 4*i+4])
+		idx[i] = i*part + int(n%uint32(part))
+	}
+	return idx
+}
+
+func (msg *MsgInvDigest) update(iv *InvVect, count int32) {
+	check := digestCheckSum(iv)
+	for _, i := range msg.cellIndexes(iv) {
+		cell := &msg.Cells[i]
+		cell.Count += count
+		cell.CheckSum ^= check
+		for j := range cell.KeySum {
+			cell.KeySum[j] ^= iv[j]
+		}
+	}
+}
+
+// AddInvVect adds an inventory vector to the digest, which must have been
+// made by NewMsgInvDigest or Decode.
+func (msg *MsgInvDigest) AddInvVect(iv *InvVect) {
+	msg.update(iv, 1)
+}
+
+// RemoveInvVect removes an inventory vector from the digest.
+func (msg *MsgInvDigest) RemoveInvVect(iv *InvVect) {
+	msg.update(iv, -1)
+}
+
+// Subtract returns a new digest representing the difference between the
+// receiver and other. Both digests must be for the same stream and have the
+// same, valid number of cells.
+func (msg *MsgInvDigest) Subtract(other *MsgInvDigest) (*MsgInvDigest, error) {
+	if msg.StreamNumber != other.StreamNumber {
+		str := fmt.Sprintf("digests are for different streams [%d, %d]",
+			msg.StreamNumber, other.StreamNumber)
+		return nil, NewMessageError("MsgInvDigest.Subtract", str)
+	}
+	if len(msg.Cells) != len(other.Cells) {
+		str := fmt.Sprintf("digests have different sizes [%d, %d]",
+			len(msg.Cells), len(other.Cells))
+		return nil, NewMessageError("MsgInvDigest.Subtract", str)
+	}
+	if !validDigestSize(uint64(len(msg.Cells))) {
+		str := fmt.Sprintf("invalid digest size [%v]", len(msg.Cells))
+		return nil, NewMessageError("MsgInvDigest.Subtract", str)
+	}
+
+	diff := &MsgInvDigest{
+		StreamNumber: msg.StreamNumber,
+		Cells:        make([]DigestCell, len(msg.Cells)),
+	}
+	for i := range msg.Cells {
+		a, b, c := &msg.Cells[i], &other.Cells[i], &diff.Cells[i]
+		c.Count = a.Count - b.Count
+		c.CheckSum = a.CheckSum ^ b.CheckSum
+		for j := range c.KeySum {
+			c.KeySum[j] = a.KeySum[j] ^ b.KeySum[j]
+		}
+	}
+	return diff, nil
+}
+
+// EstimateDifference returns an estimate of the number of inventory vectors
+// in which the receiver and other differ. It is meant to tell whether
+// reconciling is worthwhile or whether a plain inv exchange would be
+// cheaper.
+func (msg *MsgInvDigest) EstimateDifference(other *MsgInvDigest) (int, error) {
+	diff, err := msg.Subtract(other)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, c := range diff.Cells {
+		if c.Count < 0 {
+			total -= int(c.Count)
+		} else {
+			total += int(c.Count)
+		}
+	}
+	return (total + DigestHashCount - 1) / DigestHashCount, nil
+}
+
+// Reconcile recovers the difference between the receiver, which is taken to
+// be the local digest, and other, the remote one. It returns the inventory
+// vectors that only the local side has and those that only the remote side
+// has. An error is returned if the difference is too large to be recovered
+// from digests of this size, in which case the peers should fall back to
+// exchanging inv messages or retry with a larger digest.
+func (msg *MsgInvDigest) Reconcile(other *MsgInvDigest) (local, remote []*InvVect, err error) {
+	diff, err := msg.Subtract(other)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Repeatedly peel off cells that hold a single vector until none are
+	// left. A genuine difference can never yield more vectors than there
+	// are cells, so stop there in case the remote digest was forged.
+	for progress := true; progress && len(local)+len(remote) <= len(diff.Cells); {
+		progress = false
+		for i := range diff.Cells {
+			cell := &diff.Cells[i]
+			if !cell.pure() {
+				continue
+			}
+
+			iv := InvVect(cell.KeySum)
+			if cell.Count == 1 {
+				local = append(local, &iv)
+				diff.RemoveInvVect(&iv)
+			} else {
+				remote = append(remote, &iv)
+				diff.AddInvVect(&iv)
+			}
+			progress = true
+		}
+	}
+
+	for i := range diff.Cells {
+		if !diff.Cells[i].empty() {
+			return nil, nil, NewMessageError("MsgInvDigest.Reconcile",
+				"difference too large to recover from digest")
+		}
+	}
+
+	return local, remote, nil
+}
+
+// Decode decodes r using the bitmessage protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgInvDigest) Decode(r io.Reader) error {
+	var err error
+	if msg.StreamNumber, err = bmutil.ReadVarInt(r); err != nil {
+		return err
+	}
+
+	count, err := bmutil.ReadVarInt(r)
+	if err != nil {
+		return err
+	}
+
+	if !validDigestSize(count) {
+		str := fmt.Sprintf("invalid digest size [%v]", count)
+		return NewMessageError("MsgInvDigest.Decode", str)
+	}
+
+	msg.Cells = make([]DigestCell, count)
+	for i := range msg.Cells {
+		cell := &msg.Cells[i]
+		err = ReadElements(r, &cell.Count, &cell.KeySum, &cell.CheckSum)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Encode encodes the receiver to w using the bitmessage protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgInvDigest) Encode(w io.Writer) error {
+	count := len(msg.Cells)
+	if !validDigestSize(uint64(count)) {
+		str := fmt.Sprintf("invalid digest size [%v]", count)
+		return NewMessageError("MsgInvDigest.Encode", str)
+	}
+
+	err := bmutil.WriteVarInt(w, msg.StreamNumber)
+	if err != nil {
+		return err
+	}
+
+	if err = bmutil.WriteVarInt(w, uint64(count)); err != nil {
+		return err
+	}
+
+	for i := range msg.Cells {
+		cell := &msg.Cells[i]
+		err = WriteElements(w, cell.Count, &cell.KeySum, cell.CheckSum)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message. This is part
+// of the Message interface implementation.
+func (msg *MsgInvDigest) Command() string {
+	return CmdInvDigest
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver. This is part of the Message interface implementation.
+func (msg *MsgInvDigest) MaxPayloadLength() int {
+	// Stream number + num cells (varInt) + max allowed cells.
+	return 2*bmutil.MaxVarIntSize + (MaxDigestCells * digestCellPayload)
+}
+
+// DigestCellsFor returns a digest size that will recover a difference of up
+// to the given number of inventory vectors in all but a small fraction of
+// cases. Reconcile reports the rare failures.
+func DigestCellsFor(difference int) int {
+	// An IBLT with three hash functions needs about 1.5 cells per element
+	// asymptotically, but small tables fail often at that density, so leave
+	// generous room.
+	return roundDigestCells(2*difference + 10*DigestHashCount)
+}
+
+// roundDigestCells rounds a number of cells up to a multiple of
+// DigestHashCount within the allowed range.
+func roundDigestCells(cells int) int {
+	if cells < DigestHashCount {
+		return DigestHashCount
+	}
+	if cells > MaxDigestCells {
+		return MaxDigestCells
+	}
+	return cells + (DigestHashCount-cells%DigestHashCount)%DigestHashCount
+}
+
+// NewMsgInvDigest returns a new, empty invdigest message for the given stream
+// that conforms to the Message interface. The number of cells is rounded up
+// to a multiple of DigestHashCount, and is at least DigestHashCount and at
+// most MaxDigestCells. See
+// DigestCellsFor for choosing a size.
+func NewMsgInvDigest(stream uint64, cells int) *MsgInvDigest {
+	return &MsgInvDigest{
+		StreamNumber: stream,
+		Cells:        make([]DigestCell, roundDigestCells(cells)),
+	}
+}
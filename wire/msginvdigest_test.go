@@ -0,0 +1,264 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/fixed"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// digestTestVect returns a distinct inventory vector for each n.
+func digestTestVect(n uint32) *wire.InvVect {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	iv := wire.InvVect(sha256.Sum256(b[:]))
+	return &iv
+}
+
+// TestInvDigest tests the MsgInvDigest API.
+func TestInvDigest(t *testing.T) {
+	pver := wire.MaxDigestCells*(4+32+8) + 2*9
+	msg := wire.NewMsgInvDigest(1, 10)
+	if cmd := msg.Command(); cmd != "invdigest" {
+		t.Errorf("NewMsgInvDigest: wrong command - got %v want %v",
+			cmd, "invdigest")
+	}
+	if mpl := msg.MaxPayloadLength(); mpl != pver {
+		t.Errorf("MaxPayloadLength: wrong max payload length - "+
+			"got %v, want %v", mpl, pver)
+	}
+	if len(msg.Cells) != 12 {
+		t.Errorf("NewMsgInvDigest: cells not rounded up - got %d, want 12",
+			len(msg.Cells))
+	}
+
+	// There is always at least one cell for each hash.
+	for _, cells := range []int{-1, 0, 1, wire.DigestHashCount - 1} {
+		small := wire.NewMsgInvDigest(1, cells)
+		if n := len(small.Cells); n != wire.DigestHashCount {
+			t.Errorf("NewMsgInvDigest(%d): got %d cells, want %d", cells, n,
+				wire.DigestHashCount)
+		}
+		small.AddInvVect(digestTestVect(0))
+	}
+	if n := len(wire.NewMsgInvDigest(1, 1<<20).Cells); n != wire.MaxDigestCells {
+		t.Errorf("NewMsgInvDigest: got %d cells, want %d", n,
+			wire.MaxDigestCells)
+	}
+	if n := wire.DigestCellsFor(100); n%wire.DigestHashCount != 0 || n < 150 {
+		t.Errorf("DigestCellsFor: got %d cells", n)
+	}
+
+	// Adding and then removing a vector leaves an empty digest.
+	msg.AddInvVect(digestTestVect(1))
+	msg.RemoveInvVect(digestTestVect(1))
+	if !reflect.DeepEqual(msg, wire.NewMsgInvDigest(1, 10)) {
+		t.Errorf("RemoveInvVect did not undo AddInvVect")
+	}
+}
+
+// TestInvDigestReconcile tests that two digests recover their difference.
+func TestInvDigestReconcile(t *testing.T) {
+	tests := []struct {
+		shared uint32 // Vectors both sides have.
+		local  uint32 // Vectors only the local side has.
+		remote uint32 // Vectors only the remote side has.
+	}{
+		{0, 0, 0},
+		{1000, 0, 0},
+		{1000, 5, 0},
+		{1000, 0, 5},
+		{1000, 30, 20},
+		{20, 100, 100},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		size := wire.DigestCellsFor(int(test.local + test.remote))
+		a := wire.NewMsgInvDigest(1, size)
+		b := wire.NewMsgInvDigest(1, size)
+
+		n := uint32(0)
+		var wantLocal, wantRemote []*wire.InvVect
+		for ; n < test.shared; n++ {
+			a.AddInvVect(digestTestVect(n))
+			b.AddInvVect(digestTestVect(n))
+		}
+		for end := n + test.local; n < end; n++ {
+			a.AddInvVect(digestTestVect(n))
+			wantLocal = append(wantLocal, digestTestVect(n))
+		}
+		for end := n + test.remote; n < end; n++ {
+			b.AddInvVect(digestTestVect(n))
+			wantRemote = append(wantRemote, digestTestVect(n))
+		}
+
+		est, err := a.EstimateDifference(b)
+		if err != nil {
+			t.Errorf("EstimateDifference #%d error %v", i, err)
+			continue
+		}
+		if est > int(test.local+test.remote) {
+			t.Errorf("EstimateDifference #%d got %d want at most %d", i,
+				est, test.local+test.remote)
+		}
+
+		local, remote, err := a.Reconcile(b)
+		if err != nil {
+			t.Errorf("Reconcile #%d error %v", i, err)
+			continue
+		}
+		wire.SortInvVects(local)
+		wire.SortInvVects(remote)
+		wire.SortInvVects(wantLocal)
+		wire.SortInvVects(wantRemote)
+		if !reflect.DeepEqual(local, wantLocal) {
+			t.Errorf("Reconcile #%d local\n got: %s want: %s", i,
+				spew.Sdump(local), spew.Sdump(wantLocal))
+		}
+		if !reflect.DeepEqual(remote, wantRemote) {
+			t.Errorf("Reconcile #%d remote\n got: %s want: %s", i,
+				spew.Sdump(remote), spew.Sdump(wantRemote))
+		}
+	}
+}
+
+// TestInvDigestReconcileErrors tests the cases in which a digest difference
+// cannot be recovered.
+func TestInvDigestReconcileErrors(t *testing.T) {
+	wireErr := &wire.MessageError{}
+
+	small := wire.NewMsgInvDigest(1, 6)
+	for n := uint32(0); n < 50; n++ {
+		small.AddInvVect(digestTestVect(n))
+	}
+
+	tests := []struct {
+		a, b *wire.MsgInvDigest
+	}{
+		// Too large a difference.
+		{small, wire.NewMsgInvDigest(1, 6)},
+		// Different streams.
+		{wire.NewMsgInvDigest(1, 6), wire.NewMsgInvDigest(2, 6)},
+		// Different sizes.
+		{wire.NewMsgInvDigest(1, 6), wire.NewMsgInvDigest(1, 9)},
+		// Fewer cells than hashes.
+		{&wire.MsgInvDigest{StreamNumber: 1, Cells: make([]wire.DigestCell, 2)},
+			&wire.MsgInvDigest{StreamNumber: 1, Cells: make([]wire.DigestCell, 2)}},
+		{&wire.MsgInvDigest{StreamNumber: 1}, &wire.MsgInvDigest{StreamNumber: 1}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, _, err := test.a.Reconcile(test.b)
+		if reflect.TypeOf(err) != reflect.TypeOf(wireErr) {
+			t.Errorf("Reconcile #%d wrong error got: %v, want: %v", i,
+				err, wireErr)
+		}
+	}
+}
+
+// TestInvDigestWire tests the MsgInvDigest wire encode and decode.
+func TestInvDigestWire(t *testing.T) {
+	msg := wire.NewMsgInvDigest(2, 3)
+	msg.AddInvVect(digestTestVect(0))
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf); err != nil {
+		t.Fatalf("Encode error %v", err)
+	}
+	if buf.Len() != 2+3*(4+32+8) {
+		t.Errorf("Encode wrong length got %d want %d", buf.Len(),
+			2+3*(4+32+8))
+	}
+
+	var out wire.MsgInvDigest
+	if err := out.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Decode error %v", err)
+	}
+	if !reflect.DeepEqual(&out, msg) {
+		t.Errorf("Decode\n got: %s want: %s", spew.Sdump(&out),
+			spew.Sdump(msg))
+	}
+
+	// Each vector lands in one cell per part of the table.
+	counts := make([]int32, 0, len(out.Cells))
+	for _, c := range out.Cells {
+		counts = append(counts, c.Count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+	if !reflect.DeepEqual(counts, []int32{1, 1, 1}) {
+		t.Errorf("Decode cell counts got %v", counts)
+	}
+}
+
+// TestInvDigestWireErrors performs negative tests against wire encode and
+// decode of MsgInvDigest to confirm error paths work correctly.
+func TestInvDigestWireErrors(t *testing.T) {
+	wireErr := &wire.MessageError{}
+
+	base := wire.NewMsgInvDigest(1, 3)
+	baseEncoded := wire.Encode(base)
+
+	badSize := &wire.MsgInvDigest{StreamNumber: 1, Cells: make([]wire.DigestCell, 4)}
+	badSizeEncoded := []byte{0x01, 0x04}
+
+	empty := &wire.MsgInvDigest{StreamNumber: 1}
+	emptyEncoded := []byte{0x01, 0x00}
+
+	few := &wire.MsgInvDigest{StreamNumber: 1, Cells: make([]wire.DigestCell, 2)}
+	fewEncoded := append([]byte{0x01, 0x02}, make([]byte, 2*(4+32+8))...)
+
+	tests := []struct {
+		in       *wire.MsgInvDigest // Value to encode
+		buf      []byte             // Wire encoding
+		max      int                // Max size of fixed buffer to induce errors
+		writeErr error              // Expected write error
+		readErr  error              // Expected read error
+	}{
+		// Force error in stream number.
+		{base, baseEncoded, 0, io.ErrShortWrite, io.EOF},
+		// Force error in cell count.
+		{base, baseEncoded, 1, io.ErrShortWrite, io.EOF},
+		// Force error in cells.
+		{base, baseEncoded, 2, io.ErrShortWrite, io.EOF},
+		{base, baseEncoded, 50, io.ErrShortWrite, io.ErrUnexpectedEOF},
+		// Force error with a size that isn't a multiple of the hash count.
+		{badSize, badSizeEncoded, 2, wireErr, wireErr},
+		// Force error with no cells.
+		{empty, emptyEncoded, 2, wireErr, wireErr},
+		// Force error with fewer cells than hashes.
+		{few, fewEncoded, len(fewEncoded), wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		w := fixed.NewWriter(test.max)
+		err := test.in.Encode(w)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("Encode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		var msg wire.MsgInvDigest
+		r := fixed.NewReader(test.max, test.buf)
+		err = msg.Decode(r)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("Decode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+	}
+}
@@ -101,6 +101,17 @@ func (msg *MsgObject) Payload() []byte {
 // CheckPow checks if the POW that was done for an object message is sufficient.
 // obj is a byte slice containing the object message.
 func (msg *MsgObject) CheckPow(data pow.Data, refTime time.Time) bool {
+	return msg.CheckPowWithPolicy(data, refTime, nil)
+}
+
+// CheckPowWithPolicy is like CheckPow, except that the parameters are first
+// adjusted by the policy for the type of the object. A nil policy behaves the
+// same as CheckPow.
+func (msg *MsgObject) CheckPowWithPolicy(data pow.Data, refTime time.Time,
+	policy *pow.Policy) bool {
+
+	data = policy.Apply(uint32(msg.Header().ObjectType), data)
+
 	// calculate ttl from bytes 8-16 that contain ExpiresTime
 	ttl := uint64(msg.Header().Expiration().Unix() - refTime.Unix())
 
@@ -220,6 +220,34 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+// TestCheckWithPolicy checks that a policy changes the proof of work asked
+// of only the types of object it lists.
+func TestCheckWithPolicy(t *testing.T) {
+	data := pow.Data{
+		NonceTrialsPerByte: 1000,
+		ExtraBytes:         1000,
+	}
+	policy := &pow.Policy{Multipliers: map[uint32]pow.Multiplier{
+		uint32(wire.ObjectTypeBroadcast): {Num: 1000, Den: 1},
+	}}
+	refTime := time.Unix(1432295555, 0)
+
+	for n, payload := range []string{
+		"000000000592A44000000000555F535F00000000030100D6CFC4F94AA8BEE568985B6650029733726ED3",
+		"0000000000AFFFE700000000555F933400000000020100FE3ACFAE81F900ACB3FD28867750ACC0549DFE",
+	} {
+		b, _ := hex.DecodeString(payload)
+		msg, _ := wire.DecodeMsgObject(b)
+		want := msg.Header().ObjectType != wire.ObjectTypeBroadcast
+		if got := msg.CheckPowWithPolicy(data, refTime, policy); got != want {
+			t.Errorf("for test #%d CheckPowWithPolicy returned %v", n, got)
+		}
+		if !msg.CheckPowWithPolicy(data, refTime, nil) {
+			t.Errorf("for test #%d CheckPowWithPolicy with no policy returned false", n)
+		}
+	}
+}
+
 func TestCopy(t *testing.T) {
 	expires := time.Now().Add(300 * time.Minute)
 
@@ -11,6 +11,7 @@ package wire
 import (
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"strings"
 	"time"
@@ -62,6 +63,12 @@ type MsgVersion struct {
 
 	// The stream numbers of interest.
 	StreamNumbers []uint32
+
+	// InvCap is the largest number of inventory vectors the peer will accept
+	// in an inv or getdata message. It is only on the wire if Services has
+	// SFExtInvCap set. Zero means no cap was advertised, in which case
+	// MaxInvPerMsg applies.
+	InvCap uint32
 }
 
 // HasService returns whether the specified service is supported by the peer
@@ -133,6 +140,14 @@ func (msg *MsgVersion) Decode(r io.Reader) error {
 		}
 	}
 
+	msg.InvCap = 0
+	if msg.HasService(SFExtInvCap) {
+		msg.InvCap, err = readUint32(r)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -186,6 +201,10 @@ func (msg *MsgVersion) Encode(w io.Writer) error {
 		}
 	}
 
+	if msg.HasService(SFExtInvCap) {
+		return writeUint32(w, msg.InvCap)
+	}
+
 	return nil
 }
 
@@ -201,13 +220,10 @@ func (msg *MsgVersion) MaxPayloadLength() int {
 	// Protocol version 4 bytes + services 8 bytes + timestamp 8 bytes +
 	// remote and local net addresses (26*2) + nonce 8 bytes + length of user
 	// agent (varInt) + max allowed useragent length + number of streams
-	// (varInt) + list of streams
+	// (varInt) + list of streams (varInt each) + inventory cap 4 bytes
 	return 4 + 8 + 8 + 26*2 + 8 + bmutil.VarIntSerializeSize(MaxUserAgentLen) +
-		MaxUserAgentLen + MaxStreams +
-		bmutil.VarIntSerializeSize(MaxStreams)*MaxStreams
-	// The last calculation is slightly invalid because initial numbers would
-	// take up less space than larger ones. However, this serves as a quick and
-	// easy to calculate upperbound.
+		MaxUserAgentLen + bmutil.VarIntSerializeSize(MaxStreams) +
+		MaxStreams*bmutil.VarIntSerializeSize(math.MaxUint32) + 4
 }
 
 // NewMsgVersion returns a new bitmessage version message that conforms to the
@@ -246,6 +262,20 @@ func NewMsgVersionFromConn(conn net.Conn, nonce uint64, currentStream uint32, al
 	return NewMsgVersion(lna, rna, nonce, allStreams), nil
 }
 
+// SetInvCap advertises n as the largest number of inventory vectors the peer
+// generating the message will accept at once. A cap which is not positive,
+// or which is MaxInvPerMsg or more, is no cap at all, so it withdraws the
+// advertisement.
+func (msg *MsgVersion) SetInvCap(n int) {
+	if n <= 0 || n >= MaxInvPerMsg {
+		msg.InvCap = 0
+		msg.Services &^= SFExtInvCap
+		return
+	}
+	msg.InvCap = uint32(n)
+	msg.AddService(SFExtInvCap)
+}
+
 // validateUserAgent checks userAgent length against MaxUserAgentLen
 func validateUserAgent(userAgent string) error {
 	if len(userAgent) > MaxUserAgentLen {
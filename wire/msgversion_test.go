@@ -124,8 +124,9 @@ func TestVersion(t *testing.T) {
 	// Ensure max payload is expected value.
 	// Protocol version 4 bytes + services 8 bytes + timestamp 8 bytes +
 	// remote and local net addresses + nonce 8 bytes + length of user agent
-	// (varInt) + max allowed user agent length + 1 stream number
-	wantPayload := 5085
+	// (varInt) + max allowed user agent length + number of streams (varInt)
+	// + 1 stream number (varInt) + inventory cap
+	wantPayload := 5093
 	maxPayload := msg.MaxPayloadLength()
 	if maxPayload != wantPayload {
 		t.Errorf("MaxPayloadLength: wrong max payload length "+
@@ -435,3 +436,75 @@ var tooManyStreamsVersionEncoded = []byte{
 	0x74, 0x3a, 0x30, 0x2e, 0x30, 0x2e, 0x31, 0x2f, // User agent
 	0x02, 0x01, 0x02, // Stream Numbers
 }
+
+// TestVersionInvCap tests that the inventory cap is only written and read
+// when the SFExtInvCap flag is set.
+func TestVersionInvCap(t *testing.T) {
+	msg := *baseVersion
+	msg.SetInvCap(1000)
+	if !msg.HasService(wire.SFExtInvCap) || msg.InvCap != 1000 {
+		t.Fatalf("SetInvCap: got services %v, cap %d", msg.Services, msg.InvCap)
+	}
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf); err != nil {
+		t.Fatalf("Encode error %v", err)
+	}
+	want := append([]byte{}, baseVersionEncoded...)
+	want[7] |= 0x08 // SFExtInvCap is bit 35.
+	want = append(want, 0x00, 0x00, 0x03, 0xe8)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Encode\n got: %s want: %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var decoded wire.MsgVersion
+	if err := decoded.Decode(bytes.NewReader(want)); err != nil {
+		t.Fatalf("Decode error %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, &msg) {
+		t.Fatalf("Decode\n got: %s want: %s", spew.Sdump(decoded),
+			spew.Sdump(msg))
+	}
+
+	// The cap is missing even though the flag is set.
+	err := decoded.Decode(bytes.NewReader(want[:len(want)-4]))
+	if err != io.EOF {
+		t.Errorf("Decode without cap: got error %v, want %v", err, io.EOF)
+	}
+
+	// Only caps from 1 to below MaxInvPerMsg are advertised.
+	for _, test := range []struct {
+		n    int
+		want uint32
+	}{
+		{-1, 0},
+		{0, 0},
+		{1, 1},
+		{wire.MaxInvPerMsg - 1, wire.MaxInvPerMsg - 1},
+		{wire.MaxInvPerMsg, 0},
+		{wire.MaxInvPerMsg + 1, 0},
+	} {
+		capped := *baseVersion
+		capped.SetInvCap(test.n)
+		if capped.InvCap != test.want || capped.HasService(wire.SFExtInvCap) != (test.want != 0) {
+			t.Errorf("SetInvCap(%d): got services %v, cap %d", test.n,
+				capped.Services, capped.InvCap)
+		}
+	}
+
+	// Without the flag the cap is neither written nor read.
+	msg.SetInvCap(0)
+	if msg.HasService(wire.SFExtInvCap) || msg.InvCap != 0 {
+		t.Fatalf("SetInvCap(0): got services %v, cap %d", msg.Services,
+			msg.InvCap)
+	}
+	buf.Reset()
+	if err := msg.Encode(&buf); err != nil {
+		t.Fatalf("Encode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), baseVersionEncoded) {
+		t.Errorf("Encode without cap\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(baseVersionEncoded))
+	}
+}
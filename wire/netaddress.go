@@ -9,7 +9,6 @@
 package wire
 
 import (
-	"encoding/binary"
 	"errors"
 	"io"
 	"net"
@@ -122,7 +121,7 @@ func readNetAddress(r io.Reader, na *NetAddress, big bool) error {
 	if err != nil {
 		return err
 	}
-	err = binary.Read(r, binary.BigEndian, &port)
+	port, err = readUint16(r)
 	if err != nil {
 		return err
 	}
@@ -155,7 +154,7 @@ func writeNetAddress(w io.Writer, na *NetAddress, big bool) error {
 		return err
 	}
 
-	err = binary.Write(w, binary.BigEndian, na.Port)
+	err = writeUint16(w, na.Port)
 	if err != nil {
 		return err
 	}
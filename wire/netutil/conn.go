@@ -0,0 +1,177 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+var (
+	// ErrSelfConnection is returned when the remote peer turns out to be
+	// the local node, which is detected by the nonce in its version
+	// message.
+	ErrSelfConnection = errors.New("connected to self")
+
+	// ErrNoCommonStream is returned when the remote peer is not interested
+	// in any of the streams we are.
+	ErrNoCommonStream = errors.New("no stream in common with peer")
+)
+
+// HandshakeError is returned when the remote peer sends something other
+// than the expected message during the version handshake.
+type HandshakeError struct {
+	Want, Got string
+}
+
+// Error returns a human-readable description of the error.
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake: expected %s message, got %s", e.Want, e.Got)
+}
+
+// Conn is a connection to a peer which has completed the version handshake.
+// Messages are read and written with the framing of the wire package.
+type Conn struct {
+	net.Conn
+
+	// Net is the bitmessage network the peer is on.
+	Net wire.BitmessageNet
+
+	// Local and Remote are the version messages sent and received during
+	// the handshake.
+	Local, Remote *wire.MsgVersion
+
+	// Limits are the limits agreed on with the peer.
+	Limits wire.Limits
+
+	// Downgrade is set if the peer advertised a lower version or fewer
+	// services than it did before. It is only checked by a Dialer with a
+	// PeerVersionStore; the connection is made either way.
+	Downgrade *wire.DowngradeWarning
+
+	// RoundTrip is the time from sending our version message to receiving
+	// the peer's verack, which it sends in answer to it. It is an upper
+	// bound on the round trip time to the peer.
+	RoundTrip time.Duration
+
+	// ClockSkew is how far ahead of ours the peer's clock is, as given by
+	// wire.ClockSkew. It can be added to a wire.MedianTimeSource.
+	ClockSkew time.Duration
+}
+
+// ReadMessage reads the next message from the peer.
+func (c *Conn) ReadMessage() (wire.Message, error) {
+	msg, _, err := wire.ReadMessage(c.Conn, c.Net)
+	return msg, err
+}
+
+// WriteMessage sends a message to the peer.
+func (c *Conn) WriteMessage(msg wire.Message) error {
+	return wire.WriteMessage(c.Conn, msg, c.Net)
+}
+
+// Handshake does the version handshake over conn, which has just been
+// opened, by sending local and waiting for the peer's version and verack,
+// which may come in either order. If timeout is not zero, the whole
+// handshake must be done within it. If the handshake fails, conn is left for
+// the caller to close.
+func Handshake(conn net.Conn, bmnet wire.BitmessageNet, local *wire.MsgVersion,
+	timeout time.Duration) (*Conn, error) {
+
+	if timeout != 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	c := &Conn{
+		Conn:  conn,
+		Net:   bmnet,
+		Local: local,
+	}
+
+	// Both sides send their messages without waiting for the other, so
+	// writes must not hold up reading.
+	written := make(chan error, 1)
+	write := func(msg wire.Message) {
+		go func() {
+			written <- c.WriteMessage(msg)
+		}()
+	}
+
+	sent := time.Now()
+	write(local)
+
+	// The peer answers our version with a verack and sends its own version,
+	// which we answer in turn. The two can come in either order: the side
+	// which accepted the connection, as PyBitmessage does, may send its
+	// verack first.
+	var remote *wire.MsgVersion
+	var received time.Time
+	verack := false
+	for remote == nil || !verack {
+		msg, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		switch m := msg.(type) {
+		case *wire.MsgVersion:
+			if remote != nil {
+				return nil, &HandshakeError{wire.CmdVerAck, msg.Command()}
+			}
+			received = time.Now()
+			if m.Nonce == local.Nonce {
+				return nil, ErrSelfConnection
+			}
+			if !commonStream(local.StreamNumbers, m.StreamNumbers) {
+				return nil, ErrNoCommonStream
+			}
+			if err = <-written; err != nil {
+				return nil, err
+			}
+			write(wire.NewMsgVerAck())
+			remote = m
+
+		case *wire.MsgVerAck:
+			if verack {
+				return nil, &HandshakeError{wire.CmdVersion, msg.Command()}
+			}
+			c.RoundTrip = time.Since(sent)
+			verack = true
+
+		default:
+			want := wire.CmdVersion
+			if remote != nil {
+				want = wire.CmdVerAck
+			}
+			return nil, &HandshakeError{want, msg.Command()}
+		}
+	}
+	if err := <-written; err != nil {
+		return nil, err
+	}
+
+	c.Remote = remote
+	c.ClockSkew = wire.ClockSkew(remote, received, c.RoundTrip)
+	c.Limits = wire.NegotiateLimits(local, remote)
+	return c, nil
+}
+
+func commonStream(a, b []uint32) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
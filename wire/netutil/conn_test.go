@@ -0,0 +1,132 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netutil_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/netutil"
+)
+
+func newVersion(nonce uint64, streams ...uint32) *wire.MsgVersion {
+	addr := &wire.NetAddress{IP: net.ParseIP("127.0.0.1"), Port: 8444}
+	return wire.NewMsgVersion(addr, addr, nonce, streams)
+}
+
+// fakePeer does the remote side of the handshake on conn, sending msgs in
+// order once it has read our version. They are normally a version and then
+// a verack, but a peer which accepted the connection may send the verack
+// first.
+func fakePeer(conn net.Conn, msgs ...wire.Message) {
+	if _, _, err := wire.ReadMessage(conn, wire.MainNet); err != nil {
+		return
+	}
+	for _, msg := range msgs {
+		if err := wire.WriteMessage(conn, msg, wire.MainNet); err != nil {
+			return
+		}
+	}
+	wire.ReadMessage(conn, wire.MainNet)
+}
+
+// tcpPipe returns both ends of a loopback TCP connection. net.Pipe can't be
+// used since it blocks on the empty writes of payloadless messages.
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error %v", err)
+	}
+	defer l.Close()
+
+	local, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error %v", err)
+	}
+	remote, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept error %v", err)
+	}
+	return local, remote
+}
+
+func TestHandshake(t *testing.T) {
+	remote := newVersion(2, 1)
+	remote.SetInvCap(100)
+
+	verack := wire.NewMsgVerAck()
+
+	tests := []struct {
+		msgs []wire.Message
+		err  error
+	}{
+		{[]wire.Message{remote, verack}, nil},
+		{[]wire.Message{verack, remote}, nil},
+		{[]wire.Message{newVersion(1, 1), verack}, netutil.ErrSelfConnection},
+		{[]wire.Message{newVersion(2, 2), verack}, netutil.ErrNoCommonStream},
+		{[]wire.Message{verack, newVersion(1, 1)}, netutil.ErrSelfConnection},
+		{[]wire.Message{remote, &wire.MsgPong{}},
+			&netutil.HandshakeError{Want: wire.CmdVerAck, Got: wire.CmdPong}},
+		{[]wire.Message{verack, &wire.MsgPong{}},
+			&netutil.HandshakeError{Want: wire.CmdVersion, Got: wire.CmdPong}},
+		{[]wire.Message{verack, verack},
+			&netutil.HandshakeError{Want: wire.CmdVersion, Got: wire.CmdVerAck}},
+		{[]wire.Message{remote, remote},
+			&netutil.HandshakeError{Want: wire.CmdVerAck, Got: wire.CmdVersion}},
+	}
+
+	for i, test := range tests {
+		local, peer := tcpPipe(t)
+		go fakePeer(peer, test.msgs...)
+
+		c, err := netutil.Handshake(local, wire.MainNet, newVersion(1, 1), time.Second)
+		local.Close()
+		peer.Close()
+
+		if test.err != nil {
+			if he, ok := test.err.(*netutil.HandshakeError); ok {
+				if got, ok := err.(*netutil.HandshakeError); !ok || *got != *he {
+					t.Errorf("#%d got error %v, want %v", i, err, test.err)
+				}
+			} else if err != test.err {
+				t.Errorf("#%d got error %v, want %v", i, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d got error %v", i, err)
+			continue
+		}
+		if c.Remote.Nonce != 2 {
+			t.Errorf("#%d got remote nonce %d", i, c.Remote.Nonce)
+		}
+		if c.Limits.MaxInvPerMsg != 100 {
+			t.Errorf("#%d got inv limit %d, want 100", i, c.Limits.MaxInvPerMsg)
+		}
+		if c.RoundTrip <= 0 || c.RoundTrip > time.Second {
+			t.Errorf("#%d got round trip %v", i, c.RoundTrip)
+		}
+		// Timestamps are only given to the second.
+		if c.ClockSkew > time.Second || c.ClockSkew < -time.Second {
+			t.Errorf("#%d got clock skew %v", i, c.ClockSkew)
+		}
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	local, peer := tcpPipe(t)
+	defer local.Close()
+	defer peer.Close()
+
+	// The peer reads our version but never answers.
+	go wire.ReadMessage(peer, wire.MainNet)
+
+	_, err := netutil.Handshake(local, wire.MainNet, newVersion(1, 1), 50*time.Millisecond)
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("got error %v, want timeout", err)
+	}
+}
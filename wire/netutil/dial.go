@@ -0,0 +1,255 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package netutil connects to bitmessage peers. It takes care of the parts
+// that every user of the wire package otherwise writes around net.Dial:
+// choosing a port, going through a SOCKS5 proxy such as Tor, retrying with
+// exponential backoff and doing the version handshake.
+package netutil
+
+import (
+	"encoding/base32"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// DefaultPort is the port the reference client listens on. It is the same
+// for every stream.
+const DefaultPort = 8444
+
+// Backoff says how often and how quickly to retry a failed connection.
+type Backoff struct {
+	// Initial is the delay before the first retry.
+	Initial time.Duration
+
+	// Max is the longest delay between retries.
+	Max time.Duration
+
+	// Multiplier is the factor by which the delay grows after each retry.
+	// Values below 1 are treated as 1.
+	Multiplier float64
+
+	// Retries is the number of times to try again after the first attempt
+	// fails.
+	Retries int
+}
+
+// DefaultBackoff retries a few times over roughly half a minute.
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Retries:    4,
+}
+
+// Delay returns how long to wait before the given retry, counting from zero.
+func (b Backoff) Delay(retry int) time.Duration {
+	m := b.Multiplier
+	if m < 1 {
+		m = 1
+	}
+
+	d := float64(b.Initial)
+	for i := 0; i < retry && (b.Max <= 0 || d < float64(b.Max)); i++ {
+		d *= m
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// Dialer opens connections to peers. The zero value connects directly to
+// the main network on stream 1, without retrying.
+type Dialer struct {
+	// Net is the bitmessage network to use. If zero, wire.MainNet is used.
+	Net wire.BitmessageNet
+
+	// Proxy is the host:port of a SOCKS5 proxy to connect through. If it
+	// is empty, connections are made directly.
+	Proxy         string
+	ProxyUser     string
+	ProxyPassword string
+
+	// Timeout limits how long each attempt to open a connection may take,
+	// and HandshakeTimeout how long the version handshake may take after
+	// that. Zero means no limit.
+	Timeout          time.Duration
+	HandshakeTimeout time.Duration
+
+	// Backoff controls retries.
+	Backoff Backoff
+
+	// Port returns the port to use for a peer on the given stream when
+	// the address does not include one. If nil, DefaultPort is used.
+	Port func(stream uint32) int
+
+	// Streams are the streams to advertise. If empty, stream 1 is used.
+	Streams []uint32
+
+	// Services are the services to advertise.
+	Services wire.ServiceFlag
+
+	// InvCap, if not zero, is advertised as the largest number of
+	// inventory vectors we accept in one message.
+	InvCap int
+
+	// Versions, if not nil, remembers the version each peer advertised so
+	// that downgrades can be noticed. Peers are identified by host:port.
+	// See Conn.Downgrade.
+	Versions wire.PeerVersionStore
+
+	// sleep is replaced in tests.
+	sleep func(time.Duration)
+}
+
+func (d *Dialer) network() wire.BitmessageNet {
+	if d.Net == 0 {
+		return wire.MainNet
+	}
+	return d.Net
+}
+
+func (d *Dialer) streams() []uint32 {
+	if len(d.Streams) == 0 {
+		return []uint32{1}
+	}
+	return d.Streams
+}
+
+// address adds the port for the stream to addr if it does not have one.
+func (d *Dialer) address(addr string, stream uint32) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+
+	port := DefaultPort
+	if d.Port != nil {
+		port = d.Port(stream)
+	}
+	return net.JoinHostPort(addr, strconv.Itoa(port))
+}
+
+// Dial connects to the peer at addr, which may leave out the port, and does
+// the version handshake. Failed attempts are retried according to the
+// Backoff, except when the peer turns out to be ourselves. The version which
+// is sent is taken from the connection and the Dialer's settings.
+func (d *Dialer) Dial(addr string) (*Conn, error) {
+	addr = d.address(addr, d.streams()[0])
+
+	sleep := d.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	var err error
+	for retry := 0; ; retry++ {
+		var c *Conn
+		c, err = d.dialOnce(addr)
+		if err == nil {
+			return c, nil
+		}
+		if err == ErrSelfConnection || retry >= d.Backoff.Retries {
+			return nil, err
+		}
+		sleep(d.Backoff.Delay(retry))
+	}
+}
+
+func (d *Dialer) dialOnce(addr string) (*Conn, error) {
+	conn, err := d.open(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	streams := d.streams()
+	local, err := wire.NewMsgVersionFromConn(conn, nonce, streams[0], streams)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if d.Proxy != "" {
+		// The remote end of conn is the proxy, not the peer.
+		local.AddrYou, err = targetAddress(addr, streams[0])
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	local.AddService(d.Services)
+	local.SetInvCap(d.InvCap)
+
+	c, err := Handshake(conn, d.network(), local, d.HandshakeTimeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if d.Versions != nil {
+		c.Downgrade = wire.CheckDowngrade(d.Versions, addr, c.Remote)
+	}
+	return c, nil
+}
+
+// onionCatPrefix is the IPv6 prefix under which OnionCat, and the reference
+// client, represent version 2 onion addresses.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// targetAddress returns the address of the peer at addr, a host and port, to
+// advertise in the version message. A version 2 onion host is represented
+// with onionCatPrefix and any other host name with the unspecified address,
+// since it cannot be resolved without going around the proxy.
+func targetAddress(addr string, stream uint32) (*wire.NetAddress, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ip = net.IPv6zero
+		if strings.HasSuffix(host, ".onion") {
+			b, err := base32.StdEncoding.DecodeString(
+				strings.ToUpper(strings.TrimSuffix(host, ".onion")))
+			if err == nil && len(b) == net.IPv6len-len(onionCatPrefix) {
+				ip = append(append(net.IP{}, onionCatPrefix...), b...)
+			}
+		}
+	}
+	return wire.NewNetAddressIPPort(ip, uint16(port), stream, 0), nil
+}
+
+// open makes the TCP connection, through the proxy if there is one.
+func (d *Dialer) open(addr string) (net.Conn, error) {
+	if d.Proxy == "" {
+		return net.DialTimeout("tcp", addr, d.Timeout)
+	}
+
+	conn, err := net.DialTimeout("tcp", d.Proxy, d.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if d.Timeout != 0 {
+		conn.SetDeadline(time.Now().Add(d.Timeout))
+	}
+	if err = socks5Handshake(conn, addr, d.ProxyUser, d.ProxyPassword); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
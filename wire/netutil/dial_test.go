@@ -0,0 +1,225 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netutil_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/netutil"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := netutil.Backoff{
+		Initial:    time.Second,
+		Max:        10 * time.Second,
+		Multiplier: 2,
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second,
+		8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if d := b.Delay(i); d != w {
+			t.Errorf("Delay(%d) got %v, want %v", i, d, w)
+		}
+	}
+
+	b.Multiplier = 0
+	if d := b.Delay(3); d != time.Second {
+		t.Errorf("Delay with no multiplier got %v, want %v", d, time.Second)
+	}
+}
+
+// listen starts a listener which passes each connection to serve in turn.
+func listen(t *testing.T, serve ...func(net.Conn)) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error %v", err)
+	}
+	go func() {
+		for _, s := range serve {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s(conn)
+		}
+	}()
+	return l
+}
+
+func peer(conn net.Conn) {
+	fakePeer(conn, newVersion(2, 1), wire.NewMsgVerAck())
+}
+
+func TestDial(t *testing.T) {
+	// The first connection is dropped straight away.
+	l := listen(t, func(conn net.Conn) { conn.Close() }, peer)
+	defer l.Close()
+
+	var sleeps []time.Duration
+	d := &netutil.Dialer{
+		Backoff:          netutil.Backoff{Initial: time.Millisecond, Retries: 1},
+		HandshakeTimeout: time.Second,
+		InvCap:           500,
+	}
+	netutil.TstSetSleep(d, func(s time.Duration) { sleeps = append(sleeps, s) })
+
+	c, err := d.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error %v", err)
+	}
+	defer c.Close()
+
+	if len(sleeps) != 1 || sleeps[0] != time.Millisecond {
+		t.Errorf("got sleeps %v", sleeps)
+	}
+	if !c.Local.HasService(wire.SFExtInvCap) || c.Local.InvCap != 500 {
+		t.Errorf("inv cap was not advertised")
+	}
+	if c.Limits.MaxInvPerMsg != 500 {
+		t.Errorf("got inv limit %d, want 500", c.Limits.MaxInvPerMsg)
+	}
+
+	// Without retries the first failure is returned.
+	l2 := listen(t, func(conn net.Conn) { conn.Close() }, peer)
+	defer l2.Close()
+	d.Backoff.Retries = 0
+	if _, err = d.Dial(l2.Addr().String()); err == nil {
+		t.Errorf("Dial without retries succeeded")
+	}
+}
+
+func TestDialPort(t *testing.T) {
+	l := listen(t, peer)
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	var stream uint32
+	d := &netutil.Dialer{
+		Port: func(s uint32) int {
+			stream = s
+			return port
+		},
+	}
+	c, err := d.Dial("127.0.0.1")
+	if err != nil {
+		t.Fatalf("Dial error %v", err)
+	}
+	c.Close()
+	if stream != 1 {
+		t.Errorf("Port called for stream %d, want 1", stream)
+	}
+}
+
+func TestDialDowngrade(t *testing.T) {
+	old := newVersion(2, 1)
+	old.ProtocolVersion = 2
+	l := listen(t, peer, func(conn net.Conn) {
+		fakePeer(conn, old, wire.NewMsgVerAck())
+	})
+	defer l.Close()
+
+	d := &netutil.Dialer{Versions: wire.NewPeerVersions()}
+	for i, want := range []bool{false, true} {
+		c, err := d.Dial(l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial error %v", err)
+		}
+		c.Close()
+		if (c.Downgrade != nil) != want {
+			t.Errorf("connection %d got downgrade %v", i, c.Downgrade)
+		}
+	}
+}
+
+// socksServer does the server side of a SOCKS5 connect with username and
+// password authentication, records the requested address and then acts as
+// the peer.
+func socksServer(requested chan<- string) func(net.Conn) {
+	return func(conn net.Conn) {
+		defer conn.Close()
+
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil ||
+			!bytes.Equal(buf, []byte{5, 2, 0, 2}) {
+			return
+		}
+		conn.Write([]byte{5, 2})
+
+		// Username and password.
+		var b [1]byte
+		io.ReadFull(conn, b[:])
+		var creds []string
+		for i := 0; i < 2; i++ {
+			io.ReadFull(conn, b[:])
+			s := make([]byte, b[0])
+			io.ReadFull(conn, s)
+			creds = append(creds, string(s))
+		}
+		if creds[0] != "user" || creds[1] != "pass" {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+
+		// Connect request for a domain name.
+		head := make([]byte, 5)
+		io.ReadFull(conn, head)
+		host := make([]byte, head[4])
+		io.ReadFull(conn, host)
+		var port [2]byte
+		io.ReadFull(conn, port[:])
+		requested <- net.JoinHostPort(string(host),
+			strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		fakePeer(conn, newVersion(2, 1), wire.NewMsgVerAck())
+	}
+}
+
+func TestDialProxy(t *testing.T) {
+	requested := make(chan string, 1)
+	l := listen(t, socksServer(requested))
+	defer l.Close()
+
+	d := &netutil.Dialer{
+		Proxy:         l.Addr().String(),
+		ProxyUser:     "user",
+		ProxyPassword: "pass",
+		Timeout:       time.Second,
+	}
+	c, err := d.Dial("expyuzz4wqqyqhjn.onion")
+	if err != nil {
+		t.Fatalf("Dial error %v", err)
+	}
+	c.Close()
+
+	if r := <-requested; r != "expyuzz4wqqyqhjn.onion:8444" {
+		t.Errorf("proxy was asked for %s", r)
+	}
+
+	// The peer, not the proxy, is advertised as the remote address.
+	you := c.Local.AddrYou
+	onion := net.ParseIP("fd87:d87e:eb43:25df:8a67:3cb4:2188:1d2d")
+	if !you.IP.Equal(onion) || you.Port != netutil.DefaultPort {
+		t.Errorf("got remote address %s:%d, want %s:%d", you.IP, you.Port,
+			onion, netutil.DefaultPort)
+	}
+
+	l2 := listen(t, socksServer(requested))
+	defer l2.Close()
+	d.Proxy = l2.Addr().String()
+	d.ProxyPassword = "wrong"
+	if _, err = d.Dial("peer.onion"); err != netutil.ErrProxyAuth {
+		t.Errorf("Dial with wrong password got error %v", err)
+	}
+}
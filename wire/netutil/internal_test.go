@@ -0,0 +1,19 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+This test file is part of the netutil package rather than the netutil_test
+package so it can bridge access to the internals to properly test cases which
+are either not possible or can't reliably be tested via the public interface.
+The functions are only exported while the tests are being run.
+*/
+
+package netutil
+
+import "time"
+
+// TstSetSleep replaces the function the Dialer waits between retries with.
+func TstSetSleep(d *Dialer, sleep func(time.Duration)) {
+	d.sleep = sleep
+}
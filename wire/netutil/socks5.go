@@ -0,0 +1,165 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 constants from RFC 1928 and RFC 1929.
+const (
+	socks5Version    = 5
+	socks5AuthNone   = 0
+	socks5AuthPass   = 2
+	socks5Connect    = 1
+	socks5AddrIPv4   = 1
+	socks5AddrDomain = 3
+	socks5AddrIPv6   = 4
+)
+
+var (
+	// ErrProxyAuth is returned when the SOCKS5 proxy rejects the
+	// credentials, or does not accept any method we offer.
+	ErrProxyAuth = errors.New("socks5 proxy authentication failed")
+
+	// ErrProxyProtocol is returned when the SOCKS5 proxy sends a reply
+	// that cannot be understood.
+	ErrProxyProtocol = errors.New("socks5 proxy protocol error")
+)
+
+// ProxyError is returned when the SOCKS5 proxy could not connect to the
+// requested address.
+type ProxyError struct {
+	Code byte
+}
+
+// socks5Errors are the failure messages of RFC 1928.
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// Error returns a human-readable description of the error.
+func (e *ProxyError) Error() string {
+	if s, ok := socks5Errors[e.Code]; ok {
+		return "socks5 proxy: " + s
+	}
+	return fmt.Sprintf("socks5 proxy: unknown error %d", e.Code)
+}
+
+// socks5Handshake asks the SOCKS5 proxy at the other end of conn to connect to
+// addr, which is host:port. Host names are sent to the proxy unresolved so
+// that onion addresses work through Tor. If user is not empty, username and
+// password authentication is offered.
+func socks5Handshake(conn net.Conn, addr, user, password string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	// Method selection.
+	methods := []byte{socks5Version, 1, socks5AuthNone}
+	if user != "" {
+		methods = []byte{socks5Version, 2, socks5AuthNone, socks5AuthPass}
+	}
+	if _, err = conn.Write(methods); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err = io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return ErrProxyProtocol
+	}
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPass:
+		if user == "" || len(user) > 255 || len(password) > 255 {
+			return ErrProxyAuth
+		}
+		req := []byte{1, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err = conn.Write(req); err != nil {
+			return err
+		}
+		if _, err = io.ReadFull(conn, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return ErrProxyAuth
+		}
+	default:
+		return ErrProxyAuth
+	}
+
+	// Connect request.
+	req := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long for socks5: %s", host)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddrIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+	var p [2]byte
+	binary.BigEndian.PutUint16(p[:], uint16(port))
+	req = append(req, p[:]...)
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply. The bound address is read and thrown away.
+	var head [4]byte
+	if _, err = io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[0] != socks5Version {
+		return ErrProxyProtocol
+	}
+	if head[1] != 0 {
+		return &ProxyError{head[1]}
+	}
+	var n int
+	switch head[3] {
+	case socks5AddrIPv4:
+		n = net.IPv4len
+	case socks5AddrIPv6:
+		n = net.IPv6len
+	case socks5AddrDomain:
+		var l [1]byte
+		if _, err = io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		n = int(l[0])
+	default:
+		return ErrProxyProtocol
+	}
+	_, err = io.ReadFull(conn, make([]byte, n+2))
+	return err
+}
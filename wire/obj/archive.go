@@ -0,0 +1,163 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// sharedReader reads from a byte slice. Unlike a bytes.Reader, the bytes
+// left at the end can be taken by readRest without being copied.
+type sharedReader struct {
+	b   []byte
+	off int
+}
+
+func (r *sharedReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.b) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.b[r.off:])
+	r.off += n
+	return n, nil
+}
+
+// ReadByte implements io.ByteReader, so that varints are read without
+// allocating.
+func (r *sharedReader) ReadByte() (byte, error) {
+	if r.off >= len(r.b) {
+		return 0, io.EOF
+	}
+	r.off++
+	return r.b[r.off-1], nil
+}
+
+// readRest returns everything left in r. For a sharedReader the result
+// refers to the underlying slice.
+func readRest(r io.Reader) ([]byte, error) {
+	if s, ok := r.(*sharedReader); ok {
+		rest := s.b[s.off:len(s.b):len(s.b)]
+		s.off = len(s.b)
+		return rest, nil
+	}
+	return ioutil.ReadAll(r)
+}
+
+// ReadObjectShared is like ReadObject, except that the encrypted data or
+// payload of the returned object refers to b instead of being copied. The
+// object is only valid for as long as b is not changed. Use Copy to get an
+// object that does not depend on b.
+func ReadObjectShared(b []byte) (Object, error) {
+	return DecodeObject(&sharedReader{b: b})
+}
+
+// Copy returns an object equal to o which shares no memory with it, such as
+// with an Archive from which it was read.
+func Copy(o Object) (Object, error) {
+	return ReadObject(wire.Encode(o))
+}
+
+// Archive is a file of objects, each written as var_bytes, as by
+// WriteArchiveObject. The file is mapped into memory where the platform
+// allows it, so that an archive of many gigabytes can be read through
+// without being read into memory, and objects are decoded from it with
+// ReadObjectShared. Objects read from an Archive must not be used after it
+// is closed, unless they have been copied with Copy. If the file is changed
+// while it is open, the results are undefined, and on some platforms the
+// program may crash.
+type Archive struct {
+	data  []byte
+	unmap func() error
+}
+
+// OpenArchive opens the archive at path.
+func OpenArchive(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, unmap, err := mapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{data, unmap}, nil
+}
+
+// NewArchive returns an Archive which reads from data.
+func NewArchive(data []byte) *Archive {
+	return &Archive{data: data}
+}
+
+// Close releases the archive's memory.
+func (a *Archive) Close() error {
+	var err error
+	if a.unmap != nil {
+		err = a.unmap()
+		a.unmap = nil
+	}
+	a.data = nil
+	return err
+}
+
+// Len returns the size of the archive in bytes.
+func (a *Archive) Len() int {
+	return len(a.data)
+}
+
+// RawAt returns the encoded object which starts at offset, along with the
+// offset of the next one. The returned slice refers to the archive. At the
+// end of the archive, io.EOF is returned. This is enough for indexing with
+// Inspect without decoding each object.
+func (a *Archive) RawAt(offset int) ([]byte, int, error) {
+	if offset < 0 || offset > len(a.data) {
+		return nil, 0, fmt.Errorf("offset %d out of range", offset)
+	}
+	if offset == len(a.data) {
+		return nil, 0, io.EOF
+	}
+
+	length, n, err := bmutil.ReadVarIntBuf(a.data[offset:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if length > wire.MaxPayloadOfMsgObject {
+		str := fmt.Sprintf("object at %d exceeds max length of %d bytes",
+			offset, wire.MaxPayloadOfMsgObject)
+		return nil, 0, wire.NewMessageError("RawAt", str)
+	}
+	start := offset + n
+	end := start + int(length)
+	if end > len(a.data) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	return a.data[start:end:end], end, nil
+}
+
+// ObjectAt decodes the object which starts at offset with ReadObjectShared
+// and returns it along with the offset of the next one.
+func (a *Archive) ObjectAt(offset int) (Object, int, error) {
+	raw, next, err := a.RawAt(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	o, err := ReadObjectShared(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	return o, next, nil
+}
+
+// WriteArchiveObject appends an object to an archive being written to w.
+func WriteArchiveObject(w io.Writer, o Object) error {
+	return bmutil.WriteVarBytes(w, wire.Encode(o))
+}
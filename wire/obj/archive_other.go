@@ -0,0 +1,19 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package obj
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mapFile reads the whole of f, on platforms where it cannot be mapped.
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	data, err := ioutil.ReadAll(f)
+	return data, nil, err
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestArchive(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	objects := []obj.Object{
+		obj.NewMessage(1, expires, 1, []byte{1, 2, 3}),
+		obj.NewTaggedBroadcast(2, expires, 1, &hash.Sha{7}, []byte{4, 5}),
+		obj.NewTaglessBroadcast(3, expires, 1, []byte{6}),
+		obj.NewEncryptedPubKey(4, expires, 1, &hash.Sha{8}, []byte{7, 8}),
+	}
+
+	var buf bytes.Buffer
+	for _, o := range objects {
+		if err := obj.WriteArchiveObject(&buf, o); err != nil {
+			t.Fatalf("WriteArchiveObject error %v", err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "objects")
+	if err = ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := obj.OpenArchive(path)
+	if err != nil {
+		t.Fatalf("OpenArchive error %v", err)
+	}
+	if a.Len() != buf.Len() {
+		t.Errorf("got length %d, want %d", a.Len(), buf.Len())
+	}
+
+	var copies []obj.Object
+	offset := 0
+	for i := 0; ; i++ {
+		var o obj.Object
+		o, offset, err = a.ObjectAt(offset)
+		if err == io.EOF {
+			if i != len(objects) {
+				t.Errorf("got %d objects, want %d", i, len(objects))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("ObjectAt #%d error %v", i, err)
+		}
+		if !reflect.DeepEqual(o, objects[i]) {
+			t.Errorf("object #%d got %v, want %v", i, o, objects[i])
+		}
+		c, err := obj.Copy(o)
+		if err != nil {
+			t.Fatalf("Copy #%d error %v", i, err)
+		}
+		copies = append(copies, c)
+	}
+	if err = a.Close(); err != nil {
+		t.Errorf("Close error %v", err)
+	}
+
+	// Copies remain usable after the archive is closed.
+	for i, c := range copies {
+		if !bytes.Equal(wire.Encode(c), wire.Encode(objects[i])) {
+			t.Errorf("copy #%d differs", i)
+		}
+	}
+}
+
+func TestReadObjectShared(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	b := wire.Encode(obj.NewMessage(1, expires, 1, []byte{1, 2, 3}))
+
+	o, err := obj.ReadObjectShared(b)
+	if err != nil {
+		t.Fatalf("ReadObjectShared error %v", err)
+	}
+	c, err := obj.Copy(o)
+	if err != nil {
+		t.Fatalf("Copy error %v", err)
+	}
+
+	// Changing the input changes the shared object but not the copy.
+	b[len(b)-1] = 9
+	if got := o.(*obj.Message).Encrypted; !bytes.Equal(got, []byte{1, 2, 9}) {
+		t.Errorf("shared object got %v", got)
+	}
+	if got := c.(*obj.Message).Encrypted; !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("copy got %v", got)
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	a := obj.NewArchive([]byte{5, 1, 2})
+	if _, _, err := a.RawAt(0); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated object got error %v", err)
+	}
+	if _, _, err := a.RawAt(4); err == nil {
+		t.Errorf("offset out of range got no error")
+	}
+	if _, _, err := a.RawAt(3); err != io.EOF {
+		t.Errorf("end of archive got error %v", err)
+	}
+	big := obj.NewArchive([]byte{0xfe, 0, 0, 0x10, 0})
+	if _, _, err := big.RawAt(0); err == nil {
+		t.Errorf("oversized object got no error")
+	}
+}
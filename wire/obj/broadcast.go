@@ -10,7 +10,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"time"
 
 	"github.com/DanielKrawisz/bmutil/hash"
@@ -56,7 +55,7 @@ func (msg *TaglessBroadcast) EncodeForSigning(w io.Writer) error {
 
 func (msg *TaglessBroadcast) decodePayload(r io.Reader) error {
 	var err error
-	msg.encrypted, err = ioutil.ReadAll(r)
+	msg.encrypted, err = readRest(r)
 
 	return err
 }
@@ -178,7 +177,7 @@ func (msg *TaggedBroadcast) decodePayload(r io.Reader) error {
 		return err
 	}
 
-	msg.encrypted, err = ioutil.ReadAll(r)
+	msg.encrypted, err = readRest(r)
 
 	return err
 }
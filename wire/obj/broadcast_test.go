@@ -103,8 +103,7 @@ func TestBroadcastWire(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(test.base, test.out) {
-			t.Errorf("Decode #%d\n got: %s want: %s", i,
-				spew.Sdump(test.base), spew.Sdump(test.out))
+			t.Errorf("Decode #%d\n%s", i, obj.Diff(test.base, test.out))
 			continue
 		}
 	}
@@ -0,0 +1,205 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Difference describes a single field which differs between two objects.
+type Difference struct {
+	Field string
+	A     string
+	B     string
+}
+
+// String returns the difference in human-readable form.
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Field, d.A, d.B)
+}
+
+// Differences is a list of field differences between two objects.
+type Differences []Difference
+
+// String returns one line per difference, suitable for a test failure
+// message.
+func (d Differences) String() string {
+	lines := make([]string, len(d))
+	for i, diff := range d {
+		lines[i] = diff.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// field is a named value belonging to an object. Exactly one of str and
+// raw is used, depending on whether the field holds raw bytes.
+type field struct {
+	name  string
+	str   string
+	raw   []byte
+	isRaw bool
+}
+
+func strField(name string, v interface{}) field {
+	return field{name: name, str: fmt.Sprint(v)}
+}
+
+func rawField(name string, b []byte) field {
+	return field{name: name, raw: b, isRaw: true}
+}
+
+// Diff compares two objects field by field and returns the fields which
+// differ. The header is compared first, followed by the fields particular to
+// each kind of object. Objects which this package does not understand are
+// compared by payload. A nil result means the objects are the same.
+func Diff(a, b Object) Differences {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return nil
+		}
+		return Differences{{"Object", describe(a), describe(b)}}
+	}
+
+	var diffs Differences
+	if ta, tb := fmt.Sprintf("%T", a), fmt.Sprintf("%T", b); ta != tb {
+		diffs = append(diffs, Difference{"Type", ta, tb})
+	}
+
+	fa, fb := fields(a), fields(b)
+	for _, f := range fa {
+		g, ok := lookupField(fb, f.name)
+		if !ok {
+			diffs = append(diffs, Difference{f.name, f.value(), "<missing>"})
+			continue
+		}
+		if d, ok := diffField(f, g); ok {
+			diffs = append(diffs, d)
+		}
+	}
+	for _, g := range fb {
+		if _, ok := lookupField(fa, g.name); !ok {
+			diffs = append(diffs, Difference{g.name, "<missing>", g.value()})
+		}
+	}
+
+	return diffs
+}
+
+func describe(o Object) string {
+	if o == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%T", o)
+}
+
+func (f field) value() string {
+	if f.isRaw {
+		return fmt.Sprintf("%d bytes", len(f.raw))
+	}
+	return f.str
+}
+
+func lookupField(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// diffField compares two fields of the same name. Raw fields are reported
+// by length and the offset of the first byte which differs, since dumping
+// kilobytes of ciphertext is rarely helpful.
+func diffField(a, b field) (Difference, bool) {
+	if !a.isRaw || !b.isRaw {
+		if a.value() == b.value() {
+			return Difference{}, false
+		}
+		return Difference{a.name, a.value(), b.value()}, true
+	}
+
+	if bytes.Equal(a.raw, b.raw) {
+		return Difference{}, false
+	}
+
+	i := 0
+	for i < len(a.raw) && i < len(b.raw) && a.raw[i] == b.raw[i] {
+		i++
+	}
+	return Difference{
+		Field: fmt.Sprintf("%s[%d:]", a.name, i),
+		A:     fmt.Sprintf("%x (%d bytes)", excerpt(a.raw, i), len(a.raw)),
+		B:     fmt.Sprintf("%x (%d bytes)", excerpt(b.raw, i), len(b.raw)),
+	}, true
+}
+
+// excerpt returns up to 8 bytes of b starting at offset i.
+func excerpt(b []byte, i int) []byte {
+	end := i + 8
+	if end > len(b) {
+		end = len(b)
+	}
+	return b[i:end]
+}
+
+func headerFields(h *wire.ObjectHeader) []field {
+	if h == nil {
+		return []field{strField("Header", "<nil>")}
+	}
+	return []field{
+		strField("Nonce", h.Nonce),
+		strField("Expiration", h.Expiration().UTC()),
+		strField("ObjectType", h.ObjectType),
+		strField("Version", h.Version),
+		strField("StreamNumber", h.StreamNumber),
+	}
+}
+
+func pubKeyDataFields(d *PubKeyData, extended bool) []field {
+	if d == nil {
+		return []field{strField("Data", "<nil>")}
+	}
+	f := []field{
+		strField("Behavior", d.Behavior),
+		strField("Verification", d.Verification),
+		strField("Encryption", d.Encryption),
+	}
+	if extended {
+		f = append(f, strField("Pow", d.Pow))
+	}
+	return f
+}
+
+// fields breaks an object down into its named fields.
+func fields(o Object) []field {
+	f := headerFields(o.Header())
+
+	switch o := o.(type) {
+	case *GetPubKey:
+		f = append(f, strField("Ripe", o.Ripe), strField("Tag", o.Tag))
+	case *SimplePubKey:
+		f = append(f, pubKeyDataFields(o.data, false)...)
+	case *ExtendedPubKey:
+		f = append(f, pubKeyDataFields(o.data, true)...)
+		f = append(f, rawField("Signature", o.Signature))
+	case *EncryptedPubKey:
+		f = append(f, strField("Tag", o.Tag), rawField("Encrypted", o.Encrypted))
+	case *Message:
+		f = append(f, rawField("Encrypted", o.Encrypted))
+	case *TaglessBroadcast:
+		f = append(f, rawField("Encrypted", o.encrypted))
+	case *TaggedBroadcast:
+		f = append(f, strField("Tag", o.Tag), rawField("Encrypted", o.encrypted))
+	default:
+		f = append(f, rawField("Payload", o.Payload()))
+	}
+
+	return f
+}
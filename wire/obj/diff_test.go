@@ -0,0 +1,64 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestDiff(t *testing.T) {
+	base := obj.TstBaseMessage()
+
+	otherStream := obj.TstBaseMessage()
+	otherStream.SetHeader(wire.NewObjectHeader(123123,
+		time.Unix(0x495fab29, 0), wire.ObjectTypeMsg, 2, 2))
+
+	otherPayload := obj.TstBaseMessage()
+	otherPayload.Encrypted = append([]byte{}, base.Encrypted...)
+	otherPayload.Encrypted[20] = 0xff
+
+	shorter := obj.TstBaseMessage()
+	shorter.Encrypted = base.Encrypted[:64]
+
+	tests := []struct {
+		a, b   obj.Object
+		fields []string // Expected differing fields, in order.
+	}{
+		{base, obj.TstBaseMessage(), nil},
+		{nil, nil, nil},
+		{base, nil, []string{"Object"}},
+		{base, otherStream, []string{"StreamNumber"}},
+		{base, otherPayload, []string{"Encrypted[20:]"}},
+		{base, shorter, []string{"Encrypted[64:]"}},
+		{obj.TstTaggedBroadcast(), obj.TstTaglessBroadcast(),
+			[]string{"Type", "Version", "Tag"}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		diff := obj.Diff(test.a, test.b)
+		if len(diff) != len(test.fields) {
+			t.Errorf("Diff #%d got %d differences want %d:\n%s", i,
+				len(diff), len(test.fields), diff)
+			continue
+		}
+		for j, d := range diff {
+			if d.Field != test.fields[j] {
+				t.Errorf("Diff #%d difference %d got %s want %s", i, j,
+					d.Field, test.fields[j])
+			}
+		}
+	}
+
+	s := obj.Diff(base, otherPayload).String()
+	if !strings.Contains(s, "ff00000000000000 (128 bytes)") {
+		t.Errorf("Diff String got %q", s)
+	}
+}
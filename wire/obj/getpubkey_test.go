@@ -109,8 +109,7 @@ func TestGetPubKeyWire(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(&msg, test.out) {
-			t.Errorf("Decode #%d\n got: %s want: %s", i,
-				spew.Sdump(msg), spew.Sdump(test.out))
+			t.Errorf("Decode #%d\n%s", i, obj.Diff(&msg, test.out))
 			continue
 		}
 	}
@@ -0,0 +1,103 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Inspection summarizes an encoded object. It holds what a relay needs to
+// decide whether to accept an object and where to send it, without the
+// payload having been decoded.
+type Inspection struct {
+	Header *wire.ObjectHeader
+
+	// Tag is the tag of a v4 getpubkey, a v4 pubkey or a v5 broadcast,
+	// and nil for other objects.
+	Tag *hash.Sha
+
+	// Ripe is the ripe hash of a v2 or v3 getpubkey, and nil for other
+	// objects.
+	Ripe *hash.Ripe
+
+	// Size is the length of the encoded object, including the nonce.
+	Size int
+
+	// InventoryHash is the hash the object is known by on the network.
+	InventoryHash *hash.Sha
+
+	// PowValue is the result of the proof of work calculation for the
+	// object's nonce. Lower is better.
+	PowValue uint64
+
+	// PowValid is whether the proof of work is enough for the default
+	// network parameters at the time of the inspection.
+	PowValid bool
+}
+
+// Inspect reads the header of an encoded object, along with the tag or ripe
+// hash for object types which have one, and checks its proof of work against
+// pow.Default. Only the fixed fields at the start of the payload are looked
+// at, so an object which Inspect accepts may still fail to decode.
+func Inspect(raw []byte) (*Inspection, error) {
+	if len(raw) > wire.MaxPayloadOfMsgObject {
+		str := fmt.Sprintf("object exceeds max length of %d bytes",
+			wire.MaxPayloadOfMsgObject)
+		return nil, wire.NewMessageError("Inspect", str)
+	}
+
+	r := bytes.NewReader(raw)
+	header, err := wire.DecodeObjectHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &Inspection{
+		Header:        header,
+		Size:          len(raw),
+		InventoryHash: hash.InventoryHash(raw),
+	}
+
+	switch {
+	case header.ObjectType == wire.ObjectTypeGetPubKey &&
+		header.Version == TagGetPubKeyVersion,
+		header.ObjectType == wire.ObjectTypePubKey &&
+			header.Version == EncryptedPubKeyVersion,
+		header.ObjectType == wire.ObjectTypeBroadcast &&
+			header.Version == TaggedBroadcastVersion:
+		in.Tag = &hash.Sha{}
+		err = wire.ReadElement(r, in.Tag)
+	case header.ObjectType == wire.ObjectTypeGetPubKey &&
+		(header.Version == SimplePubKeyVersion ||
+			header.Version == ExtendedPubKeyVersion):
+		in.Ripe = &hash.Ripe{}
+		err = wire.ReadElement(r, in.Ripe)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	powHash := hash.DoubleSha512(append(header.Nonce.Bytes(), hash.Sha512(raw[8:])...))
+	in.PowValue = binary.BigEndian.Uint64(powHash[:8])
+	in.PowValid = in.SufficientPow(pow.Default, time.Now())
+
+	return in, nil
+}
+
+// SufficientPow reports whether the inspected object's proof of work meets
+// the target for the given parameters at the given time. It gives the same
+// answer as wire.MsgObject.CheckPow.
+func (in *Inspection) SufficientPow(data pow.Data, refTime time.Time) bool {
+	ttl := uint64(in.Header.Expiration().Unix() - refTime.Unix())
+	target := pow.CalculateTarget(uint64(in.Size), ttl, data)
+	return in.PowValue <= uint64(target)
+}
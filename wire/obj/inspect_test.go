@@ -0,0 +1,122 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/pow"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestInspect(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	tag := &hash.Sha{1, 2, 3}
+	ripeAddr := obj.MakeAddress(t, 3, 1, append([]byte{9}, make([]byte, 19)...))
+	tagAddr := obj.MakeAddress(t, 4, 1, make([]byte, 20))
+
+	tests := []struct {
+		in   obj.Object
+		tag  bool
+		ripe bool
+	}{
+		{obj.NewGetPubKey(1, expires, ripeAddr), false, true},
+		{obj.NewGetPubKey(2, expires, tagAddr), true, false},
+		{obj.NewEncryptedPubKey(3, expires, 1, tag, []byte{1, 2, 3}), true, false},
+		{obj.NewMessage(4, expires, 1, []byte{1, 2, 3}), false, false},
+		{obj.NewTaglessBroadcast(5, expires, 1, []byte{1, 2, 3}), false, false},
+		{obj.NewTaggedBroadcast(6, expires, 2, tag, []byte{1, 2, 3}), true, false},
+	}
+
+	for i, test := range tests {
+		raw := wire.Encode(test.in)
+		in, err := obj.Inspect(raw)
+		if err != nil {
+			t.Errorf("Inspect #%d error %v", i, err)
+			continue
+		}
+
+		h := test.in.Header()
+		if in.Header.ObjectType != h.ObjectType || in.Header.Version != h.Version ||
+			in.Header.StreamNumber != h.StreamNumber ||
+			!in.Header.Expiration().Equal(h.Expiration()) {
+			t.Errorf("Inspect #%d got header %v want %v", i, in.Header, h)
+		}
+		if in.Size != len(raw) {
+			t.Errorf("Inspect #%d got size %d want %d", i, in.Size, len(raw))
+		}
+		if !in.InventoryHash.IsEqual(obj.InventoryHash(test.in)) {
+			t.Errorf("Inspect #%d got wrong inventory hash", i)
+		}
+		if (in.Tag != nil) != test.tag || (in.Ripe != nil) != test.ripe {
+			t.Errorf("Inspect #%d got tag %v ripe %v", i, in.Tag, in.Ripe)
+		}
+		if in.PowValid {
+			t.Errorf("Inspect #%d reported valid POW without any done", i)
+		}
+	}
+
+	in, _ := obj.Inspect(wire.Encode(tests[1].in))
+	if !in.Tag.IsEqual(tests[1].in.(*obj.GetPubKey).Tag) {
+		t.Errorf("Inspect got tag %v want %v", in.Tag, tests[1].in.(*obj.GetPubKey).Tag)
+	}
+	in, _ = obj.Inspect(wire.Encode(tests[0].in))
+	if !in.Ripe.IsEqual(tests[0].in.(*obj.GetPubKey).Ripe) {
+		t.Errorf("Inspect got ripe %v want %v", in.Ripe, tests[0].in.(*obj.GetPubKey).Ripe)
+	}
+
+	// Truncated objects are rejected.
+	raw := wire.Encode(tests[2].in)
+	for _, n := range []int{10, 30} {
+		if _, err := obj.Inspect(raw[:n]); err == nil {
+			t.Errorf("Inspect of %d bytes expected error", n)
+		}
+	}
+	if _, err := obj.Inspect(make([]byte, wire.MaxPayloadOfMsgObject+1)); err == nil {
+		t.Errorf("Inspect of oversized object expected error")
+	}
+}
+
+// TestInspectPow ensures that Inspect agrees with MsgObject.CheckPow.
+func TestInspectPow(t *testing.T) {
+	data := pow.Data{
+		NonceTrialsPerByte: 1000,
+		ExtraBytes:         1000,
+	}
+	tests := []string{
+		"000000000592A44000000000555F535F00000000030100D6CFC4F94AA8BEE568985B6650029733726ED3",
+		"0000000000AFFFE700000000555F933400000000020100FE3ACFAE81F900ACB3FD28867750ACC0549DFE",
+		"000000000011935E00000000556D5FC00000000003010000AC0291E93F1E2380EA43C63DE826165D3AA2",
+	}
+	refTime := time.Unix(1432295555, 0)
+
+	for i, test := range tests {
+		b, _ := hex.DecodeString(test)
+		in, err := obj.Inspect(b)
+		if err != nil {
+			t.Errorf("Inspect #%d error %v", i, err)
+			continue
+		}
+		if !in.SufficientPow(data, refTime) {
+			t.Errorf("SufficientPow #%d returned false", i)
+		}
+
+		// Break the nonce.
+		bad := append([]byte{}, b...)
+		copy(bad, make([]byte, 8))
+		in, _ = obj.Inspect(bad)
+		msg, _ := wire.DecodeMsgObject(bad)
+		if in.SufficientPow(data, refTime) != msg.CheckPow(data, refTime) {
+			t.Errorf("SufficientPow #%d disagrees with CheckPow", i)
+		}
+		if in.SufficientPow(data, refTime) {
+			t.Errorf("SufficientPow #%d with zero nonce returned true", i)
+		}
+	}
+}
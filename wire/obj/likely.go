@@ -0,0 +1,176 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"sync"
+
+	. "github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// Likelihood is a guess at whether an object is meant for us, made without
+// trying to decrypt it.
+type Likelihood int
+
+const (
+	// Hopeless means the object cannot be for us, for example because its
+	// tag belongs to nobody we know.
+	Hopeless Likelihood = iota
+
+	// Possible means nothing in the object says who it is for, so it
+	// has to be decrypted to find out. Messages and tagless broadcasts are
+	// always Possible.
+	Possible
+
+	// Likely means the object carries a tag or ripe hash that we know, or
+	// it is an ack we are waiting for.
+	Likely
+)
+
+// Hints holds what is known about our own identities, our subscriptions and
+// the acks we are waiting for, so that objects can be sorted by how likely
+// they are to be for us. It is safe for concurrent use.
+type Hints struct {
+	mtx     sync.RWMutex
+	tags    map[hash.Sha]struct{} // our identities
+	ripes   map[hash.Ripe]struct{}
+	subTags map[hash.Sha]struct{} // subscriptions
+	acks    map[hash.Sha]struct{}
+}
+
+// NewHints returns empty Hints, by which every object other than a message
+// or tagless broadcast is Hopeless.
+func NewHints() *Hints {
+	return &Hints{
+		tags:    make(map[hash.Sha]struct{}),
+		ripes:   make(map[hash.Ripe]struct{}),
+		subTags: make(map[hash.Sha]struct{}),
+		acks:    make(map[hash.Sha]struct{}),
+	}
+}
+
+// AddAddress adds the tag and ripe hash of one of our identities, so that
+// requests for its pubkey are Likely as well as objects carrying its tag.
+func (h *Hints) AddAddress(addr Address) {
+	h.mtx.Lock()
+	h.tags[*Tag(addr)] = struct{}{}
+	h.ripes[*addr.RipeHash()] = struct{}{}
+	h.mtx.Unlock()
+}
+
+// AddSubscription adds the tag of an address we are subscribed to, so that
+// its broadcasts and pubkeys are Likely. Requests for its pubkey are not,
+// since only its owner can answer them.
+func (h *Hints) AddSubscription(addr Address) {
+	h.mtx.Lock()
+	h.subTags[*Tag(addr)] = struct{}{}
+	h.mtx.Unlock()
+}
+
+// AddAck adds the ack of a message we have sent. The ack is the encoded
+// object that the recipient will send back.
+func (h *Hints) AddAck(ack []byte) {
+	h.mtx.Lock()
+	h.acks[*hash.InventoryHash(ack)] = struct{}{}
+	h.mtx.Unlock()
+}
+
+// RemoveAck forgets an ack, once it has been received or the message has
+// expired.
+func (h *Hints) RemoveAck(ack []byte) {
+	h.mtx.Lock()
+	delete(h.acks, *hash.InventoryHash(ack))
+	h.mtx.Unlock()
+}
+
+// Classify guesses whether an object is meant for us.
+func (h *Hints) Classify(o Object) Likelihood {
+	var tag *hash.Sha
+	var ripe *hash.Ripe
+	switch m := o.(type) {
+	case *GetPubKey:
+		tag, ripe = m.Tag, m.Ripe
+	case *EncryptedPubKey:
+		tag = m.Tag
+	case *TaggedBroadcast:
+		tag = m.Tag
+	}
+
+	var invHash *hash.Sha
+	if h.expectingAcks() {
+		invHash = InventoryHash(o)
+	}
+	return h.classify(o.Header(), tag, ripe, invHash)
+}
+
+// ClassifyInspection is like Classify, but works on an object which has only
+// been inspected rather than decoded.
+func (h *Hints) ClassifyInspection(in *Inspection) Likelihood {
+	return h.classify(in.Header, in.Tag, in.Ripe, in.InventoryHash)
+}
+
+func (h *Hints) expectingAcks() bool {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return len(h.acks) > 0
+}
+
+func (h *Hints) classify(header *wire.ObjectHeader, tag *hash.Sha,
+	ripe *hash.Ripe, invHash *hash.Sha) Likelihood {
+
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	if invHash != nil {
+		if _, ok := h.acks[*invHash]; ok {
+			return Likely
+		}
+	}
+
+	if tag != nil {
+		if _, ok := h.tags[*tag]; ok {
+			return Likely
+		}
+		if header.ObjectType != wire.ObjectTypeGetPubKey {
+			if _, ok := h.subTags[*tag]; ok {
+				return Likely
+			}
+		}
+		return Hopeless
+	}
+	if ripe != nil {
+		if _, ok := h.ripes[*ripe]; ok {
+			return Likely
+		}
+		return Hopeless
+	}
+
+	switch header.ObjectType {
+	case wire.ObjectTypeMsg:
+		return Possible
+	case wire.ObjectTypeBroadcast:
+		if header.Version < TaggedBroadcastVersion {
+			return Possible
+		}
+	}
+	return Hopeless
+}
+
+// Order returns the objects which are not Hopeless, the Likely ones first.
+// Objects of the same likelihood keep their order.
+func (h *Hints) Order(objects []Object) []Object {
+	var likely, possible []Object
+	for _, o := range objects {
+		switch h.Classify(o) {
+		case Likely:
+			likely = append(likely, o)
+		case Possible:
+			possible = append(possible, o)
+		}
+	}
+	return append(likely, possible...)
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+func TestHints(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	mine := obj.MakeAddress(t, 4, 1, append([]byte{1}, make([]byte, 19)...))
+	old := obj.MakeAddress(t, 3, 1, append([]byte{2}, make([]byte, 19)...))
+	theirs := obj.MakeAddress(t, 4, 1, append([]byte{3}, make([]byte, 19)...))
+	subscribed := obj.MakeAddress(t, 4, 1, append([]byte{4}, make([]byte, 19)...))
+
+	ack := obj.NewMessage(99, expires, 1, []byte{9, 9, 9})
+	unknownTag := &hash.Sha{7}
+
+	h := obj.NewHints()
+	h.AddAddress(mine)
+	h.AddAddress(old)
+	h.AddSubscription(subscribed)
+	h.AddAck(wire.Encode(ack))
+
+	tests := []struct {
+		in   obj.Object
+		want obj.Likelihood
+	}{
+		{obj.NewGetPubKey(1, expires, mine), obj.Likely},
+		{obj.NewGetPubKey(2, expires, old), obj.Likely},
+		{obj.NewGetPubKey(3, expires, theirs), obj.Hopeless},
+		{obj.NewEncryptedPubKey(4, expires, 1, bmutil.Tag(mine), []byte{1}), obj.Likely},
+		{obj.NewEncryptedPubKey(5, expires, 1, unknownTag, []byte{1}), obj.Hopeless},
+		{obj.NewTaggedBroadcast(6, expires, 1, bmutil.Tag(mine), []byte{1}), obj.Likely},
+		{obj.NewTaggedBroadcast(7, expires, 1, unknownTag, []byte{1}), obj.Hopeless},
+		{obj.NewTaglessBroadcast(8, expires, 1, []byte{1}), obj.Possible},
+		{obj.NewMessage(9, expires, 1, []byte{1}), obj.Possible},
+		{ack, obj.Likely},
+		{obj.NewGetPubKey(10, expires, subscribed), obj.Hopeless},
+		{obj.NewTaggedBroadcast(11, expires, 1, bmutil.Tag(subscribed), []byte{1}), obj.Likely},
+		{obj.NewEncryptedPubKey(12, expires, 1, bmutil.Tag(subscribed), []byte{1}), obj.Likely},
+	}
+
+	var objects []obj.Object
+	for i, test := range tests {
+		objects = append(objects, test.in)
+		if got := h.Classify(test.in); got != test.want {
+			t.Errorf("Classify #%d got %d, want %d", i, got, test.want)
+		}
+
+		in, err := obj.Inspect(wire.Encode(test.in))
+		if err != nil {
+			t.Fatalf("Inspect #%d error %v", i, err)
+		}
+		if got := h.ClassifyInspection(in); got != test.want {
+			t.Errorf("ClassifyInspection #%d got %d, want %d", i, got, test.want)
+		}
+	}
+
+	want := []obj.Object{tests[0].in, tests[1].in, tests[3].in, tests[5].in,
+		tests[9].in, tests[11].in, tests[12].in, tests[7].in, tests[8].in}
+	if got := h.Order(objects); !reflect.DeepEqual(got, want) {
+		t.Errorf("Order got %v, want %v", got, want)
+	}
+
+	h.RemoveAck(wire.Encode(ack))
+	if got := h.Classify(ack); got != obj.Possible {
+		t.Errorf("Classify after RemoveAck got %d, want %d", got, obj.Possible)
+	}
+}
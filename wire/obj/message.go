@@ -10,7 +10,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"time"
 
 	"github.com/DanielKrawisz/bmutil/pow"
@@ -31,7 +30,7 @@ type Message struct {
 
 func (msg *Message) decodePayload(r io.Reader) error {
 	var err error
-	msg.Encrypted, err = ioutil.ReadAll(r)
+	msg.Encrypted, err = readRest(r)
 
 	return err
 }
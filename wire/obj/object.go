@@ -7,9 +7,11 @@ package obj
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/DanielKrawisz/bmutil/wire"
 )
@@ -77,7 +79,7 @@ func DecodeObject(r io.Reader) (Object, error) {
 		}
 	}
 
-	payload, err := ioutil.ReadAll(r)
+	payload, err := readRest(r)
 	if err != nil {
 		return nil, err
 	}
@@ -90,3 +92,41 @@ func ReadObject(obj []byte) (Object, error) {
 	r := bytes.NewReader(obj)
 	return DecodeObject(r)
 }
+
+// DecodeObjectWithOptions is like DecodeObject except that the object is
+// also checked against the given decode options. Since an object extends to
+// the end of its input, r is read until EOF.
+func DecodeObjectWithOptions(r io.Reader, opts *bmutil.DecodeOptions) (Object, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, wire.MaxPayloadOfMsgObject+1))
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadObjectWithOptions(b, opts)
+}
+
+// ReadObjectWithOptions is like ReadObject except that the object is also
+// checked against the given decode options.
+func ReadObjectWithOptions(b []byte, opts *bmutil.DecodeOptions) (Object, error) {
+	if len(b) > wire.MaxPayloadOfMsgObject {
+		str := fmt.Sprintf("object exceeds max length of %d bytes",
+			wire.MaxPayloadOfMsgObject)
+		return nil, wire.NewMessageError("ReadObject", str)
+	}
+	if err := opts.CheckSize(len(b), "ReadObject"); err != nil {
+		return nil, err
+	}
+
+	obj, err := ReadObject(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil {
+		if err = opts.CheckEncoding(b, wire.Encode(obj)); err != nil {
+			return nil, err
+		}
+	}
+
+	return obj, nil
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/DanielKrawisz/bmutil"
 	"github.com/DanielKrawisz/bmutil/hash"
 	"github.com/DanielKrawisz/bmutil/pow"
 	"github.com/DanielKrawisz/bmutil/wire"
@@ -164,3 +165,28 @@ func TestObject(t *testing.T) {
 		}
 	}
 }
+
+// TestReadObjectWithOptions ensures that strict decode options reject
+// objects with data after the end.
+func TestReadObjectWithOptions(t *testing.T) {
+	expires := time.Unix(0x495fab29, 0)
+	msg := obj.NewGetPubKey(123123, expires, obj.MakeAddress(t, 4, 1, make([]byte, 20)))
+	b := wire.Encode(msg)
+	trailing := append(append([]byte{}, b...), 0x00)
+
+	if _, err := obj.ReadObjectWithOptions(b, &bmutil.StrictDecodeOptions); err != nil {
+		t.Errorf("ReadObjectWithOptions got error %v", err)
+	}
+	if _, err := obj.ReadObjectWithOptions(trailing, nil); err != nil {
+		t.Errorf("ReadObjectWithOptions lenient got error %v", err)
+	}
+	_, err := obj.DecodeObjectWithOptions(bytes.NewReader(trailing), &bmutil.StrictDecodeOptions)
+	if err != bmutil.ErrTrailingData {
+		t.Errorf("DecodeObjectWithOptions got error %v want %v", err,
+			bmutil.ErrTrailingData)
+	}
+	_, err = obj.ReadObjectWithOptions(b, &bmutil.DecodeOptions{MaxSize: len(b) - 1})
+	if err == nil {
+		t.Errorf("ReadObjectWithOptions with size limit got no error")
+	}
+}
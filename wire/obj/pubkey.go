@@ -10,7 +10,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"time"
 
 	"github.com/DanielKrawisz/bmutil"
@@ -51,21 +50,8 @@ func EncodePubKeySignature(w io.Writer, signature []byte) (err error) {
 }
 
 // DecodePubKeySignature decodes a PubKey signature.
-func DecodePubKeySignature(r io.Reader) (signature []byte, err error) {
-	sigLength, err := bmutil.ReadVarInt(r)
-	if err != nil {
-		return
-	}
-	if sigLength > SignatureMaxLength {
-		str := fmt.Sprintf("signature length exceeds max length - "+
-			"indicates %d, but max length is %d",
-			sigLength, SignatureMaxLength)
-		err = wire.NewMessageError("Decode", str)
-		return
-	}
-	signature = make([]byte, sigLength)
-	_, err = io.ReadFull(r, signature)
-	return
+func DecodePubKeySignature(r io.Reader) ([]byte, error) {
+	return wire.ReadVarBytes(r, SignatureMaxLength, "signature", "Decode")
 }
 
 // SimplePubKey implements the Message and Object interfaces and represents a pubkey sent in
@@ -388,7 +374,7 @@ func (p *EncryptedPubKey) decodePayload(r io.Reader) error {
 	}
 	// The rest is the encrypted data, accessible only to those that know
 	// the address that the pubkey belongs to.
-	p.Encrypted, err = ioutil.ReadAll(r)
+	p.Encrypted, err = readRest(r)
 	return err
 }
 
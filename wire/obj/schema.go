@@ -0,0 +1,184 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/DanielKrawisz/bmutil"
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+// The types of the fields in a Schema.
+const (
+	// FieldUint32 and FieldUint64 are big-endian integers.
+	FieldUint32 = "uint32"
+	FieldUint64 = "uint64"
+
+	// FieldVarInt is a var_int.
+	FieldVarInt = "var_int"
+
+	// FieldBytes is a fixed number of bytes, given by the field's Size.
+	FieldBytes = "bytes"
+
+	// FieldVarBytes is a var_int length followed by that many bytes.
+	FieldVarBytes = "var_bytes"
+
+	// FieldRest is the rest of the object, such as encrypted data.
+	FieldRest = "rest"
+)
+
+// SchemaField is one field in the wire layout of an object.
+type SchemaField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Size is the number of bytes the field takes up, or zero if that
+	// varies.
+	Size int `json:"size,omitempty"`
+}
+
+// Schema describes the wire layout of one version of one type of object,
+// starting with the object header. They are listed by Schemas and kept in
+// step with the encoders by the tests, which check that each object the
+// package encodes is split into fields by its schema with nothing left
+// over.
+type Schema struct {
+	Name       string          `json:"name"`
+	ObjectType wire.ObjectType `json:"object_type"`
+	Version    uint64          `json:"version"`
+	Fields     []SchemaField   `json:"fields"`
+}
+
+var headerSchema = []SchemaField{
+	{"nonce", FieldUint64, 8},
+	{"expiration", FieldUint64, 8},
+	{"object_type", FieldUint32, 4},
+	{"version", FieldVarInt, 0},
+	{"stream", FieldVarInt, 0},
+}
+
+var pubKeySchema = []SchemaField{
+	{"behavior", FieldUint32, 4},
+	{"signing_key", FieldBytes, wire.PubKeySize},
+	{"encryption_key", FieldBytes, wire.PubKeySize},
+}
+
+func newSchema(name string, objType wire.ObjectType, version uint64,
+	fields ...[]SchemaField) Schema {
+
+	s := Schema{
+		Name:       name,
+		ObjectType: objType,
+		Version:    version,
+		Fields:     append([]SchemaField(nil), headerSchema...),
+	}
+	for _, f := range fields {
+		s.Fields = append(s.Fields, f...)
+	}
+	return s
+}
+
+var schemas = []Schema{
+	newSchema("getpubkey", wire.ObjectTypeGetPubKey, SimplePubKeyVersion,
+		[]SchemaField{{"ripe", FieldBytes, hash.RipeSize}}),
+	newSchema("getpubkey", wire.ObjectTypeGetPubKey, ExtendedPubKeyVersion,
+		[]SchemaField{{"ripe", FieldBytes, hash.RipeSize}}),
+	newSchema("getpubkey", wire.ObjectTypeGetPubKey, TagGetPubKeyVersion,
+		[]SchemaField{{"tag", FieldBytes, hash.ShaSize}}),
+	newSchema("pubkey", wire.ObjectTypePubKey, SimplePubKeyVersion, pubKeySchema),
+	newSchema("pubkey", wire.ObjectTypePubKey, ExtendedPubKeyVersion, pubKeySchema,
+		[]SchemaField{
+			{"nonce_trials_per_byte", FieldVarInt, 0},
+			{"extra_bytes", FieldVarInt, 0},
+			{"signature", FieldVarBytes, 0},
+		}),
+	newSchema("pubkey", wire.ObjectTypePubKey, EncryptedPubKeyVersion,
+		[]SchemaField{
+			{"tag", FieldBytes, hash.ShaSize},
+			{"encrypted", FieldRest, 0},
+		}),
+	newSchema("msg", wire.ObjectTypeMsg, MessageVersion,
+		[]SchemaField{{"encrypted", FieldRest, 0}}),
+	newSchema("broadcast", wire.ObjectTypeBroadcast, TaglessBroadcastVersion,
+		[]SchemaField{{"encrypted", FieldRest, 0}}),
+	newSchema("broadcast", wire.ObjectTypeBroadcast, TaggedBroadcastVersion,
+		[]SchemaField{
+			{"tag", FieldBytes, hash.ShaSize},
+			{"encrypted", FieldRest, 0},
+		}),
+}
+
+// Schemas returns the schemas of every object this package understands,
+// ordered by object type and version.
+func Schemas() []Schema {
+	list := make([]Schema, len(schemas))
+	for i, s := range schemas {
+		list[i] = s
+		list[i].Fields = append([]SchemaField(nil), s.Fields...)
+	}
+	return list
+}
+
+// LookupSchema returns the schema for the given object type and version, if
+// there is one.
+func LookupSchema(objType wire.ObjectType, version uint64) (Schema, bool) {
+	for _, s := range Schemas() {
+		if s.ObjectType == objType && s.Version == version {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+// WriteSchemas writes every schema to w as JSON.
+func WriteSchemas(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Schemas())
+}
+
+// Split breaks an encoded object into the fields given by the schema. An
+// error is returned if the object is too short for the schema or has bytes
+// left over.
+func (s *Schema) Split(b []byte) ([][]byte, error) {
+	parts := make([][]byte, len(s.Fields))
+	for i, f := range s.Fields {
+		size := f.Size
+		switch f.Type {
+		case FieldVarInt:
+			_, n, err := bmutil.ReadVarIntBuf(b)
+			if err != nil {
+				return nil, err
+			}
+			size = n
+		case FieldVarBytes:
+			length, n, err := bmutil.ReadVarIntBuf(b)
+			if err != nil {
+				return nil, err
+			}
+			if length > uint64(len(b)-n) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			size = n + int(length)
+		case FieldRest:
+			size = len(b)
+		}
+		if size > len(b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		parts[i], b = b[:size], b[size:]
+	}
+
+	if len(b) != 0 {
+		str := fmt.Sprintf("%d bytes left over after %s version %d",
+			len(b), s.Name, s.Version)
+		return nil, wire.NewMessageError("Schema.Split", str)
+	}
+	return parts, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package obj_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/hash"
+	"github.com/DanielKrawisz/bmutil/wire"
+	"github.com/DanielKrawisz/bmutil/wire/obj"
+)
+
+// TestSchemas checks that every schema matches what the encoders write.
+func TestSchemas(t *testing.T) {
+	pub1, pub2 := &wire.PubKey{1}, &wire.PubKey{2}
+	objects := []obj.Object{
+		obj.TstBaseGetPubKey(),
+		obj.TstTagGetPubKey(),
+		obj.TstBasePubKey(pub1, pub2),
+		obj.TstExpandedPubKey(pub1, pub2),
+		obj.TstEncryptedPubKey(&hash.Sha{3}),
+		obj.NewMessage(0, time.Unix(0x495fab29, 0), 1, obj.TstBaseMessage().Encrypted),
+		obj.TstTaglessBroadcast(),
+		obj.TstTaggedBroadcast(),
+	}
+
+	covered := make(map[wire.ObjectType]map[uint64]bool)
+	for i, o := range objects {
+		h := o.Header()
+		s, ok := obj.LookupSchema(h.ObjectType, h.Version)
+		if !ok {
+			t.Errorf("#%d no schema for %s version %d", i, h.ObjectType, h.Version)
+			continue
+		}
+		if covered[h.ObjectType] == nil {
+			covered[h.ObjectType] = make(map[uint64]bool)
+		}
+		covered[h.ObjectType][h.Version] = true
+
+		encoded := wire.Encode(o)
+		parts, err := s.Split(encoded)
+		if err != nil {
+			t.Errorf("#%d Split error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(bytes.Join(parts, nil), encoded) {
+			t.Errorf("#%d parts do not make up the object", i)
+		}
+		for j, f := range s.Fields {
+			if f.Size != 0 && len(parts[j]) != f.Size {
+				t.Errorf("#%d field %s got %d bytes, want %d", i, f.Name,
+					len(parts[j]), f.Size)
+			}
+		}
+
+		if _, err = s.Split(append(encoded, 0)); err == nil && s.Fields[len(s.Fields)-1].Type != obj.FieldRest {
+			t.Errorf("#%d Split accepted an extra byte", i)
+		}
+		if _, err = s.Split(encoded[:20]); err == nil {
+			t.Errorf("#%d Split accepted a truncated object", i)
+		}
+	}
+
+	// Every schema but the getpubkey for v2 addresses, which is encoded
+	// the same as for v3, has been checked.
+	for _, s := range obj.Schemas() {
+		if !covered[s.ObjectType][s.Version] && !(s.ObjectType == wire.ObjectTypeGetPubKey &&
+			s.Version == obj.SimplePubKeyVersion) {
+			t.Errorf("schema %s version %d was not checked", s.Name, s.Version)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := obj.WriteSchemas(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []obj.Schema
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(obj.Schemas()) || decoded[0].Fields[0].Name != "nonce" {
+		t.Errorf("got schemas %v", decoded)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrQueueClosed is returned by OutboundQueue.Pop once the queue has been
+// closed.
+var ErrQueueClosed = errors.New("outbound queue closed")
+
+// Priority is the order in which queued objects are sent. Lower values go
+// first.
+type Priority int
+
+const (
+	// PriorityAck is for acks, which the sender of a message is waiting on.
+	PriorityAck Priority = iota
+
+	// PriorityPubKey is for pubkeys and requests for them, without which
+	// messages cannot be sent.
+	PriorityPubKey
+
+	// PriorityMessage is for new messages and broadcasts.
+	PriorityMessage
+
+	// PriorityRebroadcast is for objects which are being sent again, such
+	// as when relaying them to a newly connected peer.
+	PriorityRebroadcast
+
+	numPriorities = iota
+)
+
+// String returns the priority in human-readable form.
+func (p Priority) String() string {
+	switch p {
+	case PriorityAck:
+		return "ack"
+	case PriorityPubKey:
+		return "pubkey"
+	case PriorityMessage:
+		return "message"
+	case PriorityRebroadcast:
+		return "rebroadcast"
+	}
+	return fmt.Sprintf("Unknown Priority (%d)", int(p))
+}
+
+// ObjectPriority returns the priority of a new object, by its type. Acks look
+// like any other msg object and rebroadcasts like any other object, so the
+// caller has to know when to use PriorityAck or PriorityRebroadcast instead.
+func ObjectPriority(msg *MsgObject) Priority {
+	switch msg.Header().ObjectType {
+	case ObjectTypeGetPubKey, ObjectTypePubKey:
+		return PriorityPubKey
+	default:
+		return PriorityMessage
+	}
+}
+
+// Queued is an object waiting in an OutboundQueue.
+type Queued struct {
+	Peer     string
+	Priority Priority
+	Object   *MsgObject
+
+	q *OutboundQueue
+}
+
+// Cancel removes the object from the queue. It returns false if the object
+// has already been taken by Pop or removed.
+func (e *Queued) Cancel() bool {
+	e.q.mtx.Lock()
+	defer e.q.mtx.Unlock()
+
+	p, ok := e.q.peers[e.Peer]
+	if !ok {
+		return false
+	}
+	i, ok := p.find(e)
+	if !ok {
+		return false
+	}
+	p.remove(e.Priority, i)
+	e.q.len--
+	return true
+}
+
+// peerQueue is the part of an OutboundQueue for one peer.
+type peerQueue struct {
+	queued [numPriorities][]*Queued
+
+	// served is when the peer was last served, as a count of Pops.
+	served uint64
+}
+
+func (p *peerQueue) find(e *Queued) (int, bool) {
+	for i, f := range p.queued[e.Priority] {
+		if f == e {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (p *peerQueue) remove(pr Priority, i int) {
+	s := p.queued[pr]
+	copy(s[i:], s[i+1:])
+	s[len(s)-1] = nil
+	p.queued[pr] = s[:len(s)-1]
+}
+
+// OutboundQueue holds objects to be sent to peers. Objects are taken from it
+// with Pop in order of priority. Within a priority, peers take turns, so that
+// one peer with a long queue does not hold up the others, and the objects
+// for each peer are taken in the order they were pushed. It is safe for
+// concurrent use, so a relay can push objects from anywhere and send them
+// from one goroutine.
+type OutboundQueue struct {
+	mtx    sync.Mutex
+	cond   *sync.Cond
+	peers  map[string]*peerQueue
+	len    int
+	pops   uint64
+	closed bool
+}
+
+// NewOutboundQueue returns an empty OutboundQueue.
+func NewOutboundQueue() *OutboundQueue {
+	q := &OutboundQueue{
+		peers: make(map[string]*peerQueue),
+	}
+	q.cond = sync.NewCond(&q.mtx)
+	return q
+}
+
+// Push adds an object to be sent to the peer with the given priority. The
+// returned Queued can be used to cancel it.
+func (q *OutboundQueue) Push(peer string, priority Priority, msg *MsgObject) *Queued {
+	if priority < 0 || priority >= numPriorities {
+		priority = PriorityRebroadcast
+	}
+	e := &Queued{
+		Peer:     peer,
+		Priority: priority,
+		Object:   msg,
+		q:        q,
+	}
+
+	q.mtx.Lock()
+	p, ok := q.peers[peer]
+	if !ok {
+		p = &peerQueue{}
+		q.peers[peer] = p
+	}
+	p.queued[priority] = append(p.queued[priority], e)
+	q.len++
+	q.mtx.Unlock()
+
+	q.cond.Signal()
+	return e
+}
+
+// Pop removes and returns the next object to send, waiting until there is
+// one. It returns ErrQueueClosed once the queue is closed.
+func (q *OutboundQueue) Pop() (*Queued, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for q.len == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	return q.pop(), nil
+}
+
+// TryPop is like Pop, except that it returns nil at once if the queue is
+// empty or closed.
+func (q *OutboundQueue) TryPop() *Queued {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.len == 0 || q.closed {
+		return nil
+	}
+	return q.pop()
+}
+
+// pop takes the next object from a queue which is not empty. The peer
+// served longest ago which has an object of the highest priority waiting
+// goes next, with ties broken by name so that the order is predictable.
+func (q *OutboundQueue) pop() *Queued {
+	for pr := Priority(0); pr < numPriorities; pr++ {
+		var next *peerQueue
+		var nextPeer string
+		for peer, p := range q.peers {
+			if len(p.queued[pr]) == 0 {
+				continue
+			}
+			if next == nil || p.served < next.served ||
+				(p.served == next.served && peer < nextPeer) {
+				next, nextPeer = p, peer
+			}
+		}
+		if next == nil {
+			continue
+		}
+
+		e := next.queued[pr][0]
+		next.remove(pr, 0)
+		q.len--
+		q.pops++
+		next.served = q.pops
+		return e
+	}
+	return nil
+}
+
+// Len returns the number of objects waiting.
+func (q *OutboundQueue) Len() int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return q.len
+}
+
+// PeerLen returns the number of objects waiting for the peer.
+func (q *OutboundQueue) PeerLen(peer string) int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	p, ok := q.peers[peer]
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, s := range p.queued {
+		n += len(s)
+	}
+	return n
+}
+
+// RemovePeer cancels everything waiting for the peer, as when it
+// disconnects, and returns the number of objects removed.
+func (q *OutboundQueue) RemovePeer(peer string) int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	p, ok := q.peers[peer]
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, s := range p.queued {
+		n += len(s)
+	}
+	delete(q.peers, peer)
+	q.len -= n
+	return n
+}
+
+// Close wakes any goroutines waiting in Pop, which return ErrQueueClosed.
+// Objects still waiting are dropped.
+func (q *OutboundQueue) Close() {
+	q.mtx.Lock()
+	q.closed = true
+	q.peers = make(map[string]*peerQueue)
+	q.len = 0
+	q.mtx.Unlock()
+
+	q.cond.Broadcast()
+}
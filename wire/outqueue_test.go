@@ -0,0 +1,103 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestOutboundQueue(t *testing.T) {
+	object := func(objType wire.ObjectType) *wire.MsgObject {
+		header := wire.NewObjectHeader(0, time.Now(), objType, 1, 1)
+		return wire.NewMsgObject(header, []byte{byte(objType)})
+	}
+	msg := object(wire.ObjectTypeMsg)
+	pubkey := object(wire.ObjectTypePubKey)
+
+	if p := wire.ObjectPriority(pubkey); p != wire.PriorityPubKey {
+		t.Errorf("pubkey: got priority %s", p)
+	}
+	if p := wire.ObjectPriority(msg); p != wire.PriorityMessage {
+		t.Errorf("msg: got priority %s", p)
+	}
+
+	q := wire.NewOutboundQueue()
+	q.Push("a", wire.PriorityRebroadcast, msg)
+	q.Push("a", wire.PriorityMessage, msg)
+	q.Push("a", wire.PriorityMessage, msg)
+	q.Push("a", wire.PriorityMessage, msg)
+	q.Push("b", wire.PriorityMessage, msg)
+	q.Push("b", wire.PriorityPubKey, pubkey)
+	cancelled := q.Push("c", wire.PriorityAck, msg)
+	q.Push("c", wire.PriorityAck, msg)
+	q.Push("c", wire.PriorityMessage, msg)
+
+	if !cancelled.Cancel() {
+		t.Error("Cancel returned false")
+	}
+	if cancelled.Cancel() {
+		t.Error("second Cancel returned true")
+	}
+
+	type popped struct {
+		peer     string
+		priority wire.Priority
+	}
+	expected := []popped{
+		{"c", wire.PriorityAck},
+		{"b", wire.PriorityPubKey},
+		{"a", wire.PriorityMessage},
+		{"c", wire.PriorityMessage},
+		{"b", wire.PriorityMessage},
+		{"a", wire.PriorityMessage},
+		{"a", wire.PriorityMessage},
+		{"a", wire.PriorityRebroadcast},
+	}
+	if q.Len() != len(expected) {
+		t.Fatalf("got Len %d, want %d", q.Len(), len(expected))
+	}
+	for i, want := range expected {
+		e := q.TryPop()
+		if e == nil {
+			t.Fatalf("pop %d: queue empty", i)
+		}
+		if e.Peer != want.peer || e.Priority != want.priority {
+			t.Errorf("pop %d: got %s %s, want %s %s", i, e.Peer, e.Priority,
+				want.peer, want.priority)
+		}
+		if e.Cancel() {
+			t.Errorf("pop %d: Cancel after Pop returned true", i)
+		}
+	}
+	if e := q.TryPop(); e != nil {
+		t.Errorf("got %v from empty queue", e)
+	}
+
+	q.Push("a", wire.PriorityMessage, msg)
+	q.Push("a", wire.PriorityAck, msg)
+	q.Push("b", wire.PriorityMessage, msg)
+	if n := q.RemovePeer("a"); n != 2 {
+		t.Errorf("RemovePeer removed %d, want 2", n)
+	}
+	if q.PeerLen("a") != 0 || q.PeerLen("b") != 1 {
+		t.Errorf("got PeerLen %d and %d", q.PeerLen("a"), q.PeerLen("b"))
+	}
+	if e, err := q.Pop(); err != nil || e.Peer != "b" {
+		t.Errorf("Pop got %v, %v", e, err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := q.Pop()
+		done <- err
+	}()
+	q.Close()
+	if err := <-done; err != wire.ErrQueueClosed {
+		t.Errorf("Pop after Close got %v", err)
+	}
+}
@@ -25,11 +25,95 @@ type ServiceFlag uint64
 const (
 	// SFNodeNetwork is a flag used to indicate a peer is a full node.
 	SFNodeNetwork ServiceFlag = 1 << iota
+
+	// SFNodeSSL is a flag used to indicate a peer supports upgrading the
+	// connection to TLS after the version handshake.
+	SFNodeSSL
+
+	// SFNodePOW is a flag used to indicate a peer is willing to do proof of
+	// work on behalf of others.
+	SFNodePOW
+
+	// SFNodeDandelion is a flag used to indicate a peer supports Dandelion
+	// stem routing of objects.
+	SFNodeDandelion
 )
 
+const (
+	// SFExtCompression is an extension flag used to indicate a peer accepts
+	// compressed message payloads.
+	SFExtCompression ServiceFlag = 1 << (32 + iota)
+
+	// SFExtTypedInv is an extension flag used to indicate a peer understands
+	// typed inventory messages.
+	SFExtTypedInv
+
+	// SFExtInvDigest is an extension flag used to indicate a peer can
+	// reconcile inventories using invdigest messages.
+	SFExtInvDigest
+
+	// SFExtInvCap is an extension flag used to indicate a peer's version
+	// message ends with the largest number of inventory vectors it will
+	// accept in a single message.
+	SFExtInvCap
+)
+
+// SFExtensionMask covers the upper 32 bits of the services field, which
+// are set aside for experimental extensions so that they will not collide
+// with flags assigned by the reference client.
+const SFExtensionMask ServiceFlag = 0xffffffff00000000
+
 // Map of service flags back to their constant names for pretty printing.
 var sfStrings = map[ServiceFlag]string{
-	SFNodeNetwork: "SFNodeNetwork",
+	SFNodeNetwork:    "SFNodeNetwork",
+	SFNodeSSL:        "SFNodeSSL",
+	SFNodePOW:        "SFNodePOW",
+	SFNodeDandelion:  "SFNodeDandelion",
+	SFExtCompression: "SFExtCompression",
+	SFExtTypedInv:    "SFExtTypedInv",
+	SFExtInvDigest:   "SFExtInvDigest",
+	SFExtInvCap:      "SFExtInvCap",
+}
+
+// KnownServices is the set of all service flags this package has a name for.
+const KnownServices = SFNodeNetwork | SFNodeSSL | SFNodePOW | SFNodeDandelion |
+	SFExtCompression | SFExtTypedInv | SFExtInvDigest | SFExtInvCap
+
+// Has returns whether all the bits of the given flag are set.
+func (f ServiceFlag) Has(flag ServiceFlag) bool {
+	return f&flag == flag
+}
+
+// Unknown returns the bits of f that do not correspond to any known
+// service flag.
+func (f ServiceFlag) Unknown() ServiceFlag {
+	return f &^ KnownServices
+}
+
+// Validate returns an error if any bits are set that do not correspond to a
+// known service flag.
+func (f ServiceFlag) Validate() error {
+	if unknown := f.Unknown(); unknown != 0 {
+		str := fmt.Sprintf("unknown service flags 0x%x", uint64(unknown))
+		return NewMessageError("ServiceFlag.Validate", str)
+	}
+	return nil
+}
+
+// Names returns the names of the known flags which are set, in order of
+// increasing bit position.
+func (f ServiceFlag) Names() []string {
+	var names []string
+	for bit := uint(0); bit < 64; bit++ {
+		flag := ServiceFlag(1) << bit
+		if f&flag == 0 {
+			continue
+		}
+		if name, ok := sfStrings[flag]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -39,21 +123,15 @@ func (f ServiceFlag) String() string {
 		return "0x0"
 	}
 
-	// Add individual bit flags.
-	s := ""
-	for flag, name := range sfStrings {
-		if f&flag == flag {
-			s += name + "|"
-			f -= flag
+	// Add individual bit flags, followed by any remaining flags which
+	// aren't accounted for as hex.
+	s := strings.Join(f.Names(), "|")
+	if unknown := f.Unknown(); unknown != 0 {
+		if s != "" {
+			s += "|"
 		}
+		s += "0x" + strconv.FormatUint(uint64(unknown), 16)
 	}
-
-	// Add any remaining flags which aren't accounted for as hex.
-	s = strings.TrimRight(s, "|")
-	if f != 0 {
-		s += "|0x" + strconv.FormatUint(uint64(f), 16)
-	}
-	s = strings.TrimLeft(s, "|")
 	return s
 }
 
@@ -9,6 +9,7 @@
 package wire_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/DanielKrawisz/bmutil/wire"
@@ -22,7 +23,9 @@ func TestServiceFlagStringer(t *testing.T) {
 	}{
 		{0, "0x0"},
 		{wire.SFNodeNetwork, "SFNodeNetwork"},
-		{0xffffffff, "SFNodeNetwork|0xfffffffe"},
+		{0xffffffff, "SFNodeNetwork|SFNodeSSL|SFNodePOW|SFNodeDandelion|0xfffffff0"},
+		{wire.SFExtTypedInv | wire.SFNodeSSL, "SFNodeSSL|SFExtTypedInv"},
+		{1 << 40, "0x10000000000"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -36,6 +39,47 @@ func TestServiceFlagStringer(t *testing.T) {
 	}
 }
 
+// TestServiceFlagHelpers tests Has, Names and Validate on service flags.
+func TestServiceFlagHelpers(t *testing.T) {
+	tests := []struct {
+		in    wire.ServiceFlag
+		has   wire.ServiceFlag
+		want  bool
+		names []string
+		valid bool
+	}{
+		{0, wire.SFNodeNetwork, false, nil, true},
+		{wire.SFNodeNetwork, wire.SFNodeNetwork, true,
+			[]string{"SFNodeNetwork"}, true},
+		{wire.SFNodeNetwork | wire.SFNodeDandelion,
+			wire.SFNodeNetwork | wire.SFNodeDandelion, true,
+			[]string{"SFNodeNetwork", "SFNodeDandelion"}, true},
+		{wire.SFNodeNetwork, wire.SFNodeNetwork | wire.SFNodeSSL, false,
+			[]string{"SFNodeNetwork"}, true},
+		{wire.SFExtCompression | 1<<20, wire.SFExtCompression, true,
+			[]string{"SFExtCompression"}, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.in.Has(test.has); got != test.want {
+			t.Errorf("Has #%d got: %v want: %v", i, got, test.want)
+		}
+		if got := test.in.Names(); !reflect.DeepEqual(got, test.names) {
+			t.Errorf("Names #%d got: %v want: %v", i, got, test.names)
+		}
+		err := test.in.Validate()
+		if test.valid && err != nil {
+			t.Errorf("Validate #%d error %v", i, err)
+		}
+		if !test.valid {
+			if _, ok := err.(*wire.MessageError); !ok {
+				t.Errorf("Validate #%d got: %v want MessageError", i, err)
+			}
+		}
+	}
+}
+
 // TestBitmessageNetStringer tests the stringized output for bitmessage net types.
 func TestBitmessageNetStringer(t *testing.T) {
 	tests := []struct {
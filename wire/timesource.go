@@ -0,0 +1,111 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxClockSkew is the largest difference between the clocks of two
+	// peers which the reference client accepts. Peers whose clocks are
+	// further off are disconnected, so their samples are not counted by a
+	// MedianTimeSource.
+	MaxClockSkew = time.Hour
+
+	// maxMedianSamples is the number of peers a MedianTimeSource remembers.
+	maxMedianSamples = 200
+
+	// minMedianSamples is the number of peers a MedianTimeSource needs
+	// before it adjusts the time, so that one or two peers can't move it.
+	minMedianSamples = 5
+)
+
+// ClockSkew returns how far ahead of ours the clock of a peer is, judging by
+// the timestamp in its version message, which was received at the given
+// time. If the round trip time to the peer is known, half of it is taken to
+// be the time the message was in transit. The timestamp is only given to the
+// second, so the result is not more precise than that.
+func ClockSkew(remote *MsgVersion, received time.Time, roundTrip time.Duration) time.Duration {
+	return remote.Timestamp.Sub(received.Add(-roundTrip / 2))
+}
+
+// MedianTimeSource estimates the time of the network from the clock skews
+// of its peers, in the same way as btcd's. It keeps one sample per peer and
+// adjusts the local time by the median, once it has enough samples. It can
+// be used to decide whether objects have expired or have expirations too
+// far in the future. It is safe for concurrent use.
+type MedianTimeSource struct {
+	mtx     sync.Mutex
+	samples map[string]time.Duration
+	order   []string
+	offset  time.Duration
+}
+
+// NewMedianTimeSource returns a MedianTimeSource with no samples.
+func NewMedianTimeSource() *MedianTimeSource {
+	return &MedianTimeSource{
+		samples: make(map[string]time.Duration),
+	}
+}
+
+// AddSample records the clock skew of a peer, identified by its address. A
+// later sample from the same peer replaces the earlier one. Samples of more
+// than MaxClockSkew are ignored, and once there are too many samples the
+// oldest is forgotten.
+func (s *MedianTimeSource) AddSample(peer string, skew time.Duration) {
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.samples[peer]; !ok {
+		if len(s.order) == maxMedianSamples {
+			delete(s.samples, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, peer)
+	}
+	s.samples[peer] = skew
+
+	if len(s.samples) < minMedianSamples {
+		s.offset = 0
+		return
+	}
+	skews := make([]time.Duration, 0, len(s.samples))
+	for _, d := range s.samples {
+		skews = append(skews, d)
+	}
+	sort.Slice(skews, func(i, j int) bool { return skews[i] < skews[j] })
+	if n := len(skews); n%2 == 1 {
+		s.offset = skews[n/2]
+	} else {
+		s.offset = (skews[n/2-1] + skews[n/2]) / 2
+	}
+}
+
+// Samples returns the number of peers whose samples are counted.
+func (s *MedianTimeSource) Samples() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.samples)
+}
+
+// Offset returns the difference between the network's time and ours.
+func (s *MedianTimeSource) Offset() time.Duration {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.offset
+}
+
+// AdjustedTime returns the current time adjusted by Offset. It can be given
+// to MsgObject.CheckPow in place of time.Now().
+func (s *MedianTimeSource) AdjustedTime() time.Time {
+	return time.Now().Add(s.Offset())
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Daniel Krawisz.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DanielKrawisz/bmutil/wire"
+)
+
+func TestClockSkew(t *testing.T) {
+	received := time.Unix(1000000, 0)
+	msg := &wire.MsgVersion{Timestamp: received.Add(10 * time.Second)}
+
+	if skew := wire.ClockSkew(msg, received, 0); skew != 10*time.Second {
+		t.Errorf("got skew %v", skew)
+	}
+	// The message was sent a second before it was received.
+	if skew := wire.ClockSkew(msg, received, 2*time.Second); skew != 11*time.Second {
+		t.Errorf("got skew %v with round trip", skew)
+	}
+}
+
+func TestMedianTimeSource(t *testing.T) {
+	s := wire.NewMedianTimeSource()
+	if s.Offset() != 0 {
+		t.Errorf("got offset %v with no samples", s.Offset())
+	}
+
+	// Too few samples to adjust the time.
+	for i := 0; i < 4; i++ {
+		s.AddSample(fmt.Sprint(i), time.Minute)
+	}
+	if s.Offset() != 0 {
+		t.Errorf("got offset %v with %d samples", s.Offset(), s.Samples())
+	}
+
+	s.AddSample("4", -time.Minute)
+	if s.Offset() != time.Minute {
+		t.Errorf("got offset %v, want %v", s.Offset(), time.Minute)
+	}
+
+	// A peer's new sample replaces its old one.
+	s.AddSample("0", -time.Minute)
+	s.AddSample("1", -3*time.Minute)
+	if s.Samples() != 5 || s.Offset() != -time.Minute {
+		t.Errorf("got offset %v from %d samples", s.Offset(), s.Samples())
+	}
+
+	// Even number of samples.
+	s.AddSample("5", time.Hour)
+	if s.Offset() != 0 {
+		t.Errorf("got offset %v, want 0", s.Offset())
+	}
+
+	// Samples which are too far off are ignored.
+	s.AddSample("6", wire.MaxClockSkew+time.Second)
+	if s.Samples() != 6 {
+		t.Errorf("got %d samples", s.Samples())
+	}
+
+	adjusted := s.AdjustedTime()
+	if d := adjusted.Sub(time.Now()); d > time.Second || d < -time.Second {
+		t.Errorf("adjusted time is off by %v", d)
+	}
+
+	// Old samples are forgotten.
+	for i := 0; i < 300; i++ {
+		s.AddSample(fmt.Sprint("peer", i), 2*time.Minute)
+	}
+	if s.Samples() != 200 || s.Offset() != 2*time.Minute {
+		t.Errorf("got offset %v from %d samples", s.Offset(), s.Samples())
+	}
+}